@@ -0,0 +1,194 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Code is a stable, machine readable error code. Clients switch on it, so a code must never be renamed once released
+type Code string
+
+const (
+	CodeInvalidRequest           Code = "invalid_request"
+	CodeValidationFailed         Code = "validation_failed"
+	CodeUnauthorized             Code = "unauthorized"
+	CodeInvalidCredentials       Code = "invalid_credentials"
+	CodeSessionNotFound          Code = "session_not_found"
+	CodeInvalidCSRFToken         Code = "invalid_csrf_token"
+	CodeUserNotFound             Code = "user_not_found"
+	CodeHabitNotFound            Code = "habit_not_found"
+	CodeCompletionNotFound       Code = "completion_not_found"
+	CodeMethodNotAllowed         Code = "method_not_allowed"
+	CodeEmailTaken               Code = "email_taken"
+	CodeCompletionExists         Code = "completion_exists"
+	CodeRevisionMismatch         Code = "revision_mismatch"
+	CodeInvalidResetToken        Code = "invalid_reset_token"
+	CodeInvalidVerificationToken Code = "invalid_verification_token"
+	CodeEmailNotVerified         Code = "email_not_verified"
+	CodeInvalidMFAToken          Code = "invalid_mfa_token"
+	CodeInvalidMFACode           Code = "invalid_mfa_code"
+	CodeMFAAlreadyEnabled        Code = "mfa_already_enabled"
+	CodeMFANotEnabled            Code = "mfa_not_enabled"
+	CodeTooManyLoginAttempts     Code = "too_many_login_attempts"
+	CodeAccessTokenNotFound      Code = "access_token_not_found"
+	CodeInsufficientScope        Code = "insufficient_scope"
+	CodeOIDCProviderNotFound     Code = "oidc_provider_not_found"
+	CodeInvalidOIDCState         Code = "invalid_oidc_state"
+	CodeOIDCLoginFailed          Code = "oidc_login_failed"
+	CodeOIDCProviderUnavailable  Code = "oidc_provider_unavailable"
+	CodeInsufficientRole         Code = "insufficient_role"
+	CodeAccountDisabled          Code = "account_disabled"
+	CodeCannotDisableSelf        Code = "cannot_disable_self"
+	CodeInternal                 Code = "internal_error"
+)
+
+// ContentType is the media type of an RFC 7807 problem details response
+const ContentType = "application/problem+json"
+
+var (
+	ErrInvalidRequest   = New(http.StatusBadRequest, CodeInvalidRequest, "The request is malformed")
+	ErrUnauthorized     = New(http.StatusUnauthorized, CodeUnauthorized, "A valid access token is required")
+	ErrMethodNotAllowed = New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "The method isn't supported by this endpoint")
+	ErrInternal         = New(http.StatusInternalServerError, CodeInternal, "An unexpected error occurred")
+)
+
+// FieldError is a problem with one field of the request. Message is shown to the client
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (f FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", f.Field, f.Message)
+}
+
+/*
+Error is an application error carried from the validation, model and db layers up to the controllers, which render it with WriteProblem.
+Packages declare their errors as sentinels with New and return them wrapped with %w, so errors.Is and errors.As both work.
+Status, Code, Detail, Fields and RetryAfter are sent to the client and must not contain anything internal.
+*/
+type Error struct {
+	Status     int
+	Code       Code
+	Detail     string
+	Fields     []FieldError
+	RetryAfter time.Duration // Sent as the Retry-After header when set
+	err        error
+}
+
+func New(status int, code Code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func (e *Error) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Detail
+	}
+
+	fields := make([]string, len(e.Fields))
+
+	for i, field := range e.Fields {
+		fields[i] = field.Error()
+	}
+
+	return fmt.Sprintf("%s - %s", e.Detail, strings.Join(fields, ", "))
+}
+
+// Unwrap returns the sentinel the error was made from by WithFields, so errors.Is matches it
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// WithFields returns a copy of the error with the field errors added. The copy still matches e with errors.Is
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	return &Error{
+		Status:     e.Status,
+		Code:       e.Code,
+		Detail:     e.Detail,
+		Fields:     append(slices.Clone(e.Fields), fields...),
+		RetryAfter: e.RetryAfter,
+		err:        e,
+	}
+}
+
+// WithRetryAfter returns a copy of the error that tells the client to wait retryAfter before trying again. The copy still matches e with errors.Is
+func (e *Error) WithRetryAfter(retryAfter time.Duration) *Error {
+	return &Error{
+		Status:     e.Status,
+		Code:       e.Code,
+		Detail:     e.Detail,
+		Fields:     slices.Clone(e.Fields),
+		RetryAfter: retryAfter,
+		err:        e,
+	}
+}
+
+// FieldErrors collects the FieldErrors from errs, skipping nils
+func FieldErrors(errs ...error) []FieldError {
+	fields := []FieldError{}
+
+	for _, err := range errs {
+		var field FieldError
+
+		if errors.As(err, &field) {
+			fields = append(fields, field)
+		} else if err != nil {
+			fields = append(fields, FieldError{Message: err.Error()})
+		}
+	}
+
+	return fields
+}
+
+// Problem is an RFC 7807 problem details response with the error's code and field errors as extension members
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     Code         `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem builds the problem details for err. Anything that isn't an *Error is reported as ErrInternal so its message is never leaked
+func NewProblem(err error, instance string) Problem {
+	appErr := ErrInternal
+
+	errors.As(err, &appErr)
+
+	return Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(appErr.Status),
+		Status:   appErr.Status,
+		Detail:   appErr.Detail,
+		Instance: instance,
+		Code:     appErr.Code,
+		Errors:   appErr.Fields,
+	}
+}
+
+// WriteProblem writes err to w as application/problem+json with the error's status code, and its Retry-After header if it has one
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(err, r.URL.Path)
+
+	var appErr *Error
+
+	if errors.As(err, &appErr) && appErr.RetryAfter > 0 {
+		// Retry-After is in whole seconds, rounded up so the client doesn't come back a moment too soon
+		w.Header().Set("Retry-After", strconv.FormatInt(int64((appErr.RetryAfter+time.Second-1)/time.Second), 10))
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+
+	// The status line has gone so there's nothing more to do if the body can't be written
+	_ = json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,113 @@
+package apperror
+
+import (
+	"dohabits/helper"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWithFields(t *testing.T) {
+	sentinel := New(http.StatusUnprocessableEntity, CodeValidationFailed, "Habit is invalid")
+	field := FieldError{Field: "name", Message: "No Habit Name Supplied"}
+
+	err := fmt.Errorf("wrapped - %w", sentinel.WithFields(field))
+
+	if !errors.Is(err, sentinel) {
+		t.Errorf("%s - Failed - errors.Is didn't match the sentinel, err=%v", helper.GetFunctionName(), err)
+	}
+
+	var appErr *Error
+
+	if !errors.As(err, &appErr) || !reflect.DeepEqual(appErr.Fields, []FieldError{field}) {
+		t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), appErr)
+	}
+
+	if len(sentinel.Fields) != 0 {
+		t.Errorf("%s - Failed - the sentinel was changed, got=%+v", helper.GetFunctionName(), sentinel.Fields)
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	got := FieldErrors(nil, FieldError{Field: "name", Message: "is required"}, fmt.Errorf("wrapped - %w", FieldError{Field: "daysTarget", Message: "is too big"}), nil)
+	want := []FieldError{{Field: "name", Message: "is required"}, {Field: "daysTarget", Message: "is too big"}}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s - Failed - got=%+v, want=%+v", helper.GetFunctionName(), got, want)
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	notFound := New(http.StatusNotFound, CodeHabitNotFound, "Habit doesn't exist")
+
+	tooManyAttempts := New(http.StatusTooManyRequests, CodeTooManyLoginAttempts, "Too many failed login attempts")
+
+	testCases := []struct {
+		name           string
+		err            error
+		want           Problem
+		wantRetryAfter string
+	}{
+		{
+			name: "Test application error",
+			err:  fmt.Errorf("db.RetrieveHabitsHandler - %w", notFound),
+			want: Problem{Type: "about:blank", Title: "Not Found", Status: http.StatusNotFound, Detail: "Habit doesn't exist", Instance: "/dohabitsapp/v1/habits/1", Code: CodeHabitNotFound},
+		},
+		{
+			name: "Test field errors",
+			err:  ErrInvalidRequest.WithFields(FieldError{Field: "habitId", Message: "habitId is required"}),
+			want: Problem{
+				Type: "about:blank", Title: "Bad Request", Status: http.StatusBadRequest, Detail: "The request is malformed", Instance: "/dohabitsapp/v1/habits/1", Code: CodeInvalidRequest,
+				Errors: []FieldError{{Field: "habitId", Message: "habitId is required"}},
+			},
+		},
+		{
+			name:           "Test Retry-After is rounded up to whole seconds",
+			err:            fmt.Errorf("loginattempts.Check - %w", tooManyAttempts.WithRetryAfter(1500*time.Millisecond)),
+			want:           Problem{Type: "about:blank", Title: "Too Many Requests", Status: http.StatusTooManyRequests, Detail: "Too many failed login attempts", Instance: "/dohabitsapp/v1/habits/1", Code: CodeTooManyLoginAttempts},
+			wantRetryAfter: "2",
+		},
+		{
+			name: "Test other errors are internal and not leaked",
+			err:  errors.New("connection refused to 10.0.0.1"),
+			want: Problem{Type: "about:blank", Title: "Internal Server Error", Status: http.StatusInternalServerError, Detail: "An unexpected error occurred", Instance: "/dohabitsapp/v1/habits/1", Code: CodeInternal},
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/dohabitsapp/v1/habits/1", nil)
+			w := httptest.NewRecorder()
+
+			WriteProblem(w, req, val.err)
+
+			if w.Code != val.want.Status {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), w.Code, val.want.Status)
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != ContentType {
+				t.Errorf("%s - Failed - Content-Type = %s, want=%s", helper.GetFunctionName(), contentType, ContentType)
+			}
+
+			if retryAfter := w.Header().Get("Retry-After"); retryAfter != val.wantRetryAfter {
+				t.Errorf("%s - Failed - Retry-After = %s, want=%s", helper.GetFunctionName(), retryAfter, val.wantRetryAfter)
+			}
+
+			got := Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			if !reflect.DeepEqual(got, val.want) {
+				t.Errorf("%s - Failed - got=%+v, want=%+v", helper.GetFunctionName(), got, val.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/view"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+var errUserIdRequired = apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "userId", Message: "userId is required"})
+
+type AdminController struct {
+	adminModel model.IAdminModel
+	adminView  view.IAdminView
+	logger     logger.ILogger
+}
+
+type IAdminController interface {
+	SearchUsersHandler(w http.ResponseWriter, r *http.Request)
+	RetrieveUserHandler(w http.ResponseWriter, r *http.Request)
+	RetrieveUserHabitsHandler(w http.ResponseWriter, r *http.Request)
+	LogoutUserHandler(w http.ResponseWriter, r *http.Request)
+	DisableUserHandler(w http.ResponseWriter, r *http.Request)
+	EnableUserHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewAdminController(adminModel model.IAdminModel, adminView view.IAdminView, logger logger.ILogger) *AdminController {
+	return &AdminController{
+		adminModel: adminModel,
+		adminView:  adminView,
+		logger:     logger,
+	}
+}
+
+// SearchUsersHandler handles GET .../admin/users?q=&limit=&offset=
+func (c *AdminController) SearchUsersHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	query := r.URL.Query()
+	adminUsersRequest := data.AdminUsersRequest{Query: query.Get("q")}
+	fields := []apperror.FieldError{}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+
+		if err != nil {
+			fields = append(fields, apperror.FieldError{Field: "limit", Message: "limit must be a number"})
+		}
+
+		adminUsersRequest.Limit = limit
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+
+		if err != nil {
+			fields = append(fields, apperror.FieldError{Field: "offset", Message: "offset must be a number"})
+		}
+
+		adminUsersRequest.Offset = offset
+	}
+
+	if len(fields) > 0 {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("limit=%s, offset=%s", query.Get("limit"), query.Get("offset")))
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(fields...))
+		return
+	}
+
+	users, err := c.adminModel.SearchUsersHandler(r.Context(), &adminUsersRequest)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.adminView.UsersHandler(users, adminUsersRequest.Limit, adminUsersRequest.Offset)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// RetrieveUserHandler handles GET .../admin/users/{userId}
+func (c *AdminController) RetrieveUserHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userId, ok := c.userId(w, r)
+
+	if !ok {
+		return
+	}
+
+	user, err := c.adminModel.RetrieveUserHandler(r.Context(), userId)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeUser(w, r, user)
+}
+
+// RetrieveUserHabitsHandler handles GET .../admin/users/{userId}/habits
+func (c *AdminController) RetrieveUserHabitsHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userId, ok := c.userId(w, r)
+
+	if !ok {
+		return
+	}
+
+	user, habits, err := c.adminModel.RetrieveUserHabitsHandler(r.Context(), userId)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.adminView.UserHabitsHandler(user, habits)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// LogoutUserHandler handles POST .../admin/users/{userId}/logout
+func (c *AdminController) LogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	admin, ok := c.admin(w, r)
+
+	if !ok {
+		return
+	}
+
+	userId, ok := c.userId(w, r)
+
+	if !ok {
+		return
+	}
+
+	revoked, revokedTokens, err := c.adminModel.LogoutUserHandler(r.Context(), admin.Subject, admin.Roles, userId)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.adminView.LogoutUserHandler(revoked, revokedTokens)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// DisableUserHandler handles POST .../admin/users/{userId}/disable
+func (c *AdminController) DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	admin, ok := c.admin(w, r)
+
+	if !ok {
+		return
+	}
+
+	userId, ok := c.userId(w, r)
+
+	if !ok {
+		return
+	}
+
+	user, err := c.adminModel.DisableUserHandler(r.Context(), admin.Subject, userId)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeUser(w, r, user)
+}
+
+// EnableUserHandler handles POST .../admin/users/{userId}/enable
+func (c *AdminController) EnableUserHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	admin, ok := c.admin(w, r)
+
+	if !ok {
+		return
+	}
+
+	userId, ok := c.userId(w, r)
+
+	if !ok {
+		return
+	}
+
+	user, err := c.adminModel.EnableUserHandler(r.Context(), admin.Subject, userId)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeUser(w, r, user)
+}
+
+// admin returns the claims of the admin the access token was issued to, writing the error response if there aren't any
+func (c *AdminController) admin(w http.ResponseWriter, r *http.Request) (*session.Claims, bool) {
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok || claims.Subject == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// userId returns the user the request is about, writing the error response if there isn't one
+func (c *AdminController) userId(w http.ResponseWriter, r *http.Request) (string, bool) {
+	userId := r.PathValue("userId")
+
+	if userId == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "userId is empty")
+		apperror.WriteProblem(w, r, errUserIdRequired)
+		return "", false
+	}
+
+	return userId, true
+}
+
+func (c *AdminController) writeUser(w http.ResponseWriter, r *http.Request, user *data.UserData) {
+	response, err := c.adminView.UserHandler(user)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+func (c *AdminController) writeResponse(w http.ResponseWriter, response []byte) {
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
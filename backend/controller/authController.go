@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"dohabits/apperror"
 	"dohabits/data"
 	"dohabits/helper"
 	"dohabits/logger"
@@ -8,35 +9,50 @@ import (
 	"dohabits/model"
 	"dohabits/view"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"sync"
 )
 
+var errSessionIdRequired = apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "SessionID", Message: "SessionID is required"})
+
 type AuthController struct {
-	authModel  model.IAuthModel
-	authView   view.IAuthView
-	jwtTokens  session.IJSONWebToken
-	csrfTokens session.ICSRFToken
-	logger     logger.ILogger
-	mx         sync.RWMutex
+	authModel      model.IAuthModel
+	authView       view.IAuthView
+	jwtTokens      session.IJSONWebToken
+	csrfTokens     session.ICSRFToken
+	logger         logger.ILogger
+	trustedProxies []netip.Prefix // Proxies whose X-Forwarded-For header gives the client IP recorded with a session
+	mx             sync.RWMutex
 }
 
 type IAuthController interface {
 	RegisterUserHandler(w http.ResponseWriter, r *http.Request)
 	LoginHandler(w http.ResponseWriter, r *http.Request)
+	LoginMFAHandler(w http.ResponseWriter, r *http.Request)
+	StartOIDCLoginHandler(w http.ResponseWriter, r *http.Request)
+	LoginOIDCHandler(w http.ResponseWriter, r *http.Request)
 	LogoutHandler(w http.ResponseWriter, r *http.Request)
 	RefreshHandler(w http.ResponseWriter, r *http.Request)
+	UpdateProfileHandler(w http.ResponseWriter, r *http.Request)
+	DeleteAccountHandler(w http.ResponseWriter, r *http.Request)
+	RetrieveSessionsHandler(w http.ResponseWriter, r *http.Request)
+	DeleteSessionHandler(w http.ResponseWriter, r *http.Request)
+	DeleteSessionsHandler(w http.ResponseWriter, r *http.Request)
 }
 
-func NewAuthController(authModel model.IAuthModel, authView view.IAuthView, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken, logger logger.ILogger) *AuthController {
+func NewAuthController(authModel model.IAuthModel, authView view.IAuthView, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken, logger logger.ILogger, trustedProxies []netip.Prefix) *AuthController {
 	return &AuthController{
-		authModel:  authModel,
-		authView:   authView,
-		jwtTokens:  jwtTokens,
-		csrfTokens: csrfTokens,
-		logger:     logger,
-		mx:         sync.RWMutex{},
+		authModel:      authModel,
+		authView:       authView,
+		jwtTokens:      jwtTokens,
+		csrfTokens:     csrfTokens,
+		logger:         logger,
+		trustedProxies: trustedProxies,
+		mx:             sync.RWMutex{},
 	}
 }
 
@@ -47,15 +63,15 @@ func (ac *AuthController) RegisterUserHandler(w http.ResponseWriter, r *http.Req
 	userRegisterRequest := data.RegisterUserRequest{}
 
 	if err := json.NewDecoder(r.Body).Decode(&userRegisterRequest); err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
 		return
 	}
 
-	registeredUserData, err := ac.authModel.RegisterUserHandler(&userRegisterRequest)
+	registeredUserData, err := ac.authModel.RegisterUserHandler(r.Context(), &userRegisterRequest)
 
 	if err != nil {
 		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -63,7 +79,7 @@ func (ac *AuthController) RegisterUserHandler(w http.ResponseWriter, r *http.Req
 
 	if err != nil {
 		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -77,27 +93,158 @@ func (ac *AuthController) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	userAuth := data.UserAuth{}
 
 	if err := json.NewDecoder(r.Body).Decode(&userAuth); err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
 		return
 	}
 
 	if userAuth.EmailAddress == "" {
 		ac.logger.DebugLog(helper.GetFunctionName(), "Email Address is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "EmailAddress", Message: "Email address is required"}))
 		return
 	}
 
 	if userAuth.Password == "" {
 		ac.logger.DebugLog(helper.GetFunctionName(), "Password is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Password", Message: "Password is required"}))
+		return
+	}
+
+	client := data.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: helper.ClientIP(r, ac.trustedProxies),
+	}
+
+	userLoggedIn, err := ac.authModel.LoginHandler(r.Context(), w, &userAuth, client, ac.jwtTokens, ac.csrfTokens)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	ac.writeLoginResponse(w, r, userLoggedIn)
+}
+
+// LoginMFAHandler exchanges the MFA pending token from LoginHandler and a code, or a recovery code, for the session
+func (ac *AuthController) LoginMFAHandler(w http.ResponseWriter, r *http.Request) {
+	ac.mx.Lock()
+	defer ac.mx.Unlock()
+
+	mfaLoginRequest := data.MFALoginRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&mfaLoginRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if mfaLoginRequest.MFAToken == "" {
+		ac.logger.DebugLog(helper.GetFunctionName(), "MFAToken is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "MFAToken", Message: "MFAToken is required"}))
+		return
+	}
+
+	client := data.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: helper.ClientIP(r, ac.trustedProxies),
+	}
+
+	userLoggedIn, err := ac.authModel.LoginMFAHandler(r.Context(), w, &mfaLoginRequest, client, ac.jwtTokens, ac.csrfTokens)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	ac.writeLoginResponse(w, r, userLoggedIn)
+}
+
+// StartOIDCLoginHandler handles GET .../oidc/{provider}/login, which sends the user to the identity provider to sign in
+func (ac *AuthController) StartOIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	oidcLoginStart, err := ac.authModel.StartOIDCLoginHandler(r.Context(), r.PathValue("provider"))
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	userLoggedIn, err := ac.authModel.LoginHandler(w, &userAuth, ac.jwtTokens, ac.csrfTokens)
+	session.SetOIDCStateCookie(w, oidcLoginStart.StateToken, oidcLoginStart.ExpiresAt)
+	http.Redirect(w, r, oidcLoginStart.AuthorizationURL, http.StatusFound)
+}
+
+/*
+LoginOIDCHandler handles POST .../oidc/{provider}/callback, which the frontend sends the code and state the identity provider returned to.
+It doesn't take ac.mx, as exchanging the code calls the provider and one that's slow to answer would hold up every other login.
+*/
+func (ac *AuthController) LoginOIDCHandler(w http.ResponseWriter, r *http.Request) {
+	stateToken, err := session.OIDCStateFromCookie(r)
 
 	if err != nil {
 		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	// The code can only be exchanged once, so the state is no use after this whether or not the sign in succeeds
+	session.ClearOIDCStateCookie(w)
+
+	oidcLoginRequest := data.OIDCLoginRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&oidcLoginRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if oidcLoginRequest.Code == "" {
+		ac.logger.DebugLog(helper.GetFunctionName(), "Code is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Code", Message: "Code is required"}))
+		return
+	}
+
+	if oidcLoginRequest.State == "" {
+		ac.logger.DebugLog(helper.GetFunctionName(), "State is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "State", Message: "State is required"}))
+		return
+	}
+
+	oidcLoginRequest.Provider = r.PathValue("provider")
+	oidcLoginRequest.StateToken = stateToken
+
+	client := data.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: helper.ClientIP(r, ac.trustedProxies),
+	}
+
+	userLoggedIn, err := ac.authModel.LoginOIDCHandler(r.Context(), w, &oidcLoginRequest, client, ac.jwtTokens, ac.csrfTokens)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	ac.writeLoginResponse(w, r, userLoggedIn)
+}
+
+// writeLoginResponse sends the tokens of a new session along with the user's details, or the MFA pending token if they have to enter a code first
+func (ac *AuthController) writeLoginResponse(w http.ResponseWriter, r *http.Request, userLoggedIn *data.UserLoggedInData) {
+	// The user has proved who they are but has to enter a code before they get a session
+	if userLoggedIn.MFAToken != "" {
+		response, err := ac.authView.MFARequiredHandler(userLoggedIn)
+
+		if err != nil {
+			ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+			apperror.WriteProblem(w, r, err)
+			return
+		}
+
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+		numOfBytes, err := w.Write(response)
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+		if err != nil {
+			ac.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+		}
 		return
 	}
 
@@ -105,11 +252,12 @@ func (ac *AuthController) LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
 	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", userLoggedIn.AccessToken))
+	session.SetRefreshTokenCookie(w, userLoggedIn.RefreshToken)
 
 	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
 	numOfBytes, err := w.Write([]byte(response))
@@ -123,62 +271,79 @@ func (ac *AuthController) LogoutHandler(w http.ResponseWriter, r *http.Request)
 	ac.mx.Lock()
 	defer ac.mx.Unlock()
 
-	// frontend will delete this short-lived JWT accessToken from session, delete user's refreshToken to prevent new short-lived JWT accessToken. It will invalidate itself after 5 mins.
+	// frontend will delete this short-lived JWT accessToken from session, delete the session's refreshToken to prevent new short-lived JWT accessToken. It will invalidate itself after 5 mins.
 	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
 
 	if !ok {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	username := claims.Username
+	userID := claims.Subject
 
-	if username == "" {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if userID == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	refreshToken, err := session.RefreshTokenFromCookie(r)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	userLoggedOutRequest := data.UserLoggedOutRequest{EmailAddress: username}
+	userLoggedOutRequest := data.UserLoggedOutRequest{UserID: userID, SessionID: claims.SessionID, RefreshToken: refreshToken}
 
-	if err := ac.authModel.LogoutHandler(w, &userLoggedOutRequest, ac.jwtTokens, ac.csrfTokens); err != nil {
+	if err := ac.authModel.LogoutHandler(r.Context(), w, &userLoggedOutRequest, ac.jwtTokens, ac.csrfTokens); err != nil {
 		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
+
+	session.ClearRefreshTokenCookie(w)
 }
 
-// Called by frontend when the Short-Lived JWT expires and receives a 401 from the protected habits endpoints. Refreshes the authToken, refreshToken
+// Called by frontend when the Short-Lived JWT expires and receives a 401 from the protected habits endpoints. Exchanges the refreshToken cookie for a new authToken and refreshToken cookie
 func (ac *AuthController) RefreshHandler(w http.ResponseWriter, r *http.Request) {
-	userRefreshRequest := data.UserRefreshRequest{}
+	refreshToken, err := session.RefreshTokenFromCookie(r)
 
-	if err := json.NewDecoder(r.Body).Decode(&userRefreshRequest); err != nil {
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	newAccessToken, csrfToken, err := ac.authModel.RefreshHandler(w, &userRefreshRequest, ac.jwtTokens, ac.csrfTokens)
+	userRefreshRequest := data.UserRefreshRequest{RefreshToken: refreshToken}
+
+	userRefreshData, err := ac.authModel.RefreshHandler(r.Context(), w, &userRefreshRequest, ac.jwtTokens, ac.csrfTokens)
 
 	if err != nil {
-		if err = ac.authModel.LogoutHandler(w, &data.UserLoggedOutRequest{EmailAddress: userRefreshRequest.EmailAddress}, ac.jwtTokens, ac.csrfTokens); err != nil {
-			ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+
+		// The cookie's refresh token can't be used again, so the client may as well drop it
+		var appErr *apperror.Error
+
+		if errors.As(err, &appErr) && appErr.Status == http.StatusUnauthorized {
+			session.ClearRefreshTokenCookie(w)
 		}
 
-		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	response, err := ac.authView.RefreshHandler(&userRefreshRequest)
+	response, err := ac.authView.RefreshHandler(userRefreshData)
 
 	if err != nil {
 		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	w.Header().Set("X-CSRF-Token", csrfToken)
-	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", newAccessToken))
+	w.Header().Set("X-CSRF-Token", userRefreshData.CSRFToken)
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", userRefreshData.AccessToken))
+	session.SetRefreshTokenCookie(w, userRefreshData.RefreshToken)
 	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
 	numOfBytes, err := w.Write([]byte(response))
 	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
@@ -186,3 +351,261 @@ func (ac *AuthController) RefreshHandler(w http.ResponseWriter, r *http.Request)
 		ac.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
 	}
 }
+
+func (ac *AuthController) UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	ac.mx.Lock()
+	defer ac.mx.Unlock()
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	// The user is found by their ID, as their email address may have passed to someone else since the access token was issued
+	userID := claims.Subject
+
+	if userID == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	updateProfileRequest := data.UpdateProfileRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&updateProfileRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	userData, err := ac.authModel.UpdateProfileHandler(r.Context(), userID, &updateProfileRequest)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := ac.authView.UpdateProfileHandler(userData)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		ac.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
+
+// DeleteAccountHandler handles DELETE .../me, which deletes the user and all their data. The refresh token cookie is deleted with it.
+func (ac *AuthController) DeleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	ac.mx.Lock()
+	defer ac.mx.Unlock()
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	// The user is found by their ID, as their email address may have passed to someone else since the access token was issued
+	userID := claims.Subject
+
+	if userID == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	deleteAccountRequest := data.DeleteAccountRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&deleteAccountRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if deleteAccountRequest.Password == "" {
+		ac.logger.DebugLog(helper.GetFunctionName(), "Password is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Password", Message: "Password is required"}))
+		return
+	}
+
+	if err := ac.authModel.DeleteAccountHandler(r.Context(), userID, &deleteAccountRequest); err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := ac.authView.DeleteAccountHandler()
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	session.ClearRefreshTokenCookie(w)
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		ac.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
+
+// RetrieveSessionsHandler handles GET .../sessions
+func (ac *AuthController) RetrieveSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ac.mx.RLock()
+	defer ac.mx.RUnlock()
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	userID := claims.Subject
+
+	if userID == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	userSessions, err := ac.authModel.RetrieveSessionsHandler(r.Context(), userID)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := ac.authView.SessionsHandler(userSessions, claims.SessionID)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		ac.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
+
+// DeleteSessionHandler handles DELETE .../sessions/{sessionId}
+func (ac *AuthController) DeleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ac.mx.Lock()
+	defer ac.mx.Unlock()
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	userID := claims.Subject
+
+	if userID == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	sessionId := r.PathValue("sessionId")
+
+	if sessionId == "" {
+		ac.logger.DebugLog(helper.GetFunctionName(), "sessionId is empty")
+		apperror.WriteProblem(w, r, errSessionIdRequired)
+		return
+	}
+
+	if err := ac.authModel.DeleteSessionHandler(r.Context(), userID, sessionId); err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := ac.authView.DeleteSessionsHandler(1)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		ac.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
+
+// DeleteSessionsHandler handles DELETE .../sessions, which revokes every session or, with ?keepCurrent=true, every other session
+func (ac *AuthController) DeleteSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ac.mx.Lock()
+	defer ac.mx.Unlock()
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	userID := claims.Subject
+
+	if userID == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	exceptSessionID := ""
+
+	if value := r.URL.Query().Get("keepCurrent"); value != "" {
+		keepCurrent, err := strconv.ParseBool(value)
+
+		if err != nil {
+			ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("keepCurrent=%s isn't a boolean", value))
+			apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "keepCurrent", Message: "keepCurrent must be true or false"}))
+			return
+		}
+
+		if keepCurrent {
+			exceptSessionID = claims.SessionID
+		}
+	}
+
+	revoked, err := ac.authModel.DeleteSessionsHandler(r.Context(), userID, exceptSessionID)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := ac.authView.DeleteSessionsHandler(revoked)
+
+	if err != nil {
+		ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	ac.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		ac.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
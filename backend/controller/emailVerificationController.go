@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/view"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type EmailVerificationController struct {
+	emailVerificationModel model.IEmailVerificationModel
+	emailVerificationView  view.IEmailVerificationView
+	logger                 logger.ILogger
+}
+
+type IEmailVerificationController interface {
+	VerifyEmailHandler(w http.ResponseWriter, r *http.Request)
+	ResendVerificationEmailHandler(w http.ResponseWriter, r *http.Request)
+	ChangeEmailHandler(w http.ResponseWriter, r *http.Request)
+	ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewEmailVerificationController(emailVerificationModel model.IEmailVerificationModel, emailVerificationView view.IEmailVerificationView, logger logger.ILogger) *EmailVerificationController {
+	return &EmailVerificationController{
+		emailVerificationModel: emailVerificationModel,
+		emailVerificationView:  emailVerificationView,
+		logger:                 logger,
+	}
+}
+
+// VerifyEmailHandler marks the user's email address as verified with the token from a verification link
+func (c *EmailVerificationController) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	verifyEmailRequest := data.VerifyEmailRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&verifyEmailRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if verifyEmailRequest.Token == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "Token is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Token", Message: "Token is required"}))
+		return
+	}
+
+	if err := c.emailVerificationModel.VerifyEmailHandler(r.Context(), &verifyEmailRequest); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.emailVerificationView.VerifyEmailHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// ResendVerificationEmailHandler emails another verification link to the address, if it belongs to an unverified user. The response is the same either way.
+func (c *EmailVerificationController) ResendVerificationEmailHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	resendVerificationEmailRequest := data.ResendVerificationEmailRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&resendVerificationEmailRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if err := c.emailVerificationModel.ResendVerificationEmailHandler(r.Context(), &resendVerificationEmailRequest); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.emailVerificationView.ResendVerificationEmailHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// ChangeEmailHandler emails a link to the new address. The address is only changed once the link is followed.
+func (c *EmailVerificationController) ChangeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	// The user is found by their ID, as their email address may have passed to someone else since the access token was issued
+	if !ok || claims.Subject == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	changeEmailRequest := data.ChangeEmailRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&changeEmailRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if err := c.emailVerificationModel.ChangeEmailHandler(r.Context(), claims.Subject, &changeEmailRequest); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.emailVerificationView.ChangeEmailHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// ConfirmEmailChangeHandler changes the user's email address with the token from an email change link
+func (c *EmailVerificationController) ConfirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	verifyEmailRequest := data.VerifyEmailRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&verifyEmailRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if verifyEmailRequest.Token == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "Token is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Token", Message: "Token is required"}))
+		return
+	}
+
+	if err := c.emailVerificationModel.ConfirmEmailChangeHandler(r.Context(), &verifyEmailRequest); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.emailVerificationView.ConfirmEmailChangeHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+func (c *EmailVerificationController) writeResponse(w http.ResponseWriter, response []byte) {
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
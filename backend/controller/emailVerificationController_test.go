@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/mail"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/validation"
+	"dohabits/view"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestAuthModel returns an AuthModel that lets unverified users do everything, as users could before email verification
+func newTestAuthModel(logger logger.ILogger, db db.IDB) *model.AuthModel {
+	emailVerificationTokens := session.NewEmailVerificationToken(session.NewHMACKeySet("secretJwt"), logger)
+	emailVerificationModel := model.NewEmailVerificationModel(logger, db, mail.NewMockMailer(logger), emailVerificationTokens, "http://localhost")
+
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := model.NewMFAModel(logger, db, session.NewMFAToken(session.NewHMACKeySet("secretJwt"), logger), loginAttempts)
+
+	return model.NewAuthModel(logger, db, emailVerificationModel, mfaModel, nil, loginAttempts, validation.DefaultPasswordPolicy(), data.UnverifiedUserAccessAll)
+}
+
+func TestEmailVerificationHandlers(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	emailVerificationTokens := session.NewEmailVerificationToken(session.NewHMACKeySet("secretJwt"), logger)
+	emailVerificationModel := model.NewEmailVerificationModel(logger, mockDB, mail.NewMockMailer(logger), emailVerificationTokens, "http://localhost")
+	emailVerificationController := NewEmailVerificationController(emailVerificationModel, view.NewEmailVerificationView(logger), logger)
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	defer func() { data.MockUsers = originalMockUsersState }()
+
+	token, err := emailVerificationTokens.GenerateEmailVerificationToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		body       string
+		wantStatus int
+		wantCode   apperror.Code
+	}{
+		{
+			name:       "Test verify email with an invalid body",
+			handler:    emailVerificationController.VerifyEmailHandler,
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test verify email without a token",
+			handler:    emailVerificationController.VerifyEmailHandler,
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test verify email with an invalid token",
+			handler:    emailVerificationController.VerifyEmailHandler,
+			body:       `{"Token":"not-a-token"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidVerificationToken,
+		},
+		{
+			name:       "Test verify email",
+			handler:    emailVerificationController.VerifyEmailHandler,
+			body:       fmt.Sprintf(`{"Token":%q}`, token),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test resend with an invalid email address",
+			handler:    emailVerificationController.ResendVerificationEmailHandler,
+			body:       `{"EmailAddress":"not-an-email"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   apperror.CodeValidationFailed,
+		},
+		{
+			name:       "Test resend to an unknown email address",
+			handler:    emailVerificationController.ResendVerificationEmailHandler,
+			body:       `{"EmailAddress":"nobody@example.com"}`,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(val.body))
+			w := httptest.NewRecorder()
+
+			val.handler(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), status, val.wantStatus, w.Body)
+				return
+			}
+
+			if val.wantStatus == http.StatusOK {
+				if got := w.Body.String(); got != `{"Success":true}` {
+					t.Errorf("%s - Failed - got=%s", helper.GetFunctionName(), got)
+				}
+				return
+			}
+
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			if problem.Code != val.wantCode {
+				t.Errorf("%s - Failed - code=%s, want=%s", helper.GetFunctionName(), problem.Code, val.wantCode)
+			}
+		})
+	}
+}
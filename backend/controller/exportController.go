@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"dohabits/apperror"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/view"
+	"fmt"
+	"net/http"
+)
+
+type ExportController struct {
+	exportModel model.IExportModel
+	exportView  view.IExportView
+	logger      logger.ILogger
+}
+
+type IExportController interface {
+	ExportHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewExportController(exportModel model.IExportModel, exportView view.IExportView, logger logger.ILogger) *ExportController {
+	return &ExportController{
+		exportModel: exportModel,
+		exportView:  exportView,
+		logger:      logger,
+	}
+}
+
+/*
+ExportHandler handles GET .../me/export, which downloads the user's personal data as a ZIP archive. The archive is streamed as it's
+written, so once it has started an error can't be sent as a problem response. It's logged instead, and the archive is cut short.
+*/
+func (c *ExportController) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok || claims.Subject == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	export, err := c.exportModel.ExportHandler(r.Context(), claims.Subject)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="dohabits-export-%s.zip"`, export.ExportedAt.Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if err := c.exportView.ExportHandler(w, export); err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing the export for userId=%s: %s", export.User.UserID, err))
+	}
+}
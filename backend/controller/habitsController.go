@@ -1,24 +1,33 @@
 package controller
 
 import (
+	"dohabits/apperror"
 	"dohabits/data"
+	"dohabits/db"
 	"dohabits/helper"
 	"dohabits/logger"
 	"dohabits/middleware/session"
 	"dohabits/model"
 	"dohabits/view"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
+)
+
+var (
+	errHabitIdRequired = apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "habitId", Message: "habitId is required"})
+	errHabitIdMismatch = apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "habitId", Message: "habitId in the body doesn't match the path"})
+	errInvalidIfMatch  = apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "If-Match", Message: "If-Match must be * or a single entity tag"})
 )
 
 type HabitsController struct {
-	opsChan     chan func()
 	habitsModel model.IHabitsModel
 	habitsView  view.IHabitsView
 	logger      logger.ILogger
-	mx          sync.RWMutex
 }
 
 type IHabitsController interface {
@@ -28,48 +37,44 @@ type IHabitsController interface {
 	UpdateHabitsHandler(w http.ResponseWriter, r *http.Request)
 	UpdateAllHabitsHandler(w http.ResponseWriter, r *http.Request)
 	DeleteHabitsHandler(w http.ResponseWriter, r *http.Request)
+	CreateCompletionHandler(w http.ResponseWriter, r *http.Request)
+	DeleteCompletionHandler(w http.ResponseWriter, r *http.Request)
 }
 
-// Initialise the processOperations Goroutine
 func NewHabitsController(habitsModel model.IHabitsModel, habitsView view.IHabitsView, logger logger.ILogger) *HabitsController {
 	logger.InfoLog(helper.GetFunctionName(), "")
 
 	habitsController := &HabitsController{
-		opsChan:     make(chan func(), 1),
 		habitsModel: habitsModel,
 		habitsView:  habitsView,
 		logger:      logger,
-		mx:          sync.RWMutex{},
 	}
 
 	return habitsController
 }
 
 func (c *HabitsController) CreateHabitsHandler(w http.ResponseWriter, r *http.Request) {
-	c.mx.Lock()
-	defer c.mx.Unlock()
-
 	c.logger.InfoLog(helper.GetFunctionName(), "")
 
 	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
 
 	if !ok {
 		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	username := claims.Username
+	userID := claims.Subject
 
-	if username == "" {
-		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims username is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
 	if r.Body == nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), "Body is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
 		return
 	}
 
@@ -77,15 +82,15 @@ func (c *HabitsController) CreateHabitsHandler(w http.ResponseWriter, r *http.Re
 
 	if err := json.NewDecoder(r.Body).Decode(&newHabit); err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error decoding JSON - err=%s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
 		return
 	}
 
-	newHabitResponse, err := c.habitsModel.CreateHabitsHandler(username, newHabit)
+	newHabitResponse, err := c.habitsModel.CreateHabitsHandler(r.Context(), userID, newHabit)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -93,10 +98,12 @@ func (c *HabitsController) CreateHabitsHandler(w http.ResponseWriter, r *http.Re
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", habitETag(newHabitResponse.Revision))
+
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", string(result)))
 	numOfBytes, err := w.Write(result)
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
@@ -106,40 +113,37 @@ func (c *HabitsController) CreateHabitsHandler(w http.ResponseWriter, r *http.Re
 }
 
 func (c *HabitsController) RetrieveHabitsHandler(w http.ResponseWriter, r *http.Request) {
-	c.mx.RLock()
-	defer c.mx.RUnlock()
-
 	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
 
 	if !ok {
 		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	username := claims.Username
+	userID := claims.Subject
 
-	if username == "" {
-		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims username is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	habitId := r.URL.Query().Get("habitId")
+	habitId := habitIdFromRequest(r)
 
 	if len(habitId) == 0 {
-		c.logger.ErrorLog(helper.GetFunctionName(), "habitId query param is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		c.logger.ErrorLog(helper.GetFunctionName(), "habitId is empty")
+		apperror.WriteProblem(w, r, errHabitIdRequired)
 		return
 	}
 
-	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("email=%s, habitId=%s", username, habitId))
+	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s", userID, habitId))
 
-	habit, err := c.habitsModel.RetrieveHabitsHandler(username, habitId)
+	habit, err := c.habitsModel.RetrieveHabitsHandler(r.Context(), userID, habitId)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -147,10 +151,12 @@ func (c *HabitsController) RetrieveHabitsHandler(w http.ResponseWriter, r *http.
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", habitETag(habit.Revision))
+
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", string(result)))
 	numOfBytes, err := w.Write(result)
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
@@ -160,32 +166,29 @@ func (c *HabitsController) RetrieveHabitsHandler(w http.ResponseWriter, r *http.
 }
 
 func (c *HabitsController) RetrieveAllHabitsHandler(w http.ResponseWriter, r *http.Request) {
-	c.mx.RLock()
-	defer c.mx.RUnlock()
-
 	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
 
 	if !ok {
 		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	username := claims.Username
+	userID := claims.Subject
 
-	if username == "" {
-		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims username is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("email=%s", username))
+	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
 
-	habits, err := c.habitsModel.RetrieveAllHabitsHandler(username)
+	habits, err := c.habitsModel.RetrieveAllHabitsHandler(r.Context(), userID)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -193,7 +196,7 @@ func (c *HabitsController) RetrieveAllHabitsHandler(w http.ResponseWriter, r *ht
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -206,22 +209,19 @@ func (c *HabitsController) RetrieveAllHabitsHandler(w http.ResponseWriter, r *ht
 }
 
 func (c *HabitsController) UpdateHabitsHandler(w http.ResponseWriter, r *http.Request) {
-	c.mx.Lock()
-	defer c.mx.Unlock()
-
 	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
 
 	if !ok {
 		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	username := claims.Username
+	userID := claims.Subject
 
-	if username == "" {
-		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims username is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
@@ -231,47 +231,47 @@ func (c *HabitsController) UpdateHabitsHandler(w http.ResponseWriter, r *http.Re
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error decoding newHabit JSON - err=%s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
 		return
 	}
 
+	// PATCH /habits/{habitId} names the habit in the path, the legacy /updatehabit route in the body
+	if pathHabitId := r.PathValue("habitId"); pathHabitId != "" {
+		if updatedHabit.HabitID != "" && updatedHabit.HabitID != pathHabitId {
+			c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("body habitId=%s doesn't match path habitId=%s", updatedHabit.HabitID, pathHabitId))
+			apperror.WriteProblem(w, r, errHabitIdMismatch)
+			return
+		}
+
+		updatedHabit.HabitID = pathHabitId
+	}
+
 	if updatedHabit.HabitID == "" {
 		c.logger.ErrorLog(helper.GetFunctionName(), "habitId is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, errHabitIdRequired)
 		return
 	}
 
-	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("email=%s, habitId=%s", username, updatedHabit.HabitID))
-
-	habit, err := c.habitsModel.RetrieveHabitsHandler(username, updatedHabit.HabitID)
+	revision, err := revisionFromIfMatch(r)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	if updatedHabit.Name != nil {
-		habit.Name = *updatedHabit.Name
-	}
-
-	if updatedHabit.Days != nil {
-		habit.Days = *updatedHabit.Days
+	// If-Match takes precedence over a revision in the body
+	if revision != nil {
+		updatedHabit.Revision = revision
 	}
 
-	if updatedHabit.DaysTarget != nil {
-		habit.DaysTarget = *updatedHabit.DaysTarget
-	}
-
-	if updatedHabit.CompletionDates != nil {
-		habit.CompletionDates = *updatedHabit.CompletionDates
-	}
+	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s", userID, updatedHabit.HabitID))
 
-	err = c.habitsModel.UpdateHabitsHandler(username, habit, updatedHabit.HabitID)
+	habit, err := c.habitsModel.UpdateHabitsHandler(r.Context(), userID, updatedHabit, updatedHabit.HabitID)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -279,10 +279,12 @@ func (c *HabitsController) UpdateHabitsHandler(w http.ResponseWriter, r *http.Re
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", habitETag(habit.Revision))
+
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", string(result)))
 	numOfBytes, err := w.Write(result)
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
@@ -292,22 +294,19 @@ func (c *HabitsController) UpdateHabitsHandler(w http.ResponseWriter, r *http.Re
 }
 
 func (c *HabitsController) UpdateAllHabitsHandler(w http.ResponseWriter, r *http.Request) {
-	c.mx.Lock()
-	defer c.mx.Unlock()
-
 	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
 
 	if !ok {
 		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	username := claims.Username
+	userID := claims.Subject
 
-	if username == "" {
-		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims username is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
@@ -317,53 +316,82 @@ func (c *HabitsController) UpdateAllHabitsHandler(w http.ResponseWriter, r *http
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error decoding JSON - err=%s", err))
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
 		return
 	}
 
-	userHabits, err := c.habitsModel.RetrieveAllHabitsHandler(username)
+	userHabits, err := c.habitsModel.UpdateAllHabitsHandler(r.Context(), userID, updatedHabit)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	for i, habit := range userHabits {
-		for _, updatedHabit := range updatedHabit {
-			if habit.HabitID == updatedHabit.HabitID {
-				if updatedHabit.Name != nil {
-					userHabits[i].Name = *updatedHabit.Name
-				}
+	result, err := c.habitsView.UpdateAllHabitsHandler(&userHabits)
 
-				if updatedHabit.Days != nil {
-					userHabits[i].Days = *updatedHabit.Days
-				}
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
+		apperror.WriteProblem(w, r, err)
+		return
+	}
 
-				if updatedHabit.DaysTarget != nil {
-					userHabits[i].DaysTarget = *updatedHabit.DaysTarget
-				}
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", string(result)))
+	numOfBytes, err := w.Write(result)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
 
-				if updatedHabit.CompletionDates != nil {
-					userHabits[i].CompletionDates = *updatedHabit.CompletionDates
-				}
-			}
-		}
+func (c *HabitsController) DeleteHabitsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
 	}
 
-	err = c.habitsModel.UpdateAllHabitsHandler(username, &userHabits)
+	userID := claims.Subject
+
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	habitId := habitIdFromRequest(r)
+
+	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s", userID, habitId))
+
+	if len(habitId) == 0 {
+		c.logger.ErrorLog(helper.GetFunctionName(), "habitId is empty")
+		apperror.WriteProblem(w, r, errHabitIdRequired)
+		return
+	}
+
+	revision, err := revisionFromIfMatch(r)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	result, err := c.habitsView.UpdateAllHabitsHandler(&userHabits)
+	err = c.habitsModel.DeleteHabitsHandler(r.Context(), userID, habitId, revision)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	result, err := c.habitsView.DeleteHabitsHandler()
+
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
@@ -375,52 +403,116 @@ func (c *HabitsController) UpdateAllHabitsHandler(w http.ResponseWriter, r *http
 	}
 }
 
-func (c *HabitsController) DeleteHabitsHandler(w http.ResponseWriter, r *http.Request) {
-	c.mx.Lock()
-	defer c.mx.Unlock()
-
+// CreateCompletionHandler handles POST .../habits/{habitId}/completions
+func (c *HabitsController) CreateCompletionHandler(w http.ResponseWriter, r *http.Request) {
 	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
 
 	if !ok {
 		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	username := claims.Username
+	userID := claims.Subject
 
-	if username == "" {
-		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims username is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
 		return
 	}
 
-	habitId := r.URL.Query().Get("habitId")
+	habitId := r.PathValue("habitId")
 
-	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("email=%s, habitId=%s", username, habitId))
+	if habitId == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("path=%s doesn't match /habits/{habitId}/completions", r.URL.Path))
+		apperror.WriteProblem(w, r, errHabitIdRequired)
+		return
+	}
 
-	if len(habitId) == 0 {
-		c.logger.ErrorLog(helper.GetFunctionName(), "habitId is empty")
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	newCompletion := data.NewCompletion{}
+
+	// An empty body checks the habit in for today
+	if err := json.NewDecoder(r.Body).Decode(&newCompletion); err != nil && !errors.Is(err, io.EOF) {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error decoding newCompletion JSON - err=%s", err))
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
 		return
 	}
 
-	err := c.habitsModel.DeleteHabitsHandler(username, habitId)
+	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userID, habitId, newCompletion.CompletionDate))
+
+	habit, err := c.habitsModel.CreateCompletionHandler(r.Context(), userID, habitId, newCompletion.CompletionDate, newCompletion.Value)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
-	result, err := c.habitsView.DeleteHabitsHandler()
+	result, err := c.habitsView.CreateCompletionHandler(habit)
+
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", habitETag(habit.Revision))
+
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", string(result)))
+	w.WriteHeader(http.StatusCreated)
+	numOfBytes, err := w.Write(result)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
+
+// DeleteCompletionHandler handles DELETE .../habits/{habitId}/completions/{completionDate}
+func (c *HabitsController) DeleteCompletionHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims not found")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	userID := claims.Subject
+
+	if userID == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), "JWT Token claims subject is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	habitId, completionDate := r.PathValue("habitId"), r.PathValue("completionDate")
+
+	if habitId == "" || completionDate == "" {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("path=%s doesn't match /habits/{habitId}/completions/{completionDate}", r.URL.Path))
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	c.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userID, habitId, completionDate))
+
+	habit, err := c.habitsModel.DeleteCompletionHandler(r.Context(), userID, habitId, completionDate)
+
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	result, err := c.habitsView.DeleteCompletionHandler(habit)
 
 	if err != nil {
 		c.logger.ErrorLog(helper.GetFunctionName(), err.Error())
-		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		apperror.WriteProblem(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", habitETag(habit.Revision))
+
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", string(result)))
 	numOfBytes, err := w.Write(result)
 	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
@@ -428,3 +520,47 @@ func (c *HabitsController) DeleteHabitsHandler(w http.ResponseWriter, r *http.Re
 		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
 	}
 }
+
+// habitIdFromRequest reads the habitId from the /habits/{habitId} path, falling back to the legacy ?habitId= query param
+func habitIdFromRequest(r *http.Request) string {
+	if habitId := r.PathValue("habitId"); habitId != "" {
+		return habitId
+	}
+
+	return r.URL.Query().Get("habitId")
+}
+
+// habitETag is the strong entity tag of a habit at revision
+func habitETag(revision int64) string {
+	return strconv.Quote(strconv.FormatInt(revision, 10))
+}
+
+/*
+revisionFromIfMatch returns the habit revision the client expects from the If-Match header, or nil when it is missing or "*".
+Only a single strong entity tag is accepted. A weak tag or one that was never issued can't match, so it fails the precondition.
+*/
+func revisionFromIfMatch(r *http.Request) (*int64, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil
+	}
+
+	if strings.Contains(ifMatch, ",") {
+		return nil, fmt.Errorf("%s - If-Match=%s %w", helper.GetFunctionName(), ifMatch, errInvalidIfMatch)
+	}
+
+	tag, isWeak := strings.CutPrefix(ifMatch, "W/")
+
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return nil, fmt.Errorf("%s - If-Match=%s %w", helper.GetFunctionName(), ifMatch, errInvalidIfMatch)
+	}
+
+	revision, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+
+	if isWeak || err != nil {
+		return nil, fmt.Errorf("%s - If-Match=%s %w", helper.GetFunctionName(), ifMatch, db.ErrRevisionMismatch)
+	}
+
+	return &revision, nil
+}
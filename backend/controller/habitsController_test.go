@@ -3,6 +3,7 @@ package controller
 import (
 	"bytes"
 	"context"
+	"dohabits/apperror"
 	"dohabits/data"
 	"dohabits/db"
 	"dohabits/helper"
@@ -11,16 +12,23 @@ import (
 	"dohabits/model"
 	"dohabits/view"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"time"
 )
 
 func TestCreateHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -37,7 +45,7 @@ func TestCreateHabitsHandler(t *testing.T) {
 		{
 			name:        "Test successful Create",
 			newHabitReq: `{"name": "Test Create Habit", "daysTarget": 50}`,
-			want:        []byte("{\"habitId\":\"7\",\"name\":\"Test Create Habit\",\"daysTarget\":50,\"completionDates\":[]}"),
+			want:        []byte("{\"habitId\":\"7\",\"name\":\"Test Create Habit\",\"daysTarget\":50,\"completionDates\":[],\"schedule\":{\"type\":\"daily\"},\"revision\":1}"),
 		},
 	}
 
@@ -46,7 +54,7 @@ func TestCreateHabitsHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPost, "/CreateHabit", io.NopCloser(bytes.NewBuffer([]byte(val.newHabitReq))))
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -83,11 +91,16 @@ func TestCreateHabitsHandler(t *testing.T) {
 func TestRetrieveHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
-	marshalledHabit, err := json.Marshal(data.MockHabit[0])
+	// Days and Progress are computed from CompletionDates when a habit is retrieved
+	wantHabit := data.MockHabit[0]
+	wantHabit.Progress = model.CalculateHabitProgress(wantHabit, time.Now())
+	wantHabit.Days = wantHabit.Progress.TotalCompletions
+
+	marshalledHabit, err := json.Marshal(wantHabit)
 
 	if err != nil {
 		t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
@@ -112,7 +125,7 @@ func TestRetrieveHabitsHandler(t *testing.T) {
 			q.Add("habitId", "1")
 			req.URL.RawQuery = q.Encode()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -147,7 +160,7 @@ func TestRetrieveHabitsHandler(t *testing.T) {
 func TestRetrieveAllHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -155,6 +168,8 @@ func TestRetrieveAllHabitsHandler(t *testing.T) {
 
 	for _, val := range data.MockHabit {
 		if val.UserID == "1" {
+			val.Progress = model.CalculateHabitProgress(val, time.Now())
+			val.Days = val.Progress.TotalCompletions
 			userMockHabits = append(userMockHabits, val)
 		}
 	}
@@ -181,7 +196,7 @@ func TestRetrieveAllHabitsHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodGet, "/RetrieveAllHabits", nil)
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -216,7 +231,7 @@ func TestRetrieveAllHabitsHandler(t *testing.T) {
 func TestUpdateHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -232,7 +247,7 @@ func TestUpdateHabitsHandler(t *testing.T) {
 		{
 			name:        "Test successful Update",
 			updateHabit: data.Habit{Name: "Test Update Habit", Days: 30, DaysTarget: 50, CompletionDates: []string{"2021-09-01", "2021-09-02", "2021-09-03"}},
-			want:        []byte("{\"habitId\":\"\",\"name\":\"Test Update Habit\",\"days\":30,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]}"),
+			want:        []byte("{\"habitId\":\"\",\"name\":\"Test Update Habit\",\"days\":3,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]}"),
 		},
 	}
 
@@ -255,7 +270,7 @@ func TestUpdateHabitsHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPut, "/UpdateHabit", io.NopCloser(bytes.NewBuffer(marshalledNewHabit)))
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -287,10 +302,68 @@ func TestUpdateHabitsHandler(t *testing.T) {
 	data.MockHabit = originalMockHabitState
 }
 
+func TestUpdateHabitsHandlerPathHabitId(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+	habitsView := view.NewHabitsView(logger)
+	c := NewHabitsController(habitsModel, habitsView, logger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PATCH /dohabitsapp/v1/habits/{habitId}", c.UpdateHabitsHandler)
+
+	// Make a deep copy of the original state
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "Test habitId taken from the path",
+			body:       `{"name":"Renamed Habit"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test matching body habitId",
+			body:       `{"habitId":"1","name":"Renamed Habit"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test conflicting body habitId",
+			body:       `{"habitId":"2","name":"Renamed Habit"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/dohabitsapp/v1/habits/1", strings.NewReader(val.body))
+			w := httptest.NewRecorder()
+
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
+
+			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
+
+			req = req.WithContext(ctx)
+
+			mux.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+		})
+	}
+
+	data.MockHabit = originalMockHabitState
+}
+
 func TestUpdateAllHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -316,7 +389,7 @@ func TestUpdateAllHabitsHandler(t *testing.T) {
 					Name: "Test Update Habit 3", Days: 30, DaysTarget: 50, CompletionDates: []string{"2021-09-01", "2021-09-02", "2021-09-03"},
 				},
 			},
-			want: []byte("[{\"habitId\":\"1\",\"name\":\"Test Update Habit 1\",\"days\":30,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]},{\"habitId\":\"2\",\"name\":\"Test Update Habit 2\",\"days\":30,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]},{\"habitId\":\"6\",\"name\":\"Test Update Habit 3\",\"days\":30,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]}]"),
+			want: []byte("[{\"habitId\":\"1\",\"name\":\"Test Update Habit 1\",\"days\":3,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]},{\"habitId\":\"2\",\"name\":\"Test Update Habit 2\",\"days\":3,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]},{\"habitId\":\"6\",\"name\":\"Test Update Habit 3\",\"days\":3,\"daysTarget\":50,\"completionDates\":[\"2021-09-01\",\"2021-09-02\",\"2021-09-03\"]}]"),
 		},
 	}
 
@@ -356,7 +429,7 @@ func TestUpdateAllHabitsHandler(t *testing.T) {
 			req := httptest.NewRequest(http.MethodPut, "/UpdateHabits", io.NopCloser(bytes.NewBuffer(marshalledNewHabit)))
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -393,7 +466,7 @@ func TestUpdateAllHabitsHandler(t *testing.T) {
 func TestDeleteHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -419,7 +492,7 @@ func TestDeleteHabitsHandler(t *testing.T) {
 			q.Add("habitId", "1")
 			req.URL.RawQuery = q.Encode()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -462,7 +535,7 @@ func TestDeleteHabitsHandler(t *testing.T) {
 func BenchmarkCreateHabitsHandler(b *testing.B) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -486,7 +559,7 @@ func BenchmarkCreateHabitsHandler(b *testing.B) {
 			req := httptest.NewRequest(http.MethodPost, "/CreateHabit", io.NopCloser(bytes.NewBuffer(marshalledNewHabitRequest)))
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -500,7 +573,7 @@ func BenchmarkCreateHabitsHandler(b *testing.B) {
 func BenchmarkRetrieveHabitsHandler(b *testing.B) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -513,7 +586,7 @@ func BenchmarkRetrieveHabitsHandler(b *testing.B) {
 			q.Add("habitId", "1")
 			req.URL.RawQuery = q.Encode()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -528,7 +601,7 @@ func BenchmarkRetrieveHabitsHandler(b *testing.B) {
 func BenchmarkRetrieveAllHabitsHandler(b *testing.B) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -538,7 +611,7 @@ func BenchmarkRetrieveAllHabitsHandler(b *testing.B) {
 			req := httptest.NewRequest(http.MethodGet, "/RetrieveAllHabits", nil)
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -552,7 +625,7 @@ func BenchmarkRetrieveAllHabitsHandler(b *testing.B) {
 func BenchmarkUpdateHabitsHandler(b *testing.B) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -588,7 +661,7 @@ func BenchmarkUpdateHabitsHandler(b *testing.B) {
 			req := httptest.NewRequest(http.MethodPut, "/UpdateHabit", io.NopCloser(bytes.NewBuffer(marshalledNewHabit)))
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -602,7 +675,7 @@ func BenchmarkUpdateHabitsHandler(b *testing.B) {
 func BenchmarkUpdateAllHabitsHandler(b *testing.B) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -657,7 +730,7 @@ func BenchmarkUpdateAllHabitsHandler(b *testing.B) {
 			req := httptest.NewRequest(http.MethodPut, "/UpdateHabits", io.NopCloser(bytes.NewBuffer(marshalledUpdatedHabit)))
 			w := httptest.NewRecorder()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -672,7 +745,7 @@ func BenchmarkUpdateAllHabitsHandler(b *testing.B) {
 func BenchmarkDeleteHabitsHandler(b *testing.B) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	habitsModel := model.NewHabitsModel(logger, db)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 	habitsView := view.NewHabitsView(logger)
 	c := NewHabitsController(habitsModel, habitsView, logger)
 
@@ -685,7 +758,7 @@ func BenchmarkDeleteHabitsHandler(b *testing.B) {
 			q.Add("habitId", "1")
 			req.URL.RawQuery = q.Encode()
 
-			claims := &session.Claims{Username: "johndoe1@example.com"}
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
 
 			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
 
@@ -695,3 +768,622 @@ func BenchmarkDeleteHabitsHandler(b *testing.B) {
 		}
 	})
 }
+
+func TestCreateCompletionHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+	habitsView := view.NewHabitsView(logger)
+	c := NewHabitsController(habitsModel, habitsView, logger)
+
+	// Served through a mux so the handler gets its {habitId} path value
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dohabitsapp/v1/habits/{habitId}/completions", c.CreateCompletionHandler)
+
+	// Make a deep copy of the original state
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	testCases := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "Test successful check-in for today",
+			path:       "/dohabitsapp/v1/habits/2/completions",
+			body:       "",
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Test successful check-in for a past date",
+			path:       "/dohabitsapp/v1/habits/2/completions",
+			body:       `{"completionDate":"2025-01-01"}`,
+			wantStatus: http.StatusCreated,
+		},
+		{
+			name:       "Test duplicate date is rejected",
+			path:       "/dohabitsapp/v1/habits/2/completions",
+			body:       `{"completionDate":"2025-01-01"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Test bad date is rejected",
+			path:       "/dohabitsapp/v1/habits/2/completions",
+			body:       `{"completionDate":"2025-13-01"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Test value on a habit without a daily goal is rejected",
+			path:       "/dohabitsapp/v1/habits/2/completions",
+			body:       `{"completionDate":"2025-01-02","value":2}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Test unknown habit",
+			path:       "/dohabitsapp/v1/habits/999/completions",
+			body:       `{"completionDate":"2025-01-02"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Test unknown path",
+			path:       "/dohabitsapp/v1/habits/2/other",
+			body:       "",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, val.path, strings.NewReader(val.body))
+			w := httptest.NewRecorder()
+
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
+
+			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
+
+			req = req.WithContext(ctx)
+
+			mux.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+				return
+			}
+
+			if val.wantStatus != http.StatusCreated {
+				return
+			}
+
+			habit := data.Habit{}
+
+			if err := json.NewDecoder(w.Result().Body).Decode(&habit); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			if habit.HabitID != "2" || habit.Days != habit.Progress.TotalCompletions {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), habit)
+			}
+		})
+	}
+
+	data.MockHabit = originalMockHabitState
+}
+
+func TestDeleteCompletionHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+	habitsView := view.NewHabitsView(logger)
+	c := NewHabitsController(habitsModel, habitsView, logger)
+
+	// Served through a mux so the handler gets its {habitId} and {completionDate} path values
+	mux := http.NewServeMux()
+	mux.HandleFunc("DELETE /dohabitsapp/v1/habits/{habitId}/completions/{completionDate}", c.DeleteCompletionHandler)
+
+	// Make a deep copy of the original state
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	testCases := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "Test successful undo",
+			path:       "/dohabitsapp/v1/habits/2/completions/2024-12-02",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test undo of a date that isn't completed",
+			path:       "/dohabitsapp/v1/habits/2/completions/2024-12-02",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Test bad date is rejected",
+			path:       "/dohabitsapp/v1/habits/2/completions/yesterday",
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Test missing date",
+			path:       "/dohabitsapp/v1/habits/2/completions",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, val.path, nil)
+			w := httptest.NewRecorder()
+
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
+
+			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
+
+			req = req.WithContext(ctx)
+
+			mux.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+				return
+			}
+
+			if val.wantStatus != http.StatusOK {
+				return
+			}
+
+			habit := data.Habit{}
+
+			if err := json.NewDecoder(w.Result().Body).Decode(&habit); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			if !reflect.DeepEqual(habit.CompletionDates, []string{"2024-12-20", "2024-12-11"}) {
+				t.Errorf("%s - Failed - got=%v", helper.GetFunctionName(), habit.CompletionDates)
+			}
+		})
+	}
+
+	data.MockHabit = originalMockHabitState
+}
+
+func TestHabitsHandlerProblems(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+	habitsView := view.NewHabitsView(logger)
+	c := NewHabitsController(habitsModel, habitsView, logger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dohabitsapp/v1/habits", c.CreateHabitsHandler)
+	mux.HandleFunc("GET /dohabitsapp/v1/habits/{habitId}", c.RetrieveHabitsHandler)
+	mux.HandleFunc("PATCH /dohabitsapp/v1/habits", c.UpdateAllHabitsHandler)
+	mux.HandleFunc("PATCH /dohabitsapp/v1/habits/{habitId}", c.UpdateHabitsHandler)
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   apperror.Code
+		wantFields []string
+	}{
+		{
+			name:       "Test malformed body",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/habits",
+			body:       `{"name":`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test malformed update body",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits/1",
+			body:       `{"name":`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test malformed update all body",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits",
+			body:       `[{"name":`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test every invalid field is listed",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/habits",
+			body:       `{"name":"","daysTarget":-1}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   apperror.CodeValidationFailed,
+			wantFields: []string{"name", "daysTarget"},
+		},
+		{
+			name:       "Test unknown habit",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/habits/999",
+			wantStatus: http.StatusNotFound,
+			wantCode:   apperror.CodeHabitNotFound,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, strings.NewReader(val.body))
+			w := httptest.NewRecorder()
+
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
+
+			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
+
+			req = req.WithContext(ctx)
+
+			mux.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+				return
+			}
+
+			if contentType := w.Header().Get("Content-Type"); contentType != apperror.ContentType {
+				t.Errorf("%s - Failed - Content-Type = %s, want=%s", helper.GetFunctionName(), contentType, apperror.ContentType)
+			}
+
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			gotFields := []string{}
+
+			for _, field := range problem.Errors {
+				gotFields = append(gotFields, field.Field)
+			}
+
+			if problem.Code != val.wantCode || problem.Status != val.wantStatus || len(gotFields) != len(val.wantFields) {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), problem)
+				return
+			}
+
+			for i, field := range val.wantFields {
+				if gotFields[i] != field {
+					t.Errorf("%s - Failed - fields=%v, want=%v", helper.GetFunctionName(), gotFields, val.wantFields)
+				}
+			}
+		})
+	}
+}
+
+// blockingMockDB holds blockedUserID's check-ins inside the DB until release is closed
+type blockingMockDB struct {
+	*db.MyMockDB
+	blockedUserID string
+	entered       chan struct{}
+	release       chan struct{}
+}
+
+func (d *blockingMockDB) CreateCompletionHandler(ctx context.Context, userId, habitId, completionDate string) (data.Habit, error) {
+	if userId == d.blockedUserID {
+		close(d.entered)
+		<-d.release
+	}
+
+	return d.MyMockDB.CreateCompletionHandler(ctx, userId, habitId, completionDate)
+}
+
+// TestConcurrentUsersDontBlockEachOther loads the API with other users' requests while one user's write is stuck in the DB
+func TestConcurrentUsersDontBlockEachOther(t *testing.T) {
+	logger := logger.NewLogger(0)
+	blockingDB := &blockingMockDB{MyMockDB: db.NewMockDB(logger), blockedUserID: "1", entered: make(chan struct{}), release: make(chan struct{})}
+	habitsModel := model.NewHabitsModel(logger, blockingDB, data.UnverifiedUserAccessAll)
+	habitsView := view.NewHabitsView(logger)
+	c := NewHabitsController(habitsModel, habitsView, logger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dohabitsapp/v1/habits", c.RetrieveAllHabitsHandler)
+	mux.HandleFunc("GET /dohabitsapp/v1/habits/{habitId}", c.RetrieveHabitsHandler)
+	mux.HandleFunc("POST /dohabitsapp/v1/habits/{habitId}/completions", c.CreateCompletionHandler)
+
+	// Make a deep copy of the original state
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	defer func() { data.MockHabit = originalMockHabitState }()
+
+	serve := func(method, path, userID string) int {
+		req := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+
+		req = req.WithContext(context.WithValue(req.Context(), session.ClaimsKey, &session.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: userID}}))
+
+		mux.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	blockedStatus := make(chan int, 1)
+
+	go func() {
+		blockedStatus <- serve(http.MethodPost, "/dohabitsapp/v1/habits/2/completions", "1")
+	}()
+
+	<-blockingDB.entered
+
+	users := []struct {
+		userID  string
+		habitId string
+	}{
+		{userID: "2", habitId: "3"},
+		{userID: "3", habitId: "4"},
+		{userID: "4", habitId: "5"},
+		// The blocked user can still read while their own write is in progress
+		{userID: "1", habitId: "1"},
+	}
+
+	const requestsPerUser = 50
+
+	var wg sync.WaitGroup
+	failures := make(chan string, len(users)*requestsPerUser*2)
+
+	for _, user := range users {
+		for i := 0; i < requestsPerUser; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for _, path := range []string{"/dohabitsapp/v1/habits", "/dohabitsapp/v1/habits/" + user.habitId} {
+					if status := serve(http.MethodGet, path, user.userID); status != http.StatusOK {
+						failures <- fmt.Sprintf("userId=%s GET %s = %d", user.userID, path, status)
+					}
+				}
+			}()
+		}
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		close(blockingDB.release)
+		t.Fatalf("%s - Failed - requests were blocked by another user's write", helper.GetFunctionName())
+	}
+
+	close(failures)
+
+	for failure := range failures {
+		t.Errorf("%s - Failed - %s", helper.GetFunctionName(), failure)
+	}
+
+	close(blockingDB.release)
+
+	if status := <-blockedStatus; status != http.StatusCreated {
+		t.Errorf("%s - Failed - blocked check-in HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, http.StatusCreated)
+	}
+}
+
+// TestConcurrentCheckInsForOneUser checks the per-user lock still stops one user's concurrent writes racing each other
+func TestConcurrentCheckInsForOneUser(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+	habitsView := view.NewHabitsView(logger)
+	c := NewHabitsController(habitsModel, habitsView, logger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dohabitsapp/v1/habits/{habitId}/completions", c.CreateCompletionHandler)
+
+	// Make a deep copy of the original state
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	defer func() { data.MockHabit = originalMockHabitState }()
+
+	const requests = 25
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, requests)
+
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodPost, "/dohabitsapp/v1/habits/2/completions", strings.NewReader(`{"completionDate":"2025-02-01"}`))
+			w := httptest.NewRecorder()
+
+			req = req.WithContext(context.WithValue(req.Context(), session.ClaimsKey, &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}))
+
+			mux.ServeHTTP(w, req)
+
+			statuses <- w.Code
+		}()
+	}
+
+	wg.Wait()
+	close(statuses)
+
+	got := map[int]int{}
+
+	for status := range statuses {
+		got[status]++
+	}
+
+	if got[http.StatusCreated] != 1 || got[http.StatusConflict] != requests-1 {
+		t.Errorf("%s - Failed - got=%v, want 1 created and %d conflicts", helper.GetFunctionName(), got, requests-1)
+	}
+}
+
+// TestHabitETags runs its steps in order against habit 6, which starts at revision 1
+func TestHabitETags(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	habitsModel := model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+	habitsView := view.NewHabitsView(logger)
+	c := NewHabitsController(habitsModel, habitsView, logger)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /dohabitsapp/v1/habits/{habitId}", c.RetrieveHabitsHandler)
+	mux.HandleFunc("PATCH /dohabitsapp/v1/habits", c.UpdateAllHabitsHandler)
+	mux.HandleFunc("PATCH /dohabitsapp/v1/habits/{habitId}", c.UpdateHabitsHandler)
+	mux.HandleFunc("DELETE /dohabitsapp/v1/habits/{habitId}", c.DeleteHabitsHandler)
+	mux.HandleFunc("POST /dohabitsapp/v1/habits/{habitId}/completions", c.CreateCompletionHandler)
+
+	// Make a deep copy of the original state
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	defer func() { data.MockHabit = originalMockHabitState }()
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		ifMatch    string
+		body       string
+		wantStatus int
+		wantETag   string
+		wantCode   apperror.Code
+	}{
+		{
+			name:       "Test retrieve returns the revision as the ETag",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/habits/6",
+			wantStatus: http.StatusOK,
+			wantETag:   `"1"`,
+		},
+		{
+			name:       "Test update at the current revision",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits/6",
+			ifMatch:    `"1"`,
+			body:       `{"name":"Limit screen time"}`,
+			wantStatus: http.StatusOK,
+			wantETag:   `"2"`,
+		},
+		{
+			name:       "Test stale update",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits/6",
+			ifMatch:    `"1"`,
+			body:       `{"name":"Overwritten"}`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   apperror.CodeRevisionMismatch,
+		},
+		{
+			name:       "Test weak ETag never matches",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits/6",
+			ifMatch:    `W/"2"`,
+			body:       `{"name":"Overwritten"}`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   apperror.CodeRevisionMismatch,
+		},
+		{
+			name:       "Test more than one ETag",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits/6",
+			ifMatch:    `"1", "2"`,
+			body:       `{"name":"Overwritten"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test stale revision in an update all body",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits",
+			body:       `[{"habitId":"6","name":"Overwritten","revision":1}]`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   apperror.CodeRevisionMismatch,
+		},
+		{
+			name:       "Test update without If-Match is unconditional",
+			method:     http.MethodPatch,
+			path:       "/dohabitsapp/v1/habits/6",
+			body:       `{"daysTarget":90}`,
+			wantStatus: http.StatusOK,
+			wantETag:   `"3"`,
+		},
+		{
+			name:       "Test check-in moves the revision on",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/habits/6/completions",
+			wantStatus: http.StatusCreated,
+			wantETag:   `"4"`,
+		},
+		{
+			name:       "Test stale delete",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/habits/6",
+			ifMatch:    `"3"`,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   apperror.CodeRevisionMismatch,
+		},
+		{
+			name:       "Test delete at the current revision",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/habits/6",
+			ifMatch:    `"4"`,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, strings.NewReader(val.body))
+			w := httptest.NewRecorder()
+
+			if val.ifMatch != "" {
+				req.Header.Set("If-Match", val.ifMatch)
+			}
+
+			claims := &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}
+
+			ctx := context.WithValue(req.Context(), session.ClaimsKey, claims)
+
+			req = req.WithContext(ctx)
+
+			mux.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Fatalf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), status, val.wantStatus, w.Body.String())
+			}
+
+			if etag := w.Header().Get("ETag"); etag != val.wantETag {
+				t.Errorf("%s - Failed - ETag = %s, want=%s", helper.GetFunctionName(), etag, val.wantETag)
+			}
+
+			if val.wantCode == "" {
+				return
+			}
+
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			if problem.Code != val.wantCode {
+				t.Errorf("%s - Failed - code=%s, want=%s", helper.GetFunctionName(), problem.Code, val.wantCode)
+			}
+		})
+	}
+}
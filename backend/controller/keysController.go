@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"dohabits/apperror"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/view"
+	"fmt"
+	"net/http"
+)
+
+// jwksMaxAge is how long clients may cache the key set. A new signing key should be published for at least this long before tokens are signed with it.
+const jwksMaxAge = 300
+
+type KeysController struct {
+	keys     *session.KeySet
+	keysView view.IKeysView
+	logger   logger.ILogger
+}
+
+type IKeysController interface {
+	JSONWebKeySetHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewKeysController(keys *session.KeySet, keysView view.IKeysView, logger logger.ILogger) *KeysController {
+	return &KeysController{
+		keys:     keys,
+		keysView: keysView,
+		logger:   logger,
+	}
+}
+
+// JSONWebKeySetHandler publishes the public keys access tokens are signed with, so other services can verify them without calling us
+func (c *KeysController) JSONWebKeySetHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	response, err := c.keysView.JSONWebKeySetHandler(c.keys.JSONWebKeySet())
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", jwksMaxAge))
+
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/view"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONWebKeySetHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	signingKeyFile := filepath.Join(t.TempDir(), "signing.pem")
+
+	if err := os.WriteFile(signingKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes}), 0o600); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	asymmetricKeys, err := session.LoadKeySet("secret", signingKeyFile, "")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name     string
+		keys     *session.KeySet
+		wantKeys int
+	}{
+		{name: "Test the shared secret is never published", keys: session.NewHMACKeySet("secret"), wantKeys: 0},
+		{name: "Test the public half of the signing key is published", keys: asymmetricKeys, wantKeys: 1},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			keysController := NewKeysController(val.keys, view.NewKeysView(logger), logger)
+
+			req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			w := httptest.NewRecorder()
+
+			keysController.JSONWebKeySetHandler(w, req)
+
+			if status := w.Code; status != http.StatusOK {
+				t.Errorf("%s - Failed - HTTP Status Code = %d", helper.GetFunctionName(), status)
+				return
+			}
+
+			body := w.Body.String()
+
+			if strings.Contains(body, "secret") || strings.Contains(body, `"d"`) {
+				t.Errorf("%s - Failed - private key material published - body=%s", helper.GetFunctionName(), body)
+				return
+			}
+
+			jwks := data.JSONWebKeySet{}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &jwks); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			if len(jwks.Keys) != val.wantKeys {
+				t.Errorf("%s - Failed - got=%+v, want %d keys", helper.GetFunctionName(), jwks.Keys, val.wantKeys)
+				return
+			}
+
+			if val.wantKeys > 0 && (jwks.Keys[0].KeyType != "OKP" || jwks.Keys[0].Algorithm != "EdDSA" || jwks.Keys[0].KeyID == "" || jwks.Keys[0].X == "") {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), jwks.Keys[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/view"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type MFAController struct {
+	mfaModel model.IMFAModel
+	mfaView  view.IMFAView
+	logger   logger.ILogger
+}
+
+type IMFAController interface {
+	EnrolTOTPHandler(w http.ResponseWriter, r *http.Request)
+	ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request)
+	DisableTOTPHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewMFAController(mfaModel model.IMFAModel, mfaView view.IMFAView, logger logger.ILogger) *MFAController {
+	return &MFAController{
+		mfaModel: mfaModel,
+		mfaView:  mfaView,
+		logger:   logger,
+	}
+}
+
+// EnrolTOTPHandler gives the user a new secret to add to their authenticator app
+func (c *MFAController) EnrolTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userID, ok := c.userID(w, r)
+
+	if !ok {
+		return
+	}
+
+	enrolment, err := c.mfaModel.EnrolTOTPHandler(r.Context(), userID)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.mfaView.EnrolTOTPHandler(enrolment)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// ConfirmTOTPHandler enables two-factor authentication with the first code from the app and returns the recovery codes
+func (c *MFAController) ConfirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userID, ok := c.userID(w, r)
+
+	if !ok {
+		return
+	}
+
+	confirmTOTPRequest := data.ConfirmTOTPRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&confirmTOTPRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if confirmTOTPRequest.Code == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "Code is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Code", Message: "Code is required"}))
+		return
+	}
+
+	recoveryCodes, err := c.mfaModel.ConfirmTOTPHandler(r.Context(), userID, &confirmTOTPRequest)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.mfaView.ConfirmTOTPHandler(recoveryCodes)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// DisableTOTPHandler turns two-factor authentication off with the user's password and a code or recovery code
+func (c *MFAController) DisableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userID, ok := c.userID(w, r)
+
+	if !ok {
+		return
+	}
+
+	disableTOTPRequest := data.DisableTOTPRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&disableTOTPRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if disableTOTPRequest.Password == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "Password is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Password", Message: "Password is required"}))
+		return
+	}
+
+	if err := c.mfaModel.DisableTOTPHandler(r.Context(), userID, &disableTOTPRequest); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.mfaView.DisableTOTPHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// userID returns the ID of the user the access token was issued to, writing the error response if there isn't one
+func (c *MFAController) userID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok || claims.Subject == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return "", false
+	}
+
+	return claims.Subject, true
+}
+
+func (c *MFAController) writeResponse(w http.ResponseWriter, response []byte) {
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
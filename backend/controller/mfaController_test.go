@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/totp"
+	"dohabits/validation"
+	"dohabits/view"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+func TestMFAHandlers(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	keys := session.NewHMACKeySet("secretJwt")
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := model.NewMFAModel(logger, mockDB, session.NewMFAToken(keys, logger), loginAttempts)
+	mfaController := NewMFAController(mfaModel, view.NewMFAView(logger), logger)
+	authModel := model.NewAuthModel(logger, mockDB, nil, mfaModel, nil, loginAttempts, validation.DefaultPasswordPolicy(), data.UnverifiedUserAccessAll)
+	authController := NewAuthController(authModel, view.NewAuthView(logger), session.NewJSONWebToken(keys, mockDB, logger), session.NewMockCSRFToken(logger), logger, nil)
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	// serve sends body to handler as johndoe1, checks the status and problem code, and returns the response
+	serve := func(handler http.HandlerFunc, body string, wantStatus int, wantCode apperror.Code) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		req = req.WithContext(context.WithValue(req.Context(), session.ClaimsKey, &session.Claims{Username: "johndoe1@example.com", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}))
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+
+		if w.Code != wantStatus {
+			t.Fatalf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), w.Code, wantStatus, w.Body)
+		}
+
+		if wantStatus != http.StatusOK {
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Code != wantCode {
+				t.Fatalf("%s - Failed - code=%s, want=%s, err=%v", helper.GetFunctionName(), problem.Code, wantCode, err)
+			}
+		}
+
+		return w
+	}
+
+	w := serve(mfaController.EnrolTOTPHandler, ``, http.StatusOK, "")
+	enrolment := data.EnrolTOTPResponse{}
+
+	if err := json.NewDecoder(w.Body).Decode(&enrolment); err != nil || enrolment.Secret == "" || enrolment.URI == "" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), enrolment, err)
+	}
+
+	step := totp.Step(time.Now())
+	code := func(step int64) string {
+		t.Helper()
+
+		code, err := totp.Code(enrolment.Secret, step)
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		return code
+	}
+
+	serve(mfaController.ConfirmTOTPHandler, `{`, http.StatusBadRequest, apperror.CodeInvalidRequest)
+	serve(mfaController.ConfirmTOTPHandler, `{}`, http.StatusBadRequest, apperror.CodeInvalidRequest)
+	serve(mfaController.ConfirmTOTPHandler, fmt.Sprintf(`{"Code":%q}`, code(step+10)), http.StatusUnauthorized, apperror.CodeInvalidMFACode)
+	w = serve(mfaController.ConfirmTOTPHandler, fmt.Sprintf(`{"Code":%q}`, code(step)), http.StatusOK, "")
+	confirmed := data.ConfirmTOTPResponse{}
+
+	if err := json.NewDecoder(w.Body).Decode(&confirmed); err != nil || len(confirmed.RecoveryCodes) == 0 {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), confirmed, err)
+	}
+
+	serve(mfaController.EnrolTOTPHandler, ``, http.StatusConflict, apperror.CodeMFAAlreadyEnabled)
+
+	// Logging in with the password gives an MFA pending token instead of the tokens
+	w = serve(authController.LoginHandler, `{"EmailAddress":"johndoe1@example.com","Password":"1secret?Password"}`, http.StatusOK, "")
+	mfaRequired := data.MFARequiredResponse{}
+
+	if err := json.NewDecoder(w.Body).Decode(&mfaRequired); err != nil || !mfaRequired.MFARequired || mfaRequired.MFAToken == "" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), mfaRequired, err)
+	}
+
+	if w.Header().Get("Authorization") != "" || len(w.Result().Cookies()) != 0 {
+		t.Fatalf("%s - Failed - tokens were issued before the code was entered", helper.GetFunctionName())
+	}
+
+	serve(authController.LoginMFAHandler, fmt.Sprintf(`{"Code":%q}`, code(step+1)), http.StatusBadRequest, apperror.CodeInvalidRequest)
+	serve(authController.LoginMFAHandler, fmt.Sprintf(`{"MFAToken":"not-a-jwt","Code":%q}`, code(step+1)), http.StatusUnauthorized, apperror.CodeInvalidMFAToken)
+	serve(authController.LoginMFAHandler, fmt.Sprintf(`{"MFAToken":%q,"Code":%q}`, mfaRequired.MFAToken, code(step+10)), http.StatusUnauthorized, apperror.CodeInvalidMFACode)
+	w = serve(authController.LoginMFAHandler, fmt.Sprintf(`{"MFAToken":%q,"Code":%q}`, mfaRequired.MFAToken, code(step+1)), http.StatusOK, "")
+
+	if w.Header().Get("Authorization") == "" || len(w.Result().Cookies()) == 0 {
+		t.Fatalf("%s - Failed - no tokens were issued, headers=%v", helper.GetFunctionName(), w.Header())
+	}
+
+	serve(mfaController.DisableTOTPHandler, fmt.Sprintf(`{"RecoveryCode":%q}`, confirmed.RecoveryCodes[0]), http.StatusBadRequest, apperror.CodeInvalidRequest)
+	serve(mfaController.DisableTOTPHandler, fmt.Sprintf(`{"Password":"wrong","RecoveryCode":%q}`, confirmed.RecoveryCodes[0]), http.StatusUnauthorized, apperror.CodeInvalidCredentials)
+	serve(mfaController.DisableTOTPHandler, fmt.Sprintf(`{"Password":"1secret?Password","RecoveryCode":%q}`, confirmed.RecoveryCodes[0]), http.StatusOK, "")
+	serve(mfaController.DisableTOTPHandler, fmt.Sprintf(`{"Password":"1secret?Password","RecoveryCode":%q}`, confirmed.RecoveryCodes[1]), http.StatusConflict, apperror.CodeMFANotEnabled)
+}
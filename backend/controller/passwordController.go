@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/view"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type PasswordController struct {
+	passwordModel model.IPasswordModel
+	passwordView  view.IPasswordView
+	logger        logger.ILogger
+}
+
+type IPasswordController interface {
+	ForgotPasswordHandler(w http.ResponseWriter, r *http.Request)
+	ResetPasswordHandler(w http.ResponseWriter, r *http.Request)
+	ChangePasswordHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewPasswordController(passwordModel model.IPasswordModel, passwordView view.IPasswordView, logger logger.ILogger) *PasswordController {
+	return &PasswordController{
+		passwordModel: passwordModel,
+		passwordView:  passwordView,
+		logger:        logger,
+	}
+}
+
+// ForgotPasswordHandler emails a password reset link to the address, if it belongs to a user. The response is the same either way.
+func (c *PasswordController) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	forgotPasswordRequest := data.ForgotPasswordRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&forgotPasswordRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if err := c.passwordModel.ForgotPasswordHandler(r.Context(), &forgotPasswordRequest); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.passwordView.ForgotPasswordHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// ResetPasswordHandler sets a new password with the token from a reset link and revokes every session the user has
+func (c *PasswordController) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	resetPasswordRequest := data.ResetPasswordRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&resetPasswordRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if resetPasswordRequest.Token == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "Token is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Token", Message: "Token is required"}))
+		return
+	}
+
+	if err := c.passwordModel.ResetPasswordHandler(r.Context(), &resetPasswordRequest); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.passwordView.ResetPasswordHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// ChangePasswordHandler sets a new password for the logged in user and logs them out of every other session
+func (c *PasswordController) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok || claims.Subject == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return
+	}
+
+	changePasswordRequest := data.ChangePasswordRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&changePasswordRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	if changePasswordRequest.CurrentPassword == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "CurrentPassword is empty")
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "CurrentPassword", Message: "CurrentPassword is required"}))
+		return
+	}
+
+	revoked, err := c.passwordModel.ChangePasswordHandler(r.Context(), claims.Subject, claims.SessionID, &changePasswordRequest)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.passwordView.ChangePasswordHandler(revoked)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+func (c *PasswordController) writeResponse(w http.ResponseWriter, response []byte) {
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/mail"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/validation"
+	"dohabits/view"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestPasswordResetHandlers(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mailer := mail.NewMockMailer(logger)
+	passwordModel := model.NewPasswordModel(logger, db.NewMockDB(logger), mailer, validation.DefaultPasswordPolicy(), "http://localhost")
+	passwordController := NewPasswordController(passwordModel, view.NewPasswordView(logger), logger)
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+	originalMockPasswordResetsState := make([]data.PasswordReset, len(data.MockPasswordResets))
+	copy(originalMockPasswordResetsState, data.MockPasswordResets)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+		data.MockPasswordResets = originalMockPasswordResetsState
+	}()
+
+	post := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		handler(w, req)
+
+		return w
+	}
+
+	// Known and unknown email addresses get the same response
+	knownUser := post(passwordController.ForgotPasswordHandler, `{"EmailAddress":"john.loggedin@example.com"}`)
+	unknownUser := post(passwordController.ForgotPasswordHandler, `{"EmailAddress":"nobody@example.com"}`)
+
+	if knownUser.Code != http.StatusOK || unknownUser.Code != http.StatusOK || knownUser.Body.String() != unknownUser.Body.String() {
+		t.Fatalf("%s - Failed - known=%d %s, unknown=%d %s", helper.GetFunctionName(), knownUser.Code, knownUser.Body, unknownUser.Code, unknownUser.Body)
+	}
+
+	sent := mailer.Sent()
+
+	if len(sent) != 1 {
+		t.Fatalf("%s - Failed - sent=%+v", helper.GetFunctionName(), sent)
+	}
+
+	linkStart := strings.Index(sent[0].Body, "http://localhost/resetpassword?")
+
+	if linkStart < 0 {
+		t.Fatalf("%s - Failed - no reset link in the email:\n%s", helper.GetFunctionName(), sent[0].Body)
+	}
+
+	resetLink, err := url.Parse(strings.Fields(sent[0].Body[linkStart:])[0])
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	token := resetLink.Query().Get("token")
+
+	testCases := []struct {
+		name       string
+		handler    http.HandlerFunc
+		body       string
+		wantStatus int
+		wantCode   apperror.Code
+	}{
+		{
+			name:       "Test forgot password with an invalid body",
+			handler:    passwordController.ForgotPasswordHandler,
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test forgot password with an invalid email address",
+			handler:    passwordController.ForgotPasswordHandler,
+			body:       `{"EmailAddress":"not-an-email"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   apperror.CodeValidationFailed,
+		},
+		{
+			name:       "Test reset password without a token",
+			handler:    passwordController.ResetPasswordHandler,
+			body:       `{"NewPassword":"2secret?Password"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test reset password with an unknown token",
+			handler:    passwordController.ResetPasswordHandler,
+			body:       `{"Token":"not-a-token","NewPassword":"2secret?Password"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidResetToken,
+		},
+		{
+			name:       "Test reset password with an invalid password",
+			handler:    passwordController.ResetPasswordHandler,
+			body:       fmt.Sprintf(`{"Token":%q,"NewPassword":"short"}`, token),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   apperror.CodeValidationFailed,
+		},
+		{
+			name:       "Test reset password",
+			handler:    passwordController.ResetPasswordHandler,
+			body:       fmt.Sprintf(`{"Token":%q,"NewPassword":"2secret?Password"}`, token),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test reset password with a token that's been used",
+			handler:    passwordController.ResetPasswordHandler,
+			body:       fmt.Sprintf(`{"Token":%q,"NewPassword":"3secret?Password"}`, token),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidResetToken,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			w := post(val.handler, val.body)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), status, val.wantStatus, w.Body)
+				return
+			}
+
+			if val.wantStatus == http.StatusOK {
+				if got := w.Body.String(); got != `{"Success":true}` {
+					t.Errorf("%s - Failed - got=%s", helper.GetFunctionName(), got)
+				}
+				return
+			}
+
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			if problem.Code != val.wantCode {
+				t.Errorf("%s - Failed - code=%s, want=%s", helper.GetFunctionName(), problem.Code, val.wantCode)
+			}
+		})
+	}
+}
+
+func TestChangePasswordHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	passwordModel := model.NewPasswordModel(logger, db.NewMockDB(logger), mail.NewMockMailer(logger), validation.DefaultPasswordPolicy(), "http://localhost")
+	passwordController := NewPasswordController(passwordModel, view.NewPasswordView(logger), logger)
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   apperror.Code
+	}{
+		{
+			name:       "Test change password without the current password",
+			body:       `{"NewPassword":"2secret?Password"}`,
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apperror.CodeInvalidRequest,
+		},
+		{
+			name:       "Test change password with the wrong current password",
+			body:       `{"CurrentPassword":"wrong?Password1","NewPassword":"2secret?Password"}`,
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   apperror.CodeInvalidCredentials,
+		},
+		{
+			name:       "Test change password to one that breaks the policy",
+			body:       `{"CurrentPassword":"1secret?Password","NewPassword":"short"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantCode:   apperror.CodeValidationFailed,
+		},
+		{
+			name:       "Test change password",
+			body:       `{"CurrentPassword":"1secret?Password","NewPassword":"2secret?Password"}`,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/password/change", bytes.NewBufferString(val.body))
+			req = req.WithContext(context.WithValue(req.Context(), session.ClaimsKey, &session.Claims{Username: "john.loggedin@example.com", SessionID: "mock-session-4a", RegisteredClaims: jwt.RegisteredClaims{Subject: "4"}}))
+			w := httptest.NewRecorder()
+
+			passwordController.ChangePasswordHandler(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), status, val.wantStatus, w.Body)
+				return
+			}
+
+			if val.wantStatus == http.StatusOK {
+				if got := w.Body.String(); got != `{"Success":true,"Revoked":1}` {
+					t.Errorf("%s - Failed - got=%s", helper.GetFunctionName(), got)
+				}
+				return
+			}
+
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Code != val.wantCode {
+				t.Errorf("%s - Failed - code=%s, want=%s, err=%v", helper.GetFunctionName(), problem.Code, val.wantCode, err)
+			}
+		})
+	}
+}
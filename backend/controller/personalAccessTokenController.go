@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/view"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+var errTokenIdRequired = apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "TokenID", Message: "TokenID is required"})
+
+type PersonalAccessTokenController struct {
+	personalAccessTokenModel model.IPersonalAccessTokenModel
+	personalAccessTokenView  view.IPersonalAccessTokenView
+	logger                   logger.ILogger
+}
+
+type IPersonalAccessTokenController interface {
+	CreatePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request)
+	RetrievePersonalAccessTokensHandler(w http.ResponseWriter, r *http.Request)
+	DeletePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request)
+}
+
+func NewPersonalAccessTokenController(personalAccessTokenModel model.IPersonalAccessTokenModel, personalAccessTokenView view.IPersonalAccessTokenView, logger logger.ILogger) *PersonalAccessTokenController {
+	return &PersonalAccessTokenController{
+		personalAccessTokenModel: personalAccessTokenModel,
+		personalAccessTokenView:  personalAccessTokenView,
+		logger:                   logger,
+	}
+}
+
+// CreatePersonalAccessTokenHandler handles POST .../tokens. The response is the only time the token is shown.
+func (c *PersonalAccessTokenController) CreatePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userID, ok := c.userID(w, r)
+
+	if !ok {
+		return
+	}
+
+	createRequest := data.CreatePersonalAccessTokenRequest{}
+
+	if err := json.NewDecoder(r.Body).Decode(&createRequest); err != nil {
+		apperror.WriteProblem(w, r, apperror.ErrInvalidRequest)
+		return
+	}
+
+	token, personalAccessToken, err := c.personalAccessTokenModel.CreatePersonalAccessTokenHandler(r.Context(), userID, &createRequest)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.personalAccessTokenView.CreatePersonalAccessTokenHandler(token, personalAccessToken)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	c.writeResponse(w, response)
+}
+
+// RetrievePersonalAccessTokensHandler handles GET .../tokens
+func (c *PersonalAccessTokenController) RetrievePersonalAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userID, ok := c.userID(w, r)
+
+	if !ok {
+		return
+	}
+
+	personalAccessTokens, err := c.personalAccessTokenModel.RetrievePersonalAccessTokensHandler(r.Context(), userID)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.personalAccessTokenView.PersonalAccessTokensHandler(personalAccessTokens)
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// DeletePersonalAccessTokenHandler handles DELETE .../tokens/{tokenId}
+func (c *PersonalAccessTokenController) DeletePersonalAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userID, ok := c.userID(w, r)
+
+	if !ok {
+		return
+	}
+
+	tokenId := r.PathValue("tokenId")
+
+	if tokenId == "" {
+		c.logger.DebugLog(helper.GetFunctionName(), "tokenId is empty")
+		apperror.WriteProblem(w, r, errTokenIdRequired)
+		return
+	}
+
+	if err := c.personalAccessTokenModel.DeletePersonalAccessTokenHandler(r.Context(), userID, tokenId); err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	response, err := c.personalAccessTokenView.DeletePersonalAccessTokenHandler()
+
+	if err != nil {
+		c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("err: %s", err))
+		apperror.WriteProblem(w, r, err)
+		return
+	}
+
+	c.writeResponse(w, response)
+}
+
+// userID returns the ID of the user the access token was issued to, writing the error response if there isn't one
+func (c *PersonalAccessTokenController) userID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims)
+
+	if !ok || claims.Subject == "" {
+		apperror.WriteProblem(w, r, apperror.ErrInternal)
+		return "", false
+	}
+
+	return claims.Subject, true
+}
+
+func (c *PersonalAccessTokenController) writeResponse(w http.ResponseWriter, response []byte) {
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("Writing response: %s", response))
+	numOfBytes, err := w.Write(response)
+	c.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("w.Write wrote %d bytes", numOfBytes))
+	if err != nil {
+		c.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error writing response: %s", err))
+	}
+}
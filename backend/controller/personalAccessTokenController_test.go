@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/view"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestPersonalAccessTokenHandlers(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	personalAccessTokenModel := model.NewPersonalAccessTokenModel(logger, mockDB, session.NewPersonalAccessTokens(mockDB, logger))
+	personalAccessTokenController := NewPersonalAccessTokenController(personalAccessTokenModel, view.NewPersonalAccessTokenView(logger), logger)
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	withClaims := func(req *http.Request) *http.Request {
+		return req.WithContext(context.WithValue(req.Context(), session.ClaimsKey, &session.Claims{Username: "johndoe1@example.com", SessionID: "mock-session-1", RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}}))
+	}
+
+	createTestCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantCode   apperror.Code
+	}{
+		{name: "Test create with a malformed body", body: `{"Name":`, wantStatus: http.StatusBadRequest, wantCode: apperror.CodeInvalidRequest},
+		{name: "Test create with an unknown scope", body: `{"Name":"CI","Scopes":["admin"]}`, wantStatus: http.StatusUnprocessableEntity, wantCode: apperror.CodeValidationFailed},
+		{name: "Test create", body: `{"Name":"CI","Scopes":["habits:read"],"ExpiresInDays":30}`, wantStatus: http.StatusCreated},
+	}
+
+	created := data.CreatePersonalAccessTokenResponse{}
+
+	for _, val := range createTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := withClaims(httptest.NewRequest(http.MethodPost, "/tokens", bytes.NewBufferString(val.body)))
+			w := httptest.NewRecorder()
+
+			personalAccessTokenController.CreatePersonalAccessTokenHandler(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), status, val.wantStatus, w.Body)
+				return
+			}
+
+			if val.wantStatus == http.StatusCreated {
+				if err := json.NewDecoder(w.Body).Decode(&created); err != nil || !strings.HasPrefix(created.Token, session.PersonalAccessTokenPrefix) || created.PersonalAccessToken.ExpiresAt == nil {
+					t.Errorf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), created, err)
+				}
+				return
+			}
+
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Code != val.wantCode {
+				t.Errorf("%s - Failed - code=%s, want=%s, err=%v", helper.GetFunctionName(), problem.Code, val.wantCode, err)
+			}
+		})
+	}
+
+	req := withClaims(httptest.NewRequest(http.MethodGet, "/tokens", nil))
+	w := httptest.NewRecorder()
+
+	personalAccessTokenController.RetrievePersonalAccessTokensHandler(w, req)
+
+	// Neither the token nor its hash is listed
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Token) || strings.Contains(w.Body.String(), "TokenHash") ||
+		!strings.Contains(w.Body.String(), created.PersonalAccessToken.TokenID) {
+		t.Fatalf("%s - Failed - HTTP Status Code = %d, body=%s", helper.GetFunctionName(), w.Code, w.Body)
+	}
+
+	deleteTestCases := []struct {
+		name       string
+		tokenId    string
+		wantStatus int
+		wantCode   apperror.Code
+	}{
+		{name: "Test revoke without a token ID", wantStatus: http.StatusBadRequest, wantCode: apperror.CodeInvalidRequest},
+		{name: "Test revoke", tokenId: created.PersonalAccessToken.TokenID, wantStatus: http.StatusOK},
+		{name: "Test revoke a token that's already revoked", tokenId: created.PersonalAccessToken.TokenID, wantStatus: http.StatusNotFound, wantCode: apperror.CodeAccessTokenNotFound},
+	}
+
+	for _, val := range deleteTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := withClaims(httptest.NewRequest(http.MethodDelete, "/tokens/"+val.tokenId, nil))
+			req.SetPathValue("tokenId", val.tokenId)
+			w := httptest.NewRecorder()
+
+			personalAccessTokenController.DeletePersonalAccessTokenHandler(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), status, val.wantStatus, w.Body)
+				return
+			}
+
+			if val.wantStatus == http.StatusOK {
+				return
+			}
+
+			problem := apperror.Problem{}
+
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil || problem.Code != val.wantCode {
+				t.Errorf("%s - Failed - code=%s, want=%s, err=%v", helper.GetFunctionName(), problem.Code, val.wantCode, err)
+			}
+		})
+	}
+}
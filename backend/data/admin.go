@@ -0,0 +1,50 @@
+package data
+
+import "time"
+
+// AdminUserResponse is a user as support staff see them. Their password and two-factor secrets are never sent.
+type AdminUserResponse struct {
+	UserID        string    `json:"UserID"`
+	FirstName     string    `json:"FirstName"`
+	LastName      string    `json:"LastName"`
+	EmailAddress  string    `json:"EmailAddress"`
+	Timezone      string    `json:"Timezone"`
+	EmailVerified bool      `json:"EmailVerified"`
+	MFAEnabled    bool      `json:"MFAEnabled"`
+	Roles         []string  `json:"Roles"`
+	Disabled      bool      `json:"Disabled"`
+	CreatedAt     time.Time `json:"CreatedAt"`
+	LastLogin     time.Time `json:"LastLogin"`
+}
+
+// AdminUsersRequest searches users by email address or name. Users are listed oldest first, a page of Limit at a time.
+type AdminUsersRequest struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+type AdminUsersResponse struct {
+	Success bool                `json:"Success"`
+	Users   []AdminUserResponse `json:"Users"`
+	Limit   int                 `json:"Limit"`
+	Offset  int                 `json:"Offset"`
+}
+
+type AdminUserDetailResponse struct {
+	Success bool              `json:"Success"`
+	User    AdminUserResponse `json:"User"`
+}
+
+// AdminUserHabitsResponse is a user's habits as they'd see them, with their progress worked out in the user's timezone
+type AdminUserHabitsResponse struct {
+	Success bool              `json:"Success"`
+	User    AdminUserResponse `json:"User"`
+	Habits  []Habit           `json:"Habits"`
+}
+
+type AdminLogoutUserResponse struct {
+	Success       bool  `json:"Success"`
+	Revoked       int64 `json:"Revoked"`       // How many of the user's sessions were logged out
+	RevokedTokens int64 `json:"RevokedTokens"` // How many of the user's personal access tokens were revoked
+}
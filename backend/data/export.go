@@ -0,0 +1,47 @@
+package data
+
+import "time"
+
+// ExportProfile is the user in their personal data export. Their password and two-factor secrets are left out.
+type ExportProfile struct {
+	UserID        string    `json:"UserID"`
+	FirstName     string    `json:"FirstName"`
+	LastName      string    `json:"LastName"`
+	EmailAddress  string    `json:"EmailAddress"`
+	Timezone      string    `json:"Timezone"`
+	EmailVerified bool      `json:"EmailVerified"`
+	MFAEnabled    bool      `json:"MFAEnabled"`
+	Roles         []string  `json:"Roles"`
+	CreatedAt     time.Time `json:"CreatedAt"`
+	LastLogin     time.Time `json:"LastLogin"`
+}
+
+// ExportSession is a session in the personal data export. The refresh token is left out.
+type ExportSession struct {
+	SessionID string    `json:"SessionID"`
+	UserAgent string    `json:"UserAgent"`
+	IPAddress string    `json:"IpAddress"`
+	CreatedAt time.Time `json:"CreatedAt"`
+}
+
+// ExportPersonalAccessToken is a personal access token in the personal data export. The token's hash is left out.
+type ExportPersonalAccessToken struct {
+	TokenID    string     `json:"TokenID"`
+	Name       string     `json:"Name"`
+	Scopes     []string   `json:"Scopes"`
+	CreatedAt  time.Time  `json:"CreatedAt"`
+	ExpiresAt  *time.Time `json:"ExpiresAt"`
+	LastUsedAt *time.Time `json:"LastUsedAt"`
+}
+
+/*
+PersonalDataExport is everything held about a user. EachHabit calls fn with each of their habits in turn, with its progress,
+rather than the habits being loaded all at once. Each call reads them again, so it's only called once per export.
+*/
+type PersonalDataExport struct {
+	User                 *UserData
+	Sessions             []UserSession
+	PersonalAccessTokens []PersonalAccessToken
+	EachHabit            func(fn func(Habit) error) error
+	ExportedAt           time.Time
+}
@@ -2,32 +2,97 @@ package data
 
 import "time"
 
+// CompletionDateLayout is the format of each entry in CompletionDates
+const CompletionDateLayout = "2006-01-02"
+
 type Habit struct {
-	HabitID         string    `json:"habitId" bson:"_id"`
-	UserID          string    `json:"userId" bson:"userId"`
-	CreatedAt       time.Time `json:"createdAt" bson:"createdAt"`
-	Name            string    `json:"name" bson:"name"`
-	Days            int       `json:"days" bson:"days"`
-	DaysTarget      int       `json:"daysTarget" bson:"daysTarget"`
-	CompletionDates []string  `json:"completionDates" bson:"completionDates"`
+	HabitID         string             `json:"habitId" bson:"_id"`
+	UserID          string             `json:"userId" bson:"userId"`
+	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
+	Name            string             `json:"name" bson:"name"`
+	Days            int                `json:"days" bson:"days"` // Computed by the model from CompletionDates
+	DaysTarget      int                `json:"daysTarget" bson:"daysTarget"`
+	CompletionDates []string           `json:"completionDates" bson:"completionDates"`
+	Schedule        HabitSchedule      `json:"schedule" bson:"schedule"`
+	Unit            string             `json:"unit,omitempty" bson:"unit"`
+	DailyGoal       float64            `json:"dailyGoal,omitempty" bson:"dailyGoal"` // A habit with a DailyGoal is measurable and is completed through Measurements
+	Measurements    []HabitMeasurement `json:"measurements,omitempty" bson:"measurements"`
+	Revision        int64              `json:"revision" bson:"revision"` // Goes up on every write and is sent as the habit's ETag
+	Progress        HabitProgress      `json:"progress" bson:"-"`
+}
+
+// HabitMeasurement records how much of a measurable habit was done. A day can have several, which are added together
+type HabitMeasurement struct {
+	Date  string  `json:"date" bson:"Date"`
+	Value float64 `json:"value" bson:"Value"`
+}
+
+// HabitDayTotal is the total measured on a day
+type HabitDayTotal struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+const (
+	ScheduleDaily         = "daily"
+	ScheduleWeekdays      = "weekdays" // Only on the listed Weekdays
+	ScheduleTimesPerWeek  = "weekly"   // Times per Monday to Sunday week
+	ScheduleTimesPerMonth = "monthly"  // Times per calendar month
+	ScheduleEveryNDays    = "interval" // Once every Interval days
+)
+
+// HabitSchedule says when a habit is due. An empty Type is treated as daily so habits created before schedules existed keep working
+type HabitSchedule struct {
+	Type     string         `json:"type" bson:"Type"`
+	Weekdays []time.Weekday `json:"weekdays,omitempty" bson:"Weekdays,omitempty"` // 0 = Sunday ... 6 = Saturday
+	Times    int            `json:"times,omitempty" bson:"Times,omitempty"`
+	Interval int            `json:"interval,omitempty" bson:"Interval,omitempty"`
+}
+
+// HabitProgress is computed from CompletionDates and Measurements and is never stored
+type HabitProgress struct {
+	CurrentStreak    int             `json:"currentStreak"`
+	LongestStreak    int             `json:"longestStreak"`
+	TotalCompletions int             `json:"totalCompletions"`
+	PercentToTarget  float64         `json:"percentToTarget"`
+	StreakUnit       string          `json:"streakUnit"`            // day, week, month or interval depending on the schedule
+	CompletionRate   float64         `json:"completionRate"`        // Percentage of scheduled periods met since the habit started
+	TodayTotal       float64         `json:"todayTotal,omitempty"`  // Measurable habits only
+	PartialDays      []HabitDayTotal `json:"partialDays,omitempty"` // Measurable habits only - days with some progress that didn't meet the DailyGoal
+}
+
+type NewCompletion struct {
+	CompletionDate string   `json:"completionDate"` // Defaults to today when empty
+	Value          *float64 `json:"value"`          // Required for measurable habits, not allowed otherwise
 }
 
 type NewHabit struct {
-	Name       string `json:"name" bson:"name"`
-	DaysTarget int    `json:"daysTarget" bson:"daysTarget"`
+	Name       string        `json:"name" bson:"name"`
+	DaysTarget int           `json:"daysTarget" bson:"daysTarget"`
+	Schedule   HabitSchedule `json:"schedule" bson:"schedule"`
+	Unit       string        `json:"unit,omitempty" bson:"unit"`
+	DailyGoal  float64       `json:"dailyGoal,omitempty" bson:"dailyGoal"`
 }
 
 type NewHabitResponse struct {
-	HabitID         string   `json:"habitId" bson:"_id"`
-	Name            string   `json:"name" bson:"name"`
-	DaysTarget      int      `json:"daysTarget" bson:"daysTarget"`
-	CompletionDates []string `json:"completionDates" bson:"completionDates"`
+	HabitID         string        `json:"habitId" bson:"_id"`
+	Name            string        `json:"name" bson:"name"`
+	DaysTarget      int           `json:"daysTarget" bson:"daysTarget"`
+	CompletionDates []string      `json:"completionDates" bson:"completionDates"`
+	Schedule        HabitSchedule `json:"schedule" bson:"schedule"`
+	Unit            string        `json:"unit,omitempty" bson:"unit"`
+	DailyGoal       float64       `json:"dailyGoal,omitempty" bson:"dailyGoal"`
+	Revision        int64         `json:"revision" bson:"revision"`
 }
 
 type UpdateHabit struct {
-	HabitID         string    `json:"habitId" bson:"_id"`
-	Name            *string   `json:"name" bson:"name"`
-	Days            *int      `json:"days" bson:"days"`
-	DaysTarget      *int      `json:"daysTarget" bson:"daysTarget"`
-	CompletionDates *[]string `json:"completionDates" bson:"completionDates"`
+	HabitID         string         `json:"habitId" bson:"_id"`
+	Name            *string        `json:"name" bson:"name"`
+	Days            *int           `json:"days" bson:"days"` // Response only - ignored on requests as it is computed from CompletionDates
+	DaysTarget      *int           `json:"daysTarget" bson:"daysTarget"`
+	CompletionDates *[]string      `json:"completionDates" bson:"completionDates"`
+	Schedule        *HabitSchedule `json:"schedule,omitempty" bson:"schedule"`
+	Unit            *string        `json:"unit,omitempty" bson:"unit"`
+	DailyGoal       *float64       `json:"dailyGoal,omitempty" bson:"dailyGoal"`
+	Revision        *int64         `json:"revision,omitempty" bson:"revision"` // When set the update is only applied if the habit is still at this revision
 }
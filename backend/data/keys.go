@@ -0,0 +1,18 @@
+package data
+
+// JSONWebKey is the public half of a token signing key, ours or an identity provider's, as described by RFC 7517
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n,omitempty"`   // RSA
+	Exponent  string `json:"e,omitempty"`   // RSA
+	Curve     string `json:"crv,omitempty"` // EdDSA and EC
+	X         string `json:"x,omitempty"`   // EdDSA and EC
+	Y         string `json:"y,omitempty"`   // EC
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
@@ -4,4 +4,6 @@ type Middleware struct {
 	IsProtected  bool
 	CSRFRequired bool
 	HTTPMethod   string
+	Scope        string // The scope a personal access token needs to use the endpoint. Empty for endpoints that need a login.
+	Role         string // The role the user needs to use the endpoint, see HasRole. Empty for endpoints every user can use.
 }
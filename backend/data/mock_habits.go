@@ -11,6 +11,8 @@ var MockHabit = []Habit{
 		Days:            30,
 		DaysTarget:      66,
 		CompletionDates: []string{"2024-12-20", "2024-12-02", "2024-12-03"},
+		Schedule:        HabitSchedule{Type: ScheduleDaily},
+		Revision:        1,
 	},
 	{
 		HabitID:         "2",
@@ -20,6 +22,8 @@ var MockHabit = []Habit{
 		Days:            30,
 		DaysTarget:      66,
 		CompletionDates: []string{"2024-12-20", "2024-12-02", "2024-12-11"},
+		Schedule:        HabitSchedule{Type: ScheduleDaily},
+		Revision:        1,
 	},
 	{
 		HabitID:         "3",
@@ -29,6 +33,8 @@ var MockHabit = []Habit{
 		Days:            5,
 		DaysTarget:      365,
 		CompletionDates: []string{"2024-12-20", "2024-12-11", "2024-12-11"},
+		Schedule:        HabitSchedule{Type: ScheduleDaily},
+		Revision:        1,
 	},
 	{
 		HabitID:         "4",
@@ -38,6 +44,8 @@ var MockHabit = []Habit{
 		Days:            25,
 		DaysTarget:      30,
 		CompletionDates: []string{"2024-12-20", "2024-12-12", "2024-12-11"},
+		Schedule:        HabitSchedule{Type: ScheduleDaily},
+		Revision:        1,
 	},
 	{
 		HabitID:         "5",
@@ -47,6 +55,8 @@ var MockHabit = []Habit{
 		Days:            30,
 		DaysTarget:      30,
 		CompletionDates: []string{"2024-12-20", "2024-12-02", "2024-12-11"},
+		Schedule:        HabitSchedule{Type: ScheduleDaily},
+		Revision:        1,
 	},
 	{
 		HabitID:         "6",
@@ -56,5 +66,7 @@ var MockHabit = []Habit{
 		Days:            5,
 		DaysTarget:      60,
 		CompletionDates: []string{"2024-12-20", "2024-12-02", "2024-12-11"},
+		Schedule:        HabitSchedule{Type: ScheduleDaily},
+		Revision:        1,
 	},
 }
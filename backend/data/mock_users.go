@@ -5,41 +5,72 @@ import "time"
 // Hash the passwords - Hashed with "golang.org/x/crypto/bcrypt"
 var MockUsers = []UserData{
 	{
-		UserID:       "1",
-		Password:     "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
-		FirstName:    "John",
-		LastName:     "Doe",
-		EmailAddress: "johndoe1@example.com",
-		CreatedAt:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
-		LastLogin:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		UserID:        "1",
+		Password:      "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
+		FirstName:     "John",
+		LastName:      "Doe",
+		EmailVerified: true,
+		EmailAddress:  "johndoe1@example.com",
+		CreatedAt:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		LastLogin:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
 	},
 	{
-		UserID:       "2",
-		Password:     "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
-		FirstName:    "Jane",
-		LastName:     "Smith",
-		EmailAddress: "janesmith@example.com",
-		CreatedAt:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
-		LastLogin:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		UserID:        "2",
+		Password:      "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
+		FirstName:     "Jane",
+		LastName:      "Smith",
+		EmailVerified: true,
+		EmailAddress:  "janesmith@example.com",
+		CreatedAt:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		LastLogin:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
 	},
 	{
-		UserID:       "3",
-		Password:     "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
-		FirstName:    "Alice",
-		LastName:     "Johnson",
-		EmailAddress: "alicejohnson@example.com",
-		CreatedAt:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
-		LastLogin:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		UserID:        "3",
+		Password:      "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
+		FirstName:     "Alice",
+		LastName:      "Johnson",
+		EmailVerified: true,
+		EmailAddress:  "alicejohnson@example.com",
+		CreatedAt:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		LastLogin:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
 	},
 	{
+		UserID:        "4",
+		Password:      "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
+		FirstName:     "John",
+		LastName:      "LoggedIn",
+		EmailVerified: true,
+		EmailAddress:  "john.loggedin@example.com",
+		CreatedAt:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		LastLogin:     time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+	},
+}
+
+// john.loggedin@example.com is logged in on two devices
+var MockUserSession = []UserSession{
+	{
+		SessionID:    "mock-session-4a",
 		UserID:       "4",
-		Password:     "$2a$10$hLa8z.sjayeZNWNAcise5.VKvgkcftE8z0n/mze7O8zXJwOQ9M5tW", // 1secret?Password
-		FirstName:    "John",
-		LastName:     "LoggedIn",
-		EmailAddress: "john.loggedin@example.com",
-		CreatedAt:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
-		LastLogin:    time.Date(2024, time.October, 10, 9, 0, 0, 0, time.UTC),
+		RefreshToken: "testjwt",
+		UserAgent:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		IPAddress:    "203.0.113.10",
+		CreatedAt:    time.Now().Add(-2 * time.Hour),
+	},
+	{
+		SessionID:    "mock-session-4b",
+		UserID:       "4",
+		RefreshToken: "testjwt",
+		UserAgent:    "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+		IPAddress:    "198.51.100.7",
+		CreatedAt:    time.Now().Add(-time.Hour),
 	},
 }
 
-var MockUserSession = []UserSession{}
+// MockPasswordResets is empty until a password reset is requested
+var MockPasswordResets = []PasswordReset{}
+
+// MockLoginAttempts is empty until a login fails
+var MockLoginAttempts = []LoginAttempts{}
+
+// MockPersonalAccessTokens is empty until a personal access token is created
+var MockPersonalAccessTokens = []PersonalAccessToken{}
@@ -0,0 +1,56 @@
+package data
+
+import "time"
+
+// The scopes a personal access token can be granted. Each one has to be asked for, habits:write doesn't imply habits:read.
+const (
+	ScopeHabitsRead  = "habits:read"
+	ScopeHabitsWrite = "habits:write"
+)
+
+// PersonalAccessTokenScopes lists every scope, in the order they're shown
+var PersonalAccessTokenScopes = []string{ScopeHabitsRead, ScopeHabitsWrite}
+
+// PersonalAccessToken lets a script or integration act as its user without logging in. Only the hash of the token is stored.
+type PersonalAccessToken struct {
+	TokenID    string     `json:"TokenID" bson:"_id"`
+	UserID     string     `json:"UserID" bson:"UserID"`
+	Name       string     `json:"Name" bson:"Name"`
+	TokenHash  string     `json:"TokenHash" bson:"TokenHash"`
+	Scopes     []string   `json:"Scopes" bson:"Scopes"`
+	CreatedAt  time.Time  `json:"CreatedAt" bson:"CreatedAt"`
+	ExpiresAt  *time.Time `json:"ExpiresAt" bson:"ExpiresAt,omitempty"`   // nil if the token never expires
+	LastUsedAt *time.Time `json:"LastUsedAt" bson:"LastUsedAt,omitempty"` // nil until the token is first used
+}
+
+type CreatePersonalAccessTokenRequest struct {
+	Name          string   `json:"Name"`
+	Scopes        []string `json:"Scopes"`
+	ExpiresInDays int      `json:"ExpiresInDays"` // 0 for a token that never expires
+}
+
+// PersonalAccessTokenResponse is a token as listed to its user. Neither the token nor its hash is ever sent.
+type PersonalAccessTokenResponse struct {
+	TokenID    string     `json:"TokenID"`
+	Name       string     `json:"Name"`
+	Scopes     []string   `json:"Scopes"`
+	CreatedAt  time.Time  `json:"CreatedAt"`
+	ExpiresAt  *time.Time `json:"ExpiresAt"`
+	LastUsedAt *time.Time `json:"LastUsedAt"`
+}
+
+// CreatePersonalAccessTokenResponse is the only time the token itself is sent, so the user has to copy it then
+type CreatePersonalAccessTokenResponse struct {
+	Success             bool                        `json:"Success"`
+	Token               string                      `json:"Token"`
+	PersonalAccessToken PersonalAccessTokenResponse `json:"PersonalAccessToken"`
+}
+
+type PersonalAccessTokensResponse struct {
+	Success              bool                          `json:"Success"`
+	PersonalAccessTokens []PersonalAccessTokenResponse `json:"PersonalAccessTokens"`
+}
+
+type DeletePersonalAccessTokenResponse struct {
+	Success bool `json:"Success"`
+}
@@ -1,6 +1,32 @@
 package data
 
-import "time"
+import (
+	"slices"
+	"time"
+)
+
+// DefaultTimezone is used for users who registered without a timezone
+const DefaultTimezone = "UTC"
+
+// UnverifiedUserAccess is what a user can do before they've verified their email address, set with UNVERIFIED_USER_ACCESS
+type UnverifiedUserAccess string
+
+const (
+	UnverifiedUserAccessAll   UnverifiedUserAccess = "all"   // Everything, as before email addresses were verified
+	UnverifiedUserAccessLogin UnverifiedUserAccess = "login" // Log in and use their habits, but not create any
+	UnverifiedUserAccessNone  UnverifiedUserAccess = "none"  // Nothing, they can't log in
+)
+
+// The roles a user can be given on top of what every user can do. Roles are granted in the database, see the README.
+const (
+	RoleSupport = "support" // Look up users and their habits, and log them out of every session
+	RoleAdmin   = "admin"   // Everything support can do, and disable and enable accounts
+)
+
+// HasRole reports whether roles allow role. The admin role allows every role.
+func HasRole(roles []string, role string) bool {
+	return slices.Contains(roles, role) || slices.Contains(roles, RoleAdmin)
+}
 
 type UserAuth struct {
 	UserID       string `json:"UserID"`
@@ -9,7 +35,15 @@ type UserAuth struct {
 }
 
 type UserRefreshRequest struct {
+	RefreshToken string `json:"-"` // Read from the refresh token cookie
+}
+
+type UserRefreshData struct {
+	Success      bool   `json:"Success"`
 	EmailAddress string `json:"EmailAddress"`
+	AccessToken  string `json:"AccessToken"`
+	RefreshToken string `json:"-"` // Replaces the refresh token that was exchanged, which can't be used again
+	CSRFToken    string `json:"CSRFToken"`
 }
 
 type UserRefreshResponse struct {
@@ -21,6 +55,7 @@ type RegisterUserRequest struct {
 	Password     string `json:"Password"`
 	FirstName    string `json:"FirstName"`
 	LastName     string `json:"LastName"`
+	Timezone     string `json:"Timezone"` // IANA name, e.g. Europe/London. Defaults to DefaultTimezone when empty
 }
 
 type RegisterUserData struct {
@@ -34,44 +69,234 @@ type RegisterUserResponse struct {
 }
 
 type UserDataResponse struct {
-	FirstName    string    `json:"FirstName"`
-	LastName     string    `json:"LastName"`
-	EmailAddress string    `json:"EmailAddress"`
-	CreatedAt    time.Time `json:"CreatedAt"`
+	FirstName     string    `json:"FirstName"`
+	LastName      string    `json:"LastName"`
+	EmailAddress  string    `json:"EmailAddress"`
+	Timezone      string    `json:"Timezone"`
+	EmailVerified bool      `json:"EmailVerified"`
+	MFAEnabled    bool      `json:"MFAEnabled"`
+	Roles         []string  `json:"Roles"`
+	CreatedAt     time.Time `json:"CreatedAt"`
+}
+
+// UpdateProfileRequest changes the fields that are set. The email address is changed with ChangeEmailRequest, as it has to be verified.
+type UpdateProfileRequest struct {
+	FirstName *string `json:"FirstName"`
+	LastName  *string `json:"LastName"`
+	Timezone  *string `json:"Timezone"`
+}
+
+type UpdateProfileResponse struct {
+	Success bool             `json:"Success"`
+	User    UserDataResponse `json:"User"`
 }
 
 type UserLoggedInData struct {
-	Success     bool      `json:"Success"`
-	User        UserData  `json:"User"`
-	AccessToken string    `json:"AccessToken"`
-	LoggedInAt  time.Time `json:"LoggedInAt"`
+	Success      bool      `json:"Success"`
+	User         UserData  `json:"User"`
+	AccessToken  string    `json:"AccessToken"`
+	RefreshToken string    `json:"RefreshToken"`
+	SessionID    string    `json:"SessionID"`
+	LoggedInAt   time.Time `json:"LoggedInAt"`
+	MFAToken     string    `json:"MFAToken"` // Set instead of the session when the password is right but a second factor is still needed
+	MFAExpiresAt time.Time `json:"MFAExpiresAt"`
 }
 
 type UserLoggedInResponse struct {
 	Success    bool             `json:"Success"`
 	User       UserDataResponse `json:"User"`
+	SessionID  string           `json:"SessionID"`
 	LoggedInAt time.Time        `json:"LoggedInAt"`
 }
 
 type UserLoggedOutRequest struct {
-	EmailAddress string `json:"EmailAddress"`
+	UserID       string `json:"UserID"`
+	SessionID    string `json:"SessionID"`
+	RefreshToken string `json:"-"` // Read from the refresh token cookie, it has to be the one stored with the session
+}
+
+// ClientInfo describes the client a session was created from, as seen in the login request
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
 }
 
 type UserData struct {
-	UserID       string    `json:"UserID"`
-	Password     string    `json:"Password" bson:"Password"`
-	FirstName    string    `json:"FirstName" bson:"FirstName"`
-	LastName     string    `json:"LastName" bson:"LastName"`
-	EmailAddress string    `json:"EmailAddress" bson:"EmailAddress"`
-	CreatedAt    time.Time `json:"CreatedAt" bson:"CreatedAt"`
-	LastLogin    time.Time `json:"LastLogin" bson:"LastLogin"`
+	UserID        string    `json:"UserID"`
+	Password      string    `json:"Password" bson:"Password"`
+	FirstName     string    `json:"FirstName" bson:"FirstName"`
+	LastName      string    `json:"LastName" bson:"LastName"`
+	EmailAddress  string    `json:"EmailAddress" bson:"EmailAddress"`
+	Timezone      string    `json:"Timezone" bson:"Timezone"`           // IANA name used to work out the user's "today"
+	EmailVerified bool      `json:"EmailVerified" bson:"EmailVerified"` // Set once the user opens the link emailed to them
+	CreatedAt     time.Time `json:"CreatedAt" bson:"CreatedAt"`
+	LastLogin     time.Time `json:"LastLogin" bson:"LastLogin"`
+	TOTP          UserTOTP  `json:"-" bson:",inline"`
+	Roles         []string  `json:"Roles" bson:"Roles"`       // See RoleSupport and RoleAdmin
+	Disabled      bool      `json:"Disabled" bson:"Disabled"` // Set by an admin to stop the user logging in
 	// IsLoggedIn   bool      `json:"IsLoggedIn" bson:"IsLoggedIn"`
 }
 
+// UserSession is one logged in client. A user has a session for every device or browser they've logged in on.
 type UserSession struct {
-	UserID       string    `json:"UserID"`
+	SessionID    string    `json:"SessionID" bson:"_id"`
+	UserID       string    `json:"UserID" bson:"UserID"`
 	RefreshToken string    `json:"RefreshToken" bson:"RefreshToken"`
-	Device       string    `json:"Device" bson:"Device"`
+	UserAgent    string    `json:"UserAgent" bson:"UserAgent"`
 	IPAddress    string    `json:"IpAddress" bson:"IpAddress"`
 	CreatedAt    time.Time `json:"CreatedAt" bson:"CreatedAt"`
 }
+
+// UserSessionResponse is a session as listed to its user. The refresh token is never sent.
+type UserSessionResponse struct {
+	SessionID string    `json:"SessionID"`
+	UserAgent string    `json:"UserAgent"`
+	IPAddress string    `json:"IpAddress"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	Current   bool      `json:"Current"` // The session the request was made with
+}
+
+type UserSessionsResponse struct {
+	Success  bool                  `json:"Success"`
+	Sessions []UserSessionResponse `json:"Sessions"`
+}
+
+type DeleteSessionsResponse struct {
+	Success bool  `json:"Success"`
+	Revoked int64 `json:"Revoked"`
+}
+
+// PasswordReset is an outstanding password reset. Only the hash of the token emailed to the user is stored.
+type PasswordReset struct {
+	TokenHash string    `json:"TokenHash" bson:"_id"`
+	UserID    string    `json:"UserID" bson:"UserID"`
+	CreatedAt time.Time `json:"CreatedAt" bson:"CreatedAt"`
+}
+
+/*
+LoginAttempts counts the failed logins in a row against an account or a client IP address, identified by Key. Failures starts
+again once the last failure is long enough ago, and no one can log in with the key until LockedUntil.
+*/
+type LoginAttempts struct {
+	Key           string    `json:"Key" bson:"_id"`
+	Failures      int       `json:"Failures" bson:"Failures"`
+	LastFailureAt time.Time `json:"LastFailureAt" bson:"LastFailureAt"`
+	LockedUntil   time.Time `json:"LockedUntil" bson:"LockedUntil"`
+}
+
+type ForgotPasswordRequest struct {
+	EmailAddress string `json:"EmailAddress"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"Token"`
+	NewPassword string `json:"NewPassword"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"CurrentPassword"`
+	NewPassword     string `json:"NewPassword"`
+}
+
+type PasswordResponse struct {
+	Success bool `json:"Success"`
+}
+
+type ChangePasswordResponse struct {
+	Success bool  `json:"Success"`
+	Revoked int64 `json:"Revoked"` // How many of the user's other sessions were logged out
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"Token"`
+}
+
+type ResendVerificationEmailRequest struct {
+	EmailAddress string `json:"EmailAddress"`
+}
+
+type EmailVerificationResponse struct {
+	Success bool `json:"Success"`
+}
+
+// ChangeEmailRequest asks for a link to be sent to the new address. The address only changes once the link is opened.
+type ChangeEmailRequest struct {
+	EmailAddress string `json:"EmailAddress"`
+	Password     string `json:"Password"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"Password"`
+}
+
+type DeleteAccountResponse struct {
+	Success bool `json:"Success"`
+}
+
+// UserTOTP is the user's authenticator app enrolment. A zero UserTOTP means they haven't started one.
+type UserTOTP struct {
+	Secret        string   `bson:"TOTPSecret"`        // Base32, shared with the authenticator app
+	Enabled       bool     `bson:"TOTPEnabled"`       // False until the enrolment is confirmed with a code
+	RecoveryCodes []string `bson:"TOTPRecoveryCodes"` // SHA-256 hashes of the unused recovery codes
+	LastUsedStep  int64    `bson:"TOTPLastUsedStep"`  // The time step of the last code accepted, so a code can't be used twice
+}
+
+// MFARequiredResponse is the login response when the user has two-factor authentication enabled. No session has been started yet.
+type MFARequiredResponse struct {
+	Success     bool      `json:"Success"`
+	MFARequired bool      `json:"MFARequired"`
+	MFAToken    string    `json:"MFAToken"`
+	ExpiresAt   time.Time `json:"ExpiresAt"`
+}
+
+// MFALoginRequest completes a login with either a code from the authenticator app or a recovery code
+type MFALoginRequest struct {
+	MFAToken     string `json:"MFAToken"`
+	Code         string `json:"Code"`
+	RecoveryCode string `json:"RecoveryCode"`
+}
+
+type TOTPEnrolment struct {
+	Secret string
+	URI    string
+}
+
+type EnrolTOTPResponse struct {
+	Success bool   `json:"Success"`
+	Secret  string `json:"Secret"`
+	URI     string `json:"URI"` // otpauth:// URI to show as a QR code
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"Code"`
+}
+
+type ConfirmTOTPResponse struct {
+	Success       bool     `json:"Success"`
+	RecoveryCodes []string `json:"RecoveryCodes"` // Only ever shown here, they're stored hashed
+}
+
+type DisableTOTPRequest struct {
+	Password     string `json:"Password"`
+	Code         string `json:"Code"`
+	RecoveryCode string `json:"RecoveryCode"`
+}
+
+type MFAResponse struct {
+	Success bool `json:"Success"`
+}
+
+// OIDCLoginStart is where to send the user to sign in with an identity provider, and the state token that has to come back with them
+type OIDCLoginStart struct {
+	AuthorizationURL string
+	StateToken       string
+	ExpiresAt        time.Time
+}
+
+// OIDCLoginRequest completes a sign in with an identity provider with the code and state it sent the user back to the site with
+type OIDCLoginRequest struct {
+	Provider   string `json:"-"` // Read from the path
+	Code       string `json:"Code"`
+	State      string `json:"State"`
+	StateToken string `json:"-"` // Read from the OIDC state cookie set when the sign in started
+}
@@ -1,24 +1,186 @@
 package db
 
 import (
+	"context"
+	"dohabits/apperror"
 	"dohabits/data"
 	"dohabits/logger"
+	"net/http"
+	"time"
 )
 
+// SessionTTL is how long a session lasts after login, matching the lifetime of its refresh token
+const SessionTTL = 24 * time.Hour
+
+// PasswordResetTTL is how long the link in a password reset email can be used for
+const PasswordResetTTL = time.Hour
+
+var (
+	ErrUserNotFound    = apperror.New(http.StatusNotFound, apperror.CodeUserNotFound, "User doesn't exist")
+	ErrUserExists      = apperror.New(http.StatusConflict, apperror.CodeEmailTaken, "A user with this email address already exists")
+	ErrSessionNotFound = apperror.New(http.StatusUnauthorized, apperror.CodeSessionNotFound, "User session doesn't exist")
+	ErrHabitNotFound   = apperror.New(http.StatusNotFound, apperror.CodeHabitNotFound, "Habit doesn't exist")
+
+	ErrCompletionExists   = apperror.New(http.StatusConflict, apperror.CodeCompletionExists, "Habit is already completed on this date")
+	ErrCompletionNotFound = apperror.New(http.StatusNotFound, apperror.CodeCompletionNotFound, "Habit isn't completed on this date")
+
+	ErrRevisionMismatch = apperror.New(http.StatusPreconditionFailed, apperror.CodeRevisionMismatch, "Habit has changed since it was retrieved")
+
+	ErrPasswordResetNotFound = apperror.New(http.StatusBadRequest, apperror.CodeInvalidResetToken, "The password reset link is invalid or has expired")
+
+	ErrTOTPStepUsed         = apperror.New(http.StatusUnauthorized, apperror.CodeInvalidMFACode, "The code has already been used, wait for the next one")
+	ErrRecoveryCodeNotFound = apperror.New(http.StatusUnauthorized, apperror.CodeInvalidMFACode, "The recovery code is wrong or has already been used")
+
+	ErrPersonalAccessTokenNotFound = apperror.New(http.StatusNotFound, apperror.CodeAccessTokenNotFound, "Personal access token doesn't exist")
+)
+
+type UserRepository interface {
+	RegisterUserHandler(ctx context.Context, newUser *data.RegisterUserRequest) (*data.UserData, error)
+	RetrieveUserDetails(ctx context.Context, emailAddress string) (*data.UserData, error)
+	// RetrieveUserDetailsByID returns the user with userID, returning ErrUserNotFound if there isn't one
+	RetrieveUserDetailsByID(ctx context.Context, userID string) (*data.UserData, error)
+	UpdateUserTimezone(ctx context.Context, userID, timezone string) error
+	// UpdateUserName replaces the user's first and last name, returning ErrUserNotFound if the user doesn't exist
+	UpdateUserName(ctx context.Context, userID, firstName, lastName string) error
+	/*
+		UpdateUserEmailAddress moves the user to emailAddress and marks it as verified, as it's only changed once the user has opened
+		the link sent to it. Returns ErrUserNotFound if the user doesn't exist.
+	*/
+	UpdateUserEmailAddress(ctx context.Context, userID, emailAddress string) error
+	/*
+		DeleteUser deletes the user and everything stored for them: their habits, sessions, personal access tokens and password resets.
+		The user is deleted last, so if it fails part way the user can try again. Returns ErrUserNotFound if the user doesn't exist.
+	*/
+	DeleteUser(ctx context.Context, userID string) error
+	// UpdateUserPassword replaces the user's password hash, returning ErrUserNotFound if the user doesn't exist
+	UpdateUserPassword(ctx context.Context, userID, hashedPassword string) error
+	// UpdateUserEmailVerified records whether the user has verified their email address, returning ErrUserNotFound if the user doesn't exist
+	UpdateUserEmailVerified(ctx context.Context, userID string, emailVerified bool) error
+	// UpdateUserDisabled records whether an admin has disabled the user, returning ErrUserNotFound if the user doesn't exist
+	UpdateUserDisabled(ctx context.Context, userID string, disabled bool) error
+	/*
+		SearchUsers returns up to limit users whose email address, first name or last name contains query regardless of case, oldest
+		first, skipping the first offset of them. An empty query matches every user.
+	*/
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]data.UserData, error)
+	// UpdateUserTOTP replaces the user's TOTP enrolment, a zero UserTOTP removes it. Returns ErrUserNotFound if the user doesn't exist
+	UpdateUserTOTP(ctx context.Context, userID string, userTOTP data.UserTOTP) error
+	/*
+		ConsumeTOTPStep records step as the time step the user's last code was accepted from, returning ErrTOTPStepUsed unless it's
+		later than the one recorded. Of two requests using the same code, only one succeeds.
+	*/
+	ConsumeTOTPStep(ctx context.Context, userID string, step int64) error
+	/*
+		ConsumeRecoveryCode removes the user's recovery code with codeHash, returning ErrRecoveryCodeNotFound if they don't have it.
+		Of two requests using the same recovery code, only one succeeds.
+	*/
+	ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) error
+}
+
+/*
+SessionRepository stores a session per logged in client, so a user can have many at once. Each is identified by its SessionID
+and removed SessionTTL after it was created. Expired sessions are never returned.
+*/
+type SessionRepository interface {
+	// LoginUser adds userSession and records the login on the user
+	LoginUser(ctx context.Context, userSession *data.UserSession) error
+	// LogoutUser deletes a single session, returning ErrSessionNotFound if the user doesn't have it
+	LogoutUser(ctx context.Context, userID, sessionID string) error
+	RetrieveUserSession(ctx context.Context, userID, sessionID string) (*data.UserSession, error)
+	/*
+		RotateRefreshToken replaces the session's refresh token with newRefreshToken, but only while it's still oldRefreshToken,
+		returning ErrSessionNotFound otherwise. Of two requests rotating the same token, only one succeeds.
+	*/
+	RotateRefreshToken(ctx context.Context, userID, sessionID, oldRefreshToken, newRefreshToken string) error
+	// RetrieveUserSessions returns the user's sessions, oldest first
+	RetrieveUserSessions(ctx context.Context, userID string) ([]data.UserSession, error)
+	// DeleteUserSessions deletes every session the user has apart from exceptSessionID, when set, and returns how many it deleted
+	DeleteUserSessions(ctx context.Context, userID, exceptSessionID string) (int64, error)
+}
+
+/*
+PasswordResetRepository stores outstanding password resets by the hash of their token. A reset is removed PasswordResetTTL
+after it was created, and a user only ever has their latest one.
+*/
+type PasswordResetRepository interface {
+	// CreatePasswordReset adds passwordReset, removing any reset the user requested before it
+	CreatePasswordReset(ctx context.Context, passwordReset *data.PasswordReset) error
+	/*
+		ConsumePasswordReset removes and returns the unexpired reset with tokenHash, returning ErrPasswordResetNotFound if there
+		isn't one. Of two requests consuming the same token, only one succeeds.
+	*/
+	ConsumePasswordReset(ctx context.Context, tokenHash string) (*data.PasswordReset, error)
+}
+
+/*
+LoginAttemptRepository stores the failed logins counted against each account and client IP address by their key, so the
+loginattempts package can share them between instances of the app. A key's record is removed once it's neither locked nor
+has failed recently.
+*/
+type LoginAttemptRepository interface {
+	// RetrieveLoginAttempts returns key's failed logins, with no Failures if it has none
+	RetrieveLoginAttempts(ctx context.Context, key string) (*data.LoginAttempts, error)
+	/*
+		RecordLoginFailure adds a failed login at now to key's count and returns the updated count. If the last failure was resetAfter
+		or longer before now, the count starts again from 1. Of two failures recorded at once, both are counted.
+	*/
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*data.LoginAttempts, error)
+	// LockLoginAttempts stops key being used to log in until lockedUntil
+	LockLoginAttempts(ctx context.Context, key string, lockedUntil time.Time) error
+	// DeleteLoginAttempts forgets key's failed logins and unlocks it
+	DeleteLoginAttempts(ctx context.Context, key string) error
+}
+
+/*
+PersonalAccessTokenRepository stores personal access tokens by the hash of the token, so a token can be looked up when it's used
+but can't be recovered by whoever reads the database. Expired tokens are never returned.
+*/
+type PersonalAccessTokenRepository interface {
+	CreatePersonalAccessToken(ctx context.Context, personalAccessToken *data.PersonalAccessToken) error
+	// RetrievePersonalAccessTokens returns the user's tokens, oldest first
+	RetrievePersonalAccessTokens(ctx context.Context, userID string) ([]data.PersonalAccessToken, error)
+	// RetrievePersonalAccessTokenByHash returns the token with tokenHash, returning ErrPersonalAccessTokenNotFound if there isn't one
+	RetrievePersonalAccessTokenByHash(ctx context.Context, tokenHash string) (*data.PersonalAccessToken, error)
+	// UpdatePersonalAccessTokenLastUsed records when the token was last used, returning ErrPersonalAccessTokenNotFound if it doesn't exist
+	UpdatePersonalAccessTokenLastUsed(ctx context.Context, tokenID string, lastUsedAt time.Time) error
+	// DeletePersonalAccessToken revokes one of the user's tokens, returning ErrPersonalAccessTokenNotFound if they don't have it
+	DeletePersonalAccessToken(ctx context.Context, userID, tokenID string) error
+}
+
+type HabitRepository interface {
+	CreateHabitsHandler(ctx context.Context, userID string, newHabit data.NewHabit) (*data.NewHabitResponse, error)
+	RetrieveAllHabitsHandler(ctx context.Context, userID string) ([]data.Habit, error)
+	/*
+		StreamAllHabitsHandler calls fn with each of the user's habits in turn, so they aren't all held in memory. It stops at the first
+		error fn returns. Unlike the other methods it has no timeout of its own, as fn may be writing to a slow client, so it runs for as
+		long as ctx allows.
+	*/
+	StreamAllHabitsHandler(ctx context.Context, userID string, fn func(data.Habit) error) error
+	RetrieveHabitsHandler(ctx context.Context, userID, habitID string) (data.Habit, error)
+	/*
+		UpdateHabitsHandler, UpdateAllHabitsHandler and DeleteHabitsHandler only write a habit that is still at the Revision it was
+		retrieved with, returning ErrRevisionMismatch otherwise. Every write to a habit, completions included, increments its Revision.
+	*/
+	UpdateHabitsHandler(ctx context.Context, userID, habitID string, habit data.Habit) error
+	UpdateAllHabitsHandler(ctx context.Context, userID string, habits []data.Habit) error
+	DeleteHabitsHandler(ctx context.Context, userID, habitID string, revision int64) error
+	// CreateCompletionHandler atomically adds a single completion date and returns the updated habit
+	CreateCompletionHandler(ctx context.Context, userID, habitID, completionDate string) (data.Habit, error)
+	// CreateMeasurementHandler atomically appends a measurement to a measurable habit and returns the updated habit
+	CreateMeasurementHandler(ctx context.Context, userID, habitID string, measurement data.HabitMeasurement) (data.Habit, error)
+	// DeleteCompletionHandler atomically removes a completion date, along with any measurements on it, and returns the updated habit
+	DeleteCompletionHandler(ctx context.Context, userID, habitID, completionDate string) (data.Habit, error)
+}
+
 type IDB interface {
 	Connect() error
 	Disconnect() error
-	RegisterUserHandler(value interface{}) (interface{}, error)
-	LoginUser(value interface{}) error
-	LogoutUser(value interface{}) error
-	RetrieveUserSession(value interface{}, userID string) (string, error)
-	RetrieveUserDetails(value interface{}) (interface{}, error)
-	CreateHabitsHandler(userId string, value interface{}) (*data.NewHabitResponse, error)
-	RetrieveAllHabitsHandler(userId string) (interface{}, error)
-	RetrieveHabitsHandler(userId, habitId string) (interface{}, error)
-	UpdateHabitsHandler(userId, habitId string, value interface{}) error
-	UpdateAllHabitsHandler(userId string, value interface{}) error
-	DeleteHabitsHandler(userId, habitId string) error
+	UserRepository
+	SessionRepository
+	PasswordResetRepository
+	LoginAttemptRepository
+	PersonalAccessTokenRepository
+	HabitRepository
 }
 
 func NewDB(algorithm string, logger logger.ILogger) IDB {
@@ -27,6 +189,8 @@ func NewDB(algorithm string, logger logger.ILogger) IDB {
 		return NewMockDB(logger)
 	case "mongodb":
 		return NewMongoDB(logger)
+	case "sqlite":
+		return NewSQLiteDB(logger)
 	default:
 		return NewMockDB(logger) // Default to MockDB if unspecified.
 	}
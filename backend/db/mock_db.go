@@ -1,20 +1,27 @@
 package db
 
 import (
+	"context"
 	"dohabits/data"
 	"dohabits/helper"
 	"dohabits/logger"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Enforce interface compliance
 var _ IDB = (*MyMockDB)(nil)
 
-var refreshTokenPath = "data/mock_refresh_tokens"
-var refreshTokenFile = "mock_refresh_token.txt"
+/*
+mockMx guards data.MockUsers, data.MockUserSession, data.MockPasswordResets, data.MockLoginAttempts, data.MockPersonalAccessTokens
+and data.MockHabit, which are shared by every MyMockDB
+*/
+var mockMx sync.RWMutex
 
 type MyMockDB struct {
 	logger logger.ILogger
@@ -38,13 +45,14 @@ func (db *MyMockDB) Disconnect() error {
 	return nil
 }
 
-func (db *MyMockDB) RegisterUserHandler(value interface{}) (interface{}, error) {
+func (db *MyMockDB) RegisterUserHandler(ctx context.Context, newUser *data.RegisterUserRequest) (*data.UserData, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	newUser, ok := value.(*data.RegisterUserRequest)
+	mockMx.Lock()
+	defer mockMx.Unlock()
 
-	if !ok {
-		return nil, fmt.Errorf("%s - value type is not data.UserData", helper.GetFunctionName())
+	if slices.ContainsFunc(data.MockUsers, func(val data.UserData) bool { return val.EmailAddress == newUser.EmailAddress }) {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserExists)
 	}
 
 	latestUserID, err := strconv.Atoi(data.MockUsers[len(data.MockUsers)-1].UserID)
@@ -59,6 +67,7 @@ func (db *MyMockDB) RegisterUserHandler(value interface{}) (interface{}, error)
 		LastName:     newUser.LastName,
 		Password:     newUser.Password,
 		EmailAddress: newUser.EmailAddress,
+		Timezone:     newUser.Timezone,
 		CreatedAt:    time.Now(),
 	}
 
@@ -67,24 +76,20 @@ func (db *MyMockDB) RegisterUserHandler(value interface{}) (interface{}, error)
 	return &registerUser, err
 }
 
-func (db *MyMockDB) LoginUser(value interface{}) error {
+func (db *MyMockDB) LoginUser(ctx context.Context, userSession *data.UserSession) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	userSession, ok := value.(*data.UserSession)
+	mockMx.Lock()
+	defer mockMx.Unlock()
 
-	if !ok {
-		return fmt.Errorf("%s - value type is not data.UserSession", helper.GetFunctionName())
+	if userSession.CreatedAt.IsZero() {
+		userSession.CreatedAt = time.Now()
 	}
 
 	data.MockUserSession = append(data.MockUserSession, *userSession)
 
 	for i, val := range data.MockUsers {
 		if val.UserID == userSession.UserID {
-
-			err := os.WriteFile(fmt.Sprintf("../%s/%s_%s", refreshTokenPath, val.EmailAddress, refreshTokenFile), []byte(userSession.RefreshToken), 0644)
-			if err != nil {
-				return fmt.Errorf("%s - Failed to store the Refresh Token", helper.GetFunctionName())
-			}
 			data.MockUsers[i].LastLogin = userSession.CreatedAt
 		}
 	}
@@ -92,88 +97,547 @@ func (db *MyMockDB) LoginUser(value interface{}) error {
 	return nil
 }
 
-func (db *MyMockDB) LogoutUser(value interface{}) error {
+func (db *MyMockDB) LogoutUser(ctx context.Context, userID, sessionID string) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
-	userLoggedOut, ok := value.(*data.UserData)
 
-	if !ok {
-		return fmt.Errorf("%s - value type is not data.UserLoggedOutRequest", helper.GetFunctionName())
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUserSession {
+		if val.UserID == userID && val.SessionID == sessionID && isLiveMockSession(val) {
+			data.MockUserSession = slices.Delete(data.MockUserSession, i, i+1)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+}
+
+func (db *MyMockDB) RetrieveUserSession(ctx context.Context, userID, sessionID string) (*data.UserSession, error) {
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	for _, val := range data.MockUserSession {
+		if val.UserID == userID && val.SessionID == sessionID && isLiveMockSession(val) {
+			userSession := val
+			return &userSession, nil
+		}
 	}
 
-	// Remove user session from struct
+	return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+}
+
+func (db *MyMockDB) RotateRefreshToken(ctx context.Context, userID, sessionID, oldRefreshToken, newRefreshToken string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
 	for i, val := range data.MockUserSession {
-		if val.UserID == userLoggedOut.UserID {
-			data.MockUserSession = append(data.MockUserSession[:i], data.MockUserSession[i+1:]...)
+		if val.UserID == userID && val.SessionID == sessionID && val.RefreshToken == oldRefreshToken && isLiveMockSession(val) {
+			data.MockUserSession[i].RefreshToken = newRefreshToken
+			return nil
 		}
 	}
 
-	for i, val := range data.MockUsers {
-		if val.UserID == userLoggedOut.UserID {
-			if err := os.Remove(fmt.Sprintf("../%s/%s_%s", refreshTokenPath, data.MockUsers[i].EmailAddress, refreshTokenFile)); err != nil {
-				return err
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+}
+
+func (db *MyMockDB) RetrieveUserSessions(ctx context.Context, userID string) ([]data.UserSession, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	userSessions := []data.UserSession{}
+
+	for _, val := range data.MockUserSession {
+		if val.UserID == userID && isLiveMockSession(val) {
+			userSessions = append(userSessions, val)
+		}
+	}
+
+	slices.SortStableFunc(userSessions, func(a, b data.UserSession) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return userSessions, nil
+}
+
+func (db *MyMockDB) DeleteUserSessions(ctx context.Context, userID, exceptSessionID string) (int64, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	var deleted int64
+
+	data.MockUserSession = slices.DeleteFunc(data.MockUserSession, func(val data.UserSession) bool {
+		if val.UserID != userID || (exceptSessionID != "" && val.SessionID == exceptSessionID) {
+			return false
+		}
+
+		if isLiveMockSession(val) {
+			deleted++
+		}
+
+		return true
+	})
+
+	return deleted, nil
+}
+
+// isLiveMockSession stands in for the TTL index on the MongoDB user_session collection
+func isLiveMockSession(userSession data.UserSession) bool {
+	return time.Since(userSession.CreatedAt) < SessionTTL
+}
+
+func (db *MyMockDB) CreatePasswordReset(ctx context.Context, passwordReset *data.PasswordReset) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", passwordReset.UserID))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	if passwordReset.CreatedAt.IsZero() {
+		passwordReset.CreatedAt = time.Now()
+	}
+
+	data.MockPasswordResets = slices.DeleteFunc(data.MockPasswordResets, func(val data.PasswordReset) bool {
+		return val.UserID == passwordReset.UserID
+	})
+
+	data.MockPasswordResets = append(data.MockPasswordResets, *passwordReset)
+
+	return nil
+}
+
+func (db *MyMockDB) ConsumePasswordReset(ctx context.Context, tokenHash string) (*data.PasswordReset, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockPasswordResets {
+		if val.TokenHash == tokenHash {
+			data.MockPasswordResets = slices.Delete(data.MockPasswordResets, i, i+1)
+
+			if time.Since(val.CreatedAt) >= PasswordResetTTL {
+				break
 			}
+
+			return &val, nil
 		}
 	}
 
+	return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPasswordResetNotFound)
+}
+
+func (db *MyMockDB) RetrieveLoginAttempts(ctx context.Context, key string) (*data.LoginAttempts, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	for _, val := range data.MockLoginAttempts {
+		if val.Key == key {
+			return &val, nil
+		}
+	}
+
+	return &data.LoginAttempts{Key: key}, nil
+}
+
+func (db *MyMockDB) RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*data.LoginAttempts, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockLoginAttempts {
+		if val.Key != key {
+			continue
+		}
+
+		if now.Sub(val.LastFailureAt) >= resetAfter {
+			val.Failures = 0
+		}
+
+		val.Failures++
+		val.LastFailureAt = now
+		data.MockLoginAttempts[i] = val
+
+		return &val, nil
+	}
+
+	loginAttempts := data.LoginAttempts{Key: key, Failures: 1, LastFailureAt: now}
+	data.MockLoginAttempts = append(data.MockLoginAttempts, loginAttempts)
+
+	return &loginAttempts, nil
+}
+
+func (db *MyMockDB) LockLoginAttempts(ctx context.Context, key string, lockedUntil time.Time) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockLoginAttempts {
+		if val.Key == key {
+			data.MockLoginAttempts[i].LockedUntil = lockedUntil
+			return nil
+		}
+	}
+
+	data.MockLoginAttempts = append(data.MockLoginAttempts, data.LoginAttempts{Key: key, LockedUntil: lockedUntil})
+
+	return nil
+}
+
+func (db *MyMockDB) DeleteLoginAttempts(ctx context.Context, key string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	data.MockLoginAttempts = slices.DeleteFunc(data.MockLoginAttempts, func(val data.LoginAttempts) bool {
+		return val.Key == key
+	})
+
 	return nil
 }
 
-func (db *MyMockDB) RetrieveUserSession(value interface{}, userID string) (string, error) {
-	username, ok := value.(string)
+func (db *MyMockDB) CreatePersonalAccessToken(ctx context.Context, personalAccessToken *data.PersonalAccessToken) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", personalAccessToken.UserID))
 
-	if !ok {
-		return "", fmt.Errorf("%s - value type is not data.MockRefreshJWT", helper.GetFunctionName())
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	if personalAccessToken.CreatedAt.IsZero() {
+		personalAccessToken.CreatedAt = time.Now()
 	}
 
-	refreshToken, err := os.ReadFile(fmt.Sprintf("../%s/%s_%s", refreshTokenPath, username, refreshTokenFile))
+	data.MockPersonalAccessTokens = append(data.MockPersonalAccessTokens, *personalAccessToken)
 
-	return string(refreshToken), err
+	return nil
 }
 
-func (db *MyMockDB) RetrieveUserDetails(value interface{}) (interface{}, error) {
+func (db *MyMockDB) RetrievePersonalAccessTokens(ctx context.Context, userID string) ([]data.PersonalAccessToken, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	personalAccessTokens := []data.PersonalAccessToken{}
+
+	for _, val := range data.MockPersonalAccessTokens {
+		if val.UserID == userID && isLiveMockPersonalAccessToken(val) {
+			personalAccessTokens = append(personalAccessTokens, val)
+		}
+	}
+
+	slices.SortStableFunc(personalAccessTokens, func(a, b data.PersonalAccessToken) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return personalAccessTokens, nil
+}
+
+func (db *MyMockDB) RetrievePersonalAccessTokenByHash(ctx context.Context, tokenHash string) (*data.PersonalAccessToken, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	if userAuth, ok := value.(*data.RegisterUserRequest); ok {
-		for _, val := range data.MockUsers {
-			if val.EmailAddress == userAuth.EmailAddress {
-				return nil, fmt.Errorf("%s - User already exists", helper.GetFunctionName())
-			}
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	for _, val := range data.MockPersonalAccessTokens {
+		if val.TokenHash == tokenHash && isLiveMockPersonalAccessToken(val) {
+			return &val, nil
 		}
+	}
+
+	return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+}
+
+func (db *MyMockDB) UpdatePersonalAccessTokenLastUsed(ctx context.Context, tokenID string, lastUsedAt time.Time) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("tokenId=%s", tokenID))
 
-		return nil, nil
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockPersonalAccessTokens {
+		if val.TokenID == tokenID {
+			data.MockPersonalAccessTokens[i].LastUsedAt = &lastUsedAt
+			return nil
+		}
 	}
 
-	if userAuth, ok := value.(*data.UserAuth); ok {
-		for _, val := range data.MockUsers {
-			if val.EmailAddress == userAuth.EmailAddress {
-				return &val, nil
-			}
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+}
+
+func (db *MyMockDB) DeletePersonalAccessToken(ctx context.Context, userID, tokenID string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, tokenId=%s", userID, tokenID))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockPersonalAccessTokens {
+		if val.UserID == userID && val.TokenID == tokenID && isLiveMockPersonalAccessToken(val) {
+			data.MockPersonalAccessTokens = slices.Delete(data.MockPersonalAccessTokens, i, i+1)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+}
+
+// isLiveMockPersonalAccessToken stands in for the TTL index on the MongoDB personal_access_tokens collection
+func isLiveMockPersonalAccessToken(personalAccessToken data.PersonalAccessToken) bool {
+	return personalAccessToken.ExpiresAt == nil || time.Now().Before(*personalAccessToken.ExpiresAt)
+}
+
+func (db *MyMockDB) RetrieveUserDetails(ctx context.Context, emailAddress string) (*data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	for _, val := range data.MockUsers {
+		if val.EmailAddress == emailAddress {
+			return &val, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) RetrieveUserDetailsByID(ctx context.Context, userID string) (*data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	for _, val := range data.MockUsers {
+		if val.UserID == userID {
+			return &val, nil
 		}
+	}
+
+	return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) UpdateUserTimezone(ctx context.Context, userID, timezone string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, timezone=%s", userID, timezone))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
 
-		return nil, fmt.Errorf("%s - User doesn't exist", helper.GetFunctionName())
+	for i, val := range data.MockUsers {
+		if val.UserID == userID {
+			data.MockUsers[i].Timezone = timezone
+			return nil
+		}
 	}
 
-	if userLoggedOutRequest, ok := value.(*data.UserLoggedOutRequest); ok {
-		for _, val := range data.MockUsers {
-			if val.EmailAddress == userLoggedOutRequest.EmailAddress {
-				return &val, nil
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) UpdateUserName(ctx context.Context, userID, firstName, lastName string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUsers {
+		if val.UserID == userID {
+			data.MockUsers[i].FirstName = firstName
+			data.MockUsers[i].LastName = lastName
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) UpdateUserEmailAddress(ctx context.Context, userID, emailAddress string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	if slices.ContainsFunc(data.MockUsers, func(val data.UserData) bool { return val.EmailAddress == emailAddress && val.UserID != userID }) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserExists)
+	}
+
+	for i, val := range data.MockUsers {
+		if val.UserID == userID {
+			data.MockUsers[i].EmailAddress = emailAddress
+			data.MockUsers[i].EmailVerified = true
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) DeleteUser(ctx context.Context, userID string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	if !slices.ContainsFunc(data.MockUsers, func(val data.UserData) bool { return val.UserID == userID }) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	data.MockHabit = slices.DeleteFunc(data.MockHabit, func(val data.Habit) bool { return val.UserID == userID })
+	data.MockUserSession = slices.DeleteFunc(data.MockUserSession, func(val data.UserSession) bool { return val.UserID == userID })
+	data.MockPersonalAccessTokens = slices.DeleteFunc(data.MockPersonalAccessTokens, func(val data.PersonalAccessToken) bool { return val.UserID == userID })
+	data.MockPasswordResets = slices.DeleteFunc(data.MockPasswordResets, func(val data.PasswordReset) bool { return val.UserID == userID })
+	data.MockUsers = slices.DeleteFunc(data.MockUsers, func(val data.UserData) bool { return val.UserID == userID })
+
+	return nil
+}
+
+func (db *MyMockDB) UpdateUserEmailVerified(ctx context.Context, userID string, emailVerified bool) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, emailVerified=%t", userID, emailVerified))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUsers {
+		if val.UserID == userID {
+			data.MockUsers[i].EmailVerified = emailVerified
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) UpdateUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, disabled=%t", userID, disabled))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUsers {
+		if val.UserID == userID {
+			data.MockUsers[i].Disabled = disabled
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) SearchUsers(ctx context.Context, query string, limit, offset int) ([]data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("query=%s, limit=%d, offset=%d", query, limit, offset))
+
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
+	query = strings.ToLower(query)
+	users := []data.UserData{}
+
+	for _, val := range data.MockUsers {
+		if strings.Contains(strings.ToLower(val.EmailAddress), query) || strings.Contains(strings.ToLower(val.FirstName), query) || strings.Contains(strings.ToLower(val.LastName), query) {
+			users = append(users, val)
+		}
+	}
+
+	slices.SortStableFunc(users, func(a, b data.UserData) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	users = users[min(offset, len(users)):]
+
+	return users[:min(limit, len(users))], nil
+}
+
+func (db *MyMockDB) UpdateUserTOTP(ctx context.Context, userID string, userTOTP data.UserTOTP) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, enabled=%t", userID, userTOTP.Enabled))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUsers {
+		if val.UserID == userID {
+			userTOTP.RecoveryCodes = append([]string{}, userTOTP.RecoveryCodes...)
+			data.MockUsers[i].TOTP = userTOTP
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) ConsumeTOTPStep(ctx context.Context, userID string, step int64) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUsers {
+		if val.UserID != userID {
+			continue
+		}
+
+		if step <= val.TOTP.LastUsedStep {
+			return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrTOTPStepUsed)
+		}
+
+		data.MockUsers[i].TOTP.LastUsedStep = step
+		return nil
+	}
+
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUsers {
+		if val.UserID != userID {
+			continue
+		}
+
+		for j, recoveryCode := range val.TOTP.RecoveryCodes {
+			if recoveryCode == codeHash {
+				data.MockUsers[i].TOTP.RecoveryCodes = append(val.TOTP.RecoveryCodes[:j:j], val.TOTP.RecoveryCodes[j+1:]...)
+				return nil
 			}
 		}
 
-		return nil, fmt.Errorf("%s - User doesn't exist", helper.GetFunctionName())
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrRecoveryCodeNotFound)
 	}
 
-	return nil, fmt.Errorf("%s - value type is unsupported", helper.GetFunctionName())
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
 }
 
-func (db *MyMockDB) CreateHabitsHandler(userId string, value interface{}) (*data.NewHabitResponse, error) {
-	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
-	newHabit, ok := value.(data.NewHabit)
+func (db *MyMockDB) UpdateUserPassword(ctx context.Context, userID, hashedPassword string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
 
-	if !ok {
-		return nil, fmt.Errorf("%s - value type is not data.Habit", helper.GetFunctionName())
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockUsers {
+		if val.UserID == userID {
+			data.MockUsers[i].Password = hashedPassword
+			return nil
+		}
 	}
 
+	return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+}
+
+func (db *MyMockDB) CreateHabitsHandler(ctx context.Context, userId string, newHabit data.NewHabit) (*data.NewHabitResponse, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
 	var id int
 	var err error
 	if len(data.MockHabit) == 0 {
@@ -196,6 +660,11 @@ func (db *MyMockDB) CreateHabitsHandler(userId string, value interface{}) (*data
 		Days:            0,
 		DaysTarget:      newHabit.DaysTarget,
 		CompletionDates: []string{},
+		Schedule:        newHabit.Schedule,
+		Unit:            newHabit.Unit,
+		DailyGoal:       newHabit.DailyGoal,
+		Measurements:    []data.HabitMeasurement{},
+		Revision:        1,
 	}
 
 	data.MockHabit = append(data.MockHabit, habit)
@@ -204,12 +673,19 @@ func (db *MyMockDB) CreateHabitsHandler(userId string, value interface{}) (*data
 		HabitID:    newHabitId,
 		Name:       newHabit.Name,
 		DaysTarget: newHabit.DaysTarget,
+		Schedule:   newHabit.Schedule,
+		Unit:       newHabit.Unit,
+		DailyGoal:  newHabit.DailyGoal,
+		Revision:   habit.Revision,
 	}, nil
 }
 
-func (db *MyMockDB) RetrieveAllHabitsHandler(userId string) (interface{}, error) {
+func (db *MyMockDB) RetrieveAllHabitsHandler(ctx context.Context, userId string) ([]data.Habit, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
 
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
 	var userMockHabits []data.Habit
 
 	for _, habit := range data.MockHabit {
@@ -221,9 +697,29 @@ func (db *MyMockDB) RetrieveAllHabitsHandler(userId string) (interface{}, error)
 	return userMockHabits, nil
 }
 
-func (db *MyMockDB) RetrieveHabitsHandler(userId, habitId string) (interface{}, error) {
+// StreamAllHabitsHandler calls fn after the lock is released, so a slow fn doesn't hold up writes
+func (db *MyMockDB) StreamAllHabitsHandler(ctx context.Context, userId string, fn func(data.Habit) error) error {
+	habits, err := db.RetrieveAllHabitsHandler(ctx, userId)
+
+	if err != nil {
+		return err
+	}
+
+	for _, habit := range habits {
+		if err := fn(habit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *MyMockDB) RetrieveHabitsHandler(ctx context.Context, userId, habitId string) (data.Habit, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s\n", userId, habitId))
 
+	mockMx.RLock()
+	defer mockMx.RUnlock()
+
 	for _, val := range data.MockHabit {
 		if val.UserID == userId && val.HabitID == habitId {
 			db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("match habitId=%s, val=%s\n", val.HabitID, val.Name))
@@ -231,77 +727,167 @@ func (db *MyMockDB) RetrieveHabitsHandler(userId, habitId string) (interface{},
 		}
 	}
 
-	err := "habit not found"
-	db.logger.ErrorLog(helper.GetFunctionName(), err)
-	return nil, fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+	db.logger.ErrorLog(helper.GetFunctionName(), "habit not found")
+	return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrHabitNotFound)
 }
 
-func (db *MyMockDB) UpdateHabitsHandler(userId, habitId string, value interface{}) error {
+func (db *MyMockDB) UpdateHabitsHandler(ctx context.Context, userId, habitId string, newHabit data.Habit) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
-	newHabit, ok := value.(data.Habit)
 
-	if !ok {
-		err := "value type is not data.Habit"
-		db.logger.ErrorLog(helper.GetFunctionName(), err)
-		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
-	}
+	mockMx.Lock()
+	defer mockMx.Unlock()
 
 	for i, val := range data.MockHabit {
 		if val.UserID == userId && val.HabitID == habitId {
 			db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("match userId=%s, habitId=%s, val=%s\n", val.UserID, val.HabitID, val.Name))
-			data.MockHabit[i].Name = newHabit.Name
-			data.MockHabit[i].Days = newHabit.Days
-			data.MockHabit[i].DaysTarget = newHabit.DaysTarget
-			data.MockHabit[i].CompletionDates = newHabit.CompletionDates
+
+			if val.Revision != newHabit.Revision {
+				return fmt.Errorf("%s - habitId=%s is at revision %d not %d: %w", helper.GetFunctionName(), habitId, val.Revision, newHabit.Revision, ErrRevisionMismatch)
+			}
+
+			updateMockHabit(i, newHabit)
 
 			return nil
 		}
 	}
 
-	err := "Failed to update"
-	db.logger.ErrorLog(helper.GetFunctionName(), err)
-	return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+	db.logger.ErrorLog(helper.GetFunctionName(), "Failed to update")
+	return fmt.Errorf("%s - Failed to update: %w", helper.GetFunctionName(), ErrHabitNotFound)
 }
 
-func (db *MyMockDB) UpdateAllHabitsHandler(userId string, value interface{}) error {
+// UpdateAllHabitsHandler checks every habit's revision before writing any of them, so a stale habit leaves them all unchanged
+func (db *MyMockDB) UpdateAllHabitsHandler(ctx context.Context, userId string, newHabits []data.Habit) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
-	newHabits, ok := value.([]data.Habit)
 
-	if !ok {
-		err := "value type is not []data.Habit"
-		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
-	}
+	mockMx.Lock()
+	defer mockMx.Unlock()
 
-	for i, val := range data.MockHabit {
-		for _, newHabit := range newHabits {
-			if val.UserID == userId && val.HabitID == newHabit.HabitID {
-				db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("match userId=%s, habitId=%s, val=%s\n", val.UserID, val.HabitID, val.Name))
-				data.MockHabit[i].Name = newHabit.Name
-				data.MockHabit[i].Days = newHabit.Days
-				data.MockHabit[i].DaysTarget = newHabit.DaysTarget
-				data.MockHabit[i].CompletionDates = newHabit.CompletionDates
+	indexes := make([]int, len(newHabits))
 
-				return nil
-			}
+	for i, newHabit := range newHabits {
+		index := slices.IndexFunc(data.MockHabit, func(val data.Habit) bool {
+			return val.UserID == userId && val.HabitID == newHabit.HabitID
+		})
+
+		if index == -1 {
+			db.logger.ErrorLog(helper.GetFunctionName(), "Failed to update")
+			return fmt.Errorf("%s - Failed to update habitId=%s: %w", helper.GetFunctionName(), newHabit.HabitID, ErrHabitNotFound)
 		}
+
+		if data.MockHabit[index].Revision != newHabit.Revision {
+			return fmt.Errorf("%s - habitId=%s is at revision %d not %d: %w", helper.GetFunctionName(), newHabit.HabitID, data.MockHabit[index].Revision, newHabit.Revision, ErrRevisionMismatch)
+		}
+
+		indexes[i] = index
+	}
+
+	for i, newHabit := range newHabits {
+		db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("match userId=%s, habitId=%s, val=%s\n", userId, newHabit.HabitID, newHabit.Name))
+		updateMockHabit(indexes[i], newHabit)
 	}
 
-	err := "Failed to update"
-	db.logger.ErrorLog(helper.GetFunctionName(), err)
-	return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+	return nil
+}
+
+// updateMockHabit copies the updatable fields of newHabit onto data.MockHabit[i] and increments its revision. mockMx must be held
+func updateMockHabit(i int, newHabit data.Habit) {
+	data.MockHabit[i].Name = newHabit.Name
+	data.MockHabit[i].Days = newHabit.Days
+	data.MockHabit[i].DaysTarget = newHabit.DaysTarget
+	data.MockHabit[i].CompletionDates = newHabit.CompletionDates
+	data.MockHabit[i].Schedule = newHabit.Schedule
+	data.MockHabit[i].Unit = newHabit.Unit
+	data.MockHabit[i].DailyGoal = newHabit.DailyGoal
+	data.MockHabit[i].Revision++
 }
 
-func (db *MyMockDB) DeleteHabitsHandler(userId, habitId string) error {
+func (db *MyMockDB) DeleteHabitsHandler(ctx context.Context, userId, habitId string, revision int64) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
 	for i, val := range data.MockHabit {
 		if val.UserID == userId && val.HabitID == habitId {
 			db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("match userId=%s, habitId=%s, val=%s\n", val.UserID, val.HabitID, val.Name))
+
+			if val.Revision != revision {
+				return fmt.Errorf("%s - habitId=%s is at revision %d not %d: %w", helper.GetFunctionName(), habitId, val.Revision, revision, ErrRevisionMismatch)
+			}
+
 			data.MockHabit = append(data.MockHabit[:i], data.MockHabit[i+1:]...)
 			return nil
 		}
 	}
 
-	err := "Failed to delete"
-	return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+	return fmt.Errorf("%s - Failed to delete: %w", helper.GetFunctionName(), ErrHabitNotFound)
+}
+
+func (db *MyMockDB) CreateCompletionHandler(ctx context.Context, userId, habitId, completionDate string) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userId, habitId, completionDate))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockHabit {
+		if val.UserID == userId && val.HabitID == habitId {
+			if slices.Contains(val.CompletionDates, completionDate) {
+				return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrCompletionExists)
+			}
+
+			// Clone so the new date isn't written into a backing array shared with a copy of MockHabit
+			data.MockHabit[i].CompletionDates = append(slices.Clone(val.CompletionDates), completionDate)
+			data.MockHabit[i].Revision++
+
+			return data.MockHabit[i], nil
+		}
+	}
+
+	return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrHabitNotFound)
+}
+
+func (db *MyMockDB) CreateMeasurementHandler(ctx context.Context, userId, habitId string, measurement data.HabitMeasurement) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, date=%s, value=%v", userId, habitId, measurement.Date, measurement.Value))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	for i, val := range data.MockHabit {
+		if val.UserID == userId && val.HabitID == habitId {
+			data.MockHabit[i].Measurements = append(slices.Clone(val.Measurements), measurement)
+			data.MockHabit[i].Revision++
+
+			return data.MockHabit[i], nil
+		}
+	}
+
+	return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrHabitNotFound)
+}
+
+func (db *MyMockDB) DeleteCompletionHandler(ctx context.Context, userId, habitId, completionDate string) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userId, habitId, completionDate))
+
+	mockMx.Lock()
+	defer mockMx.Unlock()
+
+	isMeasuredOn := func(measurement data.HabitMeasurement) bool { return measurement.Date == completionDate }
+
+	for i, val := range data.MockHabit {
+		if val.UserID == userId && val.HabitID == habitId {
+			if !slices.Contains(val.CompletionDates, completionDate) && !slices.ContainsFunc(val.Measurements, isMeasuredOn) {
+				return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrCompletionNotFound)
+			}
+
+			// Removes every occurrence, matching MongoDB's $pull
+			data.MockHabit[i].CompletionDates = slices.DeleteFunc(slices.Clone(val.CompletionDates), func(date string) bool {
+				return date == completionDate
+			})
+			data.MockHabit[i].Measurements = slices.DeleteFunc(slices.Clone(val.Measurements), isMeasuredOn)
+			data.MockHabit[i].Revision++
+
+			return data.MockHabit[i], nil
+		}
+	}
+
+	return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrHabitNotFound)
 }
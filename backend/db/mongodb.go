@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -21,21 +22,27 @@ import (
 var _ IDB = (*MongoDB)(nil)
 
 type MongoDB struct {
-	logger                logger.ILogger
-	client                *mongo.Client
-	habitsAppDBName       string
-	usersCollection       string
-	userSessionCollection string
-	habitsCollection      string
+	logger                  logger.ILogger
+	client                  *mongo.Client
+	habitsAppDBName         string
+	usersCollection         string
+	userSessionCollection   string
+	passwordResetCollection string
+	loginAttemptsCollection string
+	accessTokensCollection  string
+	habitsCollection        string
 }
 
 func NewMongoDB(logger logger.ILogger) *MongoDB {
 	return &MongoDB{
-		logger:                logger,
-		habitsAppDBName:       os.Getenv("DB_NAME"),
-		usersCollection:       os.Getenv("USERS_COLLECTION"),
-		userSessionCollection: os.Getenv("USER_SESSION_COLLECTION"),
-		habitsCollection:      os.Getenv("HABITS_COLLECTION"),
+		logger:                  logger,
+		habitsAppDBName:         os.Getenv("DB_NAME"),
+		usersCollection:         os.Getenv("USERS_COLLECTION"),
+		userSessionCollection:   os.Getenv("USER_SESSION_COLLECTION"),
+		passwordResetCollection: os.Getenv("PASSWORD_RESET_COLLECTION"),
+		loginAttemptsCollection: os.Getenv("LOGIN_ATTEMPTS_COLLECTION"),
+		accessTokensCollection:  os.Getenv("PERSONAL_ACCESS_TOKENS_COLLECTION"),
+		habitsCollection:        os.Getenv("HABITS_COLLECTION"),
 	}
 }
 
@@ -53,6 +60,27 @@ func (db *MongoDB) NewUsersSessionCollection() *mongo.Collection {
 	return db.client.Database(db.habitsAppDBName).Collection(db.userSessionCollection)
 }
 
+/*
+See README.md for password_reset document example
+*/
+func (db *MongoDB) NewPasswordResetCollection() *mongo.Collection {
+	return db.client.Database(db.habitsAppDBName).Collection(db.passwordResetCollection)
+}
+
+/*
+See README.md for login_attempts document example
+*/
+func (db *MongoDB) NewLoginAttemptsCollection() *mongo.Collection {
+	return db.client.Database(db.habitsAppDBName).Collection(db.loginAttemptsCollection)
+}
+
+/*
+See README.md for personal_access_tokens document example
+*/
+func (db *MongoDB) NewPersonalAccessTokensCollection() *mongo.Collection {
+	return db.client.Database(db.habitsAppDBName).Collection(db.accessTokensCollection)
+}
+
 /*
 See README.md for habits document example
 */
@@ -104,18 +132,89 @@ func (db *MongoDB) Connect() error {
 	return nil
 }
 
+/*
+EnsureTTLIndex expires sessions SessionTTL after they're created and indexes them by user so they can be listed.
+Sessions from before users could have several were keyed by the user's _id and have no UserID, so they're never matched and simply expire.
+Password resets are expired the same way PasswordResetTTL after they're created, and login attempts and personal access tokens
+at their ExpiresAt. Personal access tokens are also indexed by the hash they're looked up with and by user. Email addresses are
+indexed uniquely, so two users can't end up with the same address when they register or change it at the same time.
+*/
 func (db *MongoDB) EnsureTTLIndex() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	usersIndexModel := mongo.IndexModel{
+		Keys:    bson.M{"EmailAddress": 1},
+		Options: options.Index().SetUnique(true),
+	}
+
+	_, err := db.NewUsersCollection().Indexes().CreateOne(ctx, usersIndexModel)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to create unique index: %v", err))
+		return fmt.Errorf("failed to create unique index: %v", err)
+	}
+
 	newUsersSessionCollection := db.NewUsersSessionCollection()
 
-	indexModel := mongo.IndexModel{
-		Keys:    bson.M{"CreatedAt": 1},                       // Index on CreatedAt field
-		Options: options.Index().SetExpireAfterSeconds(86400), // 24 hours
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"CreatedAt": 1}, // Index on CreatedAt field
+			Options: options.Index().SetExpireAfterSeconds(int32(SessionTTL.Seconds())),
+		},
+		{
+			Keys: bson.M{"UserID": 1},
+		},
+	}
+
+	_, err = newUsersSessionCollection.Indexes().CreateMany(ctx, indexModels)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to create TTL index: %v", err))
+		return fmt.Errorf("failed to create TTL index: %v", err)
+	}
+
+	passwordResetIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"CreatedAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(int32(PasswordResetTTL.Seconds())),
+		},
+		{
+			Keys: bson.M{"UserID": 1},
+		},
+	}
+
+	_, err = db.NewPasswordResetCollection().Indexes().CreateMany(ctx, passwordResetIndexModels)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to create TTL index: %v", err))
+		return fmt.Errorf("failed to create TTL index: %v", err)
+	}
+
+	loginAttemptsIndexModel := mongo.IndexModel{
+		Keys:    bson.M{"ExpiresAt": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	_, err = db.NewLoginAttemptsCollection().Indexes().CreateOne(ctx, loginAttemptsIndexModel)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to create TTL index: %v", err))
+		return fmt.Errorf("failed to create TTL index: %v", err)
+	}
+
+	// Tokens that never expire have no ExpiresAt, which the TTL monitor leaves alone
+	personalAccessTokensIndexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.M{"ExpiresAt": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.M{"TokenHash": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.M{"UserID": 1},
+		},
 	}
 
-	_, err := newUsersSessionCollection.Indexes().CreateOne(ctx, indexModel)
+	_, err = db.NewPersonalAccessTokensCollection().Indexes().CreateMany(ctx, personalAccessTokensIndexModels)
 	if err != nil {
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to create TTL index: %v", err))
 		return fmt.Errorf("failed to create TTL index: %v", err)
@@ -134,17 +233,10 @@ func (db *MongoDB) Disconnect() error {
 	return nil
 }
 
-func (db *MongoDB) RegisterUserHandler(value interface{}) (interface{}, error) {
+func (db *MongoDB) RegisterUserHandler(ctx context.Context, newUser *data.RegisterUserRequest) (*data.UserData, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	newUser, ok := value.(*data.RegisterUserRequest)
-
-	if !ok {
-		db.logger.ErrorLog(helper.GetFunctionName(), "value type is not data.UserData")
-		return nil, fmt.Errorf("%s - value type is not data.UserData", helper.GetFunctionName())
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newUsersCollection := db.NewUsersCollection()
@@ -152,12 +244,14 @@ func (db *MongoDB) RegisterUserHandler(value interface{}) (interface{}, error) {
 	registerTime := time.Now()
 
 	type registerUserData struct {
-		Password     string    `bson:"Password"`
-		FirstName    string    `bson:"FirstName"`
-		LastName     string    `bson:"LastName"`
-		EmailAddress string    `bson:"EmailAddress"`
-		CreatedAt    time.Time `bson:"CreatedAt"`
-		LastLogin    time.Time `bson:"LastLogin"`
+		Password      string    `bson:"Password"`
+		FirstName     string    `bson:"FirstName"`
+		LastName      string    `bson:"LastName"`
+		EmailAddress  string    `bson:"EmailAddress"`
+		Timezone      string    `bson:"Timezone"`
+		EmailVerified bool      `bson:"EmailVerified"`
+		CreatedAt     time.Time `bson:"CreatedAt"`
+		LastLogin     time.Time `bson:"LastLogin"`
 	}
 
 	registerUser := registerUserData{
@@ -165,11 +259,16 @@ func (db *MongoDB) RegisterUserHandler(value interface{}) (interface{}, error) {
 		Password:     newUser.Password,
 		FirstName:    newUser.FirstName,
 		LastName:     newUser.LastName,
+		Timezone:     newUser.Timezone,
 		CreatedAt:    registerTime,
 		LastLogin:    registerTime,
 	}
 
 	insertResult, err := newUsersCollection.InsertOne(ctx, registerUser)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserExists)
+	}
+
 	if err != nil {
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert new user: %v", err))
 		return nil, fmt.Errorf("%s - Failed to insert new user: %v", helper.GetFunctionName(), err)
@@ -177,25 +276,29 @@ func (db *MongoDB) RegisterUserHandler(value interface{}) (interface{}, error) {
 
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("User registered successfully with EmailAddress: %s, Acknowledged: %v, InsertedID: %v", registerUser.EmailAddress, insertResult.Acknowledged, insertResult.InsertedID))
 
+	newUserID, ok := insertResult.InsertedID.(bson.ObjectID)
+
+	if !ok {
+		db.logger.ErrorLog(helper.GetFunctionName(), "Failed to insert new user - insertResult.InsertedID is not bson.ObjectID")
+		return nil, fmt.Errorf("%s - Failed to insert new user - insertResult.InsertedID is not bson.ObjectID", helper.GetFunctionName())
+	}
+
 	return &data.UserData{
+		UserID:       newUserID.Hex(),
 		FirstName:    newUser.FirstName,
 		LastName:     newUser.LastName,
 		Password:     newUser.Password,
 		EmailAddress: newUser.EmailAddress,
+		Timezone:     newUser.Timezone,
 		CreatedAt:    registerTime,
+		LastLogin:    registerTime,
 	}, nil
 }
 
-func (db *MongoDB) LoginUser(value interface{}) error {
+func (db *MongoDB) LoginUser(ctx context.Context, userSession *data.UserSession) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	userSession, ok := value.(*data.UserSession)
-	if !ok {
-		db.logger.ErrorLog(helper.GetFunctionName(), "value type is not data.UserSession")
-		return fmt.Errorf("%s - value type is not data.UserSession", helper.GetFunctionName())
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newUsersSessionCollection := db.NewUsersSessionCollection()
@@ -212,17 +315,19 @@ func (db *MongoDB) LoginUser(value interface{}) error {
 	}
 
 	type insertUserSessionData struct {
-		UserID       bson.ObjectID `bson:"_id"`
+		SessionID    string        `bson:"_id"`
+		UserID       bson.ObjectID `bson:"UserID"`
 		RefreshToken string        `bson:"RefreshToken"`
-		Device       string        `bson:"Device"`
+		UserAgent    string        `bson:"UserAgent"`
 		IPAddress    string        `bson:"IpAddress"`
 		CreatedAt    time.Time     `bson:"CreatedAt"`
 	}
 
 	insertUserSession := insertUserSessionData{
+		SessionID:    userSession.SessionID,
 		UserID:       bson.ObjectID(objectID),
 		RefreshToken: userSession.RefreshToken,
-		Device:       userSession.Device,
+		UserAgent:    userSession.UserAgent,
 		IPAddress:    userSession.IPAddress,
 		CreatedAt:    userSession.CreatedAt,
 	}
@@ -252,214 +357,1004 @@ func (db *MongoDB) LoginUser(value interface{}) error {
 	return nil
 }
 
-func (db *MongoDB) LogoutUser(value interface{}) error {
+func (db *MongoDB) LogoutUser(ctx context.Context, userID, sessionID string) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	userLoggedOut, ok := value.(*data.UserData)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	newUsersSessionCollection := db.NewUsersSessionCollection()
 
-	if !ok {
-		db.logger.ErrorLog(helper.GetFunctionName(), "value type is not data.UserData")
-		return fmt.Errorf("%s - value type is not data.UserData", helper.GetFunctionName())
+	sessionFilter, err := userSessionFilter(userID, sessionID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve the objectId from userId=%s", userID))
+		return fmt.Errorf("%s - Failed to retrieve the objectId from userId=%s: %v", helper.GetFunctionName(), userID, err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	deleteResult, err := newUsersSessionCollection.DeleteOne(ctx, sessionFilter)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user session for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to delete user session for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The document has been updated. Acknowledged: %v, DeleteCount: %v", deleteResult.Acknowledged, deleteResult.DeletedCount))
+
+	if deleteResult.DeletedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) RetrieveUserSession(ctx context.Context, userID, sessionID string) (*data.UserSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newUsersSessionCollection := db.NewUsersSessionCollection()
 
-	objectID, err := primitive.ObjectIDFromHex(userLoggedOut.UserID)
+	filter, err := userSessionFilter(userID, sessionID)
 	if err != nil {
-		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve the objectId from userId=%s", userLoggedOut.UserID))
-		return fmt.Errorf("%s - Failed to retrieve the objectId from userId=%s: %v", helper.GetFunctionName(), userLoggedOut.UserID, err)
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user session for userId=%s", userID))
+		return nil, fmt.Errorf("%s - Failed to retrieve user session for userId=%s: %v", helper.GetFunctionName(), userID, err)
 	}
 
-	sessionFilter := bson.D{{Key: "_id", Value: bson.ObjectID(objectID)}}
+	var result bson.M
+
+	err = newUsersSessionCollection.FindOne(ctx, filter).Decode(&result)
 
-	deleteResult, err := newUsersSessionCollection.DeleteOne(ctx, sessionFilter)
 	if err != nil {
-		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user session for userId=%s", userLoggedOut.UserID))
-		return fmt.Errorf("%s - Failed to delete user session for userId=%s: %v", helper.GetFunctionName(), userLoggedOut.UserID, err)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user session err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to get user session err=%s", helper.GetFunctionName(), err)
 	}
 
-	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The document has been updated. Acknowledged: %v, DeleteCount: %v", deleteResult.Acknowledged, deleteResult.DeletedCount))
+	userSession := decodeUserSession(result)
+
+	return &userSession, nil
+}
+
+func (db *MongoDB) RotateRefreshToken(ctx context.Context, userID, sessionID, oldRefreshToken, newRefreshToken string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	newUsersSessionCollection := db.NewUsersSessionCollection()
+
+	filter, err := userSessionFilter(userID, sessionID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to rotate the refresh token for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to rotate the refresh token for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	filter["RefreshToken"] = oldRefreshToken
+
+	update := bson.M{
+		"$set": bson.M{
+			"RefreshToken": newRefreshToken,
+		},
+	}
+
+	result, err := newUsersSessionCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to rotate the refresh token for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to rotate the refresh token for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+	}
 
 	return nil
 }
 
-func (db *MongoDB) RetrieveUserSession(value interface{}, userID string) (string, error) {
-	if userID == "" {
-		emailAddress, ok := value.(string)
+func (db *MongoDB) RetrieveUserSessions(ctx context.Context, userID string) ([]data.UserSession, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
 
-		if !ok {
-			db.logger.ErrorLog(helper.GetFunctionName(), "value type is not string")
-			return "", fmt.Errorf("%s - value type is not string", helper.GetFunctionName())
-		}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-		userDetails, err := db.RetrieveUserDetails(&data.UserAuth{EmailAddress: emailAddress})
+	newUsersSessionCollection := db.NewUsersSessionCollection()
 
-		if err != nil {
-			return "", err
-		}
+	filter, err := userSessionFilter(userID, "")
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user sessions for userId=%s", userID))
+		return nil, fmt.Errorf("%s - Failed to retrieve user sessions for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	cur, err := newUsersSessionCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: 1}}))
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user sessions: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve user sessions: err=%v", helper.GetFunctionName(), err)
+	}
+	defer cur.Close(ctx)
 
-		currentUserData, ok := userDetails.(*data.UserData)
+	userSessions := []data.UserSession{}
+
+	for cur.Next(ctx) {
+		var el bson.M
 
-		if !ok {
-			return "", fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+		if err := cur.Decode(&el); err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user sessions: err=%v", err))
+			return nil, fmt.Errorf("%s - Failed to retrieve user sessions: err=%v", helper.GetFunctionName(), err)
 		}
 
-		userID = currentUserData.UserID
+		userSessions = append(userSessions, decodeUserSession(el))
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := cur.Err(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user sessions: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve user sessions: err=%v", helper.GetFunctionName(), err)
+	}
+
+	return userSessions, nil
+}
+
+func (db *MongoDB) DeleteUserSessions(ctx context.Context, userID, exceptSessionID string) (int64, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newUsersSessionCollection := db.NewUsersSessionCollection()
 
-	objectID, err := primitive.ObjectIDFromHex(userID)
+	filter, err := userSessionFilter(userID, "")
 	if err != nil {
-		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user session for userId=%s", userID))
-		return "", fmt.Errorf("%s - Failed to retrieve user session for userId=%s: %v", helper.GetFunctionName(), userID, err)
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user sessions for userId=%s", userID))
+		return 0, fmt.Errorf("%s - Failed to delete user sessions for userId=%s: %v", helper.GetFunctionName(), userID, err)
 	}
 
-	filter := bson.M{"_id": bson.ObjectID(objectID)}
+	if exceptSessionID != "" {
+		filter["_id"] = bson.M{"$ne": exceptSessionID}
+	}
+
+	deleteResult, err := newUsersSessionCollection.DeleteMany(ctx, filter)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user sessions for userId=%s", userID))
+		return 0, fmt.Errorf("%s - Failed to delete user sessions for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The documents have been deleted. Acknowledged: %v, DeleteCount: %v", deleteResult.Acknowledged, deleteResult.DeletedCount))
+
+	return deleteResult.DeletedCount, nil
+}
+
+func (db *MongoDB) CreatePasswordReset(ctx context.Context, passwordReset *data.PasswordReset) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", passwordReset.UserID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	newPasswordResetCollection := db.NewPasswordResetCollection()
+
+	objectID, err := primitive.ObjectIDFromHex(passwordReset.UserID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert password reset for userId=%s", passwordReset.UserID))
+		return fmt.Errorf("%s - Failed to insert password reset for userId=%s: %v", helper.GetFunctionName(), passwordReset.UserID, err)
+	}
+
+	if passwordReset.CreatedAt.IsZero() {
+		passwordReset.CreatedAt = time.Now()
+	}
+
+	// Only the latest reset email works, so one that's been read by someone else is dead once the user asks again
+	if _, err := newPasswordResetCollection.DeleteMany(ctx, bson.M{"UserID": bson.ObjectID(objectID)}); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete password resets for userId=%s", passwordReset.UserID))
+		return fmt.Errorf("%s - Failed to delete password resets for userId=%s: %v", helper.GetFunctionName(), passwordReset.UserID, err)
+	}
+
+	type insertPasswordResetData struct {
+		TokenHash string        `bson:"_id"`
+		UserID    bson.ObjectID `bson:"UserID"`
+		CreatedAt time.Time     `bson:"CreatedAt"`
+	}
+
+	_, err = newPasswordResetCollection.InsertOne(ctx, insertPasswordResetData{
+		TokenHash: passwordReset.TokenHash,
+		UserID:    bson.ObjectID(objectID),
+		CreatedAt: passwordReset.CreatedAt,
+	})
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert password reset for userId=%s", passwordReset.UserID))
+		return fmt.Errorf("%s - Failed to insert password reset for userId=%s: %v", helper.GetFunctionName(), passwordReset.UserID, err)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) ConsumePasswordReset(ctx context.Context, tokenHash string) (*data.PasswordReset, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// The TTL monitor only runs once a minute, so expired resets are filtered out rather than relying on it to have removed them
+	filter := bson.M{
+		"_id":       tokenHash,
+		"CreatedAt": bson.M{"$gt": time.Now().Add(-PasswordResetTTL)},
+	}
 
-	var userSession data.UserSession
 	var result bson.M
 
-	err = newUsersSessionCollection.FindOne(ctx, filter).Decode(&result)
+	err := db.NewPasswordResetCollection().FindOneAndDelete(ctx, filter).Decode(&result)
 
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return "", fmt.Errorf("%s - User session doesn't exist", helper.GetFunctionName())
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPasswordResetNotFound)
 		}
 
-		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user session err=%s", err))
-		return "", fmt.Errorf("%s - Failed to get user session err=%s", helper.GetFunctionName(), err)
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to consume password reset err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to consume password reset err=%s", helper.GetFunctionName(), err)
 	}
 
-	if refreshToken, ok := result["RefreshToken"].(string); ok {
-		userSession.RefreshToken = refreshToken
+	passwordReset := data.PasswordReset{TokenHash: tokenHash}
+
+	if userID, ok := result["UserID"].(bson.ObjectID); ok {
+		passwordReset.UserID = userID.Hex()
 	}
 
-	return userSession.RefreshToken, nil
+	if createdAt, ok := result["CreatedAt"].(bson.DateTime); ok {
+		passwordReset.CreatedAt = createdAt.Time()
+	}
+
+	return &passwordReset, nil
 }
 
-func (db *MongoDB) RetrieveUserDetails(value interface{}) (interface{}, error) {
+func (db *MongoDB) RetrieveLoginAttempts(ctx context.Context, key string) (*data.LoginAttempts, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	newUsersCollection := db.NewUsersCollection()
+	loginAttempts := data.LoginAttempts{Key: key}
 
-	if userRegisterRequest, ok := value.(*data.RegisterUserRequest); ok {
-		filter := bson.M{"EmailAddress": userRegisterRequest.EmailAddress}
-		var result bson.M
+	err := db.NewLoginAttemptsCollection().FindOne(ctx, bson.M{"_id": key}).Decode(&loginAttempts)
 
-		err := newUsersCollection.FindOne(ctx, filter).Decode(&result)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve login attempts err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve login attempts err=%s", helper.GetFunctionName(), err)
+	}
 
-		if err != nil {
-			if errors.Is(err, mongo.ErrNoDocuments) {
-				return nil, nil
-			}
+	return &loginAttempts, nil
+}
 
-			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
-			return nil, fmt.Errorf(helper.GetFunctionName(), "Failed to get user details err=%s", err)
-		}
+func (db *MongoDB) RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*data.LoginAttempts, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
-		return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	/*
+		An update pipeline so the count is read and written in one operation. Every field in a $set stage is computed from the
+		document as it was, so Failures sees the previous LastFailureAt, which is missing on the document the upsert creates.
+	*/
+	update := []bson.M{
+		{
+			"$set": bson.M{
+				"Failures": bson.M{
+					"$cond": bson.A{
+						bson.M{"$gt": bson.A{"$LastFailureAt", now.Add(-resetAfter)}},
+						bson.M{"$add": bson.A{"$Failures", 1}},
+						1,
+					},
+				},
+				"LastFailureAt": now,
+				"LockedUntil":   bson.M{"$ifNull": bson.A{"$LockedUntil", time.Time{}}},
+				"ExpiresAt":     bson.M{"$max": bson.A{"$ExpiresAt", now.Add(resetAfter)}},
+			},
+		},
 	}
 
-	if userAuth, ok := value.(*data.UserAuth); ok {
-		filter := bson.M{"EmailAddress": userAuth.EmailAddress}
+	loginAttempts := data.LoginAttempts{}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
 
-		user, err := db.findUser(ctx, filter, newUsersCollection)
+	err := db.NewLoginAttemptsCollection().FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&loginAttempts)
 
-		if err != nil {
-			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
-			return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
-		}
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to record login failure err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to record login failure err=%s", helper.GetFunctionName(), err)
+	}
+
+	return &loginAttempts, nil
+}
+
+func (db *MongoDB) LockLoginAttempts(ctx context.Context, key string, lockedUntil time.Time) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-		return user, nil
+	// The lock outlives the record's failures if it has to, so the TTL index doesn't remove it early
+	update := bson.M{
+		"$set": bson.M{"LockedUntil": lockedUntil},
+		"$max": bson.M{"ExpiresAt": lockedUntil},
 	}
 
-	if userLoggedOutRequest, ok := value.(*data.UserLoggedOutRequest); ok {
-		filter := bson.M{"EmailAddress": userLoggedOutRequest.EmailAddress}
+	_, err := db.NewLoginAttemptsCollection().UpdateOne(ctx, bson.M{"_id": key}, update, options.UpdateOne().SetUpsert(true))
 
-		user, err := db.findUser(ctx, filter, newUsersCollection)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to lock login attempts err=%s", err))
+		return fmt.Errorf("%s - Failed to lock login attempts err=%s", helper.GetFunctionName(), err)
+	}
 
-		if err != nil {
-			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
-			return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
-		}
+	return nil
+}
+
+func (db *MongoDB) DeleteLoginAttempts(ctx context.Context, key string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
-		return user, nil
+	if _, err := db.NewLoginAttemptsCollection().DeleteOne(ctx, bson.M{"_id": key}); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete login attempts err=%s", err))
+		return fmt.Errorf("%s - Failed to delete login attempts err=%s", helper.GetFunctionName(), err)
 	}
 
-	db.logger.ErrorLog(helper.GetFunctionName(), "value type is unsupported")
-	return nil, fmt.Errorf("%s - value type is unsupported", helper.GetFunctionName())
+	return nil
 }
 
-func (db *MongoDB) findUser(ctx context.Context, filter bson.M, newUsersCollection *mongo.Collection) (*data.UserData, error) {
-	var user data.UserData
-	var result bson.M
+func (db *MongoDB) CreatePersonalAccessToken(ctx context.Context, personalAccessToken *data.PersonalAccessToken) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", personalAccessToken.UserID))
 
-	err := newUsersCollection.FindOne(ctx, filter).Decode(&result)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
 
+	objectID, err := primitive.ObjectIDFromHex(personalAccessToken.UserID)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, fmt.Errorf("%s - User doesn't exist", helper.GetFunctionName())
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert personal access token for userId=%s", personalAccessToken.UserID))
+		return fmt.Errorf("%s - Failed to insert personal access token for userId=%s: %v", helper.GetFunctionName(), personalAccessToken.UserID, err)
+	}
+
+	if personalAccessToken.CreatedAt.IsZero() {
+		personalAccessToken.CreatedAt = time.Now()
+	}
+
+	type insertPersonalAccessTokenData struct {
+		TokenID   string        `bson:"_id"`
+		UserID    bson.ObjectID `bson:"UserID"`
+		Name      string        `bson:"Name"`
+		TokenHash string        `bson:"TokenHash"`
+		Scopes    []string      `bson:"Scopes"`
+		CreatedAt time.Time     `bson:"CreatedAt"`
+		ExpiresAt *time.Time    `bson:"ExpiresAt,omitempty"`
+	}
+
+	_, err = db.NewPersonalAccessTokensCollection().InsertOne(ctx, insertPersonalAccessTokenData{
+		TokenID:   personalAccessToken.TokenID,
+		UserID:    bson.ObjectID(objectID),
+		Name:      personalAccessToken.Name,
+		TokenHash: personalAccessToken.TokenHash,
+		Scopes:    personalAccessToken.Scopes,
+		CreatedAt: personalAccessToken.CreatedAt,
+		ExpiresAt: personalAccessToken.ExpiresAt,
+	})
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert personal access token for userId=%s", personalAccessToken.UserID))
+		return fmt.Errorf("%s - Failed to insert personal access token for userId=%s: %v", helper.GetFunctionName(), personalAccessToken.UserID, err)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) RetrievePersonalAccessTokens(ctx context.Context, userID string) ([]data.PersonalAccessToken, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access tokens for userId=%s", userID))
+		return nil, fmt.Errorf("%s - Failed to retrieve personal access tokens for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	filter := livePersonalAccessTokenFilter()
+	filter["UserID"] = bson.ObjectID(objectID)
+
+	cur, err := db.NewPersonalAccessTokensCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: 1}}))
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access tokens: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve personal access tokens: err=%v", helper.GetFunctionName(), err)
+	}
+	defer cur.Close(ctx)
+
+	personalAccessTokens := []data.PersonalAccessToken{}
+
+	for cur.Next(ctx) {
+		var el bson.M
+
+		if err := cur.Decode(&el); err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access tokens: err=%v", err))
+			return nil, fmt.Errorf("%s - Failed to retrieve personal access tokens: err=%v", helper.GetFunctionName(), err)
 		}
 
-		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
-		return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
+		personalAccessTokens = append(personalAccessTokens, decodePersonalAccessToken(el))
 	}
 
-	if id, ok := result["_id"].(bson.ObjectID); ok {
-		user.UserID = id.Hex()
-	} else {
-		return nil, fmt.Errorf("%s - _id field is missing or not an ObjectID", helper.GetFunctionName())
+	if err := cur.Err(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access tokens: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve personal access tokens: err=%v", helper.GetFunctionName(), err)
+	}
+
+	return personalAccessTokens, nil
+}
+
+func (db *MongoDB) RetrievePersonalAccessTokenByHash(ctx context.Context, tokenHash string) (*data.PersonalAccessToken, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	filter := livePersonalAccessTokenFilter()
+	filter["TokenHash"] = tokenHash
+
+	var result bson.M
+
+	err := db.NewPersonalAccessTokensCollection().FindOne(ctx, filter).Decode(&result)
+
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access token err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve personal access token err=%s", helper.GetFunctionName(), err)
+	}
+
+	personalAccessToken := decodePersonalAccessToken(result)
+
+	return &personalAccessToken, nil
+}
+
+func (db *MongoDB) UpdatePersonalAccessTokenLastUsed(ctx context.Context, tokenID string, lastUsedAt time.Time) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("tokenId=%s", tokenID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := db.NewPersonalAccessTokensCollection().UpdateOne(ctx, bson.M{"_id": tokenID}, bson.M{"$set": bson.M{"LastUsedAt": lastUsedAt}})
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update personal access token tokenId=%s, err=%s", tokenID, err))
+		return fmt.Errorf("%s - Failed to update personal access token tokenId=%s, err=%s", helper.GetFunctionName(), tokenID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) DeletePersonalAccessToken(ctx context.Context, userID, tokenID string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, tokenId=%s", userID, tokenID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete personal access token for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to delete personal access token for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	filter := livePersonalAccessTokenFilter()
+	filter["_id"] = tokenID
+	filter["UserID"] = bson.ObjectID(objectID)
+
+	deleteResult, err := db.NewPersonalAccessTokensCollection().DeleteOne(ctx, filter)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete personal access token for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to delete personal access token for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	if deleteResult.DeletedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) RetrieveUserDetails(ctx context.Context, emailAddress string) (*data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	newUsersCollection := db.NewUsersCollection()
+
+	filter := bson.M{"EmailAddress": emailAddress}
+
+	user, err := db.findUser(ctx, filter, newUsersCollection)
+
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
+	}
+
+	return user, nil
+}
+
+func (db *MongoDB) RetrieveUserDetailsByID(ctx context.Context, userID string) (*data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userID, ErrUserNotFound)
+	}
+
+	user, err := db.findUser(ctx, bson.M{"_id": bson.ObjectID(objectID)}, db.NewUsersCollection())
+
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, err
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
+	}
+
+	return user, nil
+}
+
+func (db *MongoDB) UpdateUserTimezone(ctx context.Context, userID, timezone string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, timezone=%s", userID, timezone))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, bson.M{"_id": bson.ObjectID(objectID)}, bson.M{"$set": bson.M{"Timezone": timezone}})
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) UpdateUserName(ctx context.Context, userID, firstName, lastName string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	return db.updateUser(ctx, userID, bson.M{"FirstName": firstName, "LastName": lastName})
+}
+
+func (db *MongoDB) UpdateUserEmailAddress(ctx context.Context, userID, emailAddress string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	return db.updateUser(ctx, userID, bson.M{"EmailAddress": emailAddress, "EmailVerified": true})
+}
+
+// updateUser sets fields on the user, returning ErrUserNotFound if the user doesn't exist
+func (db *MongoDB) updateUser(ctx context.Context, userID string, fields bson.M) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		return fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userID, ErrUserNotFound)
+	}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, bson.M{"_id": bson.ObjectID(objectID)}, bson.M{"$set": fields})
+
+	// EmailAddress is the only field with a unique index
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserExists)
+	}
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+/*
+DeleteUser deletes the user's documents from every collection without a transaction, as MongoDB only has them on a replica set.
+Everything that refers to the user goes first, so a failure part way leaves the user in place to try again rather than leaving
+documents behind that nothing refers to.
+*/
+func (db *MongoDB) DeleteUser(ctx context.Context, userID string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		return fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userID, ErrUserNotFound)
+	}
+
+	filter := bson.M{"UserID": bson.ObjectID(objectID)}
+
+	collections := []*mongo.Collection{
+		db.NewHabitsCollection(),
+		db.NewUsersSessionCollection(),
+		db.NewPersonalAccessTokensCollection(),
+		db.NewPasswordResetCollection(),
+	}
+
+	for _, collection := range collections {
+		deleteResult, err := collection.DeleteMany(ctx, filter)
+
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete from %s for userId=%s, err=%s", collection.Name(), userID, err))
+			return fmt.Errorf("%s - Failed to delete from %s for userId=%s, err=%s", helper.GetFunctionName(), collection.Name(), userID, err)
+		}
+
+		db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("Deleted %d documents from %s", deleteResult.DeletedCount, collection.Name()))
+	}
+
+	deleteResult, err := db.NewUsersCollection().DeleteOne(ctx, bson.M{"_id": bson.ObjectID(objectID)})
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to delete user for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if deleteResult.DeletedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) UpdateUserEmailVerified(ctx context.Context, userID string, emailVerified bool) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, emailVerified=%t", userID, emailVerified))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, bson.M{"_id": bson.ObjectID(objectID)}, bson.M{"$set": bson.M{"EmailVerified": emailVerified}})
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) UpdateUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, disabled=%t", userID, disabled))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		return fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userID, ErrUserNotFound)
+	}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, bson.M{"_id": bson.ObjectID(objectID)}, bson.M{"$set": bson.M{"Disabled": disabled}})
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) SearchUsers(ctx context.Context, query string, limit, offset int) ([]data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("query=%s, limit=%d, offset=%d", query, limit, offset))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// The query is matched literally, so an admin searching for "a.b" doesn't match "axb"
+	pattern := bson.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"EmailAddress": pattern},
+		bson.M{"FirstName": pattern},
+		bson.M{"LastName": pattern},
+	}}
+
+	opts := options.Find().SetSort(bson.D{{Key: "CreatedAt", Value: 1}, {Key: "_id", Value: 1}}).SetSkip(int64(offset)).SetLimit(int64(limit))
+
+	cur, err := db.NewUsersCollection().Find(ctx, filter, opts)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to search users: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to search users: err=%v", helper.GetFunctionName(), err)
+	}
+	defer cur.Close(ctx)
+
+	users := []data.UserData{}
+
+	for cur.Next(ctx) {
+		var result bson.M
+
+		if err := cur.Decode(&result); err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to search users: err=%v", err))
+			return nil, fmt.Errorf("%s - Failed to search users: err=%v", helper.GetFunctionName(), err)
+		}
+
+		user, err := decodeUser(result)
+
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, *user)
+	}
+
+	if err := cur.Err(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to search users: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to search users: err=%v", helper.GetFunctionName(), err)
+	}
+
+	return users, nil
+}
+
+func (db *MongoDB) UpdateUserTOTP(ctx context.Context, userID string, userTOTP data.UserTOTP) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, enabled=%t", userID, userTOTP.Enabled))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if userTOTP.RecoveryCodes == nil {
+		userTOTP.RecoveryCodes = []string{}
+	}
+
+	update := bson.M{"$set": bson.M{
+		"TOTPSecret":        userTOTP.Secret,
+		"TOTPEnabled":       userTOTP.Enabled,
+		"TOTPRecoveryCodes": userTOTP.RecoveryCodes,
+		"TOTPLastUsedStep":  userTOTP.LastUsedStep,
+	}}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, bson.M{"_id": bson.ObjectID(objectID)}, update)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) ConsumeTOTPStep(ctx context.Context, userID string, step int64) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	// Only matches while step is later than the last one used, so the same code can't be accepted twice
+	filter := bson.M{"_id": bson.ObjectID(objectID), "TOTPLastUsedStep": bson.M{"$lt": step}}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"TOTPLastUsedStep": step}})
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrTOTPStepUsed)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	filter := bson.M{"_id": bson.ObjectID(objectID), "TOTPRecoveryCodes": codeHash}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"TOTPRecoveryCodes": codeHash}})
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrRecoveryCodeNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) UpdateUserPassword(ctx context.Context, userID, hashedPassword string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	result, err := db.NewUsersCollection().UpdateOne(ctx, bson.M{"_id": bson.ObjectID(objectID)}, bson.M{"$set": bson.M{"Password": hashedPassword}})
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *MongoDB) findUser(ctx context.Context, filter bson.M, newUsersCollection *mongo.Collection) (*data.UserData, error) {
+	var result bson.M
+
+	err := newUsersCollection.FindOne(ctx, filter).Decode(&result)
+
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
+	}
+
+	return decodeUser(result)
+}
+
+// decodeUser reads a users document field by field, so users from before a field was added decode with its zero value
+func decodeUser(result bson.M) (*data.UserData, error) {
+	var user data.UserData
+
+	if id, ok := result["_id"].(bson.ObjectID); ok {
+		user.UserID = id.Hex()
+	} else {
+		return nil, fmt.Errorf("%s - _id field is missing or not an ObjectID", helper.GetFunctionName())
+	}
+
+	// Manually assign other fields from result to user struct
+	if password, ok := result["Password"].(string); ok {
+		user.Password = password
+	}
+
+	if firstName, ok := result["FirstName"].(string); ok {
+		user.FirstName = firstName
+	}
+
+	if lastName, ok := result["LastName"].(string); ok {
+		user.LastName = lastName
+	}
+
+	if emailAddress, ok := result["EmailAddress"].(string); ok {
+		user.EmailAddress = emailAddress
+	}
+
+	if timezone, ok := result["Timezone"].(string); ok {
+		user.Timezone = timezone
+	}
+
+	// Users from before email addresses were verified have no EmailVerified, so they're unverified
+	if emailVerified, ok := result["EmailVerified"].(bool); ok {
+		user.EmailVerified = emailVerified
+	}
+
+	if createdAt, ok := result["CreatedAt"].(bson.DateTime); ok {
+		user.CreatedAt = createdAt.Time()
+	}
+
+	if lastLogin, ok := result["LastLogin"].(bson.DateTime); ok {
+		user.LastLogin = lastLogin.Time()
 	}
 
-	// Manually assign other fields from result to user struct
-	if password, ok := result["Password"].(string); ok {
-		user.Password = password
+	if totpSecret, ok := result["TOTPSecret"].(string); ok {
+		user.TOTP.Secret = totpSecret
 	}
 
-	if firstName, ok := result["FirstName"].(string); ok {
-		user.FirstName = firstName
+	if totpEnabled, ok := result["TOTPEnabled"].(bool); ok {
+		user.TOTP.Enabled = totpEnabled
 	}
 
-	if lastName, ok := result["LastName"].(string); ok {
-		user.LastName = lastName
+	if recoveryCodes, ok := result["TOTPRecoveryCodes"].(bson.A); ok {
+		for _, val := range recoveryCodes {
+			if recoveryCode, ok := val.(string); ok {
+				user.TOTP.RecoveryCodes = append(user.TOTP.RecoveryCodes, recoveryCode)
+			}
+		}
 	}
 
-	if emailAddress, ok := result["EmailAddress"].(string); ok {
-		user.EmailAddress = emailAddress
+	if lastUsedStep, ok := result["TOTPLastUsedStep"].(int64); ok {
+		user.TOTP.LastUsedStep = lastUsedStep
 	}
 
-	if createdAt, ok := result["CreatedAt"].(bson.DateTime); ok {
-		user.CreatedAt = createdAt.Time()
+	if roles, ok := result["Roles"].(bson.A); ok {
+		for _, val := range roles {
+			if role, ok := val.(string); ok {
+				user.Roles = append(user.Roles, role)
+			}
+		}
 	}
 
-	if lastLogin, ok := result["LastLogin"].(bson.DateTime); ok {
-		user.LastLogin = lastLogin.Time()
+	if disabled, ok := result["Disabled"].(bool); ok {
+		user.Disabled = disabled
 	}
 
 	return &user, nil
 }
 
-func (db *MongoDB) CreateHabitsHandler(userId string, value interface{}) (*data.NewHabitResponse, error) {
+func (db *MongoDB) CreateHabitsHandler(ctx context.Context, userId string, newHabit data.NewHabit) (*data.NewHabitResponse, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
-	newHabit, ok := value.(data.NewHabit)
-
-	if !ok {
-		db.logger.ErrorLog(helper.GetFunctionName(), "value type is not data.Habit")
-		return nil, fmt.Errorf("%s - value type is not data.Habit", helper.GetFunctionName())
-	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newHabitsCollection := db.NewHabitsCollection()
@@ -472,12 +1367,17 @@ func (db *MongoDB) CreateHabitsHandler(userId string, value interface{}) (*data.
 	}
 
 	type insertHabitData struct {
-		UserID          bson.ObjectID `bson:"UserID"`
-		CreatedAt       time.Time     `bson:"CreatedAt"`
-		Name            string        `bson:"Name"`
-		Days            int           `bson:"Days"`
-		DaysTarget      int           `bson:"DaysTarget"`
-		CompletionDates []string      `bson:"CompletionDates"`
+		UserID          bson.ObjectID           `bson:"UserID"`
+		CreatedAt       time.Time               `bson:"CreatedAt"`
+		Name            string                  `bson:"Name"`
+		Days            int                     `bson:"Days"`
+		DaysTarget      int                     `bson:"DaysTarget"`
+		CompletionDates []string                `bson:"CompletionDates"`
+		Schedule        data.HabitSchedule      `bson:"Schedule"`
+		Unit            string                  `bson:"Unit"`
+		DailyGoal       float64                 `bson:"DailyGoal"`
+		Measurements    []data.HabitMeasurement `bson:"Measurements"`
+		Revision        int64                   `bson:"Revision"`
 	}
 
 	insertHabit := insertHabitData{
@@ -487,6 +1387,11 @@ func (db *MongoDB) CreateHabitsHandler(userId string, value interface{}) (*data.
 		Days:            0,
 		DaysTarget:      newHabit.DaysTarget,
 		CompletionDates: []string{},
+		Schedule:        newHabit.Schedule,
+		Unit:            newHabit.Unit,
+		DailyGoal:       newHabit.DailyGoal,
+		Measurements:    []data.HabitMeasurement{},
+		Revision:        1,
 	}
 
 	insertResult, err := newHabitsCollection.InsertOne(ctx, insertHabit)
@@ -508,22 +1413,41 @@ func (db *MongoDB) CreateHabitsHandler(userId string, value interface{}) (*data.
 		HabitID:    newInsertHabitID.Hex(),
 		Name:       newHabit.Name,
 		DaysTarget: newHabit.DaysTarget,
+		Schedule:   newHabit.Schedule,
+		Unit:       newHabit.Unit,
+		DailyGoal:  newHabit.DailyGoal,
+		Revision:   insertHabit.Revision,
 	}, nil
 }
 
-func (db *MongoDB) RetrieveAllHabitsHandler(userId string) (interface{}, error) {
-	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+func (db *MongoDB) RetrieveAllHabitsHandler(ctx context.Context, userId string) ([]data.Habit, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	var results []data.Habit
+
+	err := db.StreamAllHabitsHandler(ctx, userId, func(habit data.Habit) error {
+		results = append(results, habit)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (db *MongoDB) StreamAllHabitsHandler(ctx context.Context, userId string, fn func(data.Habit) error) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
+
 	newHabitsCollection := db.NewHabitsCollection()
 
 	objectID, err := primitive.ObjectIDFromHex(userId)
 
 	if err != nil {
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve all habits: userId=%s, err=%v", userId, err))
-		return nil, fmt.Errorf("%s - Failed to retrieve all habits: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+		return fmt.Errorf("%s - Failed to retrieve all habits: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
 	}
 
 	filter := bson.M{"UserID": bson.ObjectID(objectID)}
@@ -532,9 +1456,9 @@ func (db *MongoDB) RetrieveAllHabitsHandler(userId string) (interface{}, error)
 
 	if err != nil {
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: err=%v", err))
-		return nil, fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
+		return fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
 	}
-	var results []data.Habit
+	defer cur.Close(ctx)
 
 	for cur.Next(ctx) {
 		var el bson.M
@@ -543,13 +1467,13 @@ func (db *MongoDB) RetrieveAllHabitsHandler(userId string) (interface{}, error)
 
 		if err != nil {
 			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: err=%v", err))
-			return nil, fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
+			return fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
 		}
 
 		if id, ok := el["_id"].(bson.ObjectID); ok {
 			habit.HabitID = id.Hex()
 		} else {
-			return nil, fmt.Errorf("%s - _id field is missing or not an ObjectID", helper.GetFunctionName())
+			return fmt.Errorf("%s - _id field is missing or not an ObjectID", helper.GetFunctionName())
 		}
 
 		// Manually assign other fields from result to user struct
@@ -573,6 +1497,32 @@ func (db *MongoDB) RetrieveAllHabitsHandler(userId string) (interface{}, error)
 			habit.DaysTarget = int(daysTarget)
 		}
 
+		if schedule, ok := el["Schedule"]; ok {
+			habit.Schedule, err = decodeHabitSchedule(schedule)
+
+			if err != nil {
+				db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to decode Schedule: err=%v", err))
+			}
+		}
+
+		if unit, ok := el["Unit"].(string); ok {
+			habit.Unit = unit
+		}
+
+		if dailyGoal, ok := el["DailyGoal"].(float64); ok {
+			habit.DailyGoal = dailyGoal
+		}
+
+		habit.Revision = decodeRevision(el["Revision"])
+
+		if measurements, ok := el["Measurements"].(bson.A); ok {
+			habit.Measurements, err = decodeHabitMeasurements(measurements)
+
+			if err != nil {
+				db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to decode Measurements: err=%v", err))
+			}
+		}
+
 		if completionDates, ok := el["CompletionDates"].(bson.A); ok {
 			var completionDate []string
 			for _, date := range completionDates {
@@ -592,21 +1542,23 @@ func (db *MongoDB) RetrieveAllHabitsHandler(userId string) (interface{}, error)
 			habit.CompletionDates = []string{}
 		}
 
-		results = append(results, habit)
+		if err := fn(habit); err != nil {
+			return err
+		}
 	}
 
 	if err := cur.Err(); err != nil {
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: err=%v", err))
-		return nil, fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
+		return fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
 	}
 
-	return results, nil
+	return nil
 }
 
-func (db *MongoDB) RetrieveHabitsHandler(userId, habitId string) (interface{}, error) {
+func (db *MongoDB) RetrieveHabitsHandler(ctx context.Context, userId, habitId string) (data.Habit, error) {
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s\n", userId, habitId))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newHabitCollection := db.NewHabitsCollection()
@@ -614,21 +1566,27 @@ func (db *MongoDB) RetrieveHabitsHandler(userId, habitId string) (interface{}, e
 	objectID, err := primitive.ObjectIDFromHex(habitId)
 	if err != nil {
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: userId=%s, err=%v", userId, err))
-		return nil, fmt.Errorf("%s - Failed to retrieve habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+		return data.Habit{}, fmt.Errorf("%s - Failed to retrieve habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
 	}
 
-	filter := bson.M{"_id": bson.ObjectID(objectID)}
+	objectUserID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: userId=%s, err=%v", userId, err))
+		return data.Habit{}, fmt.Errorf("%s - Failed to retrieve habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+	}
+
+	filter := bson.M{"_id": bson.ObjectID(objectID), "UserID": bson.ObjectID(objectUserID)}
 	var result bson.M
 	var habit data.Habit
 	err = newHabitCollection.FindOne(ctx, filter).Decode(&result)
 
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, fmt.Errorf("%s - Habit doesn't exist", helper.GetFunctionName())
+			return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrHabitNotFound)
 		}
 
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: userId=%s, err=%v", userId, err))
-		return nil, fmt.Errorf("%s - Failed to retrieve habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+		return data.Habit{}, fmt.Errorf("%s - Failed to retrieve habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
 	}
 
 	// Manually assign other fields from result to user struct
@@ -656,6 +1614,32 @@ func (db *MongoDB) RetrieveHabitsHandler(userId, habitId string) (interface{}, e
 		habit.DaysTarget = int(daysTarget)
 	}
 
+	if schedule, ok := result["Schedule"]; ok {
+		habit.Schedule, err = decodeHabitSchedule(schedule)
+
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to decode Schedule: err=%v", err))
+		}
+	}
+
+	if unit, ok := result["Unit"].(string); ok {
+		habit.Unit = unit
+	}
+
+	if dailyGoal, ok := result["DailyGoal"].(float64); ok {
+		habit.DailyGoal = dailyGoal
+	}
+
+	habit.Revision = decodeRevision(result["Revision"])
+
+	if measurements, ok := result["Measurements"].(bson.A); ok {
+		habit.Measurements, err = decodeHabitMeasurements(measurements)
+
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to decode Measurements: err=%v", err))
+		}
+	}
+
 	if completionDates, ok := result["CompletionDates"].(bson.A); ok {
 		var completionDate []string
 		for _, date := range completionDates {
@@ -672,18 +1656,10 @@ func (db *MongoDB) RetrieveHabitsHandler(userId, habitId string) (interface{}, e
 	return habit, nil
 }
 
-func (db *MongoDB) UpdateHabitsHandler(userId, habitId string, value interface{}) error {
+func (db *MongoDB) UpdateHabitsHandler(ctx context.Context, userId, habitId string, updateHabit data.Habit) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	updateHabit, ok := value.(data.Habit)
-
-	if !ok {
-		err := "value type is not data.Habit"
-		db.logger.ErrorLog(helper.GetFunctionName(), err)
-		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newHabitCollection := db.NewHabitsCollection()
@@ -695,14 +1671,26 @@ func (db *MongoDB) UpdateHabitsHandler(userId, habitId string, value interface{}
 		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
 	}
 
-	filter := bson.M{"_id": bson.ObjectID(objectId)}
+	objectUserId, err := primitive.ObjectIDFromHex(userId)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	// Only matches while the habit is still at the revision it was retrieved with
+	filter := bson.M{"_id": bson.ObjectID(objectId), "UserID": bson.ObjectID(objectUserId), "Revision": revisionFilter(updateHabit.Revision)}
 
 	update := bson.M{
 		"$set": bson.M{
 			"Name":            updateHabit.Name,
 			"DaysTarget":      updateHabit.DaysTarget,
 			"CompletionDates": updateHabit.CompletionDates,
+			"Schedule":        updateHabit.Schedule,
+			"Unit":            updateHabit.Unit,
+			"DailyGoal":       updateHabit.DailyGoal,
 		},
+		"$inc": bson.M{"Revision": int64(1)},
 	}
 
 	result, err := newHabitCollection.UpdateOne(ctx, filter, update)
@@ -712,23 +1700,19 @@ func (db *MongoDB) UpdateHabitsHandler(userId, habitId string, value interface{}
 		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
 	}
 
+	if result.MatchedCount == 0 {
+		return db.revisionMismatchOrNotFound(ctx, userId, habitId)
+	}
+
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The document has been updated. ModifiedCount: %v, UpsertedCount: %v", result.ModifiedCount, result.UpsertedCount))
 
 	return nil
 }
 
-func (db *MongoDB) UpdateAllHabitsHandler(userId string, value interface{}) error {
+func (db *MongoDB) UpdateAllHabitsHandler(ctx context.Context, userId string, updateHabits []data.Habit) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	updateHabits, ok := value.([]data.Habit)
-
-	if !ok {
-		err := "value type is not data.Habit"
-		db.logger.ErrorLog(helper.GetFunctionName(), err)
-		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
 
 	newHabitCollection := db.NewHabitsCollection()
@@ -749,17 +1733,25 @@ func (db *MongoDB) UpdateAllHabitsHandler(userId string, value interface{}) erro
 		}
 
 		update := mongo.NewUpdateOneModel().
-			SetFilter(bson.M{"_id": bson.ObjectID(objectId), "UserID": bson.ObjectID(objectUserId)}).
+			SetFilter(bson.M{"_id": bson.ObjectID(objectId), "UserID": bson.ObjectID(objectUserId), "Revision": revisionFilter(habit.Revision)}).
 			SetUpdate(bson.M{
 				"$set": bson.M{
 					"Name":            habit.Name,
 					"DaysTarget":      habit.DaysTarget,
 					"CompletionDates": habit.CompletionDates,
+					"Schedule":        habit.Schedule,
+					"Unit":            habit.Unit,
+					"DailyGoal":       habit.DailyGoal,
 				},
+				"$inc": bson.M{"Revision": int64(1)},
 			})
 		models = append(models, update)
 	}
 
+	if len(models) == 0 {
+		return nil
+	}
+
 	result, err := newHabitCollection.BulkWrite(ctx, models)
 	if err != nil {
 		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, err=%s", userId, err))
@@ -768,13 +1760,19 @@ func (db *MongoDB) UpdateAllHabitsHandler(userId string, value interface{}) erro
 
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The document has been updated. ModifiedCount: %v, UpsertedCount: %v", result.ModifiedCount, result.UpsertedCount))
 
+	// Without a transaction the habits that did match are still written, but a stale habit is never overwritten
+	if result.MatchedCount < int64(len(models)) {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("%d of %d habits changed since they were retrieved for userId=%s", int64(len(models))-result.MatchedCount, len(models), userId))
+		return fmt.Errorf("%s - Failed to update habits for userId=%s: %w", helper.GetFunctionName(), userId, ErrRevisionMismatch)
+	}
+
 	return nil
 }
 
-func (db *MongoDB) DeleteHabitsHandler(userId, habitId string) error {
+func (db *MongoDB) DeleteHabitsHandler(ctx context.Context, userId, habitId string, revision int64) error {
 	db.logger.InfoLog(helper.GetFunctionName(), "")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
 	newHabitCollection := db.NewHabitsCollection()
@@ -785,7 +1783,13 @@ func (db *MongoDB) DeleteHabitsHandler(userId, habitId string) error {
 		return fmt.Errorf("%s - Failed to delete habit: err=%v", helper.GetFunctionName(), err)
 	}
 
-	filter := bson.M{"_id": bson.ObjectID(objectID)}
+	objectUserID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete habit: err=%v", err))
+		return fmt.Errorf("%s - Failed to delete habit: err=%v", helper.GetFunctionName(), err)
+	}
+
+	filter := bson.M{"_id": bson.ObjectID(objectID), "UserID": bson.ObjectID(objectUserID), "Revision": revisionFilter(revision)}
 
 	result, err := newHabitCollection.DeleteOne(ctx, filter)
 
@@ -794,7 +1798,290 @@ func (db *MongoDB) DeleteHabitsHandler(userId, habitId string) error {
 		return fmt.Errorf("%s - Failed to delete habit for userId=%s: %v", helper.GetFunctionName(), userId, err)
 	}
 
+	if result.DeletedCount == 0 {
+		return db.revisionMismatchOrNotFound(ctx, userId, habitId)
+	}
+
 	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The document has been updated. Acknowledged: %v, DeleteCount: %v", result.Acknowledged, result.DeletedCount))
 
 	return nil
 }
+
+/*
+CreateCompletionHandler adds a completion date with $addToSet. The filter only matches when the date isn't already present,
+so two concurrent check-ins can't both succeed and neither overwrites the rest of CompletionDates.
+*/
+func (db *MongoDB) CreateCompletionHandler(ctx context.Context, userId, habitId, completionDate string) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userId, habitId, completionDate))
+
+	filter, err := habitFilter(userId, habitId)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to add completion for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return data.Habit{}, fmt.Errorf("%s - Failed to add completion for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	filter["CompletionDates"] = bson.M{"$ne": completionDate}
+	update := bson.M{"$addToSet": bson.M{"CompletionDates": completionDate}}
+
+	if err := db.updateCompletionDates(ctx, userId, habitId, filter, update, ErrCompletionExists); err != nil {
+		return data.Habit{}, err
+	}
+
+	return db.RetrieveHabitsHandler(ctx, userId, habitId)
+}
+
+// CreateMeasurementHandler appends a measurement with $push so concurrent check-ins on the same day are all kept
+func (db *MongoDB) CreateMeasurementHandler(ctx context.Context, userId, habitId string, measurement data.HabitMeasurement) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, date=%s, value=%v", userId, habitId, measurement.Date, measurement.Value))
+
+	filter, err := habitFilter(userId, habitId)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to add measurement for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return data.Habit{}, fmt.Errorf("%s - Failed to add measurement for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	update := bson.M{"$push": bson.M{"Measurements": measurement}}
+
+	if err := db.updateCompletionDates(ctx, userId, habitId, filter, update, ErrHabitNotFound); err != nil {
+		return data.Habit{}, err
+	}
+
+	return db.RetrieveHabitsHandler(ctx, userId, habitId)
+}
+
+// DeleteCompletionHandler removes every occurrence of a completion date, and every measurement on it, with $pull
+func (db *MongoDB) DeleteCompletionHandler(ctx context.Context, userId, habitId, completionDate string) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userId, habitId, completionDate))
+
+	filter, err := habitFilter(userId, habitId)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to remove completion for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return data.Habit{}, fmt.Errorf("%s - Failed to remove completion for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	filter["$or"] = bson.A{bson.M{"CompletionDates": completionDate}, bson.M{"Measurements.Date": completionDate}}
+	update := bson.M{"$pull": bson.M{"CompletionDates": completionDate, "Measurements": bson.M{"Date": completionDate}}}
+
+	if err := db.updateCompletionDates(ctx, userId, habitId, filter, update, ErrCompletionNotFound); err != nil {
+		return data.Habit{}, err
+	}
+
+	return db.RetrieveHabitsHandler(ctx, userId, habitId)
+}
+
+// updateCompletionDates runs the update and, when nothing matched, works out whether the habit or the completion date was the problem
+func (db *MongoDB) updateCompletionDates(ctx context.Context, userId, habitId string, filter, update bson.M, errNoMatch error) error {
+	updateCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	update["$inc"] = bson.M{"Revision": int64(1)}
+
+	result, err := db.NewHabitsCollection().UpdateOne(updateCtx, filter, update)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	if result.MatchedCount == 0 {
+		if _, err := db.RetrieveHabitsHandler(ctx, userId, habitId); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("%s - habitId=%s: %w", helper.GetFunctionName(), habitId, errNoMatch)
+	}
+
+	return nil
+}
+
+// revisionMismatchOrNotFound works out why a conditional write matched nothing: the habit is gone, or it is at another revision
+func (db *MongoDB) revisionMismatchOrNotFound(ctx context.Context, userId, habitId string) error {
+	if _, err := db.RetrieveHabitsHandler(ctx, userId, habitId); err != nil {
+		return err
+	}
+
+	db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("habitId=%s changed since it was retrieved for userId=%s", habitId, userId))
+	return fmt.Errorf("%s - habitId=%s: %w", helper.GetFunctionName(), habitId, ErrRevisionMismatch)
+}
+
+// revisionFilter matches a habit at revision. Habits created before revisions were added have no Revision field and are at revision 0
+func revisionFilter(revision int64) any {
+	if revision == 0 {
+		return bson.M{"$in": bson.A{int64(0), nil}}
+	}
+
+	return revision
+}
+
+// decodeRevision reads the Revision field, which is missing on habits created before revisions were added
+func decodeRevision(value any) int64 {
+	switch revision := value.(type) {
+	case int64:
+		return revision
+	case int32:
+		return int64(revision)
+	default:
+		return 0
+	}
+}
+
+func habitFilter(userId, habitId string) (bson.M, error) {
+	objectId, err := primitive.ObjectIDFromHex(habitId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	objectUserId, err := primitive.ObjectIDFromHex(userId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"_id": bson.ObjectID(objectId), "UserID": bson.ObjectID(objectUserId)}, nil
+}
+
+// decodeHabitSchedule round trips the embedded Schedule document, which may be decoded as bson.D or bson.M, into data.HabitSchedule
+func decodeHabitSchedule(value any) (data.HabitSchedule, error) {
+	schedule := data.HabitSchedule{}
+
+	raw, err := bson.Marshal(value)
+
+	if err != nil {
+		return schedule, err
+	}
+
+	if err := bson.Unmarshal(raw, &schedule); err != nil {
+		return schedule, err
+	}
+
+	return schedule, nil
+}
+
+// decodeHabitMeasurements round trips the Measurements array, wrapped in a document as bson can only marshal documents, into []data.HabitMeasurement
+func decodeHabitMeasurements(value bson.A) ([]data.HabitMeasurement, error) {
+	wrapper := struct {
+		Measurements []data.HabitMeasurement `bson:"Measurements"`
+	}{Measurements: []data.HabitMeasurement{}}
+
+	raw, err := bson.Marshal(bson.M{"Measurements": value})
+
+	if err != nil {
+		return wrapper.Measurements, err
+	}
+
+	if err := bson.Unmarshal(raw, &wrapper); err != nil {
+		return wrapper.Measurements, err
+	}
+
+	return wrapper.Measurements, nil
+}
+
+/*
+userSessionFilter matches the user's live sessions, or only sessionID when it's set. The TTL monitor only runs once a minute,
+so sessions past SessionTTL are filtered out rather than relying on it to have removed them.
+*/
+func userSessionFilter(userID, sessionID string) (bson.M, error) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"UserID":    bson.ObjectID(objectID),
+		"CreatedAt": bson.M{"$gt": time.Now().Add(-SessionTTL)},
+	}
+
+	if sessionID != "" {
+		filter["_id"] = sessionID
+	}
+
+	return filter, nil
+}
+
+// livePersonalAccessTokenFilter matches tokens that haven't expired, as the TTL monitor only runs once a minute
+func livePersonalAccessTokenFilter() bson.M {
+	return bson.M{
+		"$or": bson.A{
+			bson.M{"ExpiresAt": bson.M{"$exists": false}},
+			bson.M{"ExpiresAt": bson.M{"$gt": time.Now()}},
+		},
+	}
+}
+
+func decodePersonalAccessToken(result bson.M) data.PersonalAccessToken {
+	personalAccessToken := data.PersonalAccessToken{Scopes: []string{}}
+
+	if tokenID, ok := result["_id"].(string); ok {
+		personalAccessToken.TokenID = tokenID
+	}
+
+	if userID, ok := result["UserID"].(bson.ObjectID); ok {
+		personalAccessToken.UserID = userID.Hex()
+	}
+
+	if name, ok := result["Name"].(string); ok {
+		personalAccessToken.Name = name
+	}
+
+	if tokenHash, ok := result["TokenHash"].(string); ok {
+		personalAccessToken.TokenHash = tokenHash
+	}
+
+	if scopes, ok := result["Scopes"].(bson.A); ok {
+		for _, scope := range scopes {
+			if scope, ok := scope.(string); ok {
+				personalAccessToken.Scopes = append(personalAccessToken.Scopes, scope)
+			}
+		}
+	}
+
+	if createdAt, ok := result["CreatedAt"].(bson.DateTime); ok {
+		personalAccessToken.CreatedAt = createdAt.Time()
+	}
+
+	if expiresAt, ok := result["ExpiresAt"].(bson.DateTime); ok {
+		t := expiresAt.Time()
+		personalAccessToken.ExpiresAt = &t
+	}
+
+	if lastUsedAt, ok := result["LastUsedAt"].(bson.DateTime); ok {
+		t := lastUsedAt.Time()
+		personalAccessToken.LastUsedAt = &t
+	}
+
+	return personalAccessToken
+}
+
+func decodeUserSession(result bson.M) data.UserSession {
+	userSession := data.UserSession{}
+
+	if sessionID, ok := result["_id"].(string); ok {
+		userSession.SessionID = sessionID
+	}
+
+	if userID, ok := result["UserID"].(bson.ObjectID); ok {
+		userSession.UserID = userID.Hex()
+	}
+
+	if refreshToken, ok := result["RefreshToken"].(string); ok {
+		userSession.RefreshToken = refreshToken
+	}
+
+	if userAgent, ok := result["UserAgent"].(string); ok {
+		userSession.UserAgent = userAgent
+	}
+
+	if ipAddress, ok := result["IpAddress"].(string); ok {
+		userSession.IPAddress = ipAddress
+	}
+
+	if createdAt, ok := result["CreatedAt"].(bson.DateTime); ok {
+		userSession.CreatedAt = createdAt.Time()
+	}
+
+	return userSession
+}
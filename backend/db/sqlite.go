@@ -0,0 +1,1643 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Enforce interface compliance
+var _ IDB = (*SQLiteDB)(nil)
+
+// Fixed width UTC layout so stored timestamps compare correctly as strings
+const sqliteTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+type SQLiteDB struct {
+	logger                  logger.ILogger
+	client                  *sql.DB
+	usersCollection         string
+	userSessionCollection   string
+	passwordResetCollection string
+	loginAttemptsCollection string
+	accessTokensCollection  string
+	habitsCollection        string
+}
+
+func NewSQLiteDB(logger logger.ILogger) *SQLiteDB {
+	return &SQLiteDB{
+		logger:                  logger,
+		usersCollection:         os.Getenv("USERS_COLLECTION"),
+		userSessionCollection:   os.Getenv("USER_SESSION_COLLECTION"),
+		passwordResetCollection: os.Getenv("PASSWORD_RESET_COLLECTION"),
+		loginAttemptsCollection: os.Getenv("LOGIN_ATTEMPTS_COLLECTION"),
+		accessTokensCollection:  os.Getenv("PERSONAL_ACCESS_TOKENS_COLLECTION"),
+		habitsCollection:        os.Getenv("HABITS_COLLECTION"),
+	}
+}
+
+/*
+Connect opens the SQLite file at DB_URL (e.g. DB_URL=habitsapp.db) and creates the users, user_session, password_reset, login_attempts, personal_access_tokens
+and habits tables if they don't exist. Completion dates and token scopes are stored as JSON arrays so a habit row has the same shape as the MongoDB habits document.
+*/
+func (db *SQLiteDB) Connect() error {
+	path := os.Getenv("DB_URL")
+
+	if path == "" {
+		db.logger.ErrorLog(helper.GetFunctionName(), "DB_URL is empty")
+		return fmt.Errorf("%s - DB_URL is empty", helper.GetFunctionName())
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+	db.logger.DebugLog(helper.GetFunctionName(), fmt.Sprintf("%s\n", path))
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+
+	client, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("%s", err))
+		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := client.PingContext(ctx); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("%s", err))
+		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+	}
+
+	db.client = client
+
+	if err := db.createSchema(ctx); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("failed to create schema: %v", err))
+		return fmt.Errorf("%s - failed to create schema: %v", helper.GetFunctionName(), err)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("You successfully connected to SQLite: %s", path))
+
+	return nil
+}
+
+func (db *SQLiteDB) createSchema(ctx context.Context) error {
+	if err := db.dropSingleSessionTable(ctx); err != nil {
+		return err
+	}
+
+	schema := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+			UserID INTEGER PRIMARY KEY AUTOINCREMENT,
+			Password TEXT NOT NULL,
+			FirstName TEXT NOT NULL,
+			LastName TEXT NOT NULL,
+			EmailAddress TEXT NOT NULL UNIQUE,
+			CreatedAt TEXT NOT NULL,
+			LastLogin TEXT NOT NULL,
+			Timezone TEXT NOT NULL DEFAULT '',
+			EmailVerified INTEGER NOT NULL DEFAULT 0,
+			TOTPSecret TEXT NOT NULL DEFAULT '',
+			TOTPEnabled INTEGER NOT NULL DEFAULT 0,
+			TOTPRecoveryCodes TEXT NOT NULL DEFAULT '[]',
+			TOTPLastUsedStep INTEGER NOT NULL DEFAULT 0,
+			Roles TEXT NOT NULL DEFAULT '[]',
+			Disabled INTEGER NOT NULL DEFAULT 0
+		)`, db.usersCollection),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+			SessionID TEXT PRIMARY KEY,
+			UserID INTEGER NOT NULL REFERENCES %q(UserID) ON DELETE CASCADE,
+			RefreshToken TEXT NOT NULL,
+			UserAgent TEXT NOT NULL,
+			IpAddress TEXT NOT NULL,
+			CreatedAt TEXT NOT NULL
+		)`, db.userSessionCollection, db.usersCollection),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (UserID)`, db.userSessionCollection+"_UserID", db.userSessionCollection),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+			TokenHash TEXT PRIMARY KEY,
+			UserID INTEGER NOT NULL REFERENCES %q(UserID) ON DELETE CASCADE,
+			CreatedAt TEXT NOT NULL
+		)`, db.passwordResetCollection, db.usersCollection),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (UserID)`, db.passwordResetCollection+"_UserID", db.passwordResetCollection),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+			"Key" TEXT PRIMARY KEY,
+			Failures INTEGER NOT NULL DEFAULT 0,
+			LastFailureAt TEXT NOT NULL,
+			LockedUntil TEXT NOT NULL,
+			ExpiresAt TEXT NOT NULL
+		)`, db.loginAttemptsCollection),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+			TokenID TEXT PRIMARY KEY,
+			UserID INTEGER NOT NULL REFERENCES %q(UserID) ON DELETE CASCADE,
+			Name TEXT NOT NULL,
+			TokenHash TEXT NOT NULL UNIQUE,
+			Scopes TEXT NOT NULL DEFAULT '[]',
+			CreatedAt TEXT NOT NULL,
+			ExpiresAt TEXT NOT NULL DEFAULT '',
+			LastUsedAt TEXT NOT NULL DEFAULT ''
+		)`, db.accessTokensCollection, db.usersCollection),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (UserID)`, db.accessTokensCollection+"_UserID", db.accessTokensCollection),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+			HabitID INTEGER PRIMARY KEY AUTOINCREMENT,
+			UserID INTEGER NOT NULL REFERENCES %q(UserID) ON DELETE CASCADE,
+			CreatedAt TEXT NOT NULL,
+			Name TEXT NOT NULL,
+			Days INTEGER NOT NULL DEFAULT 0,
+			DaysTarget INTEGER NOT NULL,
+			CompletionDates TEXT NOT NULL DEFAULT '[]',
+			Schedule TEXT NOT NULL DEFAULT '{}',
+			Unit TEXT NOT NULL DEFAULT '',
+			DailyGoal REAL NOT NULL DEFAULT 0,
+			Measurements TEXT NOT NULL DEFAULT '[]',
+			Revision INTEGER NOT NULL DEFAULT 0
+		)`, db.habitsCollection, db.usersCollection),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (UserID)`, db.habitsCollection+"_UserID", db.habitsCollection),
+	}
+
+	for _, statement := range schema {
+		if _, err := db.client.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return db.addMissingColumns(ctx)
+}
+
+/*
+dropSingleSessionTable drops a user_session table from before users could have several sessions, which was keyed by UserID,
+so createSchema recreates it keyed by SessionID. Sessions only last SessionTTL, so users are just asked to log in again.
+*/
+func (db *SQLiteDB) dropSingleSessionTable(ctx context.Context) error {
+	var count int
+
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'UserID' AND pk = 1`
+
+	if err := db.client.QueryRowContext(ctx, query, db.userSessionCollection).Scan(&count); err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("Recreating %s with a session per login", db.userSessionCollection))
+
+	_, err := db.client.ExecContext(ctx, fmt.Sprintf(`DROP TABLE %q`, db.userSessionCollection))
+
+	return err
+}
+
+// addMissingColumns adds columns introduced after a table was first created, as CREATE TABLE IF NOT EXISTS leaves existing tables alone
+func (db *SQLiteDB) addMissingColumns(ctx context.Context) error {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{db.usersCollection, "Timezone", `TEXT NOT NULL DEFAULT ''`},
+		{db.usersCollection, "EmailVerified", `INTEGER NOT NULL DEFAULT 0`},
+		{db.usersCollection, "TOTPSecret", `TEXT NOT NULL DEFAULT ''`},
+		{db.usersCollection, "TOTPEnabled", `INTEGER NOT NULL DEFAULT 0`},
+		{db.usersCollection, "TOTPRecoveryCodes", `TEXT NOT NULL DEFAULT '[]'`},
+		{db.usersCollection, "TOTPLastUsedStep", `INTEGER NOT NULL DEFAULT 0`},
+		{db.usersCollection, "Roles", `TEXT NOT NULL DEFAULT '[]'`},
+		{db.usersCollection, "Disabled", `INTEGER NOT NULL DEFAULT 0`},
+		{db.habitsCollection, "Schedule", `TEXT NOT NULL DEFAULT '{}'`},
+		{db.habitsCollection, "Unit", `TEXT NOT NULL DEFAULT ''`},
+		{db.habitsCollection, "DailyGoal", `REAL NOT NULL DEFAULT 0`},
+		{db.habitsCollection, "Measurements", `TEXT NOT NULL DEFAULT '[]'`},
+		{db.habitsCollection, "Revision", `INTEGER NOT NULL DEFAULT 0`},
+	}
+
+	for _, val := range columns {
+		var count int
+
+		query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`
+
+		if err := db.client.QueryRowContext(ctx, query, val.table, val.column).Scan(&count); err != nil {
+			return err
+		}
+
+		if count > 0 {
+			continue
+		}
+
+		if _, err := db.client.ExecContext(ctx, fmt.Sprintf(`ALTER TABLE %q ADD COLUMN %q %s`, val.table, val.column, val.definition)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) Disconnect() error {
+	if err := db.client.Close(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("%s", err))
+		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), "Successfully disconnected from SQLite")
+	return nil
+}
+
+func (db *SQLiteDB) RegisterUserHandler(ctx context.Context, newUser *data.RegisterUserRequest) (*data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	registerTime := time.Now().UTC()
+
+	query := fmt.Sprintf(`INSERT INTO %q (Password, FirstName, LastName, EmailAddress, CreatedAt, LastLogin, Timezone) VALUES (?, ?, ?, ?, ?, ?, ?)`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, newUser.Password, newUser.FirstName, newUser.LastName, newUser.EmailAddress, formatSQLiteTime(registerTime), formatSQLiteTime(registerTime), newUser.Timezone)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserExists)
+	}
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert new user: %v", err))
+		return nil, fmt.Errorf("%s - Failed to insert new user: %v", helper.GetFunctionName(), err)
+	}
+
+	userID, err := result.LastInsertId()
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get new user id: %v", err))
+		return nil, fmt.Errorf("%s - Failed to get new user id: %v", helper.GetFunctionName(), err)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("User registered successfully with EmailAddress: %s, UserID: %d", newUser.EmailAddress, userID))
+
+	return &data.UserData{
+		UserID:       strconv.FormatInt(userID, 10),
+		FirstName:    newUser.FirstName,
+		LastName:     newUser.LastName,
+		Password:     newUser.Password,
+		EmailAddress: newUser.EmailAddress,
+		Timezone:     newUser.Timezone,
+		CreatedAt:    registerTime,
+		LastLogin:    registerTime,
+	}, nil
+}
+
+func (db *SQLiteDB) LoginUser(ctx context.Context, userSession *data.UserSession) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	if userSession.CreatedAt.IsZero() {
+		userSession.CreatedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.client.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert user session for userId=%s", userSession.UserID))
+		return fmt.Errorf("%s - Failed to insert user session for userId=%s: %v", helper.GetFunctionName(), userSession.UserID, err)
+	}
+	defer tx.Rollback()
+
+	insertSession := fmt.Sprintf(`INSERT INTO %q (SessionID, UserID, RefreshToken, UserAgent, IpAddress, CreatedAt) VALUES (?, ?, ?, ?, ?, ?)`, db.userSessionCollection)
+
+	if _, err := tx.ExecContext(ctx, insertSession, userSession.SessionID, userSession.UserID, userSession.RefreshToken, userSession.UserAgent, userSession.IPAddress, formatSQLiteTime(userSession.CreatedAt)); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert user session for userId=%s", userSession.UserID))
+		return fmt.Errorf("%s - Failed to insert user session for userId=%s: %v", helper.GetFunctionName(), userSession.UserID, err)
+	}
+
+	updateLastLogin := fmt.Sprintf(`UPDATE %q SET LastLogin = ? WHERE UserID = ?`, db.usersCollection)
+
+	result, err := tx.ExecContext(ctx, updateLastLogin, formatSQLiteTime(userSession.CreatedAt), userSession.UserID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s", userSession.UserID))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s: %v", helper.GetFunctionName(), userSession.UserID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert user session for userId=%s", userSession.UserID))
+		return fmt.Errorf("%s - Failed to insert user session for userId=%s: %v", helper.GetFunctionName(), userSession.UserID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The row has been updated. RowsAffected: %v", rowsAffected))
+
+	return nil
+}
+
+func (db *SQLiteDB) LogoutUser(ctx context.Context, userID, sessionID string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredSessions(ctx); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %q WHERE UserID = ? AND SessionID = ?`, db.userSessionCollection)
+
+	result, err := db.client.ExecContext(ctx, query, userID, sessionID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user session for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to delete user session for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The row has been deleted. RowsAffected: %v", rowsAffected))
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) RetrieveUserSession(ctx context.Context, userID, sessionID string) (*data.UserSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredSessions(ctx); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %q WHERE UserID = ? AND SessionID = ?`, sqliteSessionColumns, db.userSessionCollection)
+
+	userSession, err := scanSQLiteUserSession(db.client.QueryRowContext(ctx, query, userID, sessionID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user session err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to get user session err=%s", helper.GetFunctionName(), err)
+	}
+
+	return &userSession, nil
+}
+
+func (db *SQLiteDB) RotateRefreshToken(ctx context.Context, userID, sessionID, oldRefreshToken, newRefreshToken string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredSessions(ctx); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`UPDATE %q SET RefreshToken = ? WHERE UserID = ? AND SessionID = ? AND RefreshToken = ?`, db.userSessionCollection)
+
+	result, err := db.client.ExecContext(ctx, query, newRefreshToken, userID, sessionID, oldRefreshToken)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to rotate the refresh token for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to rotate the refresh token for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) RetrieveUserSessions(ctx context.Context, userID string) ([]data.UserSession, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredSessions(ctx); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %q WHERE UserID = ? ORDER BY CreatedAt, SessionID`, sqliteSessionColumns, db.userSessionCollection)
+
+	rows, err := db.client.QueryContext(ctx, query, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user sessions: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve user sessions: err=%v", helper.GetFunctionName(), err)
+	}
+	defer rows.Close()
+
+	userSessions := []data.UserSession{}
+
+	for rows.Next() {
+		userSession, err := scanSQLiteUserSession(rows)
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user sessions: err=%v", err))
+			return nil, fmt.Errorf("%s - Failed to retrieve user sessions: err=%v", helper.GetFunctionName(), err)
+		}
+
+		userSessions = append(userSessions, userSession)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve user sessions: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve user sessions: err=%v", helper.GetFunctionName(), err)
+	}
+
+	return userSessions, nil
+}
+
+func (db *SQLiteDB) DeleteUserSessions(ctx context.Context, userID, exceptSessionID string) (int64, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredSessions(ctx); err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %q WHERE UserID = ? AND SessionID != ?`, db.userSessionCollection)
+
+	result, err := db.client.ExecContext(ctx, query, userID, exceptSessionID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user sessions for userId=%s", userID))
+		return 0, fmt.Errorf("%s - Failed to delete user sessions for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The rows have been deleted. RowsAffected: %v", rowsAffected))
+
+	return rowsAffected, nil
+}
+
+// SQLite has no TTL index, so expired sessions are removed before sessions are read or deleted
+func (db *SQLiteDB) removeExpiredSessions(ctx context.Context) error {
+	expireSessions := fmt.Sprintf(`DELETE FROM %q WHERE CreatedAt < ?`, db.userSessionCollection)
+
+	if _, err := db.client.ExecContext(ctx, expireSessions, formatSQLiteTime(time.Now().Add(-SessionTTL))); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to remove expired user sessions err=%s", err))
+		return fmt.Errorf("%s - Failed to remove expired user sessions err=%s", helper.GetFunctionName(), err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) CreatePasswordReset(ctx context.Context, passwordReset *data.PasswordReset) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", passwordReset.UserID))
+
+	if passwordReset.CreatedAt.IsZero() {
+		passwordReset.CreatedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := db.client.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert password reset for userId=%s", passwordReset.UserID))
+		return fmt.Errorf("%s - Failed to insert password reset for userId=%s: %v", helper.GetFunctionName(), passwordReset.UserID, err)
+	}
+	defer tx.Rollback()
+
+	// Only the latest reset email works, and SQLite has no TTL index so expired resets are removed here too
+	deleteResets := fmt.Sprintf(`DELETE FROM %q WHERE UserID = ? OR CreatedAt < ?`, db.passwordResetCollection)
+
+	if _, err := tx.ExecContext(ctx, deleteResets, passwordReset.UserID, formatSQLiteTime(time.Now().Add(-PasswordResetTTL))); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete password resets for userId=%s", passwordReset.UserID))
+		return fmt.Errorf("%s - Failed to delete password resets for userId=%s: %v", helper.GetFunctionName(), passwordReset.UserID, err)
+	}
+
+	insertReset := fmt.Sprintf(`INSERT INTO %q (TokenHash, UserID, CreatedAt) VALUES (?, ?, ?)`, db.passwordResetCollection)
+
+	if _, err := tx.ExecContext(ctx, insertReset, passwordReset.TokenHash, passwordReset.UserID, formatSQLiteTime(passwordReset.CreatedAt)); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert password reset for userId=%s", passwordReset.UserID))
+		return fmt.Errorf("%s - Failed to insert password reset for userId=%s: %v", helper.GetFunctionName(), passwordReset.UserID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert password reset for userId=%s", passwordReset.UserID))
+		return fmt.Errorf("%s - Failed to insert password reset for userId=%s: %v", helper.GetFunctionName(), passwordReset.UserID, err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) ConsumePasswordReset(ctx context.Context, tokenHash string) (*data.PasswordReset, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// The reset is deleted whether or not it has expired, an expired one can't be used anyway
+	query := fmt.Sprintf(`DELETE FROM %q WHERE TokenHash = ? RETURNING UserID, CreatedAt`, db.passwordResetCollection)
+
+	var userID int64
+	var createdAt string
+
+	err := db.client.QueryRowContext(ctx, query, tokenHash).Scan(&userID, &createdAt)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPasswordResetNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to consume password reset err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to consume password reset err=%s", helper.GetFunctionName(), err)
+	}
+
+	passwordReset := data.PasswordReset{
+		TokenHash: tokenHash,
+		UserID:    strconv.FormatInt(userID, 10),
+		CreatedAt: parseSQLiteTime(createdAt),
+	}
+
+	if time.Since(passwordReset.CreatedAt) >= PasswordResetTTL {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPasswordResetNotFound)
+	}
+
+	return &passwordReset, nil
+}
+
+func (db *SQLiteDB) RetrieveLoginAttempts(ctx context.Context, key string) (*data.LoginAttempts, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT Failures, LastFailureAt, LockedUntil FROM %q WHERE "Key" = ?`, db.loginAttemptsCollection)
+
+	var lastFailureAt, lockedUntil string
+
+	loginAttempts := data.LoginAttempts{Key: key}
+
+	err := db.client.QueryRowContext(ctx, query, key).Scan(&loginAttempts.Failures, &lastFailureAt, &lockedUntil)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return &loginAttempts, nil
+	}
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve login attempts err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve login attempts err=%s", helper.GetFunctionName(), err)
+	}
+
+	loginAttempts.LastFailureAt = parseSQLiteTime(lastFailureAt)
+	loginAttempts.LockedUntil = parseSQLiteTime(lockedUntil)
+
+	return &loginAttempts, nil
+}
+
+func (db *SQLiteDB) RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*data.LoginAttempts, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// SQLite has no TTL index, so the records that have expired are removed here
+	expireLoginAttempts := fmt.Sprintf(`DELETE FROM %q WHERE ExpiresAt < ?`, db.loginAttemptsCollection)
+
+	if _, err := db.client.ExecContext(ctx, expireLoginAttempts, formatSQLiteTime(now)); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to remove expired login attempts err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to remove expired login attempts err=%s", helper.GetFunctionName(), err)
+	}
+
+	// The expressions in DO UPDATE read the row as it was, so Failures is compared with the previous LastFailureAt
+	query := fmt.Sprintf(`INSERT INTO %q ("Key", Failures, LastFailureAt, LockedUntil, ExpiresAt) VALUES (?, 1, ?, ?, ?)
+		ON CONFLICT("Key") DO UPDATE SET
+			Failures = CASE WHEN LastFailureAt > ? THEN Failures + 1 ELSE 1 END,
+			LastFailureAt = excluded.LastFailureAt,
+			ExpiresAt = MAX(ExpiresAt, excluded.ExpiresAt)
+		RETURNING Failures, LastFailureAt, LockedUntil`, db.loginAttemptsCollection)
+
+	var lastFailureAt, lockedUntil string
+
+	loginAttempts := data.LoginAttempts{Key: key}
+
+	err := db.client.QueryRowContext(ctx, query, key, formatSQLiteTime(now), formatSQLiteTime(time.Time{}), formatSQLiteTime(now.Add(resetAfter)), formatSQLiteTime(now.Add(-resetAfter))).Scan(&loginAttempts.Failures, &lastFailureAt, &lockedUntil)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to record login failure err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to record login failure err=%s", helper.GetFunctionName(), err)
+	}
+
+	loginAttempts.LastFailureAt = parseSQLiteTime(lastFailureAt)
+	loginAttempts.LockedUntil = parseSQLiteTime(lockedUntil)
+
+	return &loginAttempts, nil
+}
+
+func (db *SQLiteDB) LockLoginAttempts(ctx context.Context, key string, lockedUntil time.Time) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`INSERT INTO %q ("Key", Failures, LastFailureAt, LockedUntil, ExpiresAt) VALUES (?, 0, ?, ?, ?)
+		ON CONFLICT("Key") DO UPDATE SET
+			LockedUntil = excluded.LockedUntil,
+			ExpiresAt = MAX(ExpiresAt, excluded.ExpiresAt)`, db.loginAttemptsCollection)
+
+	if _, err := db.client.ExecContext(ctx, query, key, formatSQLiteTime(time.Time{}), formatSQLiteTime(lockedUntil), formatSQLiteTime(lockedUntil)); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to lock login attempts err=%s", err))
+		return fmt.Errorf("%s - Failed to lock login attempts err=%s", helper.GetFunctionName(), err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) DeleteLoginAttempts(ctx context.Context, key string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM %q WHERE "Key" = ?`, db.loginAttemptsCollection)
+
+	if _, err := db.client.ExecContext(ctx, query, key); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete login attempts err=%s", err))
+		return fmt.Errorf("%s - Failed to delete login attempts err=%s", helper.GetFunctionName(), err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) CreatePersonalAccessToken(ctx context.Context, personalAccessToken *data.PersonalAccessToken) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", personalAccessToken.UserID))
+
+	if personalAccessToken.CreatedAt.IsZero() {
+		personalAccessToken.CreatedAt = time.Now()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	scopes, err := json.Marshal(personalAccessToken.Scopes)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert personal access token for userId=%s", personalAccessToken.UserID))
+		return fmt.Errorf("%s - Failed to insert personal access token for userId=%s: %v", helper.GetFunctionName(), personalAccessToken.UserID, err)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %q (TokenID, UserID, Name, TokenHash, Scopes, CreatedAt, ExpiresAt) VALUES (?, ?, ?, ?, ?, ?, ?)`, db.accessTokensCollection)
+
+	_, err = db.client.ExecContext(ctx, query, personalAccessToken.TokenID, personalAccessToken.UserID, personalAccessToken.Name, personalAccessToken.TokenHash,
+		string(scopes), formatSQLiteTime(personalAccessToken.CreatedAt), formatOptionalSQLiteTime(personalAccessToken.ExpiresAt))
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert personal access token for userId=%s", personalAccessToken.UserID))
+		return fmt.Errorf("%s - Failed to insert personal access token for userId=%s: %v", helper.GetFunctionName(), personalAccessToken.UserID, err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) RetrievePersonalAccessTokens(ctx context.Context, userID string) ([]data.PersonalAccessToken, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredPersonalAccessTokens(ctx); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %q WHERE UserID = ? ORDER BY CreatedAt, TokenID`, sqlitePersonalAccessTokenColumns, db.accessTokensCollection)
+
+	rows, err := db.client.QueryContext(ctx, query, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access tokens: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve personal access tokens: err=%v", helper.GetFunctionName(), err)
+	}
+	defer rows.Close()
+
+	personalAccessTokens := []data.PersonalAccessToken{}
+
+	for rows.Next() {
+		personalAccessToken, err := scanSQLitePersonalAccessToken(rows)
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access tokens: err=%v", err))
+			return nil, fmt.Errorf("%s - Failed to retrieve personal access tokens: err=%v", helper.GetFunctionName(), err)
+		}
+
+		personalAccessTokens = append(personalAccessTokens, personalAccessToken)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access tokens: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve personal access tokens: err=%v", helper.GetFunctionName(), err)
+	}
+
+	return personalAccessTokens, nil
+}
+
+func (db *SQLiteDB) RetrievePersonalAccessTokenByHash(ctx context.Context, tokenHash string) (*data.PersonalAccessToken, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredPersonalAccessTokens(ctx); err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM %q WHERE TokenHash = ?`, sqlitePersonalAccessTokenColumns, db.accessTokensCollection)
+
+	personalAccessToken, err := scanSQLitePersonalAccessToken(db.client.QueryRowContext(ctx, query, tokenHash))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve personal access token err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to retrieve personal access token err=%s", helper.GetFunctionName(), err)
+	}
+
+	return &personalAccessToken, nil
+}
+
+func (db *SQLiteDB) UpdatePersonalAccessTokenLastUsed(ctx context.Context, tokenID string, lastUsedAt time.Time) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("tokenId=%s", tokenID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET LastUsedAt = ? WHERE TokenID = ?`, db.accessTokensCollection)
+
+	result, err := db.client.ExecContext(ctx, query, formatSQLiteTime(lastUsedAt), tokenID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update personal access token tokenId=%s, err=%s", tokenID, err))
+		return fmt.Errorf("%s - Failed to update personal access token tokenId=%s, err=%s", helper.GetFunctionName(), tokenID, err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) DeletePersonalAccessToken(ctx context.Context, userID, tokenID string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, tokenId=%s", userID, tokenID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := db.removeExpiredPersonalAccessTokens(ctx); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %q WHERE UserID = ? AND TokenID = ?`, db.accessTokensCollection)
+
+	result, err := db.client.ExecContext(ctx, query, userID, tokenID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete personal access token for userId=%s", userID))
+		return fmt.Errorf("%s - Failed to delete personal access token for userId=%s: %v", helper.GetFunctionName(), userID, err)
+	}
+
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrPersonalAccessTokenNotFound)
+	}
+
+	return nil
+}
+
+// SQLite has no TTL index, so expired personal access tokens are removed before tokens are read or deleted. Tokens without an ExpiresAt never expire.
+func (db *SQLiteDB) removeExpiredPersonalAccessTokens(ctx context.Context) error {
+	expireTokens := fmt.Sprintf(`DELETE FROM %q WHERE ExpiresAt != '' AND ExpiresAt <= ?`, db.accessTokensCollection)
+
+	if _, err := db.client.ExecContext(ctx, expireTokens, formatSQLiteTime(time.Now())); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to remove expired personal access tokens err=%s", err))
+		return fmt.Errorf("%s - Failed to remove expired personal access tokens err=%s", helper.GetFunctionName(), err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) RetrieveUserDetails(ctx context.Context, emailAddress string) (*data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	user, err := db.findUser(ctx, "EmailAddress", emailAddress)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
+	}
+
+	return user, nil
+}
+
+func (db *SQLiteDB) RetrieveUserDetailsByID(ctx context.Context, userID string) (*data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	user, err := db.findUser(ctx, "UserID", userID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to get user details err=%s", err))
+		return nil, fmt.Errorf("%s - Failed to get user details err=%s", helper.GetFunctionName(), err)
+	}
+
+	return user, nil
+}
+
+func (db *SQLiteDB) UpdateUserTimezone(ctx context.Context, userID, timezone string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, timezone=%s", userID, timezone))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET Timezone = ? WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, timezone, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) UpdateUserName(ctx context.Context, userID, firstName, lastName string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET FirstName = ?, LastName = ? WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, firstName, lastName, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) UpdateUserEmailAddress(ctx context.Context, userID, emailAddress string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET EmailAddress = ?, EmailVerified = 1 WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, emailAddress, userID)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserExists)
+	}
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// DeleteUser relies on the foreign keys to delete the user's habits, sessions, personal access tokens and password resets with them
+func (db *SQLiteDB) DeleteUser(ctx context.Context, userID string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM %q WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete user for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to delete user for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) UpdateUserEmailVerified(ctx context.Context, userID string, emailVerified bool) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, emailVerified=%t", userID, emailVerified))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET EmailVerified = ? WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, emailVerified, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) UpdateUserDisabled(ctx context.Context, userID string, disabled bool) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, disabled=%t", userID, disabled))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET Disabled = ? WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, disabled, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) SearchUsers(ctx context.Context, query string, limit, offset int) ([]data.UserData, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("query=%s, limit=%d, offset=%d", query, limit, offset))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// instr rather than LIKE, so % and _ in the query are matched literally
+	statement := fmt.Sprintf(`SELECT %s FROM %q
+		WHERE instr(lower(EmailAddress), lower(?1)) > 0 OR instr(lower(FirstName), lower(?1)) > 0 OR instr(lower(LastName), lower(?1)) > 0
+		ORDER BY CreatedAt, UserID LIMIT ?2 OFFSET ?3`, sqliteUserColumns, db.usersCollection)
+
+	rows, err := db.client.QueryContext(ctx, statement, query, limit, offset)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to search users: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to search users: err=%v", helper.GetFunctionName(), err)
+	}
+	defer rows.Close()
+
+	users := []data.UserData{}
+
+	for rows.Next() {
+		user, err := scanSQLiteUser(rows)
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to search users: err=%v", err))
+			return nil, fmt.Errorf("%s - Failed to search users: err=%v", helper.GetFunctionName(), err)
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to search users: err=%v", err))
+		return nil, fmt.Errorf("%s - Failed to search users: err=%v", helper.GetFunctionName(), err)
+	}
+
+	return users, nil
+}
+
+func (db *SQLiteDB) UpdateUserTOTP(ctx context.Context, userID string, userTOTP data.UserTOTP) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, enabled=%t", userID, userTOTP.Enabled))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if userTOTP.RecoveryCodes == nil {
+		userTOTP.RecoveryCodes = []string{}
+	}
+
+	recoveryCodes, err := json.Marshal(userTOTP.RecoveryCodes)
+
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`UPDATE %q SET TOTPSecret = ?, TOTPEnabled = ?, TOTPRecoveryCodes = ?, TOTPLastUsedStep = ? WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, userTOTP.Secret, userTOTP.Enabled, string(recoveryCodes), userTOTP.LastUsedStep, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) ConsumeTOTPStep(ctx context.Context, userID string, step int64) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Only matches while step is later than the last one used, so the same code can't be accepted twice
+	query := fmt.Sprintf(`UPDATE %q SET TOTPLastUsedStep = ?1 WHERE UserID = ?2 AND TOTPLastUsedStep < ?1`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrTOTPStepUsed)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) ConsumeRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET
+			TOTPRecoveryCodes = (SELECT json_group_array(value) FROM json_each(TOTPRecoveryCodes) WHERE value <> ?1)
+		WHERE UserID = ?2 AND EXISTS (SELECT 1 FROM json_each(TOTPRecoveryCodes) WHERE value = ?1)`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, codeHash, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrRecoveryCodeNotFound)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) UpdateUserPassword(ctx context.Context, userID, hashedPassword string) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`UPDATE %q SET Password = ? WHERE UserID = ?`, db.usersCollection)
+
+	result, err := db.client.ExecContext(ctx, query, hashedPassword, userID)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update users collection for userId=%s, err=%s", userID, err))
+		return fmt.Errorf("%s - Failed to update users collection for userId=%s, err=%s", helper.GetFunctionName(), userID, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// findUser returns the user whose column, UserID or EmailAddress, equals value
+func (db *SQLiteDB) findUser(ctx context.Context, column, value string) (*data.UserData, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %q WHERE %q = ?`, sqliteUserColumns, db.usersCollection, column)
+
+	user, err := scanSQLiteUser(db.client.QueryRowContext(ctx, query, value))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (db *SQLiteDB) CreateHabitsHandler(ctx context.Context, userId string, newHabit data.NewHabit) (*data.NewHabitResponse, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	schedule, err := json.Marshal(newHabit.Schedule)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert new habit: userId=%s, err=%v", userId, err))
+		return nil, fmt.Errorf("%s - Failed to insert new habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %q (UserID, CreatedAt, Name, Days, DaysTarget, CompletionDates, Schedule, Unit, DailyGoal, Measurements, Revision) VALUES (?, ?, ?, 0, ?, '[]', ?, ?, ?, '[]', 1)`, db.habitsCollection)
+
+	result, err := db.client.ExecContext(ctx, query, userId, formatSQLiteTime(time.Now()), newHabit.Name, newHabit.DaysTarget, string(schedule), newHabit.Unit, newHabit.DailyGoal)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert new habit: userId=%s, err=%v", userId, err))
+		return nil, fmt.Errorf("%s - Failed to insert new habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+	}
+
+	habitID, err := result.LastInsertId()
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to insert new habit - couldn't get the new habitId: userId=%s, err=%v", userId, err))
+		return nil, fmt.Errorf("%s - Failed to insert new habit - couldn't get the new habitId: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("habit inserted successfully with userId: %s, HabitID: %d", userId, habitID))
+
+	return &data.NewHabitResponse{
+		HabitID:    strconv.FormatInt(habitID, 10),
+		Name:       newHabit.Name,
+		DaysTarget: newHabit.DaysTarget,
+		Schedule:   newHabit.Schedule,
+		Unit:       newHabit.Unit,
+		DailyGoal:  newHabit.DailyGoal,
+		Revision:   1,
+	}, nil
+}
+
+func (db *SQLiteDB) RetrieveAllHabitsHandler(ctx context.Context, userId string) ([]data.Habit, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var results []data.Habit
+
+	err := db.StreamAllHabitsHandler(ctx, userId, func(habit data.Habit) error {
+		results = append(results, habit)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (db *SQLiteDB) StreamAllHabitsHandler(ctx context.Context, userId string, fn func(data.Habit) error) error {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userId))
+
+	query := fmt.Sprintf(`SELECT %s FROM %q WHERE UserID = ? ORDER BY HabitID`, sqliteHabitColumns, db.habitsCollection)
+
+	rows, err := db.client.QueryContext(ctx, query, userId)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve all habits: userId=%s, err=%v", userId, err))
+		return fmt.Errorf("%s - Failed to retrieve all habits: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		habit, err := scanSQLiteHabit(rows)
+
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: err=%v", err))
+			return fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
+		}
+
+		if err := fn(habit); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: err=%v", err))
+		return fmt.Errorf("%s - Failed to retrieve habit: err=%v", helper.GetFunctionName(), err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) RetrieveHabitsHandler(ctx context.Context, userId, habitId string) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s\n", userId, habitId))
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`SELECT %s FROM %q WHERE HabitID = ? AND UserID = ?`, sqliteHabitColumns, db.habitsCollection)
+
+	habit, err := scanSQLiteHabit(db.client.QueryRowContext(ctx, query, habitId, userId))
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return data.Habit{}, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrHabitNotFound)
+		}
+
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to retrieve habit: userId=%s, err=%v", userId, err))
+		return data.Habit{}, fmt.Errorf("%s - Failed to retrieve habit: userId=%s, err=%v", helper.GetFunctionName(), userId, err)
+	}
+
+	return habit, nil
+}
+
+func (db *SQLiteDB) UpdateHabitsHandler(ctx context.Context, userId, habitId string, updateHabit data.Habit) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := db.updateHabit(ctx, db.client, userId, habitId, updateHabit)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return db.revisionMismatchOrNotFound(ctx, userId, habitId)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The row has been updated. RowsAffected: %v", rowsAffected))
+
+	return nil
+}
+
+func (db *SQLiteDB) UpdateAllHabitsHandler(ctx context.Context, userId string, updateHabits []data.Habit) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	tx, err := db.client.BeginTx(ctx, nil)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, err=%s", userId, err))
+		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, err=%s", helper.GetFunctionName(), userId, err)
+	}
+	defer tx.Rollback()
+
+	var rowsAffected int64
+
+	for _, habit := range updateHabits {
+		result, err := db.updateHabit(ctx, tx, userId, habit.HabitID, habit)
+
+		if err != nil {
+			db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, habitId=%s, err=%s", userId, habit.HabitID, err))
+			return fmt.Errorf("%s - Failed to update habits collection for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habit.HabitID, err)
+		}
+
+		count, _ := result.RowsAffected()
+
+		// Rolls back the habits already written so a stale habit leaves them all unchanged
+		if count == 0 {
+			tx.Rollback()
+			return db.revisionMismatchOrNotFound(ctx, userId, habit.HabitID)
+		}
+
+		rowsAffected += count
+	}
+
+	if err := tx.Commit(); err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, err=%s", userId, err))
+		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, err=%s", helper.GetFunctionName(), userId, err)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The rows have been updated. RowsAffected: %v", rowsAffected))
+
+	return nil
+}
+
+func (db *SQLiteDB) DeleteHabitsHandler(ctx context.Context, userId, habitId string, revision int64) error {
+	db.logger.InfoLog(helper.GetFunctionName(), "")
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := fmt.Sprintf(`DELETE FROM %q WHERE HabitID = ? AND UserID = ? AND Revision = ?`, db.habitsCollection)
+
+	result, err := db.client.ExecContext(ctx, query, habitId, userId, revision)
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to delete habit for userId=%s: %v", userId, err))
+		return fmt.Errorf("%s - Failed to delete habit for userId=%s: %v", helper.GetFunctionName(), userId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		return db.revisionMismatchOrNotFound(ctx, userId, habitId)
+	}
+
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("The row has been deleted. RowsAffected: %v", rowsAffected))
+
+	return nil
+}
+
+/*
+CreateCompletionHandler appends a completion date in a single UPDATE. The NOT EXISTS clause makes the check and the write atomic,
+so two concurrent check-ins can't both add the same date.
+*/
+func (db *SQLiteDB) CreateCompletionHandler(ctx context.Context, userId, habitId, completionDate string) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userId, habitId, completionDate))
+
+	query := fmt.Sprintf(`UPDATE %q SET CompletionDates = json_insert(CompletionDates, '$[#]', ?1), Revision = Revision + 1
+		WHERE HabitID = ?2 AND UserID = ?3 AND NOT EXISTS (SELECT 1 FROM json_each(CompletionDates) WHERE value = ?1)`, db.habitsCollection)
+
+	if err := db.updateCompletionDates(ctx, userId, habitId, query, completionDate, ErrCompletionExists); err != nil {
+		return data.Habit{}, err
+	}
+
+	return db.RetrieveHabitsHandler(ctx, userId, habitId)
+}
+
+// CreateMeasurementHandler appends a measurement in a single UPDATE
+func (db *SQLiteDB) CreateMeasurementHandler(ctx context.Context, userId, habitId string, measurement data.HabitMeasurement) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, date=%s, value=%v", userId, habitId, measurement.Date, measurement.Value))
+
+	value, err := json.Marshal(measurement)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to encode measurement for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return data.Habit{}, fmt.Errorf("%s - Failed to encode measurement for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	query := fmt.Sprintf(`UPDATE %q SET Measurements = json_insert(Measurements, '$[#]', json(?1)), Revision = Revision + 1 WHERE HabitID = ?2 AND UserID = ?3`, db.habitsCollection)
+
+	if err := db.updateCompletionDates(ctx, userId, habitId, query, string(value), ErrHabitNotFound); err != nil {
+		return data.Habit{}, err
+	}
+
+	return db.RetrieveHabitsHandler(ctx, userId, habitId)
+}
+
+// DeleteCompletionHandler removes every occurrence of a completion date, and every measurement on it, in a single UPDATE
+func (db *SQLiteDB) DeleteCompletionHandler(ctx context.Context, userId, habitId, completionDate string) (data.Habit, error) {
+	db.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userId, habitId, completionDate))
+
+	query := fmt.Sprintf(`UPDATE %q SET
+			CompletionDates = (SELECT json_group_array(value) FROM json_each(CompletionDates) WHERE value <> ?1),
+			Measurements = (SELECT json_group_array(json(value)) FROM json_each(Measurements) WHERE json_extract(value, '$.date') <> ?1),
+			Revision = Revision + 1
+		WHERE HabitID = ?2 AND UserID = ?3 AND (
+			EXISTS (SELECT 1 FROM json_each(CompletionDates) WHERE value = ?1) OR
+			EXISTS (SELECT 1 FROM json_each(Measurements) WHERE json_extract(value, '$.date') = ?1))`, db.habitsCollection)
+
+	if err := db.updateCompletionDates(ctx, userId, habitId, query, completionDate, ErrCompletionNotFound); err != nil {
+		return data.Habit{}, err
+	}
+
+	return db.RetrieveHabitsHandler(ctx, userId, habitId)
+}
+
+/*
+updateCompletionDates runs the update, binding value to ?1, and when no row changed works out whether the habit or
+the completion was the problem
+*/
+func (db *SQLiteDB) updateCompletionDates(ctx context.Context, userId, habitId, query, value string, errNoMatch error) error {
+	updateCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := db.client.ExecContext(updateCtx, query, value, habitId, userId)
+
+	if err != nil {
+		db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update habits collection for userId=%s, habitId=%s, err=%s", userId, habitId, err))
+		return fmt.Errorf("%s - Failed to update habits collection for userId=%s, habitId=%s, err=%s", helper.GetFunctionName(), userId, habitId, err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+
+	if rowsAffected == 0 {
+		if _, err := db.RetrieveHabitsHandler(ctx, userId, habitId); err != nil {
+			return err
+		}
+
+		return fmt.Errorf("%s - habitId=%s: %w", helper.GetFunctionName(), habitId, errNoMatch)
+	}
+
+	return nil
+}
+
+// sqliteExecer is satisfied by both *sql.DB and *sql.Tx
+type sqliteExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (db *SQLiteDB) updateHabit(ctx context.Context, execer sqliteExecer, userId, habitId string, habit data.Habit) (sql.Result, error) {
+	completionDates, err := marshalCompletionDates(habit.CompletionDates)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schedule, err := json.Marshal(habit.Schedule)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Measurements are left alone as they only change through CreateMeasurementHandler and DeleteCompletionHandler.
+	// Only matches while the habit is still at the revision it was retrieved with
+	query := fmt.Sprintf(`UPDATE %q SET Name = ?, Days = ?, DaysTarget = ?, CompletionDates = ?, Schedule = ?, Unit = ?, DailyGoal = ?, Revision = Revision + 1
+		WHERE HabitID = ? AND UserID = ? AND Revision = ?`, db.habitsCollection)
+
+	return execer.ExecContext(ctx, query, habit.Name, habit.Days, habit.DaysTarget, completionDates, string(schedule), habit.Unit, habit.DailyGoal, habitId, userId, habit.Revision)
+}
+
+// revisionMismatchOrNotFound works out why a conditional write matched no row: the habit is gone, or it is at another revision
+func (db *SQLiteDB) revisionMismatchOrNotFound(ctx context.Context, userId, habitId string) error {
+	if _, err := db.RetrieveHabitsHandler(ctx, userId, habitId); err != nil {
+		return err
+	}
+
+	db.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("habitId=%s changed since it was retrieved for userId=%s", habitId, userId))
+	return fmt.Errorf("%s - habitId=%s: %w", helper.GetFunctionName(), habitId, ErrRevisionMismatch)
+}
+
+// sqliteHabitColumns is the column order scanSQLiteHabit expects
+const sqliteHabitColumns = "HabitID, UserID, CreatedAt, Name, Days, DaysTarget, CompletionDates, Schedule, Unit, DailyGoal, Measurements, Revision"
+
+// sqliteScanner is satisfied by both *sql.Row and *sql.Rows
+type sqliteScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSQLiteHabit(row sqliteScanner) (data.Habit, error) {
+	var habit data.Habit
+	var habitID, userID int64
+	var createdAt, completionDates, schedule, measurements string
+
+	if err := row.Scan(&habitID, &userID, &createdAt, &habit.Name, &habit.Days, &habit.DaysTarget, &completionDates, &schedule, &habit.Unit, &habit.DailyGoal, &measurements, &habit.Revision); err != nil {
+		return data.Habit{}, err
+	}
+
+	habit.HabitID = strconv.FormatInt(habitID, 10)
+	habit.UserID = strconv.FormatInt(userID, 10)
+	habit.CreatedAt = parseSQLiteTime(createdAt)
+	habit.CompletionDates = []string{}
+
+	if err := json.Unmarshal([]byte(completionDates), &habit.CompletionDates); err != nil {
+		return data.Habit{}, fmt.Errorf("CompletionDates is not a JSON array: %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(schedule), &habit.Schedule); err != nil {
+		return data.Habit{}, fmt.Errorf("Schedule is not a JSON object: %v", err)
+	}
+
+	habit.Measurements = []data.HabitMeasurement{}
+
+	if err := json.Unmarshal([]byte(measurements), &habit.Measurements); err != nil {
+		return data.Habit{}, fmt.Errorf("Measurements is not a JSON array: %v", err)
+	}
+
+	return habit, nil
+}
+
+// sqliteUserColumns is the column order scanSQLiteUser expects
+const sqliteUserColumns = `UserID, Password, FirstName, LastName, EmailAddress, Timezone, EmailVerified, CreatedAt, LastLogin,
+	TOTPSecret, TOTPEnabled, TOTPRecoveryCodes, TOTPLastUsedStep, Roles, Disabled`
+
+func scanSQLiteUser(row sqliteScanner) (data.UserData, error) {
+	var user data.UserData
+	var userID int64
+	var createdAt, lastLogin, recoveryCodes, roles string
+
+	if err := row.Scan(&userID, &user.Password, &user.FirstName, &user.LastName, &user.EmailAddress, &user.Timezone, &user.EmailVerified, &createdAt, &lastLogin,
+		&user.TOTP.Secret, &user.TOTP.Enabled, &recoveryCodes, &user.TOTP.LastUsedStep, &roles, &user.Disabled); err != nil {
+		return data.UserData{}, err
+	}
+
+	if err := json.Unmarshal([]byte(recoveryCodes), &user.TOTP.RecoveryCodes); err != nil {
+		return data.UserData{}, err
+	}
+
+	if err := json.Unmarshal([]byte(roles), &user.Roles); err != nil {
+		return data.UserData{}, fmt.Errorf("Roles is not a JSON array: %v", err)
+	}
+
+	user.UserID = strconv.FormatInt(userID, 10)
+	user.CreatedAt = parseSQLiteTime(createdAt)
+	user.LastLogin = parseSQLiteTime(lastLogin)
+
+	return user, nil
+}
+
+// sqliteSessionColumns is the column order scanSQLiteUserSession expects
+const sqliteSessionColumns = "SessionID, UserID, RefreshToken, UserAgent, IpAddress, CreatedAt"
+
+func scanSQLiteUserSession(row sqliteScanner) (data.UserSession, error) {
+	var userSession data.UserSession
+	var userID int64
+	var createdAt string
+
+	if err := row.Scan(&userSession.SessionID, &userID, &userSession.RefreshToken, &userSession.UserAgent, &userSession.IPAddress, &createdAt); err != nil {
+		return data.UserSession{}, err
+	}
+
+	userSession.UserID = strconv.FormatInt(userID, 10)
+	userSession.CreatedAt = parseSQLiteTime(createdAt)
+
+	return userSession, nil
+}
+
+// sqlitePersonalAccessTokenColumns is the column order scanSQLitePersonalAccessToken expects
+const sqlitePersonalAccessTokenColumns = "TokenID, UserID, Name, TokenHash, Scopes, CreatedAt, ExpiresAt, LastUsedAt"
+
+func scanSQLitePersonalAccessToken(row sqliteScanner) (data.PersonalAccessToken, error) {
+	var personalAccessToken data.PersonalAccessToken
+	var userID int64
+	var scopes, createdAt, expiresAt, lastUsedAt string
+
+	if err := row.Scan(&personalAccessToken.TokenID, &userID, &personalAccessToken.Name, &personalAccessToken.TokenHash, &scopes, &createdAt, &expiresAt, &lastUsedAt); err != nil {
+		return data.PersonalAccessToken{}, err
+	}
+
+	personalAccessToken.UserID = strconv.FormatInt(userID, 10)
+	personalAccessToken.CreatedAt = parseSQLiteTime(createdAt)
+	personalAccessToken.ExpiresAt = parseOptionalSQLiteTime(expiresAt)
+	personalAccessToken.LastUsedAt = parseOptionalSQLiteTime(lastUsedAt)
+	personalAccessToken.Scopes = []string{}
+
+	if err := json.Unmarshal([]byte(scopes), &personalAccessToken.Scopes); err != nil {
+		return data.PersonalAccessToken{}, fmt.Errorf("Scopes is not a JSON array: %v", err)
+	}
+
+	return personalAccessToken, nil
+}
+
+func marshalCompletionDates(completionDates []string) (string, error) {
+	if completionDates == nil {
+		completionDates = []string{}
+	}
+
+	result, err := json.Marshal(completionDates)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
+
+func formatSQLiteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+func parseSQLiteTime(value string) time.Time {
+	t, err := time.Parse(sqliteTimeLayout, value)
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// formatOptionalSQLiteTime stores a missing time as an empty string
+func formatOptionalSQLiteTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return formatSQLiteTime(*t)
+}
+
+func parseOptionalSQLiteTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	t := parseSQLiteTime(value)
+
+	return &t
+}
+
+// isUniqueViolation reports whether err is from an insert or update that broke a UNIQUE constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
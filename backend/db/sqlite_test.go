@@ -0,0 +1,872 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestSQLiteDB(t *testing.T) *SQLiteDB {
+	t.Setenv("DB_URL", filepath.Join(t.TempDir(), "habitsapp.db"))
+	t.Setenv("USERS_COLLECTION", "users")
+	t.Setenv("USER_SESSION_COLLECTION", "user_session")
+	t.Setenv("PASSWORD_RESET_COLLECTION", "password_reset")
+	t.Setenv("LOGIN_ATTEMPTS_COLLECTION", "login_attempts")
+	t.Setenv("PERSONAL_ACCESS_TOKENS_COLLECTION", "personal_access_tokens")
+	t.Setenv("HABITS_COLLECTION", "habits")
+
+	db := NewSQLiteDB(logger.NewLogger(0))
+
+	if err := db.Connect(); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	t.Cleanup(func() { db.Disconnect() })
+
+	return db
+}
+
+func TestSQLiteUsersAndSessions(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	registerUserRequest := &data.RegisterUserRequest{EmailAddress: "sqlite@example.com", Password: "hashed", FirstName: "First", LastName: "Last"}
+
+	if _, err := db.RetrieveUserDetails(ctx, registerUserRequest.EmailAddress); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - new user should not exist - err=%v", helper.GetFunctionName(), err)
+	}
+
+	registered, err := db.RegisterUserHandler(ctx, registerUserRequest)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.RegisterUserHandler(ctx, registerUserRequest); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("%s - Failed - registering the same email twice - got err=%v, want=%v", helper.GetFunctionName(), err, ErrUserExists)
+	}
+
+	user, err := db.RetrieveUserDetails(ctx, registerUserRequest.EmailAddress)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user.UserID != registered.UserID || user.FirstName != "First" || user.Password != "hashed" {
+		t.Fatalf("%s - Failed - got=%+v", helper.GetFunctionName(), user)
+	}
+
+	if err := db.UpdateUserTimezone(ctx, user.UserID, "Europe/Paris"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user, err := db.RetrieveUserDetails(ctx, registerUserRequest.EmailAddress); err != nil || user.Timezone != "Europe/Paris" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user, err)
+	}
+
+	if err := db.UpdateUserTimezone(ctx, "999", "Europe/Paris"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - updating a missing user should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if user.EmailVerified {
+		t.Fatalf("%s - Failed - a new user should not be verified - got=%+v", helper.GetFunctionName(), user)
+	}
+
+	if err := db.UpdateUserEmailVerified(ctx, user.UserID, true); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user, err := db.RetrieveUserDetails(ctx, registerUserRequest.EmailAddress); err != nil || !user.EmailVerified {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user, err)
+	}
+
+	if err := db.UpdateUserEmailVerified(ctx, "999", true); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - verifying a missing user should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.RetrieveUserSession(ctx, user.UserID, "laptop"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - session should not exist before login - err=%v", helper.GetFunctionName(), err)
+	}
+
+	loginTime := time.Now()
+
+	for i, sessionID := range []string{"laptop", "phone", "tablet"} {
+		userSession := &data.UserSession{SessionID: sessionID, UserID: user.UserID, RefreshToken: "refresh-" + sessionID, UserAgent: "agent-" + sessionID, IPAddress: "192.0.2.1", CreatedAt: loginTime.Add(time.Duration(i) * time.Second)}
+
+		if err := db.LoginUser(ctx, userSession); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	userSession, err := db.RetrieveUserSession(ctx, user.UserID, "phone")
+
+	if err != nil || userSession.RefreshToken != "refresh-phone" || userSession.UserAgent != "agent-phone" || userSession.UserID != user.UserID {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), userSession, err)
+	}
+
+	if err := db.RotateRefreshToken(ctx, user.UserID, "phone", "refresh-phone", "rotated-phone"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := db.RotateRefreshToken(ctx, user.UserID, "phone", "refresh-phone", "rotated-again"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - a rotated out refresh token should not rotate - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if userSession, err := db.RetrieveUserSession(ctx, user.UserID, "phone"); err != nil || userSession.RefreshToken != "rotated-phone" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), userSession, err)
+	}
+
+	if _, err := db.RetrieveUserSession(ctx, "999", "phone"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - another user's session should not be returned - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if got := sqliteSessionIDs(t, db, user.UserID); !reflect.DeepEqual(got, []string{"laptop", "phone", "tablet"}) {
+		t.Fatalf("%s - Failed - got=%v", helper.GetFunctionName(), got)
+	}
+
+	if err := db.LogoutUser(ctx, user.UserID, "laptop"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := db.LogoutUser(ctx, user.UserID, "laptop"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - logging out twice should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.RetrieveUserSession(ctx, user.UserID, "laptop"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - session should not exist after logout - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if deleted, err := db.DeleteUserSessions(ctx, user.UserID, "tablet"); err != nil || deleted != 1 {
+		t.Fatalf("%s - Failed - deleted=%d, err=%v", helper.GetFunctionName(), deleted, err)
+	}
+
+	if got := sqliteSessionIDs(t, db, user.UserID); !reflect.DeepEqual(got, []string{"tablet"}) {
+		t.Fatalf("%s - Failed - got=%v", helper.GetFunctionName(), got)
+	}
+
+	if deleted, err := db.DeleteUserSessions(ctx, user.UserID, ""); err != nil || deleted != 1 {
+		t.Fatalf("%s - Failed - deleted=%d, err=%v", helper.GetFunctionName(), deleted, err)
+	}
+
+	if err := db.LoginUser(ctx, &data.UserSession{SessionID: "expired", UserID: user.UserID, RefreshToken: "expired", CreatedAt: time.Now().Add(-25 * time.Hour)}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.RetrieveUserSession(ctx, user.UserID, "expired"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - expired session should not be returned - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if got := sqliteSessionIDs(t, db, user.UserID); len(got) != 0 {
+		t.Fatalf("%s - Failed - expired session should not be listed - got=%v", helper.GetFunctionName(), got)
+	}
+}
+
+func sqliteSessionIDs(t *testing.T, db *SQLiteDB, userID string) []string {
+	userSessions, err := db.RetrieveUserSessions(context.Background(), userID)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	sessionIDs := []string{}
+
+	for _, val := range userSessions {
+		sessionIDs = append(sessionIDs, val.SessionID)
+	}
+
+	return sessionIDs
+}
+
+func TestSQLitePasswordResets(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	user, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "reset@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := db.CreatePasswordReset(ctx, &data.PasswordReset{TokenHash: "first", UserID: user.UserID}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// Asking for another reset replaces the first
+	if err := db.CreatePasswordReset(ctx, &data.PasswordReset{TokenHash: "second", UserID: user.UserID}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.ConsumePasswordReset(ctx, "first"); !errors.Is(err, ErrPasswordResetNotFound) {
+		t.Fatalf("%s - Failed - a replaced reset should not be usable - err=%v", helper.GetFunctionName(), err)
+	}
+
+	passwordReset, err := db.ConsumePasswordReset(ctx, "second")
+
+	if err != nil || passwordReset.UserID != user.UserID {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), passwordReset, err)
+	}
+
+	if _, err := db.ConsumePasswordReset(ctx, "second"); !errors.Is(err, ErrPasswordResetNotFound) {
+		t.Fatalf("%s - Failed - a reset should only be usable once - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if err := db.CreatePasswordReset(ctx, &data.PasswordReset{TokenHash: "expired", UserID: user.UserID, CreatedAt: time.Now().Add(-PasswordResetTTL)}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.ConsumePasswordReset(ctx, "expired"); !errors.Is(err, ErrPasswordResetNotFound) {
+		t.Fatalf("%s - Failed - an expired reset should not be usable - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if err := db.UpdateUserPassword(ctx, user.UserID, "rehashed"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user, err := db.RetrieveUserDetails(ctx, "reset@example.com"); err != nil || user.Password != "rehashed" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user, err)
+	}
+
+	if err := db.UpdateUserPassword(ctx, "999", "rehashed"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - updating a missing user should fail - err=%v", helper.GetFunctionName(), err)
+	}
+}
+
+func TestSQLiteLoginAttempts(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	loginAttempts, err := db.RetrieveLoginAttempts(ctx, "account:nobody@example.com")
+
+	if err != nil || loginAttempts.Failures != 0 || !loginAttempts.LockedUntil.IsZero() {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), loginAttempts, err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		loginAttempts, err = db.RecordLoginFailure(ctx, "account:reset@example.com", now.Add(time.Duration(i)*time.Second), time.Hour)
+
+		if err != nil || loginAttempts.Failures != i {
+			t.Fatalf("%s - Failed - got=%+v, want %d failures, err=%v", helper.GetFunctionName(), loginAttempts, i, err)
+		}
+	}
+
+	if err := db.LockLoginAttempts(ctx, "account:reset@example.com", now.Add(3*time.Hour)); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	loginAttempts, err = db.RetrieveLoginAttempts(ctx, "account:reset@example.com")
+
+	if err != nil || loginAttempts.Failures != 3 || !loginAttempts.LockedUntil.Equal(now.Add(3*time.Hour)) || !loginAttempts.LastFailureAt.Equal(now.Add(3*time.Second)) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), loginAttempts, err)
+	}
+
+	// A failure long enough after the last starts the count again, but leaves the lock
+	loginAttempts, err = db.RecordLoginFailure(ctx, "account:reset@example.com", now.Add(2*time.Hour), time.Hour)
+
+	if err != nil || loginAttempts.Failures != 1 || !loginAttempts.LockedUntil.Equal(now.Add(3*time.Hour)) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), loginAttempts, err)
+	}
+
+	if err := db.DeleteLoginAttempts(ctx, "account:reset@example.com"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	loginAttempts, err = db.RetrieveLoginAttempts(ctx, "account:reset@example.com")
+
+	if err != nil || loginAttempts.Failures != 0 || !loginAttempts.LockedUntil.IsZero() {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), loginAttempts, err)
+	}
+}
+
+func TestSQLitePersonalAccessTokens(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	user, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "tokens@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if found, err := db.RetrieveUserDetailsByID(ctx, user.UserID); err != nil || found.EmailAddress != "tokens@example.com" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), found, err)
+	}
+
+	if _, err := db.RetrieveUserDetailsByID(ctx, "999"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrUserNotFound)
+	}
+
+	expiresAt := now.Add(time.Hour)
+	expiredAt := now.Add(-time.Hour)
+
+	personalAccessTokens := []data.PersonalAccessToken{
+		{TokenID: "ci", UserID: user.UserID, Name: "CI", TokenHash: "hash-ci", Scopes: []string{data.ScopeHabitsRead}, CreatedAt: now},
+		{TokenID: "script", UserID: user.UserID, Name: "Script", TokenHash: "hash-script", Scopes: []string{data.ScopeHabitsRead, data.ScopeHabitsWrite}, CreatedAt: now.Add(time.Second), ExpiresAt: &expiresAt},
+		{TokenID: "expired", UserID: user.UserID, Name: "Expired", TokenHash: "hash-expired", Scopes: []string{data.ScopeHabitsRead}, CreatedAt: now.Add(2 * time.Second), ExpiresAt: &expiredAt},
+	}
+
+	for i := range personalAccessTokens {
+		if err := db.CreatePersonalAccessToken(ctx, &personalAccessTokens[i]); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	// The hash is unique, so a token can't be stored twice
+	if err := db.CreatePersonalAccessToken(ctx, &data.PersonalAccessToken{TokenID: "duplicate", UserID: user.UserID, Name: "Duplicate", TokenHash: "hash-ci", Scopes: []string{}}); err == nil {
+		t.Fatalf("%s - Failed - storing the same hash twice should fail", helper.GetFunctionName())
+	}
+
+	// Expired tokens aren't listed or found
+	got, err := db.RetrievePersonalAccessTokens(ctx, user.UserID)
+
+	if err != nil || len(got) != 2 {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), got, err)
+	}
+
+	for i, want := range personalAccessTokens[:2] {
+		if got[i].TokenID != want.TokenID || got[i].Name != want.Name || !reflect.DeepEqual(got[i].Scopes, want.Scopes) || !got[i].CreatedAt.Equal(want.CreatedAt) ||
+			(got[i].ExpiresAt == nil) != (want.ExpiresAt == nil) || (want.ExpiresAt != nil && !got[i].ExpiresAt.Equal(*want.ExpiresAt)) {
+			t.Fatalf("%s - Failed - got=%+v, want=%+v", helper.GetFunctionName(), got[i], want)
+		}
+	}
+
+	if _, err := db.RetrievePersonalAccessTokenByHash(ctx, "hash-expired"); !errors.Is(err, ErrPersonalAccessTokenNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrPersonalAccessTokenNotFound)
+	}
+
+	lastUsedAt := now.Add(time.Minute)
+
+	if err := db.UpdatePersonalAccessTokenLastUsed(ctx, "script", lastUsedAt); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	personalAccessToken, err := db.RetrievePersonalAccessTokenByHash(ctx, "hash-script")
+
+	if err != nil || personalAccessToken.TokenID != "script" || personalAccessToken.LastUsedAt == nil || !personalAccessToken.LastUsedAt.Equal(lastUsedAt) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), personalAccessToken, err)
+	}
+
+	// Only the owner can revoke a token
+	if err := db.DeletePersonalAccessToken(ctx, "999", "script"); !errors.Is(err, ErrPersonalAccessTokenNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrPersonalAccessTokenNotFound)
+	}
+
+	if err := db.DeletePersonalAccessToken(ctx, user.UserID, "script"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.RetrievePersonalAccessTokenByHash(ctx, "hash-script"); !errors.Is(err, ErrPersonalAccessTokenNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrPersonalAccessTokenNotFound)
+	}
+}
+
+func TestSQLiteTOTP(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	user, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "totp@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// A new user hasn't enrolled
+	if user, err := db.RetrieveUserDetails(ctx, "totp@example.com"); err != nil || !reflect.DeepEqual(user.TOTP, data.UserTOTP{RecoveryCodes: []string{}}) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user.TOTP, err)
+	}
+
+	userTOTP := data.UserTOTP{Secret: "JBSWY3DPEHPK3PXP", Enabled: true, RecoveryCodes: []string{"first", "second"}, LastUsedStep: 100}
+
+	if err := db.UpdateUserTOTP(ctx, user.UserID, userTOTP); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user, err := db.RetrieveUserDetails(ctx, "totp@example.com"); err != nil || !reflect.DeepEqual(user.TOTP, userTOTP) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user.TOTP, err)
+	}
+
+	// A code's time step can only be used once, and never one from before it
+	if err := db.ConsumeTOTPStep(ctx, user.UserID, 101); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	for _, step := range []int64{101, 99} {
+		if err := db.ConsumeTOTPStep(ctx, user.UserID, step); !errors.Is(err, ErrTOTPStepUsed) {
+			t.Fatalf("%s - Failed - step=%d, got err=%v, want=%v", helper.GetFunctionName(), step, err, ErrTOTPStepUsed)
+		}
+	}
+
+	if err := db.ConsumeRecoveryCode(ctx, user.UserID, "first"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	for _, codeHash := range []string{"first", "unknown"} {
+		if err := db.ConsumeRecoveryCode(ctx, user.UserID, codeHash); !errors.Is(err, ErrRecoveryCodeNotFound) {
+			t.Fatalf("%s - Failed - codeHash=%s, got err=%v, want=%v", helper.GetFunctionName(), codeHash, err, ErrRecoveryCodeNotFound)
+		}
+	}
+
+	if user, err := db.RetrieveUserDetails(ctx, "totp@example.com"); err != nil || !reflect.DeepEqual(user.TOTP.RecoveryCodes, []string{"second"}) || user.TOTP.LastUsedStep != 101 {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user.TOTP, err)
+	}
+
+	// Disabling removes the enrolment
+	if err := db.UpdateUserTOTP(ctx, user.UserID, data.UserTOTP{}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user, err := db.RetrieveUserDetails(ctx, "totp@example.com"); err != nil || user.TOTP.Enabled || user.TOTP.Secret != "" || len(user.TOTP.RecoveryCodes) != 0 {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user.TOTP, err)
+	}
+
+	if err := db.UpdateUserTOTP(ctx, "999", userTOTP); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - updating a missing user should fail - err=%v", helper.GetFunctionName(), err)
+	}
+}
+
+func TestSQLiteAdminUsers(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	for _, registerUserRequest := range []*data.RegisterUserRequest{
+		{EmailAddress: "ada@example.com", Password: "hashed", FirstName: "Ada", LastName: "Lovelace"},
+		{EmailAddress: "alan@example.com", Password: "hashed", FirstName: "Alan", LastName: "Turing"},
+		{EmailAddress: "grace_h@example.com", Password: "hashed", FirstName: "Grace", LastName: "Hopper"},
+	} {
+		if _, err := db.RegisterUserHandler(ctx, registerUserRequest); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		query  string
+		limit  int
+		offset int
+		want   []string
+	}{
+		{name: "Test an empty query matches everyone, oldest first", limit: 10, want: []string{"ada@example.com", "alan@example.com", "grace_h@example.com"}},
+		{name: "Test matching a name regardless of case", query: "TURING", limit: 10, want: []string{"alan@example.com"}},
+		{name: "Test matching part of an email address", query: "a@ex", limit: 10, want: []string{"ada@example.com"}},
+		{name: "Test LIKE wildcards are matched literally", query: "_h@", limit: 10, want: []string{"grace_h@example.com"}},
+		{name: "Test a page of results", limit: 1, offset: 1, want: []string{"alan@example.com"}},
+		{name: "Test no matches", query: "nobody", limit: 10, want: []string{}},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			users, err := db.SearchUsers(ctx, val.query, val.limit, val.offset)
+
+			if err != nil {
+				t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			got := []string{}
+
+			for _, user := range users {
+				got = append(got, user.EmailAddress)
+			}
+
+			if !reflect.DeepEqual(got, val.want) {
+				t.Errorf("%s - Failed - got=%v, want=%v", helper.GetFunctionName(), got, val.want)
+			}
+		})
+	}
+
+	user, err := db.RetrieveUserDetails(ctx, "ada@example.com")
+
+	if err != nil || user.Disabled || len(user.Roles) != 0 {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user, err)
+	}
+
+	if err := db.UpdateUserDisabled(ctx, user.UserID, true); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// Roles are granted in the database
+	if _, err := db.client.Exec(`UPDATE "users" SET Roles = '["support"]' WHERE UserID = ?`, user.UserID); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user, err := db.RetrieveUserDetailsByID(ctx, user.UserID); err != nil || !user.Disabled || !reflect.DeepEqual(user.Roles, []string{data.RoleSupport}) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), user, err)
+	}
+
+	if err := db.UpdateUserDisabled(ctx, "999", true); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - disabling a missing user should fail - err=%v", helper.GetFunctionName(), err)
+	}
+}
+
+func TestSQLiteAccount(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	user, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "account@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	other, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "other@example.com", Password: "hashed", FirstName: "Other", LastName: "User"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := db.UpdateUserName(ctx, user.UserID, "New", "Name"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := db.UpdateUserEmailAddress(ctx, user.UserID, "moved@example.com"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if found, err := db.RetrieveUserDetailsByID(ctx, user.UserID); err != nil || found.FirstName != "New" || found.LastName != "Name" || found.EmailAddress != "moved@example.com" || !found.EmailVerified {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), found, err)
+	}
+
+	// The address is unique
+	if err := db.UpdateUserEmailAddress(ctx, user.UserID, "other@example.com"); !errors.Is(err, ErrUserExists) {
+		t.Fatalf("%s - Failed - moving to another user's address - got err=%v, want=%v", helper.GetFunctionName(), err, ErrUserExists)
+	}
+
+	if err := db.UpdateUserName(ctx, "999", "New", "Name"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrUserNotFound)
+	}
+
+	for _, userID := range []string{user.UserID, other.UserID} {
+		if _, err := db.CreateHabitsHandler(ctx, userID, data.NewHabit{Name: "Read", DaysTarget: 30}); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		if err := db.LoginUser(ctx, &data.UserSession{SessionID: "session-" + userID, UserID: userID, CreatedAt: now}); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		if err := db.CreatePersonalAccessToken(ctx, &data.PersonalAccessToken{TokenID: "token-" + userID, UserID: userID, Name: "Script", TokenHash: "hash-" + userID, Scopes: []string{}, CreatedAt: now}); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	if err := db.DeleteUser(ctx, user.UserID); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.RetrieveUserDetailsByID(ctx, user.UserID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrUserNotFound)
+	}
+
+	// Everything else of theirs goes with them, and nobody else's
+	for _, userID := range []string{user.UserID, other.UserID} {
+		want := 0
+
+		if userID == other.UserID {
+			want = 1
+		}
+
+		habits, err := db.RetrieveAllHabitsHandler(ctx, userID)
+
+		if err != nil || len(habits) != want {
+			t.Errorf("%s - Failed - userId=%s, habits=%+v, err=%v", helper.GetFunctionName(), userID, habits, err)
+		}
+
+		sessions, err := db.RetrieveUserSessions(ctx, userID)
+
+		if err != nil || len(sessions) != want {
+			t.Errorf("%s - Failed - userId=%s, sessions=%+v, err=%v", helper.GetFunctionName(), userID, sessions, err)
+		}
+
+		personalAccessTokens, err := db.RetrievePersonalAccessTokens(ctx, userID)
+
+		if err != nil || len(personalAccessTokens) != want {
+			t.Errorf("%s - Failed - userId=%s, tokens=%+v, err=%v", helper.GetFunctionName(), userID, personalAccessTokens, err)
+		}
+	}
+
+	if err := db.DeleteUser(ctx, user.UserID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrUserNotFound)
+	}
+}
+
+func TestSQLiteHabits(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	registered, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "habits@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	userId := registered.UserID
+
+	schedule := data.HabitSchedule{Type: data.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Wednesday}}
+
+	newHabitResponse, err := db.CreateHabitsHandler(ctx, userId, data.NewHabit{Name: "Read", DaysTarget: 30, Schedule: schedule})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	habit, err := db.RetrieveHabitsHandler(ctx, userId, newHabitResponse.HabitID)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if habit.Name != "Read" || habit.DaysTarget != 30 || !reflect.DeepEqual(habit.CompletionDates, []string{}) || !reflect.DeepEqual(habit.Schedule, schedule) {
+		t.Fatalf("%s - Failed - got=%+v", helper.GetFunctionName(), habit)
+	}
+
+	if _, err := db.RetrieveHabitsHandler(ctx, "999", newHabitResponse.HabitID); !errors.Is(err, ErrHabitNotFound) {
+		t.Fatalf("%s - Failed - habit should not be visible to another user - err=%v", helper.GetFunctionName(), err)
+	}
+
+	habit.Name = "Read more"
+	habit.CompletionDates = []string{"2025-01-01", "2025-01-02"}
+
+	if err := db.UpdateHabitsHandler(ctx, userId, habit.HabitID, habit); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// The habit is now at the next revision so writing the one we retrieved again is stale
+	if err := db.UpdateHabitsHandler(ctx, userId, habit.HabitID, habit); !errors.Is(err, ErrRevisionMismatch) {
+		t.Fatalf("%s - Failed - a stale update should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	habit.Revision++
+	habit.DaysTarget = 60
+	habit.Schedule = data.HabitSchedule{Type: data.ScheduleTimesPerWeek, Times: 3}
+
+	if err := db.UpdateAllHabitsHandler(ctx, userId, []data.Habit{habit}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	habit.Revision++
+
+	habits, err := db.RetrieveAllHabitsHandler(ctx, userId)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if len(habits) != 1 || !reflect.DeepEqual(habits[0], habit) {
+		t.Fatalf("%s - Failed - got=%+v, want=%+v", helper.GetFunctionName(), habits, habit)
+	}
+
+	// Streaming stops at the first error fn returns
+	errStop := errors.New("stop")
+
+	if err := db.StreamAllHabitsHandler(ctx, userId, func(data.Habit) error { return errStop }); !errors.Is(err, errStop) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, errStop)
+	}
+
+	if err := db.DeleteHabitsHandler(ctx, userId, habit.HabitID, habit.Revision-1); !errors.Is(err, ErrRevisionMismatch) {
+		t.Fatalf("%s - Failed - a stale delete should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if err := db.DeleteHabitsHandler(ctx, userId, habit.HabitID, habit.Revision); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := db.DeleteHabitsHandler(ctx, userId, habit.HabitID, habit.Revision); !errors.Is(err, ErrHabitNotFound) {
+		t.Fatalf("%s - Failed - deleting a missing habit should fail - err=%v", helper.GetFunctionName(), err)
+	}
+}
+
+func TestSQLiteCompletions(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	registered, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "completions@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	userId := registered.UserID
+
+	newHabitResponse, err := db.CreateHabitsHandler(ctx, userId, data.NewHabit{Name: "Read", DaysTarget: 30})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	habitId := newHabitResponse.HabitID
+
+	for _, completionDate := range []string{"2025-01-01", "2025-01-02"} {
+		if _, err := db.CreateCompletionHandler(ctx, userId, habitId, completionDate); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	if _, err := db.CreateCompletionHandler(ctx, userId, habitId, "2025-01-01"); !errors.Is(err, ErrCompletionExists) {
+		t.Fatalf("%s - Failed - adding a duplicate completion should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	if _, err := db.CreateCompletionHandler(ctx, "999", habitId, "2025-01-03"); !errors.Is(err, ErrHabitNotFound) {
+		t.Fatalf("%s - Failed - adding a completion to another user's habit should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	habit, err := db.DeleteCompletionHandler(ctx, userId, habitId, "2025-01-01")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if !reflect.DeepEqual(habit.CompletionDates, []string{"2025-01-02"}) {
+		t.Fatalf("%s - Failed - got=%v", helper.GetFunctionName(), habit.CompletionDates)
+	}
+
+	if _, err := db.DeleteCompletionHandler(ctx, userId, habitId, "2025-01-01"); !errors.Is(err, ErrCompletionNotFound) {
+		t.Fatalf("%s - Failed - removing a missing completion should fail - err=%v", helper.GetFunctionName(), err)
+	}
+}
+
+func TestSQLiteMeasurements(t *testing.T) {
+	db := newTestSQLiteDB(t)
+	ctx := context.Background()
+
+	registered, err := db.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "measurements@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	userId := registered.UserID
+
+	newHabitResponse, err := db.CreateHabitsHandler(ctx, userId, data.NewHabit{Name: "Water", DaysTarget: 30, Unit: "glasses", DailyGoal: 8})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	habitId := newHabitResponse.HabitID
+
+	measurements := []data.HabitMeasurement{{Date: "2025-01-01", Value: 3}, {Date: "2025-01-01", Value: 2.5}, {Date: "2025-01-02", Value: 8}}
+
+	for _, measurement := range measurements {
+		if _, err := db.CreateMeasurementHandler(ctx, userId, habitId, measurement); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	if _, err := db.CreateMeasurementHandler(ctx, "999", habitId, measurements[0]); !errors.Is(err, ErrHabitNotFound) {
+		t.Fatalf("%s - Failed - adding a measurement to another user's habit should fail - err=%v", helper.GetFunctionName(), err)
+	}
+
+	habit, err := db.RetrieveHabitsHandler(ctx, userId, habitId)
+
+	if err != nil || habit.Unit != "glasses" || habit.DailyGoal != 8 || !reflect.DeepEqual(habit.Measurements, measurements) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), habit, err)
+	}
+
+	habit, err = db.DeleteCompletionHandler(ctx, userId, habitId, "2025-01-01")
+
+	if err != nil || !reflect.DeepEqual(habit.Measurements, measurements[2:]) {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), habit.Measurements, err)
+	}
+
+	if _, err := db.DeleteCompletionHandler(ctx, userId, habitId, "2025-01-01"); !errors.Is(err, ErrCompletionNotFound) {
+		t.Fatalf("%s - Failed - removing a day without measurements should fail - err=%v", helper.GetFunctionName(), err)
+	}
+}
+
+func TestSQLiteAddsMissingColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "habitsapp.db")
+	t.Setenv("DB_URL", path)
+	t.Setenv("USERS_COLLECTION", "users")
+	t.Setenv("USER_SESSION_COLLECTION", "user_session")
+	t.Setenv("PASSWORD_RESET_COLLECTION", "password_reset")
+	t.Setenv("LOGIN_ATTEMPTS_COLLECTION", "login_attempts")
+	t.Setenv("PERSONAL_ACCESS_TOKENS_COLLECTION", "personal_access_tokens")
+	t.Setenv("HABITS_COLLECTION", "habits")
+
+	// A habits table created before the Schedule and measurement columns existed
+	client, err := sql.Open("sqlite", path)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := client.Exec(`CREATE TABLE "habits" (HabitID INTEGER PRIMARY KEY AUTOINCREMENT, UserID INTEGER NOT NULL, CreatedAt TEXT NOT NULL, Name TEXT NOT NULL, Days INTEGER NOT NULL DEFAULT 0, DaysTarget INTEGER NOT NULL, CompletionDates TEXT NOT NULL DEFAULT '[]')`); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := client.Exec(`INSERT INTO "habits" (UserID, CreatedAt, Name, DaysTarget) VALUES (1, '2025-01-01T00:00:00.000000000Z', 'Old habit', 10)`); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	client.Close()
+
+	db := NewSQLiteDB(logger.NewLogger(0))
+
+	if err := db.Connect(); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	t.Cleanup(func() { db.Disconnect() })
+
+	habit, err := db.RetrieveHabitsHandler(context.Background(), "1", "1")
+
+	if err != nil || habit.Name != "Old habit" || !reflect.DeepEqual(habit.Schedule, data.HabitSchedule{}) || habit.DailyGoal != 0 || len(habit.Measurements) != 0 {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), habit, err)
+	}
+}
+
+func TestSQLiteRecreatesSingleSessionTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "habitsapp.db")
+	t.Setenv("DB_URL", path)
+	t.Setenv("USERS_COLLECTION", "users")
+	t.Setenv("USER_SESSION_COLLECTION", "user_session")
+	t.Setenv("PASSWORD_RESET_COLLECTION", "password_reset")
+	t.Setenv("LOGIN_ATTEMPTS_COLLECTION", "login_attempts")
+	t.Setenv("PERSONAL_ACCESS_TOKENS_COLLECTION", "personal_access_tokens")
+	t.Setenv("HABITS_COLLECTION", "habits")
+
+	// A user_session table created when a user could only have one session
+	client, err := sql.Open("sqlite", path)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := client.Exec(`CREATE TABLE "user_session" (UserID INTEGER PRIMARY KEY, RefreshToken TEXT NOT NULL, Device TEXT NOT NULL, IpAddress TEXT NOT NULL, CreatedAt TEXT NOT NULL)`); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	client.Close()
+
+	db := NewSQLiteDB(logger.NewLogger(0))
+
+	if err := db.Connect(); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	t.Cleanup(func() { db.Disconnect() })
+
+	user, err := db.RegisterUserHandler(context.Background(), &data.RegisterUserRequest{EmailAddress: "sessions@example.com", Password: "hashed", FirstName: "First", LastName: "Last"})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	for _, sessionID := range []string{"laptop", "phone"} {
+		if err := db.LoginUser(context.Background(), &data.UserSession{SessionID: sessionID, UserID: user.UserID, RefreshToken: "refresh"}); err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	if got := sqliteSessionIDs(t, db, user.UserID); !reflect.DeepEqual(got, []string{"laptop", "phone"}) {
+		t.Fatalf("%s - Failed - got=%v", helper.GetFunctionName(), got)
+	}
+}
@@ -0,0 +1,101 @@
+package helper
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+/*
+ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR ranges, e.g. TRUSTED_PROXIES=10.0.0.0/8,127.0.0.1,
+naming the reverse proxies whose X-Forwarded-For header ClientIP believes. An empty list trusts no proxies.
+*/
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	trustedProxies := []netip.Prefix{}
+
+	for _, val := range strings.Split(value, ",") {
+		val = strings.TrimSpace(val)
+
+		if val == "" {
+			continue
+		}
+
+		if strings.Contains(val, "/") {
+			prefix, err := netip.ParsePrefix(val)
+
+			if err != nil {
+				return nil, fmt.Errorf("%s - %q isn't an IP address or CIDR range: %w", GetFunctionName(), val, err)
+			}
+
+			trustedProxies = append(trustedProxies, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(val)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s - %q isn't an IP address or CIDR range: %w", GetFunctionName(), val, err)
+		}
+
+		addr = addr.Unmap()
+		trustedProxies = append(trustedProxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	return trustedProxies, nil
+}
+
+/*
+ClientIP returns the IP address of the client that made r. X-Forwarded-For is only believed when the request came from one of
+trustedProxies, as anyone else can set it. The header is read right to left, as each proxy appends the address it received the
+request from, and the first address that isn't a trusted proxy is the client.
+*/
+func ClientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	remoteAddr, err := netip.ParseAddr(host)
+
+	if err != nil {
+		return host
+	}
+
+	remoteAddr = remoteAddr.Unmap()
+
+	if !isTrustedProxy(remoteAddr, trustedProxies) {
+		return remoteAddr.String()
+	}
+
+	forwardedFor := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	clientAddr := remoteAddr
+
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwardedFor[i]))
+
+		if err != nil {
+			break
+		}
+
+		clientAddr = addr.Unmap()
+
+		if !isTrustedProxy(clientAddr, trustedProxies) {
+			break
+		}
+	}
+
+	return clientAddr.String()
+}
+
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	for _, val := range trustedProxies {
+		if val.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
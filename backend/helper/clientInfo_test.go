@@ -0,0 +1,82 @@
+package helper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := ParseTrustedProxies("10.0.0.0/8, 127.0.0.1")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		want         string
+	}{
+		{
+			name:       "Test the remote address is used without a proxy",
+			remoteAddr: "203.0.113.10:52100",
+			want:       "203.0.113.10",
+		},
+		{
+			name:         "Test X-Forwarded-For is ignored from an untrusted address",
+			remoteAddr:   "203.0.113.10:52100",
+			forwardedFor: []string{"198.51.100.7"},
+			want:         "203.0.113.10",
+		},
+		{
+			name:         "Test X-Forwarded-For is used from a trusted proxy",
+			remoteAddr:   "127.0.0.1:52100",
+			forwardedFor: []string{"198.51.100.7"},
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "Test addresses the client added before the proxies are ignored",
+			remoteAddr:   "10.0.0.2:52100",
+			forwardedFor: []string{"192.0.2.1, 198.51.100.7", "10.0.0.1"},
+			want:         "198.51.100.7",
+		},
+		{
+			name:         "Test an invalid address stops at the last valid one",
+			remoteAddr:   "10.0.0.2:52100",
+			forwardedFor: []string{"not-an-ip, 10.0.0.1"},
+			want:         "10.0.0.1",
+		},
+		{
+			name:       "Test an IPv4-mapped IPv6 address is unmapped",
+			remoteAddr: "[::ffff:203.0.113.10]:52100",
+			want:       "203.0.113.10",
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = val.remoteAddr
+
+			for _, forwardedFor := range val.forwardedFor {
+				req.Header.Add("X-Forwarded-For", forwardedFor)
+			}
+
+			if got := ClientIP(req, trustedProxies); got != val.want {
+				t.Errorf("%s - Failed - got=%s, want=%s", GetFunctionName(), got, val.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if trustedProxies, err := ParseTrustedProxies(""); err != nil || len(trustedProxies) != 0 {
+		t.Errorf("%s - Failed - got=%v, err=%v", GetFunctionName(), trustedProxies, err)
+	}
+
+	if _, err := ParseTrustedProxies("10.0.0.0/8,proxy.internal"); err == nil {
+		t.Errorf("%s - Failed - a hostname should be rejected", GetFunctionName())
+	}
+}
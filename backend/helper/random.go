@@ -0,0 +1,30 @@
+package helper
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// RandomID returns a random, URL safe ID with 256 bits of entropy, for IDs that mustn't be guessable such as session IDs
+func RandomID() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("%s - Failed to generate a random ID: %w", GetFunctionName(), err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+/*
+HashToken returns the SHA-256 hash of a token made by RandomID, for tokens that are stored so they can be looked up but mustn't be usable
+by whoever reads the database. A fast hash is enough as the token has 256 bits of entropy, unlike a password.
+*/
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -9,21 +9,35 @@ import (
 )
 
 type App struct {
-	authController   controller.IAuthController
-	habitsController controller.IHabitsController
-	database         db.IDB
-	middleware       middleware.IMiddleware
-	logger           logger.ILogger
-	apiName          string
-	apiVersion       string
-	appVersion       string
-	port             string
-	jwtTokens        session.IJSONWebToken
+	authController                controller.IAuthController
+	habitsController              controller.IHabitsController
+	keysController                controller.IKeysController
+	passwordController            controller.IPasswordController
+	emailVerificationController   controller.IEmailVerificationController
+	mfaController                 controller.IMFAController
+	personalAccessTokenController controller.IPersonalAccessTokenController
+	adminController               controller.IAdminController
+	exportController              controller.IExportController
+	database                      db.IDB
+	middleware                    middleware.IMiddleware
+	logger                        logger.ILogger
+	apiName                       string
+	apiVersion                    string
+	appVersion                    string
+	port                          string
+	jwtTokens                     session.IJSONWebToken
 }
 
 type IApp interface {
 	GetAuthController() controller.IAuthController
 	GetHabitsController() controller.IHabitsController
+	GetKeysController() controller.IKeysController
+	GetPasswordController() controller.IPasswordController
+	GetEmailVerificationController() controller.IEmailVerificationController
+	GetMFAController() controller.IMFAController
+	GetPersonalAccessTokenController() controller.IPersonalAccessTokenController
+	GetAdminController() controller.IAdminController
+	GetExportController() controller.IExportController
 	GetDB() db.IDB
 	GetMiddleware() middleware.IMiddleware
 	GetLogger() logger.ILogger
@@ -36,6 +50,13 @@ type IApp interface {
 func NewApp(
 	authController *controller.AuthController,
 	habitsController *controller.HabitsController,
+	keysController *controller.KeysController,
+	passwordController *controller.PasswordController,
+	emailVerificationController *controller.EmailVerificationController,
+	mfaController *controller.MFAController,
+	personalAccessTokenController *controller.PersonalAccessTokenController,
+	adminController *controller.AdminController,
+	exportController *controller.ExportController,
 	db db.IDB,
 	middleware middleware.IMiddleware,
 	logger *logger.Logger,
@@ -46,16 +67,23 @@ func NewApp(
 	jwtTokens *session.JSONWebToken,
 ) *App {
 	return &App{
-		authController:   authController,
-		habitsController: habitsController,
-		database:         db,
-		middleware:       middleware,
-		logger:           logger,
-		apiName:          apiName,
-		apiVersion:       apiVersion,
-		appVersion:       appVersion,
-		port:             port,
-		jwtTokens:        jwtTokens,
+		authController:                authController,
+		habitsController:              habitsController,
+		keysController:                keysController,
+		passwordController:            passwordController,
+		emailVerificationController:   emailVerificationController,
+		mfaController:                 mfaController,
+		personalAccessTokenController: personalAccessTokenController,
+		adminController:               adminController,
+		exportController:              exportController,
+		database:                      db,
+		middleware:                    middleware,
+		logger:                        logger,
+		apiName:                       apiName,
+		apiVersion:                    apiVersion,
+		appVersion:                    appVersion,
+		port:                          port,
+		jwtTokens:                     jwtTokens,
 	}
 }
 
@@ -67,6 +95,34 @@ func (a *App) GetHabitsController() controller.IHabitsController {
 	return a.habitsController
 }
 
+func (a *App) GetKeysController() controller.IKeysController {
+	return a.keysController
+}
+
+func (a *App) GetPasswordController() controller.IPasswordController {
+	return a.passwordController
+}
+
+func (a *App) GetEmailVerificationController() controller.IEmailVerificationController {
+	return a.emailVerificationController
+}
+
+func (a *App) GetMFAController() controller.IMFAController {
+	return a.mfaController
+}
+
+func (a *App) GetPersonalAccessTokenController() controller.IPersonalAccessTokenController {
+	return a.personalAccessTokenController
+}
+
+func (a *App) GetAdminController() controller.IAdminController {
+	return a.adminController
+}
+
+func (a *App) GetExportController() controller.IExportController {
+	return a.exportController
+}
+
 func (a *App) GetDB() db.IDB {
 	return a.database
 }
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"dohabits/data"
+	"dohabits/oidc"
 	"fmt"
 	"os"
 
@@ -30,7 +32,7 @@ Iterates over a list of required environment variables and checks if each one is
 If any required variable is not set, it logs a fatal error and exits the application.
 */
 func checkEnvVariablesArePopulated() error {
-	requiredEnvVars := []string{"DB_TYPE", "DB_URL", "DB_NAME", "USERS_COLLECTION", "USER_SESSION_COLLECTION", "HABITS_COLLECTION", "PORT", "SITE_URL", "API_NAME", "APP_VERSION", "API_VERSION", "LOG_VERBOSITY", "JWT_SECRET"}
+	requiredEnvVars := []string{"DB_TYPE", "DB_URL", "DB_NAME", "USERS_COLLECTION", "USER_SESSION_COLLECTION", "PASSWORD_RESET_COLLECTION", "LOGIN_ATTEMPTS_COLLECTION", "PERSONAL_ACCESS_TOKENS_COLLECTION", "HABITS_COLLECTION", "PORT", "SITE_URL", "API_NAME", "APP_VERSION", "API_VERSION", "LOG_VERBOSITY"}
 
 	for _, envVal := range requiredEnvVars {
 		if value := os.Getenv(envVal); value == "" {
@@ -38,5 +40,31 @@ func checkEnvVariablesArePopulated() error {
 		}
 	}
 
+	// Tokens are signed with the key file if there is one, and with the shared secret otherwise
+	if os.Getenv("JWT_SIGNING_KEY_FILE") == "" && os.Getenv("JWT_SECRET") == "" {
+		return fmt.Errorf("environment variable 'JWT_SIGNING_KEY_FILE' or 'JWT_SECRET' must be set")
+	}
+
+	switch unverifiedUserAccess := data.UnverifiedUserAccess(os.Getenv("UNVERIFIED_USER_ACCESS")); unverifiedUserAccess {
+	case "", data.UnverifiedUserAccessAll, data.UnverifiedUserAccessLogin, data.UnverifiedUserAccessNone:
+	default:
+		return fmt.Errorf("environment variable 'UNVERIFIED_USER_ACCESS' must be '%s', '%s' or '%s', not '%s'", data.UnverifiedUserAccessAll, data.UnverifiedUserAccessLogin, data.UnverifiedUserAccessNone, unverifiedUserAccess)
+	}
+
+	// Every OIDC provider needs its own issuer and client, the rest have defaults
+	providerNames, err := oidc.ProviderNames(os.Getenv("OIDC_PROVIDERS"))
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range providerNames {
+		for _, setting := range []string{"ISSUER", "CLIENT_ID"} {
+			if envVal := oidc.ProviderEnvVar(name, setting); os.Getenv(envVal) == "" {
+				return fmt.Errorf("environment variable '%s' is not set", envVal)
+			}
+		}
+	}
+
 	return nil
 }
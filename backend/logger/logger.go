@@ -5,57 +5,65 @@ import (
 	"os"
 )
 
+// Each level has its own log.Logger so concurrent requests can't log with another level's prefix or flags
 type Logger struct {
 	verbosity int
+	info      *log.Logger
+	error     *log.Logger
+	debug     *log.Logger
+	security  *log.Logger
 }
 
 type ILogger interface {
 	InfoLog(functionName, message string)
 	ErrorLog(functionName, message string)
 	DebugLog(functionName, message string)
+	// SecurityLog records an event that may be an attack, such as a stolen token being used
+	SecurityLog(functionName, message string)
 }
 
 func NewLogger(verbosity int) *Logger {
 	return &Logger{
 		verbosity: verbosity,
+		info:      log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime),
+		error:     log.New(os.Stdout, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile),
+		debug:     log.New(os.Stdout, "DEBUG: ", log.Ldate|log.Ltime|log.Lmicroseconds),
+		security:  log.New(os.Stdout, "SECURITY: ", log.Ldate|log.Ltime|log.Lmicroseconds),
 	}
 }
 
 func (l *Logger) InfoLog(functionName, message string) {
 	if l.verbosity > 0 {
-		log.SetOutput(os.Stdout)
-		log.SetPrefix("INFO: ")
-		log.SetFlags(log.Ldate | log.Ltime)
 		if message == "" {
-			log.Println(functionName)
+			l.info.Println(functionName)
 			return
 		}
-		log.Printf("%s - %s\n", functionName, message)
+		l.info.Printf("%s - %s\n", functionName, message)
 	}
 }
 
 func (l *Logger) ErrorLog(functionName, message string) {
 	if l.verbosity >= 1 {
-		log.SetOutput(os.Stdout)
-		log.SetPrefix("ERROR: ")
-		log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 		if message == "" {
-			log.Println(functionName)
+			l.error.Println(functionName)
 			return
 		}
-		log.Printf("%s - %s\n", functionName, message)
+		l.error.Printf("%s - %s\n", functionName, message)
 	}
 }
 
 func (l *Logger) DebugLog(functionName, message string) {
 	if l.verbosity >= 2 {
-		log.SetOutput(os.Stdout)
-		log.SetPrefix("DEBUG: ")
-		log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
 		if message == "" {
-			log.Println(functionName)
+			l.debug.Println(functionName)
 			return
 		}
-		log.Printf("%s - %s\n", functionName, message)
+		l.debug.Printf("%s - %s\n", functionName, message)
+	}
+}
+
+func (l *Logger) SecurityLog(functionName, message string) {
+	if l.verbosity >= 1 {
+		l.security.Printf("%s - %s\n", functionName, message)
 	}
 }
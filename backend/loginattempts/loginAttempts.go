@@ -0,0 +1,281 @@
+/*
+Package loginattempts protects logins from password guessing. Failed logins are counted against both the account and the client
+IP address, so neither guessing one account's password from many addresses nor trying many accounts from one address gets far.
+Once either reaches its limit it's locked out, for twice as long with each further failure, and logins are refused with 429 Too
+Many Requests and a Retry-After header until the lockout ends.
+*/
+package loginattempts
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	accountKeyPrefix = "account:"
+	ipKeyPrefix      = "ip:"
+)
+
+var ErrTooManyLoginAttempts = apperror.New(http.StatusTooManyRequests, apperror.CodeTooManyLoginAttempts, "Too many failed login attempts, try again later")
+
+/*
+Store keeps the failed logins counted against each key. MemoryStore keeps them in the app, which is enough for a single instance.
+The database implements Store as well, for when several instances of the app need to share them.
+*/
+type Store interface {
+	RetrieveLoginAttempts(ctx context.Context, key string) (*data.LoginAttempts, error)
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*data.LoginAttempts, error)
+	LockLoginAttempts(ctx context.Context, key string, lockedUntil time.Time) error
+	DeleteLoginAttempts(ctx context.Context, key string) error
+}
+
+// NewStore returns the Store picked by LOGIN_ATTEMPTS_STORE, "memory", the default, or "db"
+func NewStore(storeType string, db db.IDB) (Store, error) {
+	switch storeType {
+	case "memory", "":
+		return NewMemoryStore(), nil
+	case "db":
+		return db, nil
+	default:
+		return nil, fmt.Errorf("%s - unknown LOGIN_ATTEMPTS_STORE %q, expected memory or db", helper.GetFunctionName(), storeType)
+	}
+}
+
+/*
+Policy is when logins are locked out. An account or IP address is locked for Lockout once it has MaxFailures or MaxFailuresPerIP
+failed logins in a row, and each failure after that doubles the lockout up to MaxLockout. Failures are forgotten once there's
+been none for twice MaxLockout, so they're still counted when the longest lockout ends, and an account's are forgotten as soon as
+it logs in.
+*/
+type Policy struct {
+	MaxFailures      int
+	MaxFailuresPerIP int // Higher than MaxFailures, as many users can share an address behind NAT
+	Lockout          time.Duration
+	MaxLockout       time.Duration
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxFailures:      5,
+		MaxFailuresPerIP: 50,
+		Lockout:          time.Minute,
+		MaxLockout:       time.Hour,
+	}
+}
+
+// ParsePolicy reads LOGIN_MAX_FAILURES, LOGIN_MAX_FAILURES_PER_IP, LOGIN_LOCKOUT and LOGIN_MAX_LOCKOUT. Any that are empty keep their DefaultPolicy value.
+func ParsePolicy(maxFailures, maxFailuresPerIP, lockout, maxLockout string) (Policy, error) {
+	policy := DefaultPolicy()
+
+	for _, val := range []struct {
+		name   string
+		value  string
+		target *int
+	}{
+		{name: "LOGIN_MAX_FAILURES", value: maxFailures, target: &policy.MaxFailures},
+		{name: "LOGIN_MAX_FAILURES_PER_IP", value: maxFailuresPerIP, target: &policy.MaxFailuresPerIP},
+	} {
+		if val.value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(val.value)
+
+		if err != nil || n < 1 {
+			return Policy{}, fmt.Errorf("%s - %s must be a whole number of at least 1, not %q", helper.GetFunctionName(), val.name, val.value)
+		}
+
+		*val.target = n
+	}
+
+	for _, val := range []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{name: "LOGIN_LOCKOUT", value: lockout, target: &policy.Lockout},
+		{name: "LOGIN_MAX_LOCKOUT", value: maxLockout, target: &policy.MaxLockout},
+	} {
+		if val.value == "" {
+			continue
+		}
+
+		d, err := time.ParseDuration(val.value)
+
+		if err != nil || d <= 0 {
+			return Policy{}, fmt.Errorf("%s - %s must be a positive duration such as 30s or 15m, not %q", helper.GetFunctionName(), val.name, val.value)
+		}
+
+		*val.target = d
+	}
+
+	if policy.MaxLockout < policy.Lockout {
+		return Policy{}, fmt.Errorf("%s - LOGIN_MAX_LOCKOUT (%s) can't be shorter than LOGIN_LOCKOUT (%s)", helper.GetFunctionName(), policy.MaxLockout, policy.Lockout)
+	}
+
+	return policy, nil
+}
+
+// resetAfter is how long after the last failed login the failures are forgotten
+func (p Policy) resetAfter() time.Duration {
+	return 2 * p.MaxLockout
+}
+
+// lockout is how long a key is locked for once it has failures failed logins, or 0 if it isn't
+func (p Policy) lockout(failures, maxFailures int) time.Duration {
+	if failures < maxFailures {
+		return 0
+	}
+
+	lockout := p.Lockout
+
+	for i := maxFailures; i < failures && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+
+	return min(lockout, p.MaxLockout)
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+	logger logger.ILogger
+	now    func() time.Time
+}
+
+type ILimiter interface {
+	// Check returns ErrTooManyLoginAttempts, with how long to wait, if the account or the IP address is locked out
+	Check(ctx context.Context, emailAddress, ipAddress string) error
+	// RecordFailure counts a failed login against the account and the IP address, locking out either that reaches its limit
+	RecordFailure(ctx context.Context, emailAddress, ipAddress string) error
+	// RecordSuccess forgets the account's failed logins once it's logged in. The IP address's are kept, or logging into an attacker's own account would clear them.
+	RecordSuccess(ctx context.Context, emailAddress string) error
+}
+
+func NewLimiter(store Store, policy Policy, logger logger.ILogger) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+func (l *Limiter) Check(ctx context.Context, emailAddress, ipAddress string) error {
+	l.logger.InfoLog(helper.GetFunctionName(), "")
+
+	now := l.now()
+
+	var retryAfter time.Duration
+
+	for _, limit := range l.limits(emailAddress, ipAddress) {
+		loginAttempts, err := l.store.RetrieveLoginAttempts(ctx, limit.key)
+
+		if err != nil {
+			return err
+		}
+
+		if loginAttempts.LockedUntil.IsZero() {
+			continue
+		}
+
+		if wait := loginAttempts.LockedUntil.Sub(now); wait > 0 {
+			retryAfter = max(retryAfter, wait)
+			continue
+		}
+
+		// The lockout has ended. Clearing it records the unlock once rather than on every login after it
+		if err := l.store.LockLoginAttempts(ctx, limit.key, time.Time{}); err != nil {
+			return err
+		}
+
+		l.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Lockout of %s has ended", limit.key))
+	}
+
+	if retryAfter > 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrTooManyLoginAttempts.WithRetryAfter(retryAfter))
+	}
+
+	return nil
+}
+
+func (l *Limiter) RecordFailure(ctx context.Context, emailAddress, ipAddress string) error {
+	l.logger.InfoLog(helper.GetFunctionName(), "")
+
+	now := l.now()
+
+	for _, limit := range l.limits(emailAddress, ipAddress) {
+		loginAttempts, err := l.store.RecordLoginFailure(ctx, limit.key, now, l.policy.resetAfter())
+
+		if err != nil {
+			return err
+		}
+
+		lockout := l.policy.lockout(loginAttempts.Failures, limit.maxFailures)
+
+		if lockout == 0 {
+			continue
+		}
+
+		if err := l.store.LockLoginAttempts(ctx, limit.key, now.Add(lockout)); err != nil {
+			return err
+		}
+
+		l.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Locked out %s for %s after %d failed logins", limit.key, lockout, loginAttempts.Failures))
+	}
+
+	return nil
+}
+
+func (l *Limiter) RecordSuccess(ctx context.Context, emailAddress string) error {
+	l.logger.InfoLog(helper.GetFunctionName(), "")
+
+	key := accountKey(emailAddress)
+	loginAttempts, err := l.store.RetrieveLoginAttempts(ctx, key)
+
+	if err != nil {
+		return err
+	}
+
+	if loginAttempts.Failures == 0 && loginAttempts.LockedUntil.IsZero() {
+		return nil
+	}
+
+	if err := l.store.DeleteLoginAttempts(ctx, key); err != nil {
+		return err
+	}
+
+	l.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Cleared %d failed logins for %s after a successful login", loginAttempts.Failures, key))
+
+	return nil
+}
+
+type limit struct {
+	key         string
+	maxFailures int
+}
+
+// limits returns the keys a login is counted against. The IP address is skipped when it isn't known.
+func (l *Limiter) limits(emailAddress, ipAddress string) []limit {
+	limits := []limit{{key: accountKey(emailAddress), maxFailures: l.policy.MaxFailures}}
+
+	if ipAddress != "" {
+		limits = append(limits, limit{key: ipKeyPrefix + ipAddress, maxFailures: l.policy.MaxFailuresPerIP})
+	}
+
+	return limits
+}
+
+// accountKey ignores case and surrounding spaces, so they can't be used to get more guesses at an account's password
+func accountKey(emailAddress string) string {
+	return accountKeyPrefix + strings.ToLower(strings.TrimSpace(emailAddress))
+}
@@ -0,0 +1,189 @@
+package loginattempts
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"testing"
+	"time"
+)
+
+// checkRetryAfter returns how long Check says to wait, or 0 if the login is allowed
+func checkRetryAfter(t *testing.T, limiter *Limiter, emailAddress, ipAddress string) time.Duration {
+	t.Helper()
+
+	err := limiter.Check(context.Background(), emailAddress, ipAddress)
+
+	if err == nil {
+		return 0
+	}
+
+	var appErr *apperror.Error
+
+	if !errors.Is(err, ErrTooManyLoginAttempts) || !errors.As(err, &appErr) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrTooManyLoginAttempts)
+	}
+
+	return appErr.RetryAfter
+}
+
+func TestLimiter(t *testing.T) {
+	logger := logger.NewLogger(0)
+	policy := Policy{MaxFailures: 3, MaxFailuresPerIP: 5, Lockout: time.Minute, MaxLockout: 4 * time.Minute}
+
+	originalMockLoginAttemptsState := make([]data.LoginAttempts, len(data.MockLoginAttempts))
+	copy(originalMockLoginAttemptsState, data.MockLoginAttempts)
+
+	defer func() { data.MockLoginAttempts = originalMockLoginAttemptsState }()
+
+	stores := []struct {
+		name  string
+		store Store
+	}{
+		{name: "memory", store: NewMemoryStore()},
+		{name: "db", store: db.NewMockDB(logger)},
+	}
+
+	for _, val := range stores {
+		t.Run(val.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			limiter := NewLimiter(val.store, policy, logger)
+			limiter.now = func() time.Time { return now }
+
+			fail := func(emailAddress, ipAddress string) {
+				t.Helper()
+
+				if err := limiter.RecordFailure(ctx, emailAddress, ipAddress); err != nil {
+					t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				}
+			}
+
+			// The account is locked once it reaches MaxFailures, whatever the case of the email address
+			fail("locked@example.com", "192.0.2.1")
+			fail("Locked@Example.com", "192.0.2.2")
+
+			if wait := checkRetryAfter(t, limiter, "locked@example.com", "192.0.2.3"); wait != 0 {
+				t.Fatalf("%s - Failed - locked after 2 failures, wait=%s", helper.GetFunctionName(), wait)
+			}
+
+			fail(" locked@example.com ", "192.0.2.3")
+
+			if wait := checkRetryAfter(t, limiter, "locked@example.com", "192.0.2.4"); wait != time.Minute {
+				t.Fatalf("%s - Failed - got wait=%s, want=%s", helper.GetFunctionName(), wait, time.Minute)
+			}
+
+			// Other accounts can still log in from the same addresses
+			if wait := checkRetryAfter(t, limiter, "other@example.com", "192.0.2.1"); wait != 0 {
+				t.Fatalf("%s - Failed - got wait=%s", helper.GetFunctionName(), wait)
+			}
+
+			// Each failure after the lockout ends doubles it, up to MaxLockout
+			lockout := time.Minute
+
+			for _, want := range []time.Duration{2 * time.Minute, 4 * time.Minute, 4 * time.Minute} {
+				now = now.Add(lockout)
+
+				if wait := checkRetryAfter(t, limiter, "locked@example.com", ""); wait != 0 {
+					t.Fatalf("%s - Failed - the lockout didn't end, wait=%s", helper.GetFunctionName(), wait)
+				}
+
+				fail("locked@example.com", "")
+				lockout = want
+
+				if wait := checkRetryAfter(t, limiter, "locked@example.com", ""); wait != want {
+					t.Fatalf("%s - Failed - got wait=%s, want=%s", helper.GetFunctionName(), wait, want)
+				}
+			}
+
+			// Logging in forgets the account's failures
+			now = now.Add(policy.MaxLockout)
+
+			if err := limiter.RecordSuccess(ctx, "locked@example.com"); err != nil {
+				t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			fail("locked@example.com", "")
+
+			if wait := checkRetryAfter(t, limiter, "locked@example.com", ""); wait != 0 {
+				t.Fatalf("%s - Failed - failures weren't forgotten, wait=%s", helper.GetFunctionName(), wait)
+			}
+
+			// Failures are forgotten twice MaxLockout after the last one
+			fail("locked@example.com", "")
+			now = now.Add(2 * policy.MaxLockout)
+			fail("locked@example.com", "")
+
+			if wait := checkRetryAfter(t, limiter, "locked@example.com", ""); wait != 0 {
+				t.Fatalf("%s - Failed - failures weren't forgotten, wait=%s", helper.GetFunctionName(), wait)
+			}
+
+			// An address trying many accounts is locked once it reaches MaxFailuresPerIP, even for accounts it hasn't tried
+			for _, emailAddress := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+				fail(emailAddress, "198.51.100.1")
+			}
+
+			if wait := checkRetryAfter(t, limiter, "f@example.com", "198.51.100.1"); wait != time.Minute {
+				t.Fatalf("%s - Failed - got wait=%s, want=%s", helper.GetFunctionName(), wait, time.Minute)
+			}
+
+			// Logging in doesn't forget the address's failures
+			if err := limiter.RecordSuccess(ctx, "f@example.com"); err != nil {
+				t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			if wait := checkRetryAfter(t, limiter, "f@example.com", "198.51.100.1"); wait != time.Minute {
+				t.Fatalf("%s - Failed - got wait=%s, want=%s", helper.GetFunctionName(), wait, time.Minute)
+			}
+
+			if wait := checkRetryAfter(t, limiter, "f@example.com", "198.51.100.2"); wait != 0 {
+				t.Fatalf("%s - Failed - got wait=%s", helper.GetFunctionName(), wait)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	testCases := []struct {
+		name             string
+		maxFailures      string
+		maxFailuresPerIP string
+		lockout          string
+		maxLockout       string
+		want             Policy
+		wantErr          bool
+	}{
+		{name: "Test defaults", want: DefaultPolicy()},
+		{
+			name:             "Test every setting",
+			maxFailures:      "10",
+			maxFailuresPerIP: "100",
+			lockout:          "30s",
+			maxLockout:       "15m",
+			want:             Policy{MaxFailures: 10, MaxFailuresPerIP: 100, Lockout: 30 * time.Second, MaxLockout: 15 * time.Minute},
+		},
+		{name: "Test max failures isn't a number", maxFailures: "five", wantErr: true},
+		{name: "Test max failures is 0", maxFailures: "0", wantErr: true},
+		{name: "Test lockout isn't a duration", lockout: "60", wantErr: true},
+		{name: "Test max lockout shorter than the lockout", lockout: "2h", wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			got, err := ParsePolicy(val.maxFailures, val.maxFailuresPerIP, val.lockout, val.maxLockout)
+
+			if (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+				return
+			}
+
+			if !val.wantErr && got != val.want {
+				t.Errorf("%s - Failed - got=%+v, want=%+v", helper.GetFunctionName(), got, val.want)
+			}
+		})
+	}
+}
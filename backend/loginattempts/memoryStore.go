@@ -0,0 +1,89 @@
+package loginattempts
+
+import (
+	"context"
+	"dohabits/data"
+	"sync"
+	"time"
+)
+
+// Enforce interface compliance
+var _ Store = (*MemoryStore)(nil)
+
+/*
+MemoryStore keeps failed logins in the app. They're lost when it restarts, and each instance counts its own, so use the db
+Store when there are several. Expired records are removed as failures are recorded, at most once every resetAfter.
+*/
+type MemoryStore struct {
+	mx          sync.Mutex
+	attempts    map[string]data.LoginAttempts
+	lastCleanup time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		attempts: map[string]data.LoginAttempts{},
+	}
+}
+
+func (s *MemoryStore) RetrieveLoginAttempts(ctx context.Context, key string) (*data.LoginAttempts, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	loginAttempts, ok := s.attempts[key]
+
+	if !ok {
+		loginAttempts = data.LoginAttempts{Key: key}
+	}
+
+	return &loginAttempts, nil
+}
+
+func (s *MemoryStore) RecordLoginFailure(ctx context.Context, key string, now time.Time, resetAfter time.Duration) (*data.LoginAttempts, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if now.Sub(s.lastCleanup) >= resetAfter {
+		for k, val := range s.attempts {
+			if now.Sub(val.LastFailureAt) >= resetAfter && !now.Before(val.LockedUntil) {
+				delete(s.attempts, k)
+			}
+		}
+
+		s.lastCleanup = now
+	}
+
+	loginAttempts := s.attempts[key]
+	loginAttempts.Key = key
+
+	if now.Sub(loginAttempts.LastFailureAt) >= resetAfter {
+		loginAttempts.Failures = 0
+	}
+
+	loginAttempts.Failures++
+	loginAttempts.LastFailureAt = now
+	s.attempts[key] = loginAttempts
+
+	return &loginAttempts, nil
+}
+
+func (s *MemoryStore) LockLoginAttempts(ctx context.Context, key string, lockedUntil time.Time) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	loginAttempts := s.attempts[key]
+	loginAttempts.Key = key
+	loginAttempts.LockedUntil = lockedUntil
+	s.attempts[key] = loginAttempts
+
+	return nil
+}
+
+func (s *MemoryStore) DeleteLoginAttempts(ctx context.Context, key string) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	delete(s.attempts, key)
+
+	return nil
+}
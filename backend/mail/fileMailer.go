@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"context"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Enforce interface compliance
+var _ Mailer = (*FileMailer)(nil)
+
+/*
+FileMailer stands in for a mail server during local development. Each email is written to its own .eml file in dir, which any
+mail client can open, or to the log when dir is empty. Emails contain reset links, so it mustn't be used in production.
+*/
+type FileMailer struct {
+	dir    string
+	from   string
+	logger logger.ILogger
+}
+
+func NewFileMailer(dir, from string, logger logger.ILogger) *FileMailer {
+	return &FileMailer{
+		dir:    dir,
+		from:   from,
+		logger: logger,
+	}
+}
+
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	email, err := formatMessage(m.from, message)
+
+	if err != nil {
+		return err
+	}
+
+	if m.dir == "" {
+		m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("MAIL_DIR isn't set, logging the email instead:\n%s", email))
+		return nil
+	}
+
+	file, err := os.CreateTemp(m.dir, time.Now().UTC().Format("20060102T150405")+"-*.eml")
+
+	if err != nil {
+		m.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to create the email file: err=%s", err))
+		return fmt.Errorf("%s - Failed to create the email file: %w", helper.GetFunctionName(), err)
+	}
+
+	defer file.Close()
+
+	if _, err := file.Write(email); err != nil {
+		m.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to write the email file: err=%s", err))
+		return fmt.Errorf("%s - Failed to write the email file: %w", helper.GetFunctionName(), err)
+	}
+
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("Email written to %s", file.Name()))
+
+	return nil
+}
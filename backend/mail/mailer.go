@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"context"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultFrom is the sender of emails written by the FileMailer when MAIL_FROM isn't set
+const defaultFrom = "DoHabitsApp <no-reply@localhost>"
+
+var errHeaderInjection = errors.New("email header contains a line break")
+
+// Message is a plain text email to a single recipient
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails to users, such as password reset links
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+/*
+NewMailer returns the Mailer picked by MAILER_TYPE. "smtp" sends through the SMTP_HOST relay, and "file", the default, writes each
+email to MAIL_DIR, or the log when MAIL_DIR isn't set, so the app runs locally without a mail server.
+*/
+func NewMailer(mailerType string, logger logger.ILogger) (Mailer, error) {
+	switch mailerType {
+	case "smtp":
+		return NewSMTPMailer(os.Getenv("SMTP_HOST"), os.Getenv("SMTP_PORT"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"), logger)
+	case "file", "":
+		from := os.Getenv("MAIL_FROM")
+
+		if from == "" {
+			from = defaultFrom
+		}
+
+		return NewFileMailer(os.Getenv("MAIL_DIR"), from, logger), nil
+	default:
+		return nil, fmt.Errorf("%s - unknown MAILER_TYPE %q, expected smtp or file", helper.GetFunctionName(), mailerType)
+	}
+}
+
+// formatMessage renders message as an RFC 5322 email. Header values come from users, so line breaks are rejected rather than letting them add headers.
+func formatMessage(from string, message Message) ([]byte, error) {
+	for _, value := range []string{from, message.To, message.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), errHeaderInjection)
+		}
+	}
+
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "From: %s\r\n", from)
+	fmt.Fprintf(&sb, "To: %s\r\n", message.To)
+	fmt.Fprintf(&sb, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&sb, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(strings.ReplaceAll(message.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return []byte(sb.String()), nil
+}
@@ -0,0 +1,174 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewMailer(t *testing.T) {
+	logger := logger.NewLogger(0)
+
+	testCases := []struct {
+		name       string
+		mailerType string
+		smtpHost   string
+		mailFrom   string
+		wantErr    bool
+	}{
+		{name: "Test the file mailer is the default", mailerType: ""},
+		{name: "Test file mailer", mailerType: "file"},
+		{name: "Test SMTP mailer", mailerType: "smtp", smtpHost: "smtp.example.com", mailFrom: "DoHabitsApp <no-reply@example.com>"},
+		{name: "Test SMTP mailer without a host", mailerType: "smtp", mailFrom: "no-reply@example.com", wantErr: true},
+		{name: "Test SMTP mailer without a sender", mailerType: "smtp", smtpHost: "smtp.example.com", wantErr: true},
+		{name: "Test unknown mailer", mailerType: "carrierpigeon", wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			t.Setenv("SMTP_HOST", val.smtpHost)
+			t.Setenv("MAIL_FROM", val.mailFrom)
+
+			_, err := NewMailer(val.mailerType, logger)
+
+			if (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	fileMailer := NewFileMailer(dir, defaultFrom, logger.NewLogger(0))
+
+	message := Message{To: "johndoe1@example.com", Subject: "Reset your password", Body: "Line one\nLine two"}
+
+	if err := fileMailer.Send(context.Background(), message); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+
+	if err != nil || len(files) != 1 {
+		t.Fatalf("%s - Failed - files=%v, err=%v", helper.GetFunctionName(), files, err)
+	}
+
+	email, err := os.ReadFile(files[0])
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	for _, want := range []string{"From: " + defaultFrom + "\r\n", "To: johndoe1@example.com\r\n", "Subject: Reset your password\r\n", "\r\n\r\nLine one\r\nLine two"} {
+		if !strings.Contains(string(email), want) {
+			t.Errorf("%s - Failed - email doesn't contain %q:\n%s", helper.GetFunctionName(), want, email)
+		}
+	}
+
+	// A recipient smuggling in another header is rejected rather than written
+	err = fileMailer.Send(context.Background(), Message{To: "johndoe1@example.com\r\nBcc: attacker@example.com", Subject: "Hi"})
+
+	if !errors.Is(err, errHeaderInjection) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, errHeaderInjection)
+	}
+
+	// Without a directory the email is logged
+	if err := NewFileMailer("", defaultFrom, logger.NewLogger(0)).Send(context.Background(), message); err != nil {
+		t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+}
+
+// serveSMTP accepts one connection on listener and answers it as a minimal SMTP server, sending the DATA it receives to received
+func serveSMTP(listener net.Listener, received chan<- string) {
+	conn, err := listener.Accept()
+
+	if err != nil {
+		close(received)
+		return
+	}
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	conn.Write([]byte("220 localhost ESMTP\r\n"))
+
+	var data strings.Builder
+	inData := false
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		if err != nil {
+			close(received)
+			return
+		}
+
+		if inData {
+			if line == ".\r\n" {
+				inData = false
+				conn.Write([]byte("250 OK\r\n"))
+				continue
+			}
+
+			data.WriteString(line)
+			continue
+		}
+
+		switch command := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(command, "EHLO"):
+			conn.Write([]byte("250 localhost\r\n"))
+		case command == "DATA":
+			inData = true
+			conn.Write([]byte("354 Go ahead\r\n"))
+		case command == "QUIT":
+			conn.Write([]byte("221 Bye\r\n"))
+			received <- data.String()
+			return
+		default:
+			conn.Write([]byte("250 OK\r\n"))
+		}
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go serveSMTP(listener, received)
+
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	smtpMailer, err := NewSMTPMailer(host, port, "", "", "DoHabitsApp <no-reply@example.com>", logger.NewLogger(0))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := smtpMailer.Send(context.Background(), Message{To: "johndoe1@example.com", Subject: "Reset your password", Body: "Hello"}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	email := <-received
+
+	if !strings.Contains(email, "To: johndoe1@example.com\r\n") || !strings.Contains(email, "\r\n\r\nHello") {
+		t.Errorf("%s - Failed - got:\n%s", helper.GetFunctionName(), email)
+	}
+}
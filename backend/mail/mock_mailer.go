@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"dohabits/helper"
+	"dohabits/logger"
+	"sync"
+)
+
+// Enforce interface compliance
+var _ Mailer = (*MockMailer)(nil)
+
+// MockMailer keeps the emails it's asked to send so tests can read them, failing every send when Err is set
+type MockMailer struct {
+	logger logger.ILogger
+	Err    error
+	sent   []Message
+	mx     sync.Mutex
+}
+
+func NewMockMailer(logger logger.ILogger) *MockMailer {
+	return &MockMailer{
+		logger: logger,
+	}
+}
+
+func (m *MockMailer) Send(ctx context.Context, message Message) error {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+
+	m.sent = append(m.sent, message)
+
+	return nil
+}
+
+// Sent returns the emails sent so far, oldest first
+func (m *MockMailer) Sent() []Message {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return append([]Message{}, m.sent...)
+}
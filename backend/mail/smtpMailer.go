@@ -0,0 +1,133 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+)
+
+// defaultSMTPPort is the submission port, used when SMTP_PORT isn't set
+const defaultSMTPPort = "587"
+
+// Enforce interface compliance
+var _ Mailer = (*SMTPMailer)(nil)
+
+/*
+SMTPMailer sends emails through an SMTP relay. The connection is upgraded with STARTTLS whenever the server offers it, and
+credentials are only sent over TLS, or to localhost, as net/smtp's PLAIN auth refuses to send them in the clear.
+*/
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+	envelope string // The bare address of from, which the SMTP MAIL command takes
+	logger   logger.ILogger
+}
+
+func NewSMTPMailer(host, port, username, password, from string, logger logger.ILogger) (*SMTPMailer, error) {
+	if host == "" {
+		return nil, fmt.Errorf("%s - SMTP_HOST must be set when MAILER_TYPE is smtp", helper.GetFunctionName())
+	}
+
+	if port == "" {
+		port = defaultSMTPPort
+	}
+
+	address, err := netmail.ParseAddress(from)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s - MAIL_FROM must be an email address such as \"DoHabitsApp <no-reply@example.com>\": %w", helper.GetFunctionName(), err)
+	}
+
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     address.String(),
+		envelope: address.Address,
+		logger:   logger,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	email, err := formatMessage(m.from, message)
+
+	if err != nil {
+		return err
+	}
+
+	if err := m.send(ctx, message.To, email); err != nil {
+		m.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to send the email: err=%s", err))
+		return fmt.Errorf("%s - Failed to send the email: %w", helper.GetFunctionName(), err)
+	}
+
+	return nil
+}
+
+// send is smtp.SendMail, apart from the connection being bound to ctx so a stalled server can't hold the request forever
+func (m *SMTPMailer) send(ctx context.Context, to string, email []byte) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+
+	if m.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.envelope); err != nil {
+		return err
+	}
+
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	writer, err := client.Data()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(email); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -2,20 +2,26 @@ package main
 
 import (
 	"dohabits/controller"
+	"dohabits/data"
 	"dohabits/db"
 	"dohabits/helper"
 	"dohabits/internal"
 	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/mail"
 	"dohabits/middleware"
 	"dohabits/middleware/session"
 	"dohabits/model"
+	"dohabits/oidc"
 	"dohabits/routes"
+	"dohabits/validation"
 	"dohabits/view"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	_ "time/tzdata" // Embeds the IANA timezone database as the alpine image doesn't ship one
 )
 
 var App *internal.App
@@ -40,23 +46,95 @@ func init() {
 		log.Fatal(err)
 	}
 
-	jwtTokens := session.NewJSONWebToken(os.Getenv("JWT_SECRET"), db)
-	csrfTokens := session.NewCSRFToken(os.Getenv("JWT_SECRET"), logger)
+	keys, err := session.LoadKeySet(os.Getenv("JWT_SECRET"), os.Getenv("JWT_SIGNING_KEY_FILE"), os.Getenv("JWT_VERIFICATION_KEY_FILES"))
 
-	authModel := model.NewAuthModel(logger, db)
-	habitsModel := model.NewHabitsModel(logger, db)
+	if err != nil {
+		log.Fatalf("Failed to load the JWT signing keys: err=%s", err)
+	}
+
+	jwtTokens := session.NewJSONWebToken(keys, db, logger)
+	csrfTokens := session.NewCSRFToken(keys, logger)
+	personalAccessTokens := session.NewPersonalAccessTokens(db, logger)
+
+	trustedProxies, err := helper.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+
+	if err != nil {
+		log.Fatalf("TRUSTED_PROXIES must be a comma-separated list of IP addresses and CIDR ranges: err=%s", err)
+	}
+
+	mailer, err := mail.NewMailer(os.Getenv("MAILER_TYPE"), logger)
+
+	if err != nil {
+		log.Fatalf("Failed to set up the mailer: err=%s", err)
+	}
+
+	unverifiedUserAccess := data.UnverifiedUserAccess(os.Getenv("UNVERIFIED_USER_ACCESS"))
+
+	if unverifiedUserAccess == "" {
+		unverifiedUserAccess = data.UnverifiedUserAccessAll
+	}
+
+	passwordPolicy, err := validation.ParsePasswordPolicy(os.Getenv("PASSWORD_MIN_LENGTH"), os.Getenv("PASSWORD_MIN_CHARACTER_CLASSES"), os.Getenv("PASSWORD_BLOCKLIST_FILE"))
+
+	if err != nil {
+		log.Fatalf("Failed to read the password policy: err=%s", err)
+	}
+
+	loginAttemptsPolicy, err := loginattempts.ParsePolicy(os.Getenv("LOGIN_MAX_FAILURES"), os.Getenv("LOGIN_MAX_FAILURES_PER_IP"), os.Getenv("LOGIN_LOCKOUT"), os.Getenv("LOGIN_MAX_LOCKOUT"))
+
+	if err != nil {
+		log.Fatalf("Failed to read the login lockout settings: err=%s", err)
+	}
+
+	loginAttemptsStore, err := loginattempts.NewStore(os.Getenv("LOGIN_ATTEMPTS_STORE"), db)
+
+	if err != nil {
+		log.Fatalf("Failed to set up the login attempts store: err=%s", err)
+	}
+
+	oidcProviders, err := oidc.LoadProviders(os.Getenv("OIDC_PROVIDERS"), os.Getenv("SITE_URL"), logger)
+
+	if err != nil {
+		log.Fatalf("Failed to set up the OIDC providers: err=%s", err)
+	}
+
+	loginAttempts := loginattempts.NewLimiter(loginAttemptsStore, loginAttemptsPolicy, logger)
+	emailVerificationTokens := session.NewEmailVerificationToken(keys, logger)
+	emailVerificationModel := model.NewEmailVerificationModel(logger, db, mailer, emailVerificationTokens, os.Getenv("SITE_URL"))
+	mfaModel := model.NewMFAModel(logger, db, session.NewMFAToken(keys, logger), loginAttempts)
+	oidcModel := model.NewOIDCModel(logger, db, session.NewOIDCStateToken(keys, logger), oidcProviders)
+	authModel := model.NewAuthModel(logger, db, emailVerificationModel, mfaModel, oidcModel, loginAttempts, passwordPolicy, unverifiedUserAccess)
+	habitsModel := model.NewHabitsModel(logger, db, unverifiedUserAccess)
+	passwordModel := model.NewPasswordModel(logger, db, mailer, passwordPolicy, os.Getenv("SITE_URL"))
+	personalAccessTokenModel := model.NewPersonalAccessTokenModel(logger, db, personalAccessTokens)
+	adminModel := model.NewAdminModel(logger, db)
+	exportModel := model.NewExportModel(logger, db)
 	authView := view.NewAuthView(logger)
 	habitsView := view.NewHabitsView(logger)
-	authController := controller.NewAuthController(authModel, authView, jwtTokens, csrfTokens, logger)
+	keysView := view.NewKeysView(logger)
+	passwordView := view.NewPasswordView(logger)
+	emailVerificationView := view.NewEmailVerificationView(logger)
+	mfaView := view.NewMFAView(logger)
+	personalAccessTokenView := view.NewPersonalAccessTokenView(logger)
+	adminView := view.NewAdminView(logger)
+	exportView := view.NewExportView(logger)
+	authController := controller.NewAuthController(authModel, authView, jwtTokens, csrfTokens, logger, trustedProxies)
 	habitsController := controller.NewHabitsController(habitsModel, habitsView, logger)
-
-	mw := middleware.NewMiddleware(jwtTokens, csrfTokens, logger)
+	keysController := controller.NewKeysController(keys, keysView, logger)
+	passwordController := controller.NewPasswordController(passwordModel, passwordView, logger)
+	emailVerificationController := controller.NewEmailVerificationController(emailVerificationModel, emailVerificationView, logger)
+	mfaController := controller.NewMFAController(mfaModel, mfaView, logger)
+	personalAccessTokenController := controller.NewPersonalAccessTokenController(personalAccessTokenModel, personalAccessTokenView, logger)
+	adminController := controller.NewAdminController(adminModel, adminView, logger)
+	exportController := controller.NewExportController(exportModel, exportView, logger)
+
+	mw := middleware.NewMiddleware(jwtTokens, csrfTokens, personalAccessTokens, logger)
 	apiName := os.Getenv("API_NAME")
 	apiVersion := os.Getenv("API_VERSION")
 	appVersion := os.Getenv("APP_VERSION")
 	port := os.Getenv("PORT")
 
-	App = internal.NewApp(authController, habitsController, db, mw, logger, apiName, apiVersion, appVersion, port, jwtTokens)
+	App = internal.NewApp(authController, habitsController, keysController, passwordController, emailVerificationController, mfaController, personalAccessTokenController, adminController, exportController, db, mw, logger, apiName, apiVersion, appVersion, port, jwtTokens)
 
 	App.GetLogger().DebugLog(helper.GetFunctionName(), fmt.Sprintf("%s loaded successfully. App Version = %s, API Version = %s", App.GetAPIName(), App.GetAppVersion(), App.GetAPIVersion()))
 }
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"dohabits/apperror"
 	"dohabits/helper"
 	"dohabits/logger"
 	"dohabits/middleware/session"
@@ -8,17 +9,26 @@ import (
 	"net/http"
 )
 
+var ErrInvalidCSRFToken = apperror.New(http.StatusForbidden, apperror.CodeInvalidCSRFToken, "A valid X-CSRF-Token header is required")
+
 func CSRFToken(csrfTokens session.ICSRFToken, logger logger.ILogger) func(http.HandlerFunc) http.HandlerFunc {
 	functionName := helper.GetFunctionName()
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			logger.InfoLog(functionName, "")
-			// If CSRF token is required for certain HTTP methods (e.g., POST, PUT, DELETE), validate it
-			if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodDelete {
+
+			// Personal access tokens are sent by scripts rather than a browser, so there's no cookie for a forged request to ride on
+			if claims, ok := r.Context().Value(session.ClaimsKey).(*session.Claims); ok && claims.PersonalAccessTokenID != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// If CSRF token is required for certain HTTP methods (e.g., POST, PUT, PATCH, DELETE), validate it
+			if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodDelete {
 				// Validate CSRF Token
 				if err := csrfTokens.ValidateCSRFToken(r); err != nil {
 					logger.ErrorLog(functionName, fmt.Sprintf("CSRF validation failed: %v", err))
-					http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+					apperror.WriteProblem(w, r, ErrInvalidCSRFToken)
 					return
 				}
 			}
@@ -26,7 +36,7 @@ func CSRFToken(csrfTokens session.ICSRFToken, logger logger.ILogger) func(http.H
 			csrfToken, err := csrfTokens.CSRFToken(w)
 
 			if err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				apperror.WriteProblem(w, r, err)
 				return
 			}
 
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	csrfTokens := session.NewCSRFToken(session.NewHMACKeySet("secret"), logger)
+
+	handler := CSRFToken(csrfTokens, logger)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	testCases := []struct {
+		name       string
+		method     string
+		claims     *session.Claims
+		wantStatus int
+	}{
+		{name: "Test GET without a token", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "Test POST without a token", method: http.MethodPost, wantStatus: http.StatusForbidden},
+		{name: "Test PUT without a token", method: http.MethodPut, wantStatus: http.StatusForbidden},
+		{name: "Test PATCH without a token", method: http.MethodPatch, wantStatus: http.StatusForbidden},
+		{name: "Test DELETE without a token", method: http.MethodDelete, wantStatus: http.StatusForbidden},
+		{name: "Test POST after logging in without a token", method: http.MethodPost, claims: &session.Claims{Username: "johndoe1@example.com", SessionID: "mock-session-1"}, wantStatus: http.StatusForbidden},
+		{name: "Test POST with a personal access token", method: http.MethodPost, claims: &session.Claims{Username: "johndoe1@example.com", PersonalAccessTokenID: "script"}, wantStatus: http.StatusOK},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, "/dohabitsapp/v1/habits/1", nil)
+
+			if val.claims != nil {
+				req = req.WithContext(context.WithValue(req.Context(), session.ClaimsKey, val.claims))
+			}
+
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+		})
+	}
+}
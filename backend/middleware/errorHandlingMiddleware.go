@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"dohabits/apperror"
 	"dohabits/helper"
 	"dohabits/logger"
 	"fmt"
@@ -16,7 +17,7 @@ func ErrorHandlingMiddleware(logger logger.ILogger) func(http.HandlerFunc) http.
 			defer func() {
 				if err := recover(); err != nil {
 					logger.ErrorLog(functionName, fmt.Sprintf("middleware.ErrorHandlingMiddleware - Err: %s", err))
-					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+					apperror.WriteProblem(w, r, apperror.ErrInternal)
 				}
 			}()
 
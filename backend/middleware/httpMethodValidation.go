@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"dohabits/apperror"
 	"dohabits/helper"
 	"dohabits/logger"
 	"net/http"
@@ -13,7 +14,8 @@ func HTTPMethodValidation(httpMethod string, logger logger.ILogger) func(http.Ha
 			logger.InfoLog(functionName, "")
 
 			if r.Method != httpMethod {
-				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				w.Header().Set("Allow", httpMethod)
+				apperror.WriteProblem(w, r, apperror.ErrMethodNotAllowed)
 				return
 			}
 
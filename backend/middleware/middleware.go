@@ -8,22 +8,24 @@ import (
 )
 
 type Middleware struct {
-	jwtTokens  session.IJSONWebToken
-	csrfTokens session.ICSRFToken
-	logger     logger.ILogger
+	jwtTokens            session.IJSONWebToken
+	csrfTokens           session.ICSRFToken
+	personalAccessTokens session.IPersonalAccessTokens
+	logger               logger.ILogger
 }
 
 type IMiddleware interface {
 	MiddlewareList(handler http.HandlerFunc, dependencies data.Middleware) http.HandlerFunc
-	protectedMiddlewareList() []func(http.HandlerFunc) http.HandlerFunc
+	protectedMiddlewareList(scope, role string) []func(http.HandlerFunc) http.HandlerFunc
 	chainMiddleware(handler http.HandlerFunc, middlewares []func(http.HandlerFunc) http.HandlerFunc) http.HandlerFunc
 }
 
-func NewMiddleware(jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken, logger logger.ILogger) *Middleware {
+func NewMiddleware(jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken, personalAccessTokens session.IPersonalAccessTokens, logger logger.ILogger) *Middleware {
 	return &Middleware{
-		jwtTokens:  jwtTokens,
-		csrfTokens: csrfTokens,
-		logger:     logger,
+		jwtTokens:            jwtTokens,
+		csrfTokens:           csrfTokens,
+		personalAccessTokens: personalAccessTokens,
+		logger:               logger,
 	}
 }
 
@@ -34,7 +36,7 @@ func (mw *Middleware) MiddlewareList(handler http.HandlerFunc, dependencies data
 	}
 
 	if dependencies.IsProtected {
-		middlewares = append(middlewares, mw.protectedMiddlewareList()...)
+		middlewares = append(middlewares, mw.protectedMiddlewareList(dependencies.Scope, dependencies.Role)...)
 	}
 
 	if dependencies.CSRFRequired {
@@ -46,10 +48,16 @@ func (mw *Middleware) MiddlewareList(handler http.HandlerFunc, dependencies data
 	return mw.chainMiddleware(handler, middlewares)
 }
 
-func (mw *Middleware) protectedMiddlewareList() []func(http.HandlerFunc) http.HandlerFunc {
-	return []func(http.HandlerFunc) http.HandlerFunc{
-		session.AuthMiddleware(mw.jwtTokens, mw.logger),
+func (mw *Middleware) protectedMiddlewareList(scope, role string) []func(http.HandlerFunc) http.HandlerFunc {
+	middlewares := []func(http.HandlerFunc) http.HandlerFunc{
+		session.AuthMiddleware(mw.jwtTokens, mw.personalAccessTokens, scope, mw.logger),
+	}
+
+	if role != "" {
+		middlewares = append(middlewares, session.RoleMiddleware(role, mw.logger))
 	}
+
+	return middlewares
 }
 
 // Chain the listed middlewares in reverse order
@@ -2,21 +2,24 @@ package session
 
 import (
 	"context"
+	"dohabits/apperror"
 	"dohabits/helper"
 	"dohabits/logger"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type contextKey string
 
 const ClaimsKey = contextKey("claims")
 
-func AuthMiddleware(jwtTokens IJSONWebToken, logger logger.ILogger) func(http.HandlerFunc) http.HandlerFunc {
+/*
+AuthMiddleware accepts either a JWT access token or a personal access token as the bearer token. A personal access token is
+only accepted if it has been granted scope, so endpoints without a scope can only be used after logging in.
+*/
+func AuthMiddleware(jwtTokens IJSONWebToken, personalAccessTokens IPersonalAccessTokens, scope string, logger logger.ILogger) func(http.HandlerFunc) http.HandlerFunc {
 	functionName := helper.GetFunctionName()
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
@@ -27,19 +30,38 @@ func AuthMiddleware(jwtTokens IJSONWebToken, logger logger.ILogger) func(http.Ha
 			// Attempt to get the Access Token from the Authorization Header
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
 				logger.ErrorLog(functionName, "No Auth Header present")
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				apperror.WriteProblem(w, r, apperror.ErrUnauthorized)
 				return
 			}
 
 			tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-				return jwtTokens.GetJWTKey(), nil
-			})
 
-			if err != nil || !token.Valid {
+			// Personal access tokens don't expire every few minutes, so there's nothing to renew
+			if IsPersonalAccessToken(tokenStr) {
+				claims, err := personalAccessTokens.ParsePersonalAccessToken(r.Context(), tokenStr)
+
+				if err != nil {
+					logger.ErrorLog(functionName, fmt.Sprintf("Personal access token error: %s", err))
+					apperror.WriteProblem(w, r, err)
+					return
+				}
+
+				if !claims.HasScope(scope) {
+					logger.ErrorLog(functionName, fmt.Sprintf("tokenId=%s doesn't have scope %q", claims.PersonalAccessTokenID, scope))
+					apperror.WriteProblem(w, r, ErrInsufficientScope)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), ClaimsKey, claims)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			claims, err := jwtTokens.ParseJSONWebToken(tokenStr)
+
+			if err != nil {
 				logger.ErrorLog(functionName, "JWT Token error")
-				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				apperror.WriteProblem(w, r, apperror.ErrUnauthorized)
 				return
 			}
 
@@ -56,10 +78,11 @@ func AuthMiddleware(jwtTokens IJSONWebToken, logger logger.ILogger) func(http.Ha
 			}
 
 			// Attempt to get a new Access Token
-			newAccessToken, err := jwtTokens.HandleLongLivedJSONWebToken(claims.Username)
+			newAccessToken, err := jwtTokens.RenewAccessToken(r.Context(), claims.Subject, claims.SessionID)
 
 			if err != nil {
-				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				logger.ErrorLog(functionName, fmt.Sprintf("Failed to refresh the access token: %s", err))
+				apperror.WriteProblem(w, r, err)
 				return
 			}
 
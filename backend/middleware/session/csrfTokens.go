@@ -5,23 +5,27 @@ import (
 	"dohabits/logger"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// csrfAudience sets CSRF tokens apart from access tokens, which are signed with the same keys, so neither can stand in for the other
+const csrfAudience = "csrf"
+
 type ICSRFToken interface {
 	CSRFToken(w http.ResponseWriter) (string, error)
 	generateCSRFToken() (string, error)
 	ValidateCSRFToken(r *http.Request) error
 }
 type CSRFToken struct {
-	jwtKey []byte
+	keys   *KeySet
 	logger logger.ILogger
 }
 
-func NewCSRFToken(jwtSecret string, logger logger.ILogger) *CSRFToken {
-	return &CSRFToken{jwtKey: []byte(jwtSecret), logger: logger}
+func NewCSRFToken(keys *KeySet, logger logger.ILogger) *CSRFToken {
+	return &CSRFToken{keys: keys, logger: logger}
 }
 
 func (cs *CSRFToken) CSRFToken(w http.ResponseWriter) (string, error) {
@@ -39,18 +43,15 @@ func (cs *CSRFToken) CSRFToken(w http.ResponseWriter) (string, error) {
 func (cs *CSRFToken) generateCSRFToken() (string, error) {
 	shortLivedJWT := time.Now().Add(5 * time.Minute)
 
-	claims := &Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(shortLivedJWT),
-		},
+	claims := &jwt.RegisteredClaims{
+		Audience:  jwt.ClaimStrings{csrfAudience},
+		ExpiresAt: jwt.NewNumericDate(shortLivedJWT),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString(cs.jwtKey)
+	return cs.keys.Sign(claims)
 }
 
-// ValidateCSRFToken validates the CSRF token in the request
+// ValidateCSRFToken validates the CSRF token in the request. The parser checks it hasn't expired
 func (cs *CSRFToken) ValidateCSRFToken(r *http.Request) error {
 	tokenFromHeader := r.Header.Get("X-CSRF-Token")
 
@@ -59,30 +60,19 @@ func (cs *CSRFToken) ValidateCSRFToken(r *http.Request) error {
 		return fmt.Errorf("%s - Invalid or missing CSRF token", helper.GetFunctionName())
 	}
 
-	token, err := jwt.Parse(tokenFromHeader, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the signing method is what you expect
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			cs.logger.ErrorLog(helper.GetFunctionName(), "Unexpected Signing Method")
-			return nil, fmt.Errorf("%s - Unexpected Signing Method", helper.GetFunctionName())
-		}
-		return cs.jwtKey, nil
-	})
+	claims := &jwt.RegisteredClaims{}
+
+	// The key set only accepts each key's own signing method
+	token, err := cs.keys.Parse(tokenFromHeader, claims)
 
 	if err != nil {
 		return err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// Optional: Check expiration
-		if exp, ok := claims["exp"].(float64); ok {
-			if time.Now().Unix() > int64(exp) {
-				cs.logger.ErrorLog(helper.GetFunctionName(), "Invalid CSRF Token")
-				return fmt.Errorf("%s - Invalid CSRF Token", helper.GetFunctionName())
-			}
-		}
-		return nil
+	if !token.Valid || !slices.Contains(claims.Audience, csrfAudience) {
+		cs.logger.ErrorLog(helper.GetFunctionName(), "Invalid CSRF Token")
+		return fmt.Errorf("%s - Invalid CSRF Token", helper.GetFunctionName())
 	}
 
-	cs.logger.ErrorLog(helper.GetFunctionName(), "Invalid CSRF Token")
-	return fmt.Errorf("%s - Invalid CSRF Token", helper.GetFunctionName())
+	return nil
 }
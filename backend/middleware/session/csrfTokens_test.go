@@ -0,0 +1,76 @@
+package session
+
+import (
+	"dohabits/helper"
+	"dohabits/logger"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRFToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	keys := NewHMACKeySet("secretJwt")
+	csrfTokens := NewCSRFToken(keys, logger)
+	jwtTokens := NewJSONWebToken(keys, nil, logger)
+	mfaTokens := NewMFAToken(keys, logger)
+
+	csrfToken, err := csrfTokens.CSRFToken(httptest.NewRecorder())
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// A CSRF token is handed to the page's scripts, so it mustn't grant access
+	if _, err := jwtTokens.ParseJSONWebToken(csrfToken); err == nil {
+		t.Errorf("%s - Failed - a CSRF token was accepted as an access token", helper.GetFunctionName())
+	}
+
+	accessToken, refreshToken, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "session-1", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	mfaToken, _, err := mfaTokens.GenerateMFAToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "Test a CSRF token", token: csrfToken, wantErr: false},
+		{name: "Test a missing token", token: "", wantErr: true},
+		{name: "Test an access token", token: accessToken, wantErr: true},
+		{name: "Test a refresh token", token: refreshToken, wantErr: true},
+		{name: "Test an MFA token", token: mfaToken, wantErr: true},
+		{name: "Test a CSRF token signed with another key", token: mustCSRFToken(t, NewCSRFToken(NewHMACKeySet("otherSecret"), logger)), wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/dohabitsapp/v1/habits", nil)
+			req.Header.Set("X-CSRF-Token", val.token)
+
+			if err := csrfTokens.ValidateCSRFToken(req); (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+}
+
+func mustCSRFToken(t *testing.T, csrfTokens *CSRFToken) string {
+	t.Helper()
+
+	csrfToken, err := csrfTokens.CSRFToken(httptest.NewRecorder())
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	return csrfToken
+}
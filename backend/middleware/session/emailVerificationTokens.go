@@ -0,0 +1,143 @@
+package session
+
+import (
+	"dohabits/apperror"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// EmailVerificationTTL is how long the link in a verification email can be used for
+const EmailVerificationTTL = 24 * time.Hour
+
+// emailVerificationAudience sets verification tokens apart from access tokens, which are signed with the same keys
+const emailVerificationAudience = "verify-email"
+
+// emailChangeAudience sets email change tokens apart from verification tokens, so verifying an address can't change one
+const emailChangeAudience = "change-email"
+
+// ErrInvalidEmailVerificationToken means the verification link has been tampered with, has expired or is for an old email address
+var ErrInvalidEmailVerificationToken = apperror.New(http.StatusBadRequest, apperror.CodeInvalidVerificationToken, "The verification link is invalid or has expired")
+
+// EmailVerificationClaims are the claims of the token in a verification link. The subject is the user's ID.
+type EmailVerificationClaims struct {
+	EmailAddress string `json:"email"` // The address being verified, so a link stops working once the user changes it
+	jwt.RegisteredClaims
+}
+
+// EmailChangeClaims are the claims of the token in the link sent to a new email address. The subject is the user's ID.
+type EmailChangeClaims struct {
+	EmailAddress         string `json:"email"`          // The new address, which the link was sent to
+	PreviousEmailAddress string `json:"previous_email"` // The address when the change was asked for, so the link stops working once it's used
+	jwt.RegisteredClaims
+}
+
+/*
+EmailVerificationToken signs the tokens in email verification links with the access token keys. They aren't stored anywhere,
+as verifying an address twice changes nothing, so they only need to be unforgeable and expire.
+*/
+type EmailVerificationToken struct {
+	keys   *KeySet
+	logger logger.ILogger
+}
+
+type IEmailVerificationToken interface {
+	GenerateEmailVerificationToken(userID, emailAddress string) (string, error)
+	ParseEmailVerificationToken(tokenString string) (*EmailVerificationClaims, error)
+	GenerateEmailChangeToken(userID, previousEmailAddress, emailAddress string) (string, error)
+	ParseEmailChangeToken(tokenString string) (*EmailChangeClaims, error)
+}
+
+func NewEmailVerificationToken(keys *KeySet, logger logger.ILogger) *EmailVerificationToken {
+	return &EmailVerificationToken{
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+func (ev *EmailVerificationToken) GenerateEmailVerificationToken(userID, emailAddress string) (string, error) {
+	now := time.Now()
+
+	claims := &EmailVerificationClaims{
+		EmailAddress: emailAddress,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{emailVerificationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(EmailVerificationTTL)),
+		},
+	}
+
+	tokenString, err := ev.keys.Sign(claims)
+
+	if err != nil {
+		ev.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Email verification token generation failed: %v", err))
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// ParseEmailVerificationToken returns the claims of a verification token, or ErrInvalidEmailVerificationToken for any other token
+func (ev *EmailVerificationToken) ParseEmailVerificationToken(tokenString string) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+
+	token, err := ev.keys.Parse(tokenString, claims)
+
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrInvalidEmailVerificationToken)
+	}
+
+	if !slices.Contains(claims.Audience, emailVerificationAudience) || claims.Subject == "" || claims.EmailAddress == "" {
+		return nil, fmt.Errorf("%s - not an email verification token: %w", helper.GetFunctionName(), ErrInvalidEmailVerificationToken)
+	}
+
+	return claims, nil
+}
+
+// GenerateEmailChangeToken signs the token in the link sent to emailAddress, which moves the user to it, valid for EmailVerificationTTL
+func (ev *EmailVerificationToken) GenerateEmailChangeToken(userID, previousEmailAddress, emailAddress string) (string, error) {
+	now := time.Now()
+
+	claims := &EmailChangeClaims{
+		EmailAddress:         emailAddress,
+		PreviousEmailAddress: previousEmailAddress,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{emailChangeAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(EmailVerificationTTL)),
+		},
+	}
+
+	tokenString, err := ev.keys.Sign(claims)
+
+	if err != nil {
+		ev.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Email change token generation failed: %v", err))
+		return "", err
+	}
+
+	return tokenString, nil
+}
+
+// ParseEmailChangeToken returns the claims of an email change token, or ErrInvalidEmailVerificationToken for any other token
+func (ev *EmailVerificationToken) ParseEmailChangeToken(tokenString string) (*EmailChangeClaims, error) {
+	claims := &EmailChangeClaims{}
+
+	token, err := ev.keys.Parse(tokenString, claims)
+
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrInvalidEmailVerificationToken)
+	}
+
+	if !slices.Contains(claims.Audience, emailChangeAudience) || claims.Subject == "" || claims.EmailAddress == "" || claims.PreviousEmailAddress == "" {
+		return nil, fmt.Errorf("%s - not an email change token: %w", helper.GetFunctionName(), ErrInvalidEmailVerificationToken)
+	}
+
+	return claims, nil
+}
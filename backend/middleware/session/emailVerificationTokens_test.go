@@ -0,0 +1,101 @@
+package session
+
+import (
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"testing"
+)
+
+func TestEmailVerificationToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	keys := NewHMACKeySet("secretJwt")
+	emailVerificationTokens := NewEmailVerificationToken(keys, logger)
+	jwtTokens := NewJSONWebToken(keys, nil, logger)
+
+	token, err := emailVerificationTokens.GenerateEmailVerificationToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	claims, err := emailVerificationTokens.ParseEmailVerificationToken(token)
+
+	if err != nil || claims.Subject != "1" || claims.EmailAddress != "johndoe1@example.com" {
+		t.Fatalf("%s - Failed - claims=%+v, err=%v", helper.GetFunctionName(), claims, err)
+	}
+
+	// A verification link can't be used as an access token, or the other way round
+	if _, err := jwtTokens.ParseJSONWebToken(token); err == nil {
+		t.Fatalf("%s - Failed - a verification token was accepted as an access token", helper.GetFunctionName())
+	}
+
+	accessToken, _, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "session-1", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	otherKeyToken, err := NewEmailVerificationToken(NewHMACKeySet("otherSecret"), logger).GenerateEmailVerificationToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "Test access token", token: accessToken},
+		{name: "Test token signed with another key", token: otherKeyToken},
+		{name: "Test token that isn't a JWT", token: "not-a-jwt"},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			_, err := emailVerificationTokens.ParseEmailVerificationToken(val.token)
+
+			if !errors.Is(err, ErrInvalidEmailVerificationToken) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidEmailVerificationToken)
+			}
+		})
+	}
+}
+
+func TestEmailChangeToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	keys := NewHMACKeySet("secretJwt")
+	emailVerificationTokens := NewEmailVerificationToken(keys, logger)
+	jwtTokens := NewJSONWebToken(keys, nil, logger)
+
+	token, err := emailVerificationTokens.GenerateEmailChangeToken("1", "johndoe1@example.com", "john.new@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	claims, err := emailVerificationTokens.ParseEmailChangeToken(token)
+
+	if err != nil || claims.Subject != "1" || claims.PreviousEmailAddress != "johndoe1@example.com" || claims.EmailAddress != "john.new@example.com" {
+		t.Fatalf("%s - Failed - claims=%+v, err=%v", helper.GetFunctionName(), claims, err)
+	}
+
+	// An email change link can't verify an address or be used as an access token
+	if _, err := emailVerificationTokens.ParseEmailVerificationToken(token); !errors.Is(err, ErrInvalidEmailVerificationToken) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidEmailVerificationToken)
+	}
+
+	if _, err := jwtTokens.ParseJSONWebToken(token); err == nil {
+		t.Fatalf("%s - Failed - an email change token was accepted as an access token", helper.GetFunctionName())
+	}
+
+	verificationToken, err := emailVerificationTokens.GenerateEmailVerificationToken("1", "john.new@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := emailVerificationTokens.ParseEmailChangeToken(verificationToken); !errors.Is(err, ErrInvalidEmailVerificationToken) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidEmailVerificationToken)
+	}
+}
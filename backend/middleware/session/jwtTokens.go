@@ -1,52 +1,130 @@
 package session
 
 import (
+	"context"
+	"crypto/subtle"
+	"dohabits/apperror"
 	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+var (
+	// ErrInvalidRefreshToken means the user's session has expired and they need to log in again
+	ErrInvalidRefreshToken = apperror.New(http.StatusUnauthorized, apperror.CodeUnauthorized, "The session has expired, please log in again")
+	// ErrRefreshTokenReused means a refresh token was used after it had been rotated out, so its session has been revoked
+	ErrRefreshTokenReused = apperror.New(http.StatusUnauthorized, apperror.CodeUnauthorized, "The session has been revoked, please log in again")
+	// ErrAccountDisabled means an admin has disabled the user's account, so it can't log in or be used until it's enabled again
+	ErrAccountDisabled = apperror.New(http.StatusForbidden, apperror.CodeAccountDisabled, "The account has been disabled")
+)
+
+/*
+refreshTokenAudience sets refresh tokens apart from access tokens, which are signed with the same keys. ParseJSONWebToken rejects
+it, so a refresh token can't be sent as a Bearer token, and only HandleLongLivedJSONWebToken and RenewAccessToken accept it.
+*/
+const refreshTokenAudience = "refresh"
+
 type JSONWebToken struct {
-	jwtKey []byte
+	keys   *KeySet
 	db     db.IDB
+	logger logger.ILogger
 }
 
 type IJSONWebToken interface {
-	GetJWTKey() []byte
-	GenerateJSONWebTokens(username string) (string, string, error)
-	generateShortLivedJSONWebToken(username string) (string, error)
-	generateLongLivedJSONWebToken(username string) (string, error)
-	generateJSONWebToken(username string, expirationTime time.Time) (string, error)
-	HandleLongLivedJSONWebToken(username string) (string, error)
+	ParseJSONWebToken(tokenString string) (*Claims, error)
+	GenerateJSONWebTokens(userID, username, sessionID string, roles []string) (string, string, error)
+	generateShortLivedJSONWebToken(userID, username, sessionID string, roles []string) (string, error)
+	generateLongLivedJSONWebToken(userID, username, sessionID string, roles []string) (string, error)
+	generateJSONWebToken(userID, username, sessionID string, roles []string, audience string, expirationTime time.Time) (string, error)
+	HandleLongLivedJSONWebToken(ctx context.Context, refreshToken string) (*RefreshedTokens, error)
+	RenewAccessToken(ctx context.Context, userID, sessionID string) (string, error)
 }
 
-func NewJSONWebToken(jwtSecret string, db db.IDB) *JSONWebToken {
+func NewJSONWebToken(keys *KeySet, db db.IDB, logger logger.ILogger) *JSONWebToken {
 	return &JSONWebToken{
-		jwtKey: []byte(jwtSecret),
+		keys:   keys,
 		db:     db,
+		logger: logger,
 	}
 }
 
-// Claims represents the JWT claims
+// RefreshedTokens are issued in exchange for a refresh token. The refresh token that was exchanged can't be used again.
+type RefreshedTokens struct {
+	Username     string
+	SessionID    string
+	AccessToken  string
+	RefreshToken string
+}
+
+/*
+Claims represents the JWT claims. Subject is the user's ID, which unlike Username, their email address, never changes or passes to
+another user.
+*/
 type Claims struct {
-	Username string `json:"username"`
+	Username  string   `json:"username"`
+	SessionID string   `json:"sid"`             // The session the token was issued to
+	Roles     []string `json:"roles,omitempty"` // The user's roles when the token was issued, see data.HasRole
+	// Set instead of SessionID when the request was authenticated with a personal access token, which is limited to its Scopes
+	PersonalAccessTokenID string   `json:"-"`
+	Scopes                []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
-func (sa *JSONWebToken) GetJWTKey() []byte {
-	return sa.jwtKey
+// ParseJSONWebToken verifies a token signed by any of the keys in the key set and returns its claims
+func (sa *JSONWebToken) ParseJSONWebToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := sa.keys.Parse(tokenString, claims)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, fmt.Errorf("%s - invalid token", helper.GetFunctionName())
+	}
+
+	// Tokens issued for something else, such as refreshing the session or verifying an email address, are signed with the same keys but never grant access
+	if len(claims.Audience) > 0 {
+		return nil, fmt.Errorf("%s - token is for %v", helper.GetFunctionName(), claims.Audience)
+	}
+
+	return claims, nil
+}
+
+// parseRefreshToken verifies a refresh token signed by any of the keys in the key set and returns its claims
+func (sa *JSONWebToken) parseRefreshToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := sa.keys.Parse(tokenString, claims)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || !slices.Contains(claims.Audience, refreshTokenAudience) || claims.Subject == "" || claims.SessionID == "" {
+		return nil, fmt.Errorf("%s - invalid refresh token", helper.GetFunctionName())
+	}
+
+	return claims, nil
 }
 
 // Generates the Access/Refresh JWT Tokens
-func (sa *JSONWebToken) GenerateJSONWebTokens(username string) (string, string, error) {
-	accessToken, err := sa.generateShortLivedJSONWebToken(username) // username will be passed as query param
+func (sa *JSONWebToken) GenerateJSONWebTokens(userID, username, sessionID string, roles []string) (string, string, error) {
+	accessToken, err := sa.generateShortLivedJSONWebToken(userID, username, sessionID, roles) // username will be passed as query param
 
 	if err != nil {
 		return "", "", err
 	}
 
-	refreshToken, err := sa.generateLongLivedJSONWebToken(username)
+	refreshToken, err := sa.generateLongLivedJSONWebToken(userID, username, sessionID, roles)
 
 	if err != nil {
 		return "", "", err
@@ -56,58 +134,149 @@ func (sa *JSONWebToken) GenerateJSONWebTokens(username string) (string, string,
 }
 
 // Generates a new short-lived access token
-func (sa *JSONWebToken) generateShortLivedJSONWebToken(username string) (string, error) {
+func (sa *JSONWebToken) generateShortLivedJSONWebToken(userID, username, sessionID string, roles []string) (string, error) {
 	shortLivedJWT := time.Now().Add(5 * time.Minute)
-	return sa.generateJSONWebToken(username, shortLivedJWT)
+	return sa.generateJSONWebToken(userID, username, sessionID, roles, "", shortLivedJWT)
 }
 
 // Generates a new long-lived refresh token
-func (sa *JSONWebToken) generateLongLivedJSONWebToken(username string) (string, error) {
-	longLivedJWT := time.Now().Add(24 * time.Hour) // Long-lived refresh token
-	tokenString, err := sa.generateJSONWebToken(username, longLivedJWT)
+func (sa *JSONWebToken) generateLongLivedJSONWebToken(userID, username, sessionID string, roles []string) (string, error) {
+	longLivedJWT := time.Now().Add(db.SessionTTL) // Long-lived refresh token
+	tokenString, err := sa.generateJSONWebToken(userID, username, sessionID, roles, refreshTokenAudience, longLivedJWT)
 
 	return tokenString, err
 }
 
-// Generate a JSON Web Token with username and expiry claims
-func (sa *JSONWebToken) generateJSONWebToken(username string, expirationTime time.Time) (string, error) {
+// Generate a JSON Web Token with user ID, username, session, roles, audience and expiry claims. An access token has no audience. The random ID makes every token unique, so a rotated refresh token never matches the one it replaced.
+func (sa *JSONWebToken) generateJSONWebToken(userID, username, sessionID string, roles []string, audience string, expirationTime time.Time) (string, error) {
 	expires := expirationTime
 
+	tokenID, err := helper.RandomID()
+
+	if err != nil {
+		return "", err
+	}
+
 	claims := &Claims{
-		Username: username,
+		Username:  username,
+		SessionID: sessionID,
+		Roles:     roles,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expires),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	if audience != "" {
+		claims.Audience = jwt.ClaimStrings{audience}
+	}
 
-	return token.SignedString(sa.jwtKey)
+	return sa.keys.Sign(claims)
 }
 
-// Retrieve the refresh token and validate it
-func (sa *JSONWebToken) HandleLongLivedJSONWebToken(username string) (string, error) {
-	refreshToken, err := sa.db.RetrieveUserSession(username, "")
+/*
+HandleLongLivedJSONWebToken exchanges a refresh token for a new access token and refresh token, rotating the refresh token stored
+with the session. The refresh token is only valid while it's the one stored with its session. Presenting one that has been
+rotated out means two clients hold the session's refresh tokens, one of which has likely been stolen. As there's no knowing which,
+the whole session is revoked. The user is found by the token's subject, their ID, so a token issued before the user changed their
+email address or deleted their account can't reach whoever has the address now. The new tokens carry the user's current email
+address and roles, and a disabled user gets ErrAccountDisabled.
+*/
+func (sa *JSONWebToken) HandleLongLivedJSONWebToken(ctx context.Context, refreshToken string) (*RefreshedTokens, error) {
+	claims, err := sa.parseRefreshToken(refreshToken)
 
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidRefreshToken)
 	}
 
-	// Check if Refresh Token is valid
-	token, err := jwt.Parse(string(refreshToken), func(token *jwt.Token) (interface{}, error) {
-		return sa.jwtKey, nil
-	})
+	userData, err := sa.db.RetrieveUserDetailsByID(ctx, claims.Subject)
+
+	if err != nil {
+		return nil, err
+	}
 
-	if err != nil || !token.Valid {
+	if userData.Disabled {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrAccountDisabled)
+	}
+
+	userSession, err := sa.db.RetrieveUserSession(ctx, userData.UserID, claims.SessionID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(userSession.RefreshToken), []byte(refreshToken)) != 1 {
+		return nil, sa.revokeReusedSession(ctx, userData.UserID, claims.SessionID)
+	}
+
+	newAccessToken, newRefreshToken, err := sa.GenerateJSONWebTokens(userData.UserID, userData.EmailAddress, claims.SessionID, userData.Roles)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = sa.db.RotateRefreshToken(ctx, userData.UserID, claims.SessionID, refreshToken, newRefreshToken)
+
+	// Another request rotated the same refresh token first
+	if errors.Is(err, db.ErrSessionNotFound) {
+		return nil, sa.revokeReusedSession(ctx, userData.UserID, claims.SessionID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &RefreshedTokens{
+		Username:     userData.EmailAddress,
+		SessionID:    claims.SessionID,
+		AccessToken:  newAccessToken,
+		RefreshToken: newRefreshToken,
+	}, nil
+}
+
+/*
+RenewAccessToken issues a new access token with the user's current email address and roles to a session that hasn't been revoked or
+expired, leaving its refresh token as it is. The user is found by their ID, and a disabled user gets ErrAccountDisabled.
+*/
+func (sa *JSONWebToken) RenewAccessToken(ctx context.Context, userID, sessionID string) (string, error) {
+	// Access tokens issued before they carried the user's ID can't be renewed, so the user logs in again
+	if userID == "" {
+		return "", fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidRefreshToken)
+	}
+
+	userData, err := sa.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
 		return "", err
 	}
 
-	// Generate a new access token and refresh token
-	newJWTAccessToken, _, err := sa.GenerateJSONWebTokens(username)
+	if userData.Disabled {
+		return "", fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrAccountDisabled)
+	}
+
+	userSession, err := sa.db.RetrieveUserSession(ctx, userData.UserID, sessionID)
 
 	if err != nil {
 		return "", err
 	}
 
-	return newJWTAccessToken, nil
+	// Check if Refresh Token is valid
+	if _, err := sa.parseRefreshToken(userSession.RefreshToken); err != nil {
+		return "", fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidRefreshToken)
+	}
+
+	return sa.generateShortLivedJSONWebToken(userData.UserID, userData.EmailAddress, sessionID, userData.Roles)
+}
+
+// revokeReusedSession logs the reuse of a rotated out refresh token as a security event and revokes its session
+func (sa *JSONWebToken) revokeReusedSession(ctx context.Context, userID, sessionID string) error {
+	sa.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Rotated out refresh token reused for userId=%s, sessionId=%s - revoking the session", userID, sessionID))
+
+	if err := sa.db.LogoutUser(ctx, userID, sessionID); err != nil && !errors.Is(err, db.ErrSessionNotFound) {
+		return err
+	}
+
+	return fmt.Errorf("%s - userId=%s, sessionId=%s: %w", helper.GetFunctionName(), userID, sessionID, ErrRefreshTokenReused)
 }
@@ -0,0 +1,248 @@
+package session
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleLongLivedJSONWebToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	jwtTokens := NewJSONWebToken(NewHMACKeySet("secretJwt"), mockDB, logger)
+	ctx := context.Background()
+
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() { data.MockUserSession = originalMockUserSessionState }()
+
+	_, firstRefreshToken, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "rotation-session", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	err = mockDB.LoginUser(ctx, &data.UserSession{
+		SessionID:    "rotation-session",
+		UserID:       "1",
+		RefreshToken: firstRefreshToken,
+		CreatedAt:    time.Now(),
+	})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// Exchanging the current refresh token rotates it
+	refreshedTokens, err := jwtTokens.HandleLongLivedJSONWebToken(ctx, firstRefreshToken)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if refreshedTokens.RefreshToken == firstRefreshToken || refreshedTokens.AccessToken == "" {
+		t.Fatalf("%s - Failed - got=%+v", helper.GetFunctionName(), refreshedTokens)
+	}
+
+	if refreshedTokens.Username != "johndoe1@example.com" || refreshedTokens.SessionID != "rotation-session" {
+		t.Fatalf("%s - Failed - got=%+v", helper.GetFunctionName(), refreshedTokens)
+	}
+
+	// Reusing the rotated out refresh token revokes the session
+	_, err = jwtTokens.HandleLongLivedJSONWebToken(ctx, firstRefreshToken)
+
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrRefreshTokenReused)
+	}
+
+	if _, err = mockDB.RetrieveUserSession(ctx, "1", "rotation-session"); !errors.Is(err, db.ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - the session wasn't revoked, err=%v", helper.GetFunctionName(), err)
+	}
+
+	// The refresh token issued by the rotation dies with the session
+	_, err = jwtTokens.HandleLongLivedJSONWebToken(ctx, refreshedTokens.RefreshToken)
+
+	if !errors.Is(err, db.ErrSessionNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, db.ErrSessionNotFound)
+	}
+
+	// Tokens that aren't signed with the secret are rejected
+	_, err = jwtTokens.HandleLongLivedJSONWebToken(ctx, "not-a-jwt")
+
+	if !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestRenewAccessToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	jwtTokens := NewJSONWebToken(NewHMACKeySet("secretJwt"), mockDB, logger)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	_, refreshToken, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "renew-session", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := mockDB.LoginUser(ctx, &data.UserSession{SessionID: "renew-session", UserID: "1", RefreshToken: refreshToken, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// A role granted in the database reaches the user's access token when it's renewed
+	data.MockUsers[0].Roles = []string{data.RoleSupport}
+
+	accessToken, err := jwtTokens.RenewAccessToken(ctx, "1", "renew-session")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	claims, err := jwtTokens.ParseJSONWebToken(accessToken)
+
+	if err != nil || !data.HasRole(claims.Roles, data.RoleSupport) || data.HasRole(claims.Roles, data.RoleAdmin) {
+		t.Fatalf("%s - Failed - claims=%+v, err=%v", helper.GetFunctionName(), claims, err)
+	}
+
+	if err := mockDB.UpdateUserDisabled(ctx, "1", true); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := jwtTokens.RenewAccessToken(ctx, "1", "renew-session"); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrAccountDisabled)
+	}
+
+	if _, err := jwtTokens.HandleLongLivedJSONWebToken(ctx, refreshToken); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrAccountDisabled)
+	}
+}
+
+func TestRefreshTokenIsNotAnAccessToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	jwtTokens := NewJSONWebToken(NewHMACKeySet("secretJwt"), mockDB, logger)
+
+	accessToken, refreshToken, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "mock-session-1", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := jwtTokens.ParseJSONWebToken(refreshToken); err == nil {
+		t.Errorf("%s - Failed - a refresh token was accepted as an access token", helper.GetFunctionName())
+	}
+
+	if _, err := jwtTokens.HandleLongLivedJSONWebToken(context.Background(), accessToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidRefreshToken)
+	}
+
+	handler := AuthMiddleware(jwtTokens, NewPersonalAccessTokens(mockDB, logger), "", logger)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/dohabitsapp/v1/habits", nil)
+	req.Header.Set("Authorization", "Bearer "+refreshToken)
+	w := httptest.NewRecorder()
+
+	handler(w, req)
+
+	if status := w.Code; status != http.StatusUnauthorized {
+		t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, http.StatusUnauthorized)
+	}
+}
+
+func TestTokensFollowTheUserNotTheEmailAddress(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	jwtTokens := NewJSONWebToken(NewHMACKeySet("secretJwt"), mockDB, logger)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+		data.MockHabit = originalMockHabitState
+		data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState
+	}()
+
+	_, refreshToken, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "moved-session", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := mockDB.LoginUser(ctx, &data.UserSession{SessionID: "moved-session", UserID: "1", RefreshToken: refreshToken, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// The user moves to another address and someone else registers the one they left
+	if err := mockDB.UpdateUserEmailAddress(ctx, "1", "john.moved@example.com"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := mockDB.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "johndoe1@example.com", FirstName: "Next", LastName: "Owner", Timezone: data.DefaultTimezone}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	accessToken, err := jwtTokens.RenewAccessToken(ctx, "1", "moved-session")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if claims, err := jwtTokens.ParseJSONWebToken(accessToken); err != nil || claims.Subject != "1" || claims.Username != "john.moved@example.com" {
+		t.Errorf("%s - Failed - claims=%+v, err=%v", helper.GetFunctionName(), claims, err)
+	}
+
+	refreshedTokens, err := jwtTokens.HandleLongLivedJSONWebToken(ctx, refreshToken)
+
+	if err != nil || refreshedTokens.Username != "john.moved@example.com" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), refreshedTokens, err)
+	}
+
+	// Once the user has deleted their account, their tokens don't reach anyone
+	if err := mockDB.DeleteUser(ctx, "1"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := jwtTokens.RenewAccessToken(ctx, "1", "moved-session"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, db.ErrUserNotFound)
+	}
+
+	if _, err := jwtTokens.HandleLongLivedJSONWebToken(ctx, refreshedTokens.RefreshToken); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, db.ErrUserNotFound)
+	}
+
+	// An access token from before tokens carried the user's ID can't be renewed
+	if _, err := jwtTokens.RenewAccessToken(ctx, "", "moved-session"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidRefreshToken)
+	}
+}
@@ -0,0 +1,98 @@
+package session
+
+import (
+	"dohabits/apperror"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MFATokenTTL is how long the user has to enter their code after their password has been accepted
+const MFATokenTTL = 5 * time.Minute
+
+// mfaAudience sets MFA pending tokens apart from access tokens, which are signed with the same keys
+const mfaAudience = "mfa"
+
+// ErrInvalidMFAToken means the MFA pending token from the login response has been tampered with or has expired
+var ErrInvalidMFAToken = apperror.New(http.StatusUnauthorized, apperror.CodeInvalidMFAToken, "The login has expired, please log in again")
+
+// MFAClaims are the claims of an MFA pending token. The subject is the user's ID.
+type MFAClaims struct {
+	EmailAddress string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+/*
+MFAToken signs the short-lived token login returns when the user's password is right but they still have to enter a code.
+It proves the password was checked, so the second step only needs the code, and can't be used as an access token.
+*/
+type MFAToken struct {
+	keys   *KeySet
+	logger logger.ILogger
+}
+
+type IMFAToken interface {
+	GenerateMFAToken(userID, emailAddress string) (string, time.Time, error)
+	ParseMFAToken(tokenString string) (*MFAClaims, error)
+}
+
+func NewMFAToken(keys *KeySet, logger logger.ILogger) *MFAToken {
+	return &MFAToken{
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+// GenerateMFAToken returns an MFA pending token for the user and when it expires
+func (mt *MFAToken) GenerateMFAToken(userID, emailAddress string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(MFATokenTTL)
+
+	tokenID, err := helper.RandomID()
+
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := &MFAClaims{
+		EmailAddress: emailAddress,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	tokenString, err := mt.keys.Sign(claims)
+
+	if err != nil {
+		mt.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("MFA token generation failed: %v", err))
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// ParseMFAToken returns the claims of an MFA pending token, or ErrInvalidMFAToken for any other token
+func (mt *MFAToken) ParseMFAToken(tokenString string) (*MFAClaims, error) {
+	claims := &MFAClaims{}
+
+	token, err := mt.keys.Parse(tokenString, claims)
+
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrInvalidMFAToken)
+	}
+
+	if !slices.Contains(claims.Audience, mfaAudience) || claims.Subject == "" || claims.EmailAddress == "" {
+		return nil, fmt.Errorf("%s - not an MFA token: %w", helper.GetFunctionName(), ErrInvalidMFAToken)
+	}
+
+	return claims, nil
+}
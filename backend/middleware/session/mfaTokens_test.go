@@ -0,0 +1,68 @@
+package session
+
+import (
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMFAToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	keys := NewHMACKeySet("secretJwt")
+	mfaTokens := NewMFAToken(keys, logger)
+	jwtTokens := NewJSONWebToken(keys, nil, logger)
+
+	token, expiresAt, err := mfaTokens.GenerateMFAToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if time.Until(expiresAt) > MFATokenTTL || time.Until(expiresAt) < MFATokenTTL-time.Minute {
+		t.Fatalf("%s - Failed - expiresAt=%s", helper.GetFunctionName(), expiresAt)
+	}
+
+	claims, err := mfaTokens.ParseMFAToken(token)
+
+	if err != nil || claims.Subject != "1" || claims.EmailAddress != "johndoe1@example.com" {
+		t.Fatalf("%s - Failed - claims=%+v, err=%v", helper.GetFunctionName(), claims, err)
+	}
+
+	// Having only passed the password check mustn't grant access
+	if _, err := jwtTokens.ParseJSONWebToken(token); err == nil {
+		t.Fatalf("%s - Failed - an MFA token was accepted as an access token", helper.GetFunctionName())
+	}
+
+	accessToken, _, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "session-1", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	verificationToken, err := NewEmailVerificationToken(keys, logger).GenerateEmailVerificationToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "Test access token", token: accessToken},
+		{name: "Test email verification token", token: verificationToken},
+		{name: "Test token that isn't a JWT", token: "not-a-jwt"},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			_, err := mfaTokens.ParseMFAToken(val.token)
+
+			if !errors.Is(err, ErrInvalidMFAToken) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidMFAToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"dohabits/helper"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// OIDCStateCookieName is the cookie the OIDC state token is kept in while the user signs in with an identity provider
+const OIDCStateCookieName = "oidc_state"
+
+/*
+SetOIDCStateCookie keeps the OIDC state token in the browser until expiresAt. It's Lax rather than Strict like the refresh token
+cookie, as the user comes back from the identity provider's site.
+*/
+func SetOIDCStateCookie(w http.ResponseWriter, stateToken string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    stateToken,
+		Path:     "/",
+		Expires:  expiresAt,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearOIDCStateCookie tells the client to delete its OIDC state cookie, so a sign in can only be completed once
+func ClearOIDCStateCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCStateCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// OIDCStateFromCookie returns the OIDC state token the request carries, or ErrInvalidOIDCState if there isn't one
+func OIDCStateFromCookie(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(OIDCStateCookieName)
+
+	if err != nil || cookie.Value == "" {
+		return "", fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidOIDCState)
+	}
+
+	return cookie.Value, nil
+}
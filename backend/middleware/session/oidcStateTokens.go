@@ -0,0 +1,100 @@
+package session
+
+import (
+	"dohabits/apperror"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCStateTTL is how long the user has to sign in with the identity provider and come back
+const OIDCStateTTL = 10 * time.Minute
+
+// oidcStateAudience sets OIDC state tokens apart from access tokens, which are signed with the same keys
+const oidcStateAudience = "oidc-state"
+
+// ErrInvalidOIDCState means the sign in wasn't started in this browser, has been tampered with or has expired
+var ErrInvalidOIDCState = apperror.New(http.StatusBadRequest, apperror.CodeInvalidOIDCState, "The sign in has expired or was started in another browser, please try again")
+
+/*
+OIDCStateClaims are what the app has to remember between sending the user to an identity provider and them coming back. State
+is also sent to the provider and has to come back with the code, Nonce has to be in the ID token, and CodeVerifier is the PKCE
+secret the code is exchanged with.
+*/
+type OIDCStateClaims struct {
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	jwt.RegisteredClaims
+}
+
+/*
+OIDCStateToken signs the OIDC state kept in a cookie while the user signs in with an identity provider, so it doesn't have to be
+stored anywhere. The cookie is only sent back to this app, so someone who intercepts the code can't exchange it.
+*/
+type OIDCStateToken struct {
+	keys   *KeySet
+	logger logger.ILogger
+}
+
+type IOIDCStateToken interface {
+	GenerateOIDCStateToken(provider, state, nonce, codeVerifier string) (string, time.Time, error)
+	ParseOIDCStateToken(tokenString string) (*OIDCStateClaims, error)
+}
+
+func NewOIDCStateToken(keys *KeySet, logger logger.ILogger) *OIDCStateToken {
+	return &OIDCStateToken{
+		keys:   keys,
+		logger: logger,
+	}
+}
+
+// GenerateOIDCStateToken returns a state token for a sign in with provider and when it expires
+func (ot *OIDCStateToken) GenerateOIDCStateToken(provider, state, nonce, codeVerifier string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(OIDCStateTTL)
+
+	claims := &OIDCStateClaims{
+		Provider:     provider,
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcStateAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	tokenString, err := ot.keys.Sign(claims)
+
+	if err != nil {
+		ot.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("OIDC state token generation failed: %v", err))
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// ParseOIDCStateToken returns the claims of an OIDC state token, or ErrInvalidOIDCState for any other token
+func (ot *OIDCStateToken) ParseOIDCStateToken(tokenString string) (*OIDCStateClaims, error) {
+	claims := &OIDCStateClaims{}
+
+	token, err := ot.keys.Parse(tokenString, claims)
+
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrInvalidOIDCState)
+	}
+
+	if !slices.Contains(claims.Audience, oidcStateAudience) || claims.Provider == "" || claims.State == "" || claims.Nonce == "" || claims.CodeVerifier == "" {
+		return nil, fmt.Errorf("%s - not an OIDC state token: %w", helper.GetFunctionName(), ErrInvalidOIDCState)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,63 @@
+package session
+
+import (
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOIDCStateToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	keys := NewHMACKeySet("secretJwt")
+	oidcStateTokens := NewOIDCStateToken(keys, logger)
+	jwtTokens := NewJSONWebToken(keys, nil, logger)
+
+	token, expiresAt, err := oidcStateTokens.GenerateOIDCStateToken("company", "state", "nonce", "verifier")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if time.Until(expiresAt) > OIDCStateTTL || time.Until(expiresAt) < OIDCStateTTL-time.Minute {
+		t.Fatalf("%s - Failed - expiresAt=%s", helper.GetFunctionName(), expiresAt)
+	}
+
+	claims, err := oidcStateTokens.ParseOIDCStateToken(token)
+
+	if err != nil || claims.Provider != "company" || claims.State != "state" || claims.Nonce != "nonce" || claims.CodeVerifier != "verifier" {
+		t.Fatalf("%s - Failed - claims=%+v, err=%v", helper.GetFunctionName(), claims, err)
+	}
+
+	// Starting a sign in mustn't grant access
+	if _, err := jwtTokens.ParseJSONWebToken(token); err == nil {
+		t.Fatalf("%s - Failed - an OIDC state token was accepted as an access token", helper.GetFunctionName())
+	}
+
+	mfaToken, _, err := NewMFAToken(keys, logger).GenerateMFAToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "Test MFA token", token: mfaToken},
+		{name: "Test token signed with another key", token: func() string {
+			token, _, _ := NewOIDCStateToken(NewHMACKeySet("anotherSecret"), logger).GenerateOIDCStateToken("company", "state", "nonce", "verifier")
+			return token
+		}()},
+		{name: "Test token that isn't a JWT", token: "not-a-jwt"},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			if _, err := oidcStateTokens.ParseOIDCStateToken(val.token); !errors.Is(err, ErrInvalidOIDCState) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidOIDCState)
+			}
+		})
+	}
+}
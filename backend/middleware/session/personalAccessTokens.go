@@ -0,0 +1,127 @@
+package session
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// PersonalAccessTokenPrefix starts every personal access token, so AuthMiddleware can tell them from JWTs and secret scanners can find leaked ones
+const PersonalAccessTokenPrefix = "dhp_"
+
+// lastUsedInterval is how stale a token's LastUsedAt can get, so a busy script doesn't write to the database on every request
+const lastUsedInterval = time.Minute
+
+// ErrInsufficientScope means the personal access token is valid but wasn't granted the scope the endpoint needs
+var ErrInsufficientScope = apperror.New(http.StatusForbidden, apperror.CodeInsufficientScope, "The access token doesn't have the scope this endpoint requires")
+
+/*
+PersonalAccessTokens generates and checks the long-lived tokens users create for scripts and integrations. Only a hash of each
+token is stored, so the token itself is shown once when it's created and can't be recovered afterwards.
+*/
+type PersonalAccessTokens struct {
+	db     db.IDB
+	logger logger.ILogger
+}
+
+type IPersonalAccessTokens interface {
+	GeneratePersonalAccessToken() (string, string, error)
+	ParsePersonalAccessToken(ctx context.Context, token string) (*Claims, error)
+}
+
+func NewPersonalAccessTokens(db db.IDB, logger logger.ILogger) *PersonalAccessTokens {
+	return &PersonalAccessTokens{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// IsPersonalAccessToken reports whether the bearer token is a personal access token rather than a JWT
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// GeneratePersonalAccessToken returns a new token and the hash it's stored as
+func (pt *PersonalAccessTokens) GeneratePersonalAccessToken() (string, string, error) {
+	randomID, err := helper.RandomID()
+
+	if err != nil {
+		pt.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Personal access token generation failed: %v", err))
+		return "", "", err
+	}
+
+	token := PersonalAccessTokenPrefix + randomID
+
+	return token, helper.HashToken(token), nil
+}
+
+/*
+ParsePersonalAccessToken returns the claims of the user a personal access token belongs to, or apperror.ErrUnauthorized if it's
+unknown, revoked or expired, and ErrAccountDisabled if its user has been disabled. The claims have no SessionID, as the token isn't
+tied to a login, and no Roles, so it can't be used for the admin API. They carry the token's scopes so AuthMiddleware can check them
+against the endpoint.
+*/
+func (pt *PersonalAccessTokens) ParsePersonalAccessToken(ctx context.Context, token string) (*Claims, error) {
+	if !IsPersonalAccessToken(token) {
+		return nil, fmt.Errorf("%s - not a personal access token: %w", helper.GetFunctionName(), apperror.ErrUnauthorized)
+	}
+
+	personalAccessToken, err := pt.db.RetrievePersonalAccessTokenByHash(ctx, helper.HashToken(token))
+
+	if errors.Is(err, db.ErrPersonalAccessTokenNotFound) {
+		return nil, fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, apperror.ErrUnauthorized)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	userData, err := pt.db.RetrieveUserDetailsByID(ctx, personalAccessToken.UserID)
+
+	if errors.Is(err, db.ErrUserNotFound) {
+		return nil, fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, apperror.ErrUnauthorized)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if userData.Disabled {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrAccountDisabled)
+	}
+
+	now := time.Now()
+
+	// The request goes ahead even if LastUsedAt can't be updated, it's only shown to the user
+	if personalAccessToken.LastUsedAt == nil || now.Sub(*personalAccessToken.LastUsedAt) >= lastUsedInterval {
+		if err := pt.db.UpdatePersonalAccessTokenLastUsed(ctx, personalAccessToken.TokenID, now); err != nil {
+			pt.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to update last used for tokenId=%s: %v", personalAccessToken.TokenID, err))
+		}
+	}
+
+	return &Claims{
+		Username:              userData.EmailAddress,
+		PersonalAccessTokenID: personalAccessToken.TokenID,
+		Scopes:                personalAccessToken.Scopes,
+		RegisteredClaims:      jwt.RegisteredClaims{Subject: userData.UserID},
+	}, nil
+}
+
+// HasScope reports whether the claims allow scope. Claims from a JWT allow everything, only personal access tokens are limited to their scopes.
+func (c *Claims) HasScope(scope string) bool {
+	if c.PersonalAccessTokenID == "" {
+		return true
+	}
+
+	return scope != "" && slices.Contains(c.Scopes, scope)
+}
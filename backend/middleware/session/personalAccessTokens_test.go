@@ -0,0 +1,140 @@
+package session
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestPersonalAccessToken stores a token for johndoe1@example.com with scopes and returns it
+func newTestPersonalAccessToken(t *testing.T, mockDB db.IDB, personalAccessTokens *PersonalAccessTokens, tokenID string, scopes ...string) string {
+	t.Helper()
+
+	token, tokenHash, err := personalAccessTokens.GeneratePersonalAccessToken()
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	err = mockDB.CreatePersonalAccessToken(context.Background(), &data.PersonalAccessToken{
+		TokenID:   tokenID,
+		UserID:    "1",
+		Name:      tokenID,
+		TokenHash: tokenHash,
+		Scopes:    scopes,
+	})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	return token
+}
+
+func TestParsePersonalAccessToken(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	personalAccessTokens := NewPersonalAccessTokens(mockDB, logger)
+	ctx := context.Background()
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	token := newTestPersonalAccessToken(t, mockDB, personalAccessTokens, "script", data.ScopeHabitsRead)
+	revokedToken := newTestPersonalAccessToken(t, mockDB, personalAccessTokens, "revoked", data.ScopeHabitsRead)
+
+	if err := mockDB.DeletePersonalAccessToken(ctx, "1", "revoked"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	claims, err := personalAccessTokens.ParsePersonalAccessToken(ctx, token)
+
+	if err != nil || claims.Username != "johndoe1@example.com" || claims.PersonalAccessTokenID != "script" || claims.SessionID != "" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), claims, err)
+	}
+
+	if !claims.HasScope(data.ScopeHabitsRead) || claims.HasScope(data.ScopeHabitsWrite) || claims.HasScope("") {
+		t.Fatalf("%s - Failed - got scopes=%v", helper.GetFunctionName(), claims.Scopes)
+	}
+
+	// Using the token records when it was last used
+	personalAccessToken, err := mockDB.RetrievePersonalAccessTokenByHash(ctx, helper.HashToken(token))
+
+	if err != nil || personalAccessToken.LastUsedAt == nil || time.Since(*personalAccessToken.LastUsedAt) > time.Minute {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), personalAccessToken, err)
+	}
+
+	for _, invalidToken := range []string{revokedToken, PersonalAccessTokenPrefix + "unknown", "not-a-personal-access-token"} {
+		if _, err := personalAccessTokens.ParsePersonalAccessToken(ctx, invalidToken); !errors.Is(err, apperror.ErrUnauthorized) {
+			t.Errorf("%s - Failed - token=%s, got err=%v, want=%v", helper.GetFunctionName(), invalidToken, err, apperror.ErrUnauthorized)
+		}
+	}
+}
+
+func TestAuthMiddlewarePersonalAccessTokens(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	personalAccessTokens := NewPersonalAccessTokens(mockDB, logger)
+	jwtTokens := NewJSONWebToken(NewHMACKeySet("secretJwt"), mockDB, logger)
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	readToken := newTestPersonalAccessToken(t, mockDB, personalAccessTokens, "read", data.ScopeHabitsRead)
+	writeToken := newTestPersonalAccessToken(t, mockDB, personalAccessTokens, "write", data.ScopeHabitsWrite)
+
+	accessToken, _, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "mock-session-1", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name       string
+		scope      string
+		token      string
+		wantStatus int
+	}{
+		{name: "Test token with the endpoint's scope", scope: data.ScopeHabitsRead, token: readToken, wantStatus: http.StatusOK},
+		{name: "Test habits:write doesn't imply habits:read", scope: data.ScopeHabitsRead, token: writeToken, wantStatus: http.StatusForbidden},
+		{name: "Test token without the endpoint's scope", scope: data.ScopeHabitsWrite, token: readToken, wantStatus: http.StatusForbidden},
+		{name: "Test endpoint without a scope needs a login", token: readToken, wantStatus: http.StatusForbidden},
+		{name: "Test unknown token", scope: data.ScopeHabitsRead, token: PersonalAccessTokenPrefix + "unknown", wantStatus: http.StatusUnauthorized},
+		{name: "Test JWT on an endpoint without a scope", token: accessToken, wantStatus: http.StatusOK},
+		{name: "Test JWT isn't limited by scope", scope: data.ScopeHabitsWrite, token: accessToken, wantStatus: http.StatusOK},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			handler := AuthMiddleware(jwtTokens, personalAccessTokens, val.scope, logger)(func(w http.ResponseWriter, r *http.Request) {
+				if claims, ok := r.Context().Value(ClaimsKey).(*Claims); !ok || claims.Username != "johndoe1@example.com" {
+					t.Errorf("%s - Failed - got claims=%+v", helper.GetFunctionName(), claims)
+				}
+
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/dohabitsapp/v1/habits", nil)
+			req.Header.Set("Authorization", "Bearer "+val.token)
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+		})
+	}
+}
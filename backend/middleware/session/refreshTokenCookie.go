@@ -0,0 +1,52 @@
+package session
+
+import (
+	"dohabits/apperror"
+	"dohabits/db"
+	"dohabits/helper"
+	"fmt"
+	"net/http"
+)
+
+// RefreshTokenCookieName is the cookie the refresh token is delivered in. It's HttpOnly so scripts running in the page can't read it.
+const RefreshTokenCookieName = "refresh_token"
+
+// ErrRefreshTokenRequired means the request doesn't carry the refresh token cookie set at login
+var ErrRefreshTokenRequired = apperror.New(http.StatusUnauthorized, apperror.CodeUnauthorized, "A refresh token cookie is required, please log in again")
+
+// SetRefreshTokenCookie delivers the refresh token to the client. It lives as long as the session it belongs to.
+func SetRefreshTokenCookie(w http.ResponseWriter, refreshToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshTokenCookieName,
+		Value:    refreshToken,
+		Path:     "/",
+		MaxAge:   int(db.SessionTTL.Seconds()),
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearRefreshTokenCookie tells the client to delete its refresh token cookie
+func ClearRefreshTokenCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshTokenCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// RefreshTokenFromCookie returns the refresh token the request carries, or ErrRefreshTokenRequired if there isn't one
+func RefreshTokenFromCookie(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(RefreshTokenCookieName)
+
+	if err != nil || cookie.Value == "" {
+		return "", fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrRefreshTokenRequired)
+	}
+
+	return cookie.Value, nil
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"net/http"
+)
+
+// ErrInsufficientRole means the user is logged in but doesn't have the role the endpoint needs
+var ErrInsufficientRole = apperror.New(http.StatusForbidden, apperror.CodeInsufficientRole, "You don't have the role this endpoint requires")
+
+/*
+RoleMiddleware only lets through users whose access token carries role, see data.HasRole. It runs after AuthMiddleware, which puts
+the claims in the request context. Roles are read from the access token, so a role that's taken away lasts until the token is
+renewed, at most 5 minutes. Refresh tokens carry roles too, but they're never accepted as access tokens, and exchanging one issues
+tokens with the user's current roles.
+*/
+func RoleMiddleware(role string, logger logger.ILogger) func(http.HandlerFunc) http.HandlerFunc {
+	functionName := helper.GetFunctionName()
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			logger.InfoLog(functionName, "")
+
+			claims, ok := r.Context().Value(ClaimsKey).(*Claims)
+
+			if !ok {
+				logger.ErrorLog(functionName, "No claims in the request context")
+				apperror.WriteProblem(w, r, apperror.ErrUnauthorized)
+				return
+			}
+
+			if !data.HasRole(claims.Roles, role) {
+				logger.SecurityLog(functionName, fmt.Sprintf("%s doesn't have role %q for %s %s", claims.Username, role, r.Method, r.URL.Path))
+				apperror.WriteProblem(w, r, ErrInsufficientRole)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+	}
+}
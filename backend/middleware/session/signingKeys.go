@@ -0,0 +1,328 @@
+package session
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"dohabits/data"
+	"dohabits/helper"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signing or verifying tokens
+const minRSAKeyBits = 2048
+
+var errNoSigningKey = errors.New("either JWT_SIGNING_KEY_FILE or JWT_SECRET must be set")
+
+type signingKey struct {
+	id        string // The kid header of the tokens it signs. Empty for JWT_SECRET, as tokens signed with it have never had one.
+	method    jwt.SigningMethod
+	signKey   interface{} // nil for keys that only verify tokens
+	verifyKey interface{}
+	publicKey *data.JSONWebKey // nil for JWT_SECRET, which can't be published
+}
+
+/*
+KeySet holds the key new tokens are signed with and every key tokens are accepted from, looked up by the token's kid header.
+A signing key is retired by moving it to the verification keys, so the tokens it has already signed stay valid until they
+expire. Each key is bound to its own algorithm, so a token can't choose how it's verified.
+*/
+type KeySet struct {
+	signing      *signingKey
+	verification map[string]*signingKey
+	methods      []string
+}
+
+// NewHMACKeySet returns a KeySet that signs and verifies tokens with an HS256 shared secret
+func NewHMACKeySet(secret string) *KeySet {
+	keySet := &KeySet{verification: map[string]*signingKey{}}
+	keySet.signing = newHMACKey(secret)
+	keySet.addVerificationKey(keySet.signing)
+
+	return keySet
+}
+
+/*
+LoadKeySet builds the KeySet from the environment. signingKeyFile is a PEM encoded RSA or Ed25519 private key that new tokens
+are signed with, and verificationKeyFiles is a comma-separated list of PEM encoded keys that tokens are still accepted from.
+Without a signing key file tokens are signed with the HS256 secret as before. With one, the secret is only used to verify
+tokens signed before the switch, and can be removed once they have expired.
+*/
+func LoadKeySet(secret, signingKeyFile, verificationKeyFiles string) (*KeySet, error) {
+	keySet := &KeySet{verification: map[string]*signingKey{}}
+
+	if secret != "" {
+		keySet.signing = newHMACKey(secret)
+		keySet.addVerificationKey(keySet.signing)
+	}
+
+	if signingKeyFile != "" {
+		key, err := loadSigningKey(signingKeyFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keySet.signing = key
+		keySet.addVerificationKey(key)
+	}
+
+	if keySet.signing == nil {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), errNoSigningKey)
+	}
+
+	for _, path := range strings.Split(verificationKeyFiles, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+
+		key, err := loadVerificationKey(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keySet.addVerificationKey(key)
+	}
+
+	return keySet, nil
+}
+
+// Sign signs claims with the current signing key, naming it in the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+
+	if ks.signing.id != "" {
+		token.Header["kid"] = ks.signing.id
+	}
+
+	return token.SignedString(ks.signing.signKey)
+}
+
+// Parse verifies tokenString with the key named by its kid header and decodes its claims
+func (ks *KeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, ks.keyFunc, jwt.WithValidMethods(ks.methods))
+}
+
+// JSONWebKeySet returns the public keys tokens are verified with, the signing key first
+func (ks *KeySet) JSONWebKeySet() data.JSONWebKeySet {
+	jwks := data.JSONWebKeySet{Keys: []data.JSONWebKey{}}
+
+	if ks.signing.publicKey != nil {
+		jwks.Keys = append(jwks.Keys, *ks.signing.publicKey)
+	}
+
+	keyIDs := make([]string, 0, len(ks.verification))
+
+	for keyID := range ks.verification {
+		keyIDs = append(keyIDs, keyID)
+	}
+
+	sort.Strings(keyIDs)
+
+	for _, keyID := range keyIDs {
+		if key := ks.verification[keyID]; key != ks.signing && key.publicKey != nil {
+			jwks.Keys = append(jwks.Keys, *key.publicKey)
+		}
+	}
+
+	return jwks
+}
+
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	keyID, ok := token.Header["kid"].(string)
+
+	if !ok && token.Header["kid"] != nil {
+		return nil, fmt.Errorf("%s - kid header isn't a string", helper.GetFunctionName())
+	}
+
+	key, ok := ks.verification[keyID]
+
+	if !ok {
+		return nil, fmt.Errorf("%s - unknown kid %q", helper.GetFunctionName(), keyID)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("%s - kid %q is for %s, not %s", helper.GetFunctionName(), keyID, key.method.Alg(), token.Method.Alg())
+	}
+
+	return key.verifyKey, nil
+}
+
+// addVerificationKey accepts tokens signed by key. Listing the signing key again as a verification key changes nothing.
+func (ks *KeySet) addVerificationKey(key *signingKey) {
+	if _, ok := ks.verification[key.id]; ok {
+		return
+	}
+
+	ks.verification[key.id] = key
+
+	for _, method := range ks.methods {
+		if method == key.method.Alg() {
+			return
+		}
+	}
+
+	ks.methods = append(ks.methods, key.method.Alg())
+}
+
+func newHMACKey(secret string) *signingKey {
+	return &signingKey{
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// loadSigningKey reads a PEM encoded RSA or Ed25519 private key
+func loadSigningKey(path string) (*signingKey, error) {
+	block, err := readPEMFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parsePrivateKey(block)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s - %s: %w", helper.GetFunctionName(), path, err)
+	}
+
+	key, err := newAsymmetricKey(privateKey.Public())
+
+	if err != nil {
+		return nil, fmt.Errorf("%s - %s: %w", helper.GetFunctionName(), path, err)
+	}
+
+	key.signKey = privateKey
+
+	return key, nil
+}
+
+// loadVerificationKey reads a PEM encoded RSA or Ed25519 public key. A private key is accepted too, so a retired signing key file can be listed as it is.
+func loadVerificationKey(path string) (*signingKey, error) {
+	block, err := readPEMFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var publicKey crypto.PublicKey
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		var privateKey crypto.Signer
+
+		if privateKey, err = parsePrivateKey(block); err == nil {
+			publicKey = privateKey.Public()
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s - %s: %w", helper.GetFunctionName(), path, err)
+	}
+
+	key, err := newAsymmetricKey(publicKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s - %s: %w", helper.GetFunctionName(), path, err)
+	}
+
+	return key, nil
+}
+
+func readPEMFile(path string) (*pem.Block, error) {
+	contents, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), err)
+	}
+
+	block, _ := pem.Decode(contents)
+
+	if block == nil {
+		return nil, fmt.Errorf("%s - %s isn't PEM encoded", helper.GetFunctionName(), path)
+	}
+
+	return block, nil
+}
+
+func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch privateKey := privateKey.(type) {
+		case *rsa.PrivateKey:
+			return privateKey, nil
+		case ed25519.PrivateKey:
+			return privateKey, nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+		}
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+// newAsymmetricKey returns the key that verifies with publicKey. Its kid is the RFC 7638 thumbprint of the public key, so it never changes for the same key.
+func newAsymmetricKey(publicKey crypto.PublicKey) (*signingKey, error) {
+	key := &signingKey{verifyKey: publicKey}
+
+	var thumbprintInput string
+
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits, got %d", minRSAKeyBits, publicKey.N.BitLen())
+		}
+
+		key.method = jwt.SigningMethodRS256
+		key.publicKey = &data.JSONWebKey{
+			KeyType:  "RSA",
+			Modulus:  base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes()),
+			Exponent: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes()),
+		}
+		thumbprintInput = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, key.publicKey.Exponent, key.publicKey.Modulus)
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+		key.publicKey = &data.JSONWebKey{
+			KeyType: "OKP",
+			Curve:   "Ed25519",
+			X:       base64.RawURLEncoding.EncodeToString(publicKey),
+		}
+		thumbprintInput = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, key.publicKey.X)
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	thumbprint := sha256.Sum256([]byte(thumbprintInput))
+	key.id = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+
+	key.publicKey.Use = "sig"
+	key.publicKey.Algorithm = key.method.Alg()
+	key.publicKey.KeyID = key.id
+
+	return key, nil
+}
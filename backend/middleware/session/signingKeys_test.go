@@ -0,0 +1,245 @@
+package session
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"dohabits/helper"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writePEMFile writes key to a PEM file in dir and returns its path
+func writePEMFile(t *testing.T, dir, name, blockType string, key []byte) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: key}), 0o600); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	return path
+}
+
+func newTestClaims() *Claims {
+	return &Claims{
+		Username:  "johndoe1@example.com",
+		SessionID: "session-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	rsaKeyBytes, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	edKeyBytes, err := x509.MarshalPKCS8PrivateKey(edKey)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	edPublicKeyBytes, err := x509.MarshalPKIXPublicKey(edKey.Public())
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	smallRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	rsaKeyFile := writePEMFile(t, dir, "rsa.pem", "PRIVATE KEY", rsaKeyBytes)
+	edKeyFile := writePEMFile(t, dir, "ed25519.pem", "PRIVATE KEY", edKeyBytes)
+	edPublicKeyFile := writePEMFile(t, dir, "ed25519.pub.pem", "PUBLIC KEY", edPublicKeyBytes)
+	smallRSAKeyFile := writePEMFile(t, dir, "small.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(smallRSAKey))
+
+	testCases := []struct {
+		name                 string
+		secret               string
+		signingKeyFile       string
+		verificationKeyFiles string
+		wantAlg              string
+		wantKeys             int
+		wantErr              bool
+	}{
+		{name: "Test the secret signs when there's no key file", secret: "secret", wantAlg: "HS256", wantKeys: 0},
+		{name: "Test RS256 signing key", signingKeyFile: rsaKeyFile, wantAlg: "RS256", wantKeys: 1},
+		{name: "Test EdDSA signing key with the secret kept for verification", secret: "secret", signingKeyFile: edKeyFile, wantAlg: "EdDSA", wantKeys: 1},
+		{name: "Test retired keys are published for verification", signingKeyFile: edKeyFile, verificationKeyFiles: rsaKeyFile + ", " + edPublicKeyFile, wantAlg: "EdDSA", wantKeys: 2},
+		{name: "Test no signing key", wantErr: true},
+		{name: "Test missing key file", signingKeyFile: filepath.Join(dir, "missing.pem"), wantErr: true},
+		{name: "Test RSA key that's too small", signingKeyFile: smallRSAKeyFile, wantErr: true},
+		{name: "Test public key can't sign", signingKeyFile: edPublicKeyFile, wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			keySet, err := LoadKeySet(val.secret, val.signingKeyFile, val.verificationKeyFiles)
+
+			if val.wantErr {
+				if err == nil {
+					t.Errorf("%s - Failed - expected an error", helper.GetFunctionName())
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			tokenString, err := keySet.Sign(newTestClaims())
+
+			if err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			claims := &Claims{}
+			token, err := keySet.Parse(tokenString, claims)
+
+			if err != nil || token.Method.Alg() != val.wantAlg || claims.SessionID != "session-1" {
+				t.Errorf("%s - Failed - alg=%v, claims=%+v, err=%v", helper.GetFunctionName(), token.Method, claims, err)
+				return
+			}
+
+			jwks := keySet.JSONWebKeySet()
+
+			if len(jwks.Keys) != val.wantKeys {
+				t.Errorf("%s - Failed - got %d keys, want=%d", helper.GetFunctionName(), len(jwks.Keys), val.wantKeys)
+				return
+			}
+
+			// The signing key is published first, under the kid its tokens carry
+			if val.wantKeys > 0 && (jwks.Keys[0].KeyID != token.Header["kid"] || jwks.Keys[0].Algorithm != val.wantAlg) {
+				t.Errorf("%s - Failed - got=%+v, kid=%v", helper.GetFunctionName(), jwks.Keys[0], token.Header["kid"])
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	_, newKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	newKeyBytes, err := x509.MarshalPKCS8PrivateKey(newKey)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	oldKeyFile := writePEMFile(t, dir, "old.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(oldKey))
+	newKeyFile := writePEMFile(t, dir, "new.pem", "PRIVATE KEY", newKeyBytes)
+
+	hmacKeySet := NewHMACKeySet("secret")
+	oldKeySet, err := LoadKeySet("", oldKeyFile, "")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// The old RSA key and the secret are retired but their tokens are still accepted
+	rotatedKeySet, err := LoadKeySet("secret", newKeyFile, oldKeyFile)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	hmacToken, err := hmacKeySet.Sign(newTestClaims())
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	oldToken, err := oldKeySet.Sign(newTestClaims())
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// An attacker signing with the RSA public key as an HMAC secret, hoping it's verified as one
+	confusedToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newTestClaims())
+	confusedToken.Header["kid"] = oldKeySet.signing.id
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&oldKey.PublicKey)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	confusedTokenString, err := confusedToken.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	unknownKidToken := jwt.NewWithClaims(jwt.SigningMethodHS256, newTestClaims())
+	unknownKidToken.Header["kid"] = "unknown"
+	unknownKidTokenString, err := unknownKidToken.SignedString([]byte("secret"))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name        string
+		keySet      *KeySet
+		tokenString string
+		wantErr     bool
+	}{
+		{name: "Test token signed with the secret before the switch", keySet: rotatedKeySet, tokenString: hmacToken},
+		{name: "Test token signed with the retired key", keySet: rotatedKeySet, tokenString: oldToken},
+		{name: "Test token signed with the public key as an HMAC secret", keySet: rotatedKeySet, tokenString: confusedTokenString, wantErr: true},
+		{name: "Test token with an unknown kid", keySet: rotatedKeySet, tokenString: unknownKidTokenString, wantErr: true},
+		{name: "Test token signed with a key that's been dropped", keySet: hmacKeySet, tokenString: oldToken, wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			_, err := val.keySet.Parse(val.tokenString, &Claims{})
+
+			if (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+}
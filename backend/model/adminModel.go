@@ -0,0 +1,213 @@
+package model
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+// How many users a search returns when it doesn't ask for a number, and the most it can ask for
+const (
+	DefaultUserSearchLimit = 50
+	MaxUserSearchLimit     = 100
+)
+
+// ErrDisableSelf stops an admin locking themselves out. Another admin has to disable them.
+var ErrDisableSelf = apperror.New(http.StatusConflict, apperror.CodeCannotDisableSelf, "You can't disable your own account")
+
+// ErrUserOutranks stops support staff acting on a user with a role they don't have, such as an admin
+var ErrUserOutranks = apperror.New(http.StatusForbidden, apperror.CodeInsufficientRole, "You can't act on a user with a role you don't have")
+
+/*
+AdminModel is the admin API, which lets support staff help users without going to the database. Every change is written to the
+security log with the ID of the admin who made it.
+*/
+type AdminModel struct {
+	logger logger.ILogger
+	db     db.IDB
+}
+
+type IAdminModel interface {
+	SearchUsersHandler(ctx context.Context, adminUsersRequest *data.AdminUsersRequest) ([]data.UserData, error)
+	RetrieveUserHandler(ctx context.Context, userID string) (*data.UserData, error)
+	RetrieveUserHabitsHandler(ctx context.Context, userID string) (*data.UserData, []data.Habit, error)
+	LogoutUserHandler(ctx context.Context, adminID string, adminRoles []string, userID string) (int64, int64, error)
+	DisableUserHandler(ctx context.Context, adminID, userID string) (*data.UserData, error)
+	EnableUserHandler(ctx context.Context, adminID, userID string) (*data.UserData, error)
+}
+
+func NewAdminModel(logger logger.ILogger, db db.IDB) *AdminModel {
+	return &AdminModel{
+		logger: logger,
+		db:     db,
+	}
+}
+
+// SearchUsersHandler returns a page of the users whose email address or name contains the query, oldest first
+func (m *AdminModel) SearchUsersHandler(ctx context.Context, adminUsersRequest *data.AdminUsersRequest) ([]data.UserData, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	fields := []apperror.FieldError{}
+
+	if adminUsersRequest.Limit == 0 {
+		adminUsersRequest.Limit = DefaultUserSearchLimit
+	}
+
+	if adminUsersRequest.Limit < 1 || adminUsersRequest.Limit > MaxUserSearchLimit {
+		fields = append(fields, apperror.FieldError{Field: "limit", Message: fmt.Sprintf("limit must be from 1 to %d", MaxUserSearchLimit)})
+	}
+
+	if adminUsersRequest.Offset < 0 {
+		fields = append(fields, apperror.FieldError{Field: "offset", Message: "offset can't be negative"})
+	}
+
+	if len(fields) > 0 {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), apperror.ErrInvalidRequest.WithFields(fields...))
+	}
+
+	return m.db.SearchUsers(ctx, adminUsersRequest.Query, adminUsersRequest.Limit, adminUsersRequest.Offset)
+}
+
+func (m *AdminModel) RetrieveUserHandler(ctx context.Context, userID string) (*data.UserData, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	return m.db.RetrieveUserDetailsByID(ctx, userID)
+}
+
+// RetrieveUserHabitsHandler returns the user and their habits, with progress as of today in the user's timezone
+func (m *AdminModel) RetrieveUserHabitsHandler(ctx context.Context, userID string) (*data.UserData, []data.Habit, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	habits, err := m.db.RetrieveAllHabitsHandler(ctx, userData.UserID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	today := userNow(userData)
+
+	for i, habit := range habits {
+		habits[i] = withProgress(habit, today)
+	}
+
+	return userData, habits, nil
+}
+
+/*
+LogoutUserHandler revokes every one of the user's sessions and personal access tokens, and returns how many of each it revoked.
+adminRoles are the roles of the admin asking, who can't log out a user with a role they don't have, so support can't log out an admin.
+*/
+func (m *AdminModel) LogoutUserHandler(ctx context.Context, adminID string, adminRoles []string, userID string) (int64, int64, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if slices.ContainsFunc(userData.Roles, func(role string) bool { return !data.HasRole(adminRoles, role) }) {
+		return 0, 0, fmt.Errorf("%s - adminId=%s, userId=%s: %w", helper.GetFunctionName(), adminID, userData.UserID, ErrUserOutranks)
+	}
+
+	revoked, err := m.db.DeleteUserSessions(ctx, userData.UserID, "")
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	personalAccessTokens, err := m.db.RetrievePersonalAccessTokens(ctx, userData.UserID)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var revokedTokens int64
+
+	for _, personalAccessToken := range personalAccessTokens {
+		err := m.db.DeletePersonalAccessToken(ctx, userData.UserID, personalAccessToken.TokenID)
+
+		// The user revoked it themselves in the meantime
+		if errors.Is(err, db.ErrPersonalAccessTokenNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		revokedTokens++
+	}
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("adminId=%s logged userId=%s out of %d sessions and revoked %d personal access tokens", adminID, userData.UserID, revoked, revokedTokens))
+
+	return revoked, revokedTokens, nil
+}
+
+/*
+DisableUserHandler stops the user logging in and logs them out of every session. Their personal access tokens stop working, and
+access tokens they already have stop working when they're next renewed, within 5 minutes.
+*/
+func (m *AdminModel) DisableUserHandler(ctx context.Context, adminID, userID string) (*data.UserData, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if userData.UserID == adminID {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrDisableSelf)
+	}
+
+	if err := m.db.UpdateUserDisabled(ctx, userData.UserID, true); err != nil {
+		return nil, err
+	}
+
+	userData.Disabled = true
+
+	revoked, err := m.db.DeleteUserSessions(ctx, userData.UserID, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("adminId=%s disabled userId=%s and logged them out of %d sessions", adminID, userData.UserID, revoked))
+
+	return userData, nil
+}
+
+// EnableUserHandler lets a disabled user log in again. Their personal access tokens work again too.
+func (m *AdminModel) EnableUserHandler(ctx context.Context, adminID, userID string) (*data.UserData, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.db.UpdateUserDisabled(ctx, userData.UserID, false); err != nil {
+		return nil, err
+	}
+
+	userData.Disabled = false
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("adminId=%s enabled userId=%s", adminID, userData.UserID))
+
+	return userData, nil
+}
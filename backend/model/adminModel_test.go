@@ -0,0 +1,195 @@
+package model
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"testing"
+)
+
+func TestSearchUsersHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	adminModel := NewAdminModel(logger, db.NewMockDB(logger))
+
+	testCases := []struct {
+		name        string
+		request     data.AdminUsersRequest
+		wantUserIDs []string
+		wantLimit   int
+		wantErr     error
+	}{
+		{name: "Test every user", request: data.AdminUsersRequest{}, wantUserIDs: []string{"1", "2", "3", "4"}, wantLimit: DefaultUserSearchLimit},
+		{name: "Test search by email address", request: data.AdminUsersRequest{Query: "JANESMITH"}, wantUserIDs: []string{"2"}, wantLimit: DefaultUserSearchLimit},
+		{name: "Test a page of users", request: data.AdminUsersRequest{Limit: 2, Offset: 1}, wantUserIDs: []string{"2", "3"}, wantLimit: 2},
+		{name: "Test limit too high", request: data.AdminUsersRequest{Limit: MaxUserSearchLimit + 1}, wantErr: apperror.ErrInvalidRequest},
+		{name: "Test negative limit", request: data.AdminUsersRequest{Limit: -1}, wantErr: apperror.ErrInvalidRequest},
+		{name: "Test negative offset", request: data.AdminUsersRequest{Offset: -1}, wantErr: apperror.ErrInvalidRequest},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			users, err := adminModel.SearchUsersHandler(context.Background(), &val.request)
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+				return
+			}
+
+			if val.wantErr != nil {
+				return
+			}
+
+			if val.request.Limit != val.wantLimit {
+				t.Errorf("%s - Failed - got limit=%d, want=%d", helper.GetFunctionName(), val.request.Limit, val.wantLimit)
+			}
+
+			userIDs := []string{}
+
+			for _, user := range users {
+				userIDs = append(userIDs, user.UserID)
+			}
+
+			if len(userIDs) != len(val.wantUserIDs) {
+				t.Errorf("%s - Failed - got userIds=%v, want=%v", helper.GetFunctionName(), userIDs, val.wantUserIDs)
+				return
+			}
+
+			for i := range userIDs {
+				if userIDs[i] != val.wantUserIDs[i] {
+					t.Errorf("%s - Failed - got userIds=%v, want=%v", helper.GetFunctionName(), userIDs, val.wantUserIDs)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestRetrieveUserHabitsHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	adminModel := NewAdminModel(logger, db.NewMockDB(logger))
+
+	user, habits, err := adminModel.RetrieveUserHabitsHandler(context.Background(), "1")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if user.UserID != "1" || len(habits) == 0 {
+		t.Fatalf("%s - Failed - user=%+v, habits=%+v", helper.GetFunctionName(), user, habits)
+	}
+
+	for _, habit := range habits {
+		if habit.UserID != "1" {
+			t.Errorf("%s - Failed - got another user's habit=%+v", helper.GetFunctionName(), habit)
+		}
+	}
+
+	if _, _, err := adminModel.RetrieveUserHabitsHandler(context.Background(), "999"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, db.ErrUserNotFound)
+	}
+}
+
+func TestDisableUserHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	adminModel := NewAdminModel(logger, mockDB)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	if _, err := adminModel.DisableUserHandler(ctx, "1", "1"); !errors.Is(err, ErrDisableSelf) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrDisableSelf)
+	}
+
+	user, err := adminModel.DisableUserHandler(ctx, "1", "4")
+
+	if err != nil || !user.Disabled {
+		t.Fatalf("%s - Failed - user=%+v, err=%v", helper.GetFunctionName(), user, err)
+	}
+
+	if stored, _ := mockDB.RetrieveUserDetailsByID(ctx, "4"); !stored.Disabled {
+		t.Errorf("%s - Failed - the user wasn't disabled in the database", helper.GetFunctionName())
+	}
+
+	if sessions, _ := mockDB.RetrieveUserSessions(ctx, "4"); len(sessions) != 0 {
+		t.Errorf("%s - Failed - the user still has sessions=%+v", helper.GetFunctionName(), sessions)
+	}
+
+	if user, err := adminModel.EnableUserHandler(ctx, "1", "4"); err != nil || user.Disabled {
+		t.Errorf("%s - Failed - user=%+v, err=%v", helper.GetFunctionName(), user, err)
+	}
+
+	if stored, _ := mockDB.RetrieveUserDetailsByID(ctx, "4"); stored.Disabled {
+		t.Errorf("%s - Failed - the user wasn't enabled in the database", helper.GetFunctionName())
+	}
+}
+
+func TestLogoutUserHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	adminModel := NewAdminModel(logger, mockDB)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+		data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState
+	}()
+
+	// Roles are only ever granted in the database
+	data.MockUsers[3].Roles = []string{data.RoleAdmin}
+
+	if err := mockDB.CreatePersonalAccessToken(ctx, &data.PersonalAccessToken{TokenID: "token-4", UserID: "4", Name: "Script", TokenHash: "hash-4", Scopes: []string{}}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name              string
+		adminRoles        []string
+		userID            string
+		wantRevoked       int64
+		wantRevokedTokens int64
+		wantErr           error
+	}{
+		{name: "Test support can't log out an admin", adminRoles: []string{data.RoleSupport}, userID: "4", wantErr: ErrUserOutranks},
+		{name: "Test support logs out a user", adminRoles: []string{data.RoleSupport}, userID: "2"},
+		{name: "Test an admin logs out an admin", adminRoles: []string{data.RoleAdmin}, userID: "4", wantRevoked: 2, wantRevokedTokens: 1},
+		{name: "Test unknown user", adminRoles: []string{data.RoleAdmin}, userID: "999", wantErr: db.ErrUserNotFound},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			revoked, revokedTokens, err := adminModel.LogoutUserHandler(ctx, "1", val.adminRoles, val.userID)
+
+			if !errors.Is(err, val.wantErr) || revoked != val.wantRevoked || revokedTokens != val.wantRevokedTokens {
+				t.Errorf("%s - Failed - revoked=%d, revokedTokens=%d, err=%v, want revoked=%d, revokedTokens=%d, err=%v", helper.GetFunctionName(), revoked, revokedTokens, err, val.wantRevoked, val.wantRevokedTokens, val.wantErr)
+			}
+		})
+	}
+
+	if personalAccessTokens, _ := mockDB.RetrievePersonalAccessTokens(ctx, "4"); len(personalAccessTokens) != 0 {
+		t.Errorf("%s - Failed - the user still has personal access tokens=%+v", helper.GetFunctionName(), personalAccessTokens)
+	}
+}
@@ -1,55 +1,108 @@
 package model
 
 import (
+	"context"
+	"crypto/subtle"
+	"dohabits/apperror"
 	"dohabits/data"
 	"dohabits/db"
 	"dohabits/helper"
 	"dohabits/logger"
+	"dohabits/loginattempts"
 	"dohabits/middleware/session"
 	"dohabits/validation"
+	"errors"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 )
 
+// ErrSessionNotFound is returned when revoking a session the user doesn't have. db.ErrSessionNotFound is a 401 as it ends the
+// session it's returned to, whereas revoking a missing session leaves the caller logged in.
+var ErrSessionNotFound = apperror.New(http.StatusNotFound, apperror.CodeSessionNotFound, "Session doesn't exist")
+
+// maxUserAgentLength caps the User-Agent stored with a session, as it's whatever the client chose to send
+const maxUserAgentLength = 512
+
 type AuthModel struct {
-	logger logger.ILogger
-	db     db.IDB
+	logger               logger.ILogger
+	db                   db.IDB
+	emailVerification    IEmailVerificationModel
+	mfa                  IMFAModel
+	oidc                 IOIDCModel
+	loginAttempts        loginattempts.ILimiter
+	passwordPolicy       *validation.PasswordPolicy
+	unverifiedUserAccess data.UnverifiedUserAccess
 }
 
 type IAuthModel interface {
-	RegisterUserHandler(userRegisterRequest *data.RegisterUserRequest) (*data.RegisterUserData, error)
-	LoginHandler(w http.ResponseWriter, userAuth *data.UserAuth, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error)
-	LogoutHandler(w http.ResponseWriter, UserLoggedOutRequest *data.UserLoggedOutRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) error
-	RefreshHandler(w http.ResponseWriter, userRefreshRequest *data.UserRefreshRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (string, string, error)
+	RegisterUserHandler(ctx context.Context, userRegisterRequest *data.RegisterUserRequest) (*data.RegisterUserData, error)
+	LoginHandler(ctx context.Context, w http.ResponseWriter, userAuth *data.UserAuth, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error)
+	LoginMFAHandler(ctx context.Context, w http.ResponseWriter, mfaLoginRequest *data.MFALoginRequest, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error)
+	StartOIDCLoginHandler(ctx context.Context, providerName string) (*data.OIDCLoginStart, error)
+	LoginOIDCHandler(ctx context.Context, w http.ResponseWriter, oidcLoginRequest *data.OIDCLoginRequest, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error)
+	LogoutHandler(ctx context.Context, w http.ResponseWriter, UserLoggedOutRequest *data.UserLoggedOutRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) error
+	RefreshHandler(ctx context.Context, w http.ResponseWriter, userRefreshRequest *data.UserRefreshRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserRefreshData, error)
+	UpdateProfileHandler(ctx context.Context, userID string, updateProfileRequest *data.UpdateProfileRequest) (*data.UserData, error)
+	DeleteAccountHandler(ctx context.Context, userID string, deleteAccountRequest *data.DeleteAccountRequest) error
+	RetrieveSessionsHandler(ctx context.Context, userID string) ([]data.UserSession, error)
+	DeleteSessionHandler(ctx context.Context, userID, sessionID string) error
+	DeleteSessionsHandler(ctx context.Context, userID, exceptSessionID string) (int64, error)
 }
 
-func NewAuthModel(logger logger.ILogger, db db.IDB) *AuthModel {
+func NewAuthModel(logger logger.ILogger, db db.IDB, emailVerification IEmailVerificationModel, mfa IMFAModel, oidc IOIDCModel, loginAttempts loginattempts.ILimiter, passwordPolicy *validation.PasswordPolicy, unverifiedUserAccess data.UnverifiedUserAccess) *AuthModel {
 	return &AuthModel{
-		logger: logger,
-		db:     db,
+		logger:               logger,
+		db:                   db,
+		emailVerification:    emailVerification,
+		mfa:                  mfa,
+		oidc:                 oidc,
+		loginAttempts:        loginAttempts,
+		passwordPolicy:       passwordPolicy,
+		unverifiedUserAccess: unverifiedUserAccess,
 	}
 }
 
-func (am *AuthModel) RegisterUserHandler(userRegisterRequest *data.RegisterUserRequest) (*data.RegisterUserData, error) {
+func (am *AuthModel) RegisterUserHandler(ctx context.Context, userRegisterRequest *data.RegisterUserRequest) (*data.RegisterUserData, error) {
 	am.logger.InfoLog(helper.GetFunctionName(), "")
 
+	fields := []apperror.FieldError{}
+
 	if !validation.IsValidName(userRegisterRequest.FirstName) {
-		return nil, fmt.Errorf("%s - user: %s first name is invalid. FirstName: %s", helper.GetFunctionName(), userRegisterRequest.EmailAddress, userRegisterRequest.FirstName)
+		fields = append(fields, apperror.FieldError{Field: "FirstName", Message: "First name is invalid"})
 	}
 
 	if !validation.IsValidName(userRegisterRequest.LastName) {
-		return nil, fmt.Errorf("%s - user: %s last name is invalid. LastName: %s", helper.GetFunctionName(), userRegisterRequest.EmailAddress, userRegisterRequest.LastName)
+		fields = append(fields, apperror.FieldError{Field: "LastName", Message: "Last name is invalid"})
 	}
 
 	if !validation.IsValidEmail(userRegisterRequest.EmailAddress) {
-		return nil, fmt.Errorf("%s - user: %s email address is invalid. LastName: %s", helper.GetFunctionName(), userRegisterRequest.EmailAddress, userRegisterRequest.EmailAddress)
+		fields = append(fields, apperror.FieldError{Field: "EmailAddress", Message: "Email address is invalid"})
 	}
 
-	_, err := am.db.RetrieveUserDetails(&data.RegisterUserRequest{EmailAddress: userRegisterRequest.EmailAddress})
+	if err := am.passwordPolicy.Validate("Password", userRegisterRequest.Password, userRegisterRequest.EmailAddress); err != nil {
+		fields = append(fields, apperror.FieldErrors(err)...)
+	}
 
-	if err != nil {
+	if len(fields) > 0 {
+		return nil, fmt.Errorf("%s - user: %s %w", helper.GetFunctionName(), userRegisterRequest.EmailAddress, validation.ErrInvalidUser.WithFields(fields...))
+	}
+
+	if userRegisterRequest.Timezone == "" {
+		userRegisterRequest.Timezone = data.DefaultTimezone
+	}
+
+	if !validation.IsValidTimezone(userRegisterRequest.Timezone) {
+		return nil, fmt.Errorf("%s - user: %s %w", helper.GetFunctionName(), userRegisterRequest.EmailAddress, invalidTimezone(userRegisterRequest.Timezone))
+	}
+
+	_, err := am.db.RetrieveUserDetails(ctx, userRegisterRequest.EmailAddress)
+
+	if err == nil {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), db.ErrUserExists)
+	}
+
+	if !errors.Is(err, db.ErrUserNotFound) {
 		return nil, err
 	}
 
@@ -61,16 +114,15 @@ func (am *AuthModel) RegisterUserHandler(userRegisterRequest *data.RegisterUserR
 
 	userRegisterRequest.Password = string(hashedPassword)
 
-	userData, err := am.db.RegisterUserHandler(userRegisterRequest)
+	registerUserData, err := am.db.RegisterUserHandler(ctx, userRegisterRequest)
 
 	if err != nil {
 		return nil, err
 	}
 
-	registerUserData, ok := userData.(*data.UserData)
-
-	if !ok {
-		return nil, fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	// The account exists either way, and the user can ask for another link with /verify-email/resend
+	if err := am.emailVerification.SendVerificationEmail(ctx, registerUserData); err != nil {
+		am.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to send the verification email for userId=%s: err=%s", registerUserData.UserID, err))
 	}
 
 	return &data.RegisterUserData{
@@ -79,39 +131,125 @@ func (am *AuthModel) RegisterUserHandler(userRegisterRequest *data.RegisterUserR
 	}, nil
 }
 
-func (am *AuthModel) LoginHandler(w http.ResponseWriter, userAuth *data.UserAuth, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error) {
+/*
+LoginHandler starts a new session for the client, leaving any sessions the user has on other clients alone. A user with
+two-factor authentication gets an MFA pending token instead, which LoginMFAHandler exchanges for the session.
+*/
+func (am *AuthModel) LoginHandler(ctx context.Context, w http.ResponseWriter, userAuth *data.UserAuth, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error) {
 	am.logger.InfoLog(helper.GetFunctionName(), "")
 
-	userDetails, err := am.db.RetrieveUserDetails(userAuth)
+	// A locked out login is refused before the password is checked, so guessing it right doesn't help
+	if err := am.loginAttempts.Check(ctx, userAuth.EmailAddress, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	userData, err := am.db.RetrieveUserDetails(ctx, userAuth.EmailAddress)
+
+	// An unknown email address gets the same error as a wrong password, and is counted the same, so it doesn't reveal who has an account
+	if errors.Is(err, db.ErrUserNotFound) {
+		if err := am.loginAttempts.RecordFailure(ctx, userAuth.EmailAddress, client.IPAddress); err != nil {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("%s - Unknown user: %w", helper.GetFunctionName(), validation.ErrInvalidCredentials)
+	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	userData, ok := userDetails.(*data.UserData)
+	if !validation.VerifyUserPassword(userAuth.Password, userData.Password) {
+		if err := am.loginAttempts.RecordFailure(ctx, userAuth.EmailAddress, client.IPAddress); err != nil {
+			return nil, err
+		}
 
-	if !ok {
-		return nil, fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+		return nil, fmt.Errorf("%s - Invalid Password: %w", helper.GetFunctionName(), validation.ErrInvalidCredentials)
 	}
 
-	if !validation.VerifyUserPassword(userAuth.Password, userData.Password) {
-		return nil, fmt.Errorf("%s - Invalid Password", helper.GetFunctionName())
+	// Checked after the password so it doesn't reveal whether an address has an unverified account
+	if !userData.EmailVerified && am.unverifiedUserAccess == data.UnverifiedUserAccessNone {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrEmailNotVerified)
 	}
 
-	hasExistingRefreshToken, err := am.db.RetrieveUserSession(userData.EmailAddress, userData.UserID)
+	return am.completeLogin(ctx, w, userData, client, jwtTokens, csrfTokens)
+}
 
-	if err != nil && !strings.Contains(err.Error(), "User session doesn't exist") {
-		return nil, err
+/*
+completeLogin starts the session for a user who has proved who they are, or asks for a code first if they have two-factor
+authentication. A disabled user is only refused once they've proved who they are, so it doesn't reveal which accounts are disabled.
+*/
+func (am *AuthModel) completeLogin(ctx context.Context, w http.ResponseWriter, userData *data.UserData, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error) {
+	if userData.Disabled {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, session.ErrAccountDisabled)
 	}
 
-	if hasExistingRefreshToken != "" {
-		am.logger.InfoLog(helper.GetFunctionName(), "The user has an existing session - they've likely lost their JWT Access Token and /refresh endpoint has failed - thus sending them to the login page. Attempt to delete the session to recreate.")
-		if err := am.db.LogoutUser(userData); err != nil {
+	if userData.TOTP.Enabled {
+		mfaToken, mfaExpiresAt, err := am.mfa.StartMFALogin(userData)
+
+		if err != nil {
 			return nil, err
 		}
+
+		return &data.UserLoggedInData{
+			User:         *userData,
+			MFAToken:     mfaToken,
+			MFAExpiresAt: mfaExpiresAt,
+		}, nil
+	}
+
+	return am.startSession(ctx, w, userData, client, jwtTokens, csrfTokens)
+}
+
+// LoginMFAHandler starts the session for a login that LoginHandler asked for a code for, once the code is accepted
+func (am *AuthModel) LoginMFAHandler(ctx context.Context, w http.ResponseWriter, mfaLoginRequest *data.MFALoginRequest, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error) {
+	am.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := am.mfa.CompleteMFALogin(ctx, mfaLoginRequest, client)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The account may have been disabled since the password was checked
+	if userData.Disabled {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, session.ErrAccountDisabled)
 	}
 
-	accessToken, refreshToken, err := jwtTokens.GenerateJSONWebTokens(userAuth.EmailAddress)
+	return am.startSession(ctx, w, userData, client, jwtTokens, csrfTokens)
+}
+
+// StartOIDCLoginHandler starts a sign in with an identity provider, returning where to send the user and the state to keep until they come back
+func (am *AuthModel) StartOIDCLoginHandler(ctx context.Context, providerName string) (*data.OIDCLoginStart, error) {
+	am.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return am.oidc.StartOIDCLogin(ctx, providerName)
+}
+
+/*
+LoginOIDCHandler completes a sign in with an identity provider and starts the session like LoginHandler does, including asking
+for a code if the user has two-factor authentication, as the app's second factor is separate from the provider's.
+*/
+func (am *AuthModel) LoginOIDCHandler(ctx context.Context, w http.ResponseWriter, oidcLoginRequest *data.OIDCLoginRequest, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error) {
+	am.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := am.oidc.CompleteOIDCLogin(ctx, oidcLoginRequest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return am.completeLogin(ctx, w, userData, client, jwtTokens, csrfTokens)
+}
+
+// startSession issues the tokens for a new session and stores it
+func (am *AuthModel) startSession(ctx context.Context, w http.ResponseWriter, userData *data.UserData, client data.ClientInfo, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserLoggedInData, error) {
+	sessionID, err := helper.RandomID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, refreshToken, err := jwtTokens.GenerateJSONWebTokens(userData.UserID, userData.EmailAddress, sessionID, userData.Roles)
 
 	if err != nil {
 		return nil, err
@@ -125,73 +263,217 @@ func (am *AuthModel) LoginHandler(w http.ResponseWriter, userAuth *data.UserAuth
 
 	loggedInAt := time.Now()
 
-	if err := am.db.LoginUser(&data.UserSession{
+	userAgent := client.UserAgent
+
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+
+	if err := am.db.LoginUser(ctx, &data.UserSession{
+		SessionID:    sessionID,
 		UserID:       userData.UserID,
 		RefreshToken: refreshToken,
-		Device:       helper.GetDeviceInfo(),
-		IPAddress:    helper.GetLocalIP(),
+		UserAgent:    userAgent,
+		IPAddress:    client.IPAddress,
 		CreatedAt:    loggedInAt,
 	}); err != nil {
 		return nil, err
 	}
 
+	if err := am.loginAttempts.RecordSuccess(ctx, userData.EmailAddress); err != nil {
+		return nil, err
+	}
+
 	w.Header().Set("X-CSRF-Token", csrfToken)
 	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 
 	return &data.UserLoggedInData{
-		Success:     true,
-		User:        *userData,
-		AccessToken: accessToken,
-		LoggedInAt:  loggedInAt,
+		Success:      true,
+		User:         *userData,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		SessionID:    sessionID,
+		LoggedInAt:   loggedInAt,
 	}, nil
 }
 
-func (am *AuthModel) LogoutHandler(w http.ResponseWriter, userLoggedOutRequest *data.UserLoggedOutRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) error {
+// LogoutHandler ends the session the request was made with
+func (am *AuthModel) LogoutHandler(ctx context.Context, w http.ResponseWriter, userLoggedOutRequest *data.UserLoggedOutRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) error {
 	am.logger.InfoLog(helper.GetFunctionName(), "")
 
-	userDetails, err := am.db.RetrieveUserDetails(userLoggedOutRequest)
+	userData, err := am.db.RetrieveUserDetailsByID(ctx, userLoggedOutRequest.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	userSession, err := am.db.RetrieveUserSession(ctx, userData.UserID, userLoggedOutRequest.SessionID)
 
 	if err != nil {
 		return err
 	}
 
-	userData, ok := userDetails.(*data.UserData)
+	// The refresh token has to belong to the session being ended, a leaked access token on its own can't log the user out
+	if subtle.ConstantTimeCompare([]byte(userSession.RefreshToken), []byte(userLoggedOutRequest.RefreshToken)) != 1 {
+		return fmt.Errorf("%s - sessionId=%s: %w", helper.GetFunctionName(), userLoggedOutRequest.SessionID, session.ErrInvalidRefreshToken)
+	}
+
+	if err := am.db.LogoutUser(ctx, userData.UserID, userLoggedOutRequest.SessionID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RefreshHandler exchanges a refresh token for new access, refresh and CSRF tokens
+func (am *AuthModel) RefreshHandler(ctx context.Context, w http.ResponseWriter, userRefreshRequest *data.UserRefreshRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (*data.UserRefreshData, error) {
+	am.logger.InfoLog(helper.GetFunctionName(), "")
+
+	refreshedTokens, err := jwtTokens.HandleLongLivedJSONWebToken(ctx, userRefreshRequest.RefreshToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	csrfToken, err := csrfTokens.CSRFToken(w)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.UserRefreshData{
+		Success:      true,
+		EmailAddress: refreshedTokens.Username,
+		AccessToken:  refreshedTokens.AccessToken,
+		RefreshToken: refreshedTokens.RefreshToken,
+		CSRFToken:    csrfToken,
+	}, nil
+}
+
+// UpdateProfileHandler updates the fields set in updateProfileRequest and returns the updated user. Nothing is changed if any field is invalid.
+func (am *AuthModel) UpdateProfileHandler(ctx context.Context, userID string, updateProfileRequest *data.UpdateProfileRequest) (*data.UserData, error) {
+	am.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := am.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []apperror.FieldError{}
+
+	if updateProfileRequest.FirstName != nil && !validation.IsValidName(*updateProfileRequest.FirstName) {
+		fields = append(fields, apperror.FieldError{Field: "FirstName", Message: "First name is invalid"})
+	}
+
+	if updateProfileRequest.LastName != nil && !validation.IsValidName(*updateProfileRequest.LastName) {
+		fields = append(fields, apperror.FieldError{Field: "LastName", Message: "Last name is invalid"})
+	}
+
+	if len(fields) > 0 {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userID, validation.ErrInvalidUser.WithFields(fields...))
+	}
 
-	if !ok {
-		return fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	if updateProfileRequest.Timezone != nil && !validation.IsValidTimezone(*updateProfileRequest.Timezone) {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userID, invalidTimezone(*updateProfileRequest.Timezone))
 	}
 
-	refreshToken, err := am.db.RetrieveUserSession(userData.EmailAddress, userData.UserID)
+	if updateProfileRequest.FirstName != nil || updateProfileRequest.LastName != nil {
+		if updateProfileRequest.FirstName != nil {
+			userData.FirstName = *updateProfileRequest.FirstName
+		}
+
+		if updateProfileRequest.LastName != nil {
+			userData.LastName = *updateProfileRequest.LastName
+		}
+
+		if err := am.db.UpdateUserName(ctx, userData.UserID, userData.FirstName, userData.LastName); err != nil {
+			return nil, err
+		}
+	}
+
+	if updateProfileRequest.Timezone != nil {
+		if err := am.db.UpdateUserTimezone(ctx, userData.UserID, *updateProfileRequest.Timezone); err != nil {
+			return nil, err
+		}
+
+		userData.Timezone = *updateProfileRequest.Timezone
+	}
+
+	return userData, nil
+}
+
+/*
+DeleteAccountHandler deletes the user and all their data once their password is confirmed, so a stolen access token can't do it.
+A user who signed up with an identity provider has no password, and sets one with /password/forgot first.
+*/
+func (am *AuthModel) DeleteAccountHandler(ctx context.Context, userID string, deleteAccountRequest *data.DeleteAccountRequest) error {
+	am.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := am.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
 		return err
 	}
 
-	if refreshToken == "" {
-		return fmt.Errorf("%s - The user doesn't have a refresh token so therefore they don't have an active session to log out. Refresh Token:%s", helper.GetFunctionName(), refreshToken)
+	if !validation.VerifyUserPassword(deleteAccountRequest.Password, userData.Password) {
+		return fmt.Errorf("%s - Invalid Password: %w", helper.GetFunctionName(), validation.ErrInvalidCredentials)
 	}
 
-	if err := am.db.LogoutUser(userData); err != nil {
+	if err := am.db.DeleteUser(ctx, userData.UserID); err != nil {
 		return err
 	}
 
+	am.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s deleted their account", userData.UserID))
+
 	return nil
 }
 
-func (am *AuthModel) RefreshHandler(w http.ResponseWriter, userRefreshRequest *data.UserRefreshRequest, jwtTokens session.IJSONWebToken, csrfTokens session.ICSRFToken) (string, string, error) {
+// RetrieveSessionsHandler returns the user's active sessions, oldest first
+func (am *AuthModel) RetrieveSessionsHandler(ctx context.Context, userID string) ([]data.UserSession, error) {
 	am.logger.InfoLog(helper.GetFunctionName(), "")
 
-	newAccessToken, err := jwtTokens.HandleLongLivedJSONWebToken(userRefreshRequest.EmailAddress)
+	userData, err := am.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	csrfToken, err := csrfTokens.CSRFToken(w)
+	return am.db.RetrieveUserSessions(ctx, userData.UserID)
+}
+
+// DeleteSessionHandler revokes one of the user's sessions, which may be the one the request was made with
+func (am *AuthModel) DeleteSessionHandler(ctx context.Context, userID, sessionID string) error {
+	am.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("sessionId=%s", sessionID))
+
+	userData, err := am.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return err
+	}
+
+	err = am.db.LogoutUser(ctx, userData.UserID, sessionID)
+
+	if errors.Is(err, db.ErrSessionNotFound) {
+		return fmt.Errorf("%s - sessionId=%s: %w", helper.GetFunctionName(), sessionID, ErrSessionNotFound)
+	}
+
+	return err
+}
+
+// DeleteSessionsHandler revokes all of the user's sessions apart from exceptSessionID, when set, and returns how many it revoked
+func (am *AuthModel) DeleteSessionsHandler(ctx context.Context, userID, exceptSessionID string) (int64, error) {
+	am.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := am.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
-		return "", "", err
+		return 0, err
 	}
 
-	return newAccessToken, csrfToken, nil
+	return am.db.DeleteUserSessions(ctx, userData.UserID, exceptSessionID)
+}
+
+func invalidTimezone(timezone string) error {
+	return validation.ErrInvalidTimezone.WithFields(apperror.FieldError{Field: "Timezone", Message: fmt.Sprintf("%q isn't an IANA timezone such as Europe/London", timezone)})
 }
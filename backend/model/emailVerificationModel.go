@@ -0,0 +1,269 @@
+package model
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/mail"
+	"dohabits/middleware/session"
+	"dohabits/validation"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// verifyEmailPath is the frontend page the emailed link opens, which posts the token to /verify-email
+const verifyEmailPath = "/verifyemail"
+
+// changeEmailPath is the frontend page the link sent to a new email address opens, which posts the token to /verify-email/change
+const changeEmailPath = "/changeemail"
+
+// ErrEmailNotVerified is returned when UNVERIFIED_USER_ACCESS doesn't let an unverified user do something
+var ErrEmailNotVerified = apperror.New(http.StatusForbidden, apperror.CodeEmailNotVerified, "Verify your email address with the link emailed to you first")
+
+type EmailVerificationModel struct {
+	logger             logger.ILogger
+	db                 db.IDB
+	mailer             mail.Mailer
+	verificationTokens session.IEmailVerificationToken
+	siteURL            string
+}
+
+type IEmailVerificationModel interface {
+	SendVerificationEmail(ctx context.Context, userData *data.UserData) error
+	VerifyEmailHandler(ctx context.Context, verifyEmailRequest *data.VerifyEmailRequest) error
+	ResendVerificationEmailHandler(ctx context.Context, resendVerificationEmailRequest *data.ResendVerificationEmailRequest) error
+	ChangeEmailHandler(ctx context.Context, userID string, changeEmailRequest *data.ChangeEmailRequest) error
+	ConfirmEmailChangeHandler(ctx context.Context, verifyEmailRequest *data.VerifyEmailRequest) error
+}
+
+func NewEmailVerificationModel(logger logger.ILogger, db db.IDB, mailer mail.Mailer, verificationTokens session.IEmailVerificationToken, siteURL string) *EmailVerificationModel {
+	return &EmailVerificationModel{
+		logger:             logger,
+		db:                 db,
+		mailer:             mailer,
+		verificationTokens: verificationTokens,
+		siteURL:            strings.TrimRight(siteURL, "/"),
+	}
+}
+
+// SendVerificationEmail emails the user a signed link that verifies their email address, valid for session.EmailVerificationTTL
+func (evm *EmailVerificationModel) SendVerificationEmail(ctx context.Context, userData *data.UserData) error {
+	evm.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userData.UserID))
+
+	token, err := evm.verificationTokens.GenerateEmailVerificationToken(userData.UserID, userData.EmailAddress)
+
+	if err != nil {
+		return err
+	}
+
+	verifyLink := fmt.Sprintf("%s%s?token=%s", evm.siteURL, verifyEmailPath, url.QueryEscape(token))
+
+	message := mail.Message{
+		To:      userData.EmailAddress,
+		Subject: "Verify your DoHabits email address",
+		Body: fmt.Sprintf("Hi %s,\n\nWelcome to DoHabits! To verify your email address, open this link within %s:\n\n%s\n\nIf you didn't create an account, you can ignore this email.\n",
+			userData.FirstName, session.EmailVerificationTTL, verifyLink),
+	}
+
+	return evm.mailer.Send(ctx, message)
+}
+
+// VerifyEmailHandler marks the email address in the token as verified. Verifying an address again succeeds without changing anything.
+func (evm *EmailVerificationModel) VerifyEmailHandler(ctx context.Context, verifyEmailRequest *data.VerifyEmailRequest) error {
+	evm.logger.InfoLog(helper.GetFunctionName(), "")
+
+	claims, err := evm.verificationTokens.ParseEmailVerificationToken(verifyEmailRequest.Token)
+
+	if err != nil {
+		return err
+	}
+
+	userData, err := evm.db.RetrieveUserDetailsByID(ctx, claims.Subject)
+
+	if errors.Is(err, db.ErrUserNotFound) {
+		return fmt.Errorf("%s - the user no longer exists: %w", helper.GetFunctionName(), session.ErrInvalidEmailVerificationToken)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// The user has changed their email address since the link was sent
+	if userData.EmailAddress != claims.EmailAddress {
+		return fmt.Errorf("%s - the user's email address has changed since the link was sent: %w", helper.GetFunctionName(), session.ErrInvalidEmailVerificationToken)
+	}
+
+	if userData.EmailVerified {
+		return nil
+	}
+
+	return evm.db.UpdateUserEmailVerified(ctx, userData.UserID, true)
+}
+
+/*
+ResendVerificationEmailHandler sends another verification link, e.g. when the first has expired. It doesn't need the user to be
+logged in, as they may not be allowed to until they've verified. Unknown and already verified email addresses succeed without
+an email being sent, so the response doesn't reveal who has an account.
+*/
+func (evm *EmailVerificationModel) ResendVerificationEmailHandler(ctx context.Context, resendVerificationEmailRequest *data.ResendVerificationEmailRequest) error {
+	evm.logger.InfoLog(helper.GetFunctionName(), "")
+
+	if !validation.IsValidEmail(resendVerificationEmailRequest.EmailAddress) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), validation.ErrInvalidUser.WithFields(apperror.FieldError{Field: "EmailAddress", Message: "Email address is invalid"}))
+	}
+
+	userData, err := evm.db.RetrieveUserDetails(ctx, resendVerificationEmailRequest.EmailAddress)
+
+	if errors.Is(err, db.ErrUserNotFound) {
+		evm.logger.InfoLog(helper.GetFunctionName(), "No user has the email address, no email sent")
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if userData.EmailVerified {
+		evm.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s is already verified, no email sent", userData.UserID))
+		return nil
+	}
+
+	if err := evm.SendVerificationEmail(ctx, userData); err != nil {
+		evm.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to send the verification email for userId=%s: err=%s", userData.UserID, err))
+	}
+
+	return nil
+}
+
+/*
+ChangeEmailHandler emails a link to the new address once the password is confirmed, so a stolen access token can't move the account
+to someone else's address. Nothing changes until the link is opened, see ConfirmEmailChangeHandler.
+*/
+func (evm *EmailVerificationModel) ChangeEmailHandler(ctx context.Context, userID string, changeEmailRequest *data.ChangeEmailRequest) error {
+	evm.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := evm.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if !validation.VerifyUserPassword(changeEmailRequest.Password, userData.Password) {
+		return fmt.Errorf("%s - Invalid Password: %w", helper.GetFunctionName(), validation.ErrInvalidCredentials)
+	}
+
+	if !validation.IsValidEmail(changeEmailRequest.EmailAddress) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), validation.ErrInvalidUser.WithFields(apperror.FieldError{Field: "EmailAddress", Message: "Email address is invalid"}))
+	}
+
+	if strings.EqualFold(changeEmailRequest.EmailAddress, userData.EmailAddress) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), validation.ErrInvalidUser.WithFields(apperror.FieldError{Field: "EmailAddress", Message: "New email address must be different from the current one"}))
+	}
+
+	if err := evm.checkEmailAvailable(ctx, changeEmailRequest.EmailAddress); err != nil {
+		return err
+	}
+
+	token, err := evm.verificationTokens.GenerateEmailChangeToken(userData.UserID, userData.EmailAddress, changeEmailRequest.EmailAddress)
+
+	if err != nil {
+		return err
+	}
+
+	changeLink := fmt.Sprintf("%s%s?token=%s", evm.siteURL, changeEmailPath, url.QueryEscape(token))
+
+	message := mail.Message{
+		To:      changeEmailRequest.EmailAddress,
+		Subject: "Confirm your new DoHabits email address",
+		Body: fmt.Sprintf("Hi %s,\n\nTo change your DoHabits email address to this one, open this link within %s:\n\n%s\n\nIf you didn't ask to change it, you can ignore this email.\n",
+			userData.FirstName, session.EmailVerificationTTL, changeLink),
+	}
+
+	if err := evm.mailer.Send(ctx, message); err != nil {
+		return err
+	}
+
+	evm.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s asked to change their email address", userData.UserID))
+
+	return nil
+}
+
+/*
+ConfirmEmailChangeHandler moves the user to the new address in the token and marks it as verified. Every session is logged out, as
+their access tokens still carry the old address, and the old address is told about the change, in case it wasn't the user who made it.
+*/
+func (evm *EmailVerificationModel) ConfirmEmailChangeHandler(ctx context.Context, verifyEmailRequest *data.VerifyEmailRequest) error {
+	evm.logger.InfoLog(helper.GetFunctionName(), "")
+
+	claims, err := evm.verificationTokens.ParseEmailChangeToken(verifyEmailRequest.Token)
+
+	if err != nil {
+		return err
+	}
+
+	userData, err := evm.db.RetrieveUserDetailsByID(ctx, claims.Subject)
+
+	if errors.Is(err, db.ErrUserNotFound) {
+		return fmt.Errorf("%s - the user no longer exists: %w", helper.GetFunctionName(), session.ErrInvalidEmailVerificationToken)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// The link has been used, or the address has changed again since it was sent
+	if userData.EmailAddress != claims.PreviousEmailAddress {
+		return fmt.Errorf("%s - the user's email address has changed since the link was sent: %w", helper.GetFunctionName(), session.ErrInvalidEmailVerificationToken)
+	}
+
+	if err := evm.checkEmailAvailable(ctx, claims.EmailAddress); err != nil {
+		return err
+	}
+
+	if err := evm.db.UpdateUserEmailAddress(ctx, userData.UserID, claims.EmailAddress); err != nil {
+		return err
+	}
+
+	revoked, err := evm.db.DeleteUserSessions(ctx, userData.UserID, "")
+
+	if err != nil {
+		return err
+	}
+
+	evm.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s changed their email address and was logged out of %d sessions", userData.UserID, revoked))
+
+	message := mail.Message{
+		To:      claims.PreviousEmailAddress,
+		Subject: "Your DoHabits email address has changed",
+		Body: fmt.Sprintf("Hi %s,\n\nYour DoHabits email address has been changed to %s. If you didn't change it, reset your password and contact support.\n",
+			userData.FirstName, claims.EmailAddress),
+	}
+
+	// The change has been made either way
+	if err := evm.mailer.Send(ctx, message); err != nil {
+		evm.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to send the email change notice for userId=%s: err=%s", userData.UserID, err))
+	}
+
+	return nil
+}
+
+// checkEmailAvailable returns db.ErrUserExists if another user has the email address
+func (evm *EmailVerificationModel) checkEmailAvailable(ctx context.Context, emailAddress string) error {
+	_, err := evm.db.RetrieveUserDetails(ctx, emailAddress)
+
+	if err == nil {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), db.ErrUserExists)
+	}
+
+	if !errors.Is(err, db.ErrUserNotFound) {
+		return err
+	}
+
+	return nil
+}
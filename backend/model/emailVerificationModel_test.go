@@ -0,0 +1,326 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/mail"
+	"dohabits/middleware/session"
+	"dohabits/validation"
+	"errors"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var (
+	verifyTokenPattern      = regexp.MustCompile(`/verifyemail\?token=([A-Za-z0-9_.-]+)`)
+	changeEmailTokenPattern = regexp.MustCompile(`/changeemail\?token=([A-Za-z0-9_.-]+)`)
+)
+
+// newTestAuthModel returns an AuthModel that lets unverified users do everything, as users could before email verification
+func newTestAuthModel(logger logger.ILogger, db db.IDB) *AuthModel {
+	emailVerificationTokens := session.NewEmailVerificationToken(session.NewHMACKeySet("secretJwt"), logger)
+	emailVerificationModel := NewEmailVerificationModel(logger, db, mail.NewMockMailer(logger), emailVerificationTokens, "http://localhost")
+
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := NewMFAModel(logger, db, session.NewMFAToken(session.NewHMACKeySet("secretJwt"), logger), loginAttempts)
+
+	return NewAuthModel(logger, db, emailVerificationModel, mfaModel, nil, loginAttempts, validation.DefaultPasswordPolicy(), data.UnverifiedUserAccessAll)
+}
+
+// verifyTokenFromEmail returns the token in the verification link of the latest email the mailer sent
+func verifyTokenFromEmail(t *testing.T, mailer *mail.MockMailer) string {
+	t.Helper()
+
+	sent := mailer.Sent()
+
+	if len(sent) == 0 {
+		t.Fatalf("%s - Failed - no email was sent", helper.GetFunctionName())
+	}
+
+	match := verifyTokenPattern.FindStringSubmatch(sent[len(sent)-1].Body)
+
+	if match == nil {
+		t.Fatalf("%s - Failed - no verification link in the email:\n%s", helper.GetFunctionName(), sent[len(sent)-1].Body)
+	}
+
+	return match[1]
+}
+
+func TestEmailVerification(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mailer := mail.NewMockMailer(logger)
+	mockDB := db.NewMockDB(logger)
+	emailVerificationTokens := session.NewEmailVerificationToken(session.NewHMACKeySet("secretJwt"), logger)
+	emailVerificationModel := NewEmailVerificationModel(logger, mockDB, mailer, emailVerificationTokens, "http://localhost/")
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := NewMFAModel(logger, mockDB, session.NewMFAToken(session.NewHMACKeySet("secretJwt"), logger), loginAttempts)
+	authModel := NewAuthModel(logger, mockDB, emailVerificationModel, mfaModel, nil, loginAttempts, validation.DefaultPasswordPolicy(), data.UnverifiedUserAccessNone)
+	jwtTokens := session.NewJSONWebToken(session.NewHMACKeySet("secretJwt"), mockDB, logger)
+	csrfTokens := session.NewMockCSRFToken(logger)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	userAuth := &data.UserAuth{EmailAddress: "new.user@example.com", Password: "1secret?Password"}
+
+	registeredUser, err := authModel.RegisterUserHandler(ctx, &data.RegisterUserRequest{
+		EmailAddress: userAuth.EmailAddress,
+		Password:     userAuth.Password,
+		FirstName:    "New",
+		LastName:     "User",
+	})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if registeredUser.User.EmailVerified {
+		t.Fatalf("%s - Failed - a new user is verified before following the link", helper.GetFunctionName())
+	}
+
+	sent := mailer.Sent()
+
+	if len(sent) != 1 || sent[0].To != userAuth.EmailAddress {
+		t.Fatalf("%s - Failed - sent=%+v", helper.GetFunctionName(), sent)
+	}
+
+	token := verifyTokenFromEmail(t, mailer)
+
+	// UNVERIFIED_USER_ACCESS=none doesn't let them log in yet
+	_, err = authModel.LoginHandler(ctx, httptest.NewRecorder(), userAuth, data.ClientInfo{}, jwtTokens, csrfTokens)
+
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrEmailNotVerified)
+	}
+
+	// Resending sends a new link to an unverified user only, and succeeds for addresses without an account
+	resendTestCases := []struct {
+		name         string
+		emailAddress string
+		wantSent     int
+	}{
+		{name: "Test resend to an unverified user", emailAddress: userAuth.EmailAddress, wantSent: 2},
+		{name: "Test resend to a verified user", emailAddress: "johndoe1@example.com", wantSent: 2},
+		{name: "Test resend to an unknown email address", emailAddress: "nobody@example.com", wantSent: 2},
+	}
+
+	for _, val := range resendTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			if err := emailVerificationModel.ResendVerificationEmailHandler(ctx, &data.ResendVerificationEmailRequest{EmailAddress: val.emailAddress}); err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			if sent := mailer.Sent(); len(sent) != val.wantSent {
+				t.Errorf("%s - Failed - got %d emails, want=%d", helper.GetFunctionName(), len(sent), val.wantSent)
+			}
+		})
+	}
+
+	// A link for an address that has since moved to another account
+	otherUserToken, err := emailVerificationTokens.GenerateEmailVerificationToken("1", userAuth.EmailAddress)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// A link for an address the user has since changed
+	oldAddressToken, err := emailVerificationTokens.GenerateEmailVerificationToken(registeredUser.User.UserID, "old.address@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	verifyTestCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "Test tampered token", token: token + "x", wantErr: session.ErrInvalidEmailVerificationToken},
+		{name: "Test token for another user", token: otherUserToken, wantErr: session.ErrInvalidEmailVerificationToken},
+		{name: "Test token for an old email address", token: oldAddressToken, wantErr: session.ErrInvalidEmailVerificationToken},
+		{name: "Test valid token", token: token},
+		{name: "Test the same link again", token: token},
+	}
+
+	for _, val := range verifyTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			err := emailVerificationModel.VerifyEmailHandler(ctx, &data.VerifyEmailRequest{Token: val.token})
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+
+	if _, err = authModel.LoginHandler(ctx, httptest.NewRecorder(), userAuth, data.ClientInfo{}, jwtTokens, csrfTokens); err != nil {
+		t.Fatalf("%s - Failed - a verified user can't log in, err=%s", helper.GetFunctionName(), err)
+	}
+
+	// There's nothing to resend once they're verified
+	if err := emailVerificationModel.ResendVerificationEmailHandler(ctx, &data.ResendVerificationEmailRequest{EmailAddress: userAuth.EmailAddress}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if sent := mailer.Sent(); len(sent) != 2 {
+		t.Fatalf("%s - Failed - got %d emails, want=2", helper.GetFunctionName(), len(sent))
+	}
+}
+
+func TestEmailChange(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mailer := mail.NewMockMailer(logger)
+	mockDB := db.NewMockDB(logger)
+	emailVerificationTokens := session.NewEmailVerificationToken(session.NewHMACKeySet("secretJwt"), logger)
+	emailVerificationModel := NewEmailVerificationModel(logger, mockDB, mailer, emailVerificationTokens, "http://localhost/")
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	if err := mockDB.LoginUser(ctx, &data.UserSession{SessionID: "change-email-session", UserID: "1"}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	changeTestCases := []struct {
+		name         string
+		emailAddress string
+		password     string
+		wantErr      error
+	}{
+		{name: "Test wrong password", emailAddress: "john.new@example.com", password: "wrongPassword", wantErr: validation.ErrInvalidCredentials},
+		{name: "Test invalid email address", emailAddress: "john.new", password: "1secret?Password", wantErr: validation.ErrInvalidUser},
+		{name: "Test the same email address", emailAddress: "JohnDoe1@example.com", password: "1secret?Password", wantErr: validation.ErrInvalidUser},
+		{name: "Test another user's email address", emailAddress: "janesmith@example.com", password: "1secret?Password", wantErr: db.ErrUserExists},
+		{name: "Test valid change", emailAddress: "john.new@example.com", password: "1secret?Password"},
+	}
+
+	for _, val := range changeTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			err := emailVerificationModel.ChangeEmailHandler(ctx, "1", &data.ChangeEmailRequest{EmailAddress: val.emailAddress, Password: val.password})
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+
+	sent := mailer.Sent()
+
+	if len(sent) != 1 || sent[0].To != "john.new@example.com" {
+		t.Fatalf("%s - Failed - sent=%+v", helper.GetFunctionName(), sent)
+	}
+
+	match := changeEmailTokenPattern.FindStringSubmatch(sent[0].Body)
+
+	if match == nil {
+		t.Fatalf("%s - Failed - no email change link in the email:\n%s", helper.GetFunctionName(), sent[0].Body)
+	}
+
+	// Nothing changes until the link is followed
+	if stored, _ := mockDB.RetrieveUserDetailsByID(ctx, "1"); stored.EmailAddress != "johndoe1@example.com" {
+		t.Fatalf("%s - Failed - the email address changed before the link was followed, got=%s", helper.GetFunctionName(), stored.EmailAddress)
+	}
+
+	verificationToken, err := emailVerificationTokens.GenerateEmailVerificationToken("1", "johndoe1@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	confirmTestCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "Test tampered token", token: match[1] + "x", wantErr: session.ErrInvalidEmailVerificationToken},
+		{name: "Test email verification token", token: verificationToken, wantErr: session.ErrInvalidEmailVerificationToken},
+		{name: "Test valid token", token: match[1]},
+		{name: "Test the same link again", token: match[1], wantErr: session.ErrInvalidEmailVerificationToken},
+	}
+
+	for _, val := range confirmTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			err := emailVerificationModel.ConfirmEmailChangeHandler(ctx, &data.VerifyEmailRequest{Token: val.token})
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+
+	stored, err := mockDB.RetrieveUserDetailsByID(ctx, "1")
+
+	if err != nil || stored.EmailAddress != "john.new@example.com" || !stored.EmailVerified {
+		t.Fatalf("%s - Failed - user=%+v, err=%v", helper.GetFunctionName(), stored, err)
+	}
+
+	if sessions, _ := mockDB.RetrieveUserSessions(ctx, "1"); len(sessions) != 0 {
+		t.Errorf("%s - Failed - the user is still logged in, sessions=%+v", helper.GetFunctionName(), sessions)
+	}
+
+	if sent := mailer.Sent(); len(sent) != 2 || sent[1].To != "johndoe1@example.com" {
+		t.Errorf("%s - Failed - the old address wasn't told about the change, sent=%+v", helper.GetFunctionName(), sent)
+	}
+}
+
+func TestUnverifiedUserAccess(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockHabit = originalMockHabitState
+	}()
+
+	if err := mockDB.UpdateUserEmailVerified(ctx, "1", false); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name                 string
+		unverifiedUserAccess data.UnverifiedUserAccess
+		wantErr              error
+	}{
+		{name: "Test all lets an unverified user create habits", unverifiedUserAccess: data.UnverifiedUserAccessAll},
+		{name: "Test login only lets an unverified user read", unverifiedUserAccess: data.UnverifiedUserAccessLogin, wantErr: ErrEmailNotVerified},
+		{name: "Test none stops an unverified user creating habits", unverifiedUserAccess: data.UnverifiedUserAccessNone, wantErr: ErrEmailNotVerified},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			habitsModel := NewHabitsModel(logger, mockDB, val.unverifiedUserAccess)
+
+			_, err := habitsModel.CreateHabitsHandler(ctx, "1", data.NewHabit{Name: "Read", DaysTarget: 10})
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"time"
+)
+
+// ExportModel gathers a user's personal data for them to download
+type ExportModel struct {
+	logger logger.ILogger
+	db     db.IDB
+}
+
+type IExportModel interface {
+	ExportHandler(ctx context.Context, userID string) (*data.PersonalDataExport, error)
+}
+
+func NewExportModel(logger logger.ILogger, db db.IDB) *ExportModel {
+	return &ExportModel{
+		logger: logger,
+		db:     db,
+	}
+}
+
+/*
+ExportHandler returns the user's profile, sessions and personal access tokens, and streams their habits from the database each time
+EachHabit is called, with their progress as of today in the user's timezone, as RetrieveAllHabitsHandler returns them. Everything but
+the habits is read up front, so an error that stops the export is returned before any of it has been sent. The habits are read with ctx, the request's
+context, and no timeout, so a large export to a slow client isn't cut off part way.
+*/
+func (m *ExportModel) ExportHandler(ctx context.Context, userID string) (*data.PersonalDataExport, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := m.db.RetrieveUserSessions(ctx, userData.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	personalAccessTokens, err := m.db.RetrievePersonalAccessTokens(ctx, userData.UserID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	today := userNow(userData)
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s exported their personal data", userData.UserID))
+
+	return &data.PersonalDataExport{
+		User:                 userData,
+		Sessions:             sessions,
+		PersonalAccessTokens: personalAccessTokens,
+		EachHabit: func(fn func(data.Habit) error) error {
+			return m.db.StreamAllHabitsHandler(ctx, userData.UserID, func(habit data.Habit) error {
+				return fn(withProgress(habit, today))
+			})
+		},
+		ExportedAt: time.Now().UTC(),
+	}, nil
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"testing"
+)
+
+func TestExportHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	exportModel := NewExportModel(logger, mockDB)
+	ctx := context.Background()
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	if err := mockDB.CreatePersonalAccessToken(ctx, &data.PersonalAccessToken{TokenID: "token-4", UserID: "4", Name: "Script", TokenHash: "hash-4", Scopes: []string{}}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	export, err := exportModel.ExportHandler(ctx, "4")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if export.User.UserID != "4" || len(export.Sessions) != 2 || len(export.PersonalAccessTokens) != 1 || export.ExportedAt.IsZero() {
+		t.Fatalf("%s - Failed - user=%+v, sessions=%+v, personalAccessTokens=%+v", helper.GetFunctionName(), export.User, export.Sessions, export.PersonalAccessTokens)
+	}
+
+	want, _ := db.NewMockDB(logger).RetrieveAllHabitsHandler(ctx, "4")
+
+	// EachHabit reads the habits again each time it's called
+	for i := 0; i < 2; i++ {
+		habits := []data.Habit{}
+
+		err := export.EachHabit(func(habit data.Habit) error {
+			habits = append(habits, habit)
+			return nil
+		})
+
+		if err != nil || len(habits) != len(want) || len(habits) == 0 {
+			t.Fatalf("%s - Failed - got habits=%+v, want=%+v, err=%v", helper.GetFunctionName(), habits, want, err)
+		}
+
+		for _, habit := range habits {
+			if habit.UserID != "4" || habit.Schedule.Type == "" {
+				t.Errorf("%s - Failed - got habit=%+v", helper.GetFunctionName(), habit)
+			}
+		}
+	}
+
+	// An error from fn stops the habits being read
+	errStop := errors.New("stop")
+	calls := 0
+
+	err = export.EachHabit(func(habit data.Habit) error {
+		calls++
+		return errStop
+	})
+
+	if !errors.Is(err, errStop) || calls != 1 {
+		t.Errorf("%s - Failed - calls=%d, err=%v", helper.GetFunctionName(), calls, err)
+	}
+
+	if _, err := exportModel.ExportHandler(ctx, "999"); !errors.Is(err, db.ErrUserNotFound) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, db.ErrUserNotFound)
+	}
+}
@@ -1,55 +1,67 @@
 package model
 
 import (
+	"context"
+	"dohabits/apperror"
 	"dohabits/data"
 	"dohabits/db"
 	"dohabits/helper"
 	"dohabits/logger"
 	"dohabits/validation"
 	"fmt"
+	"time"
 )
 
+// HabitsModel serialises each user's writes with a per-user lock, so users never block each other and reads take no lock at all
 type HabitsModel struct {
-	logger logger.ILogger
-	db     db.IDB
+	logger               logger.ILogger
+	db                   db.IDB
+	locks                *userLocks
+	unverifiedUserAccess data.UnverifiedUserAccess
 }
 
 type IHabitsModel interface {
-	CreateHabitsHandler(userEmailAddress string, habit data.NewHabit) (*data.NewHabitResponse, error)
-	RetrieveHabitsHandler(userEmailAddress, habitId string) (data.Habit, error)
-	RetrieveAllHabitsHandler(userEmailAddress string) ([]data.Habit, error)
-	UpdateHabitsHandler(userEmailAddress string, habits data.Habit, habitId string) error
-	UpdateAllHabitsHandler(userEmailAddress string, habits *[]data.Habit) error
-	DeleteHabitsHandler(userEmailAddress, habitId string) error
+	CreateHabitsHandler(ctx context.Context, userID string, habit data.NewHabit) (*data.NewHabitResponse, error)
+	RetrieveHabitsHandler(ctx context.Context, userID, habitId string) (data.Habit, error)
+	RetrieveAllHabitsHandler(ctx context.Context, userID string) ([]data.Habit, error)
+	UpdateHabitsHandler(ctx context.Context, userID string, update data.UpdateHabit, habitId string) (data.Habit, error)
+	UpdateAllHabitsHandler(ctx context.Context, userID string, updates []data.UpdateHabit) ([]data.Habit, error)
+	DeleteHabitsHandler(ctx context.Context, userID, habitId string, revision *int64) error
+	CreateCompletionHandler(ctx context.Context, userID, habitId, completionDate string, value *float64) (data.Habit, error)
+	DeleteCompletionHandler(ctx context.Context, userID, habitId, completionDate string) (data.Habit, error)
 }
 
-func NewHabitsModel(logger logger.ILogger, db db.IDB) *HabitsModel {
+func NewHabitsModel(logger logger.ILogger, db db.IDB, unverifiedUserAccess data.UnverifiedUserAccess) *HabitsModel {
 	return &HabitsModel{
-		logger: logger,
-		db:     db,
+		logger:               logger,
+		db:                   db,
+		locks:                newUserLocks(),
+		unverifiedUserAccess: unverifiedUserAccess,
 	}
 }
 
-func (m *HabitsModel) CreateHabitsHandler(userEmailAddress string, habit data.NewHabit) (*data.NewHabitResponse, error) {
-	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userEmailAddress=%s", userEmailAddress))
+func (m *HabitsModel) CreateHabitsHandler(ctx context.Context, userID string, habit data.NewHabit) (*data.NewHabitResponse, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
 
 	if err := validation.ValidateHabit(habit, m.logger); err != nil {
 		return nil, err
 	}
 
-	userDetails, err := m.db.RetrieveUserDetails(&data.UserAuth{EmailAddress: userEmailAddress})
+	habit.Schedule = normaliseSchedule(habit.Schedule)
+
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	currentUserData, ok := userDetails.(*data.UserData)
-
-	if !ok {
-		return nil, fmt.Errorf(helper.GetFunctionName(), "data.UserData is invalid")
+	if !currentUserData.EmailVerified && m.unverifiedUserAccess != data.UnverifiedUserAccessAll {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), currentUserData.UserID, ErrEmailNotVerified)
 	}
 
-	newHabitResponse, err := m.db.CreateHabitsHandler(currentUserData.UserID, habit)
+	defer m.locks.lock(currentUserData.UserID)()
+
+	newHabitResponse, err := m.db.CreateHabitsHandler(ctx, currentUserData.UserID, habit)
 
 	if err != nil {
 		return nil, err
@@ -58,143 +70,335 @@ func (m *HabitsModel) CreateHabitsHandler(userEmailAddress string, habit data.Ne
 	return newHabitResponse, nil
 }
 
-func (m *HabitsModel) RetrieveHabitsHandler(userEmailAddress, habitId string) (data.Habit, error) {
-	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userEmailAddress=%s, habitId=%s", userEmailAddress, habitId))
-	habit := data.Habit{}
+func (m *HabitsModel) RetrieveHabitsHandler(ctx context.Context, userID, habitId string) (data.Habit, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s", userID, habitId))
+
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return data.Habit{}, err
+	}
 
-	userDetails, err := m.db.RetrieveUserDetails(&data.UserAuth{EmailAddress: userEmailAddress})
+	habit, err := m.db.RetrieveHabitsHandler(ctx, currentUserData.UserID, habitId)
 
 	if err != nil {
-		return habit, err
+		return data.Habit{}, err
 	}
 
-	currentUserData, ok := userDetails.(*data.UserData)
+	return withProgress(habit, userNow(currentUserData)), nil
+}
+
+func (m *HabitsModel) RetrieveAllHabitsHandler(ctx context.Context, userID string) ([]data.Habit, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
 
-	if !ok {
-		return data.Habit{}, fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
 	}
 
-	result, err := m.db.RetrieveHabitsHandler(currentUserData.UserID, habitId)
+	habits, err := m.db.RetrieveAllHabitsHandler(ctx, currentUserData.UserID)
 
 	if err != nil {
-		return habit, err
+		return nil, err
 	}
 
-	habit, ok = result.(data.Habit)
+	today := userNow(currentUserData)
 
-	if !ok {
-		return habit, fmt.Errorf("%s - habits type is not data.Habit", helper.GetFunctionName())
+	for i, habit := range habits {
+		habits[i] = withProgress(habit, today)
 	}
 
-	return habit, nil
+	return habits, nil
 }
 
-func (m *HabitsModel) RetrieveAllHabitsHandler(userEmailAddress string) ([]data.Habit, error) {
-	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userEmailAddress=%s", userEmailAddress))
+/*
+UpdateHabitsHandler applies the fields set in update to the stored habit and returns it with Days and Progress refreshed.
+The habit is read, merged and written under the user's lock so concurrent updates to it can't overwrite each other.
+When update.Revision is set the habit must still be at that revision, otherwise ErrRevisionMismatch is returned.
+*/
+func (m *HabitsModel) UpdateHabitsHandler(ctx context.Context, userID string, update data.UpdateHabit, habitId string) (data.Habit, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s", userID, habitId))
 
-	userDetails, err := m.db.RetrieveUserDetails(&data.UserAuth{EmailAddress: userEmailAddress})
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
-		return nil, err
+		return data.Habit{}, err
+	}
+
+	defer m.locks.lock(currentUserData.UserID)()
+
+	habit, err := m.db.RetrieveHabitsHandler(ctx, currentUserData.UserID, habitId)
+
+	if err != nil {
+		return data.Habit{}, err
+	}
+
+	if err := checkRevision(habit, update.Revision); err != nil {
+		return data.Habit{}, err
+	}
+
+	today := userNow(currentUserData)
+
+	if err := applyHabitUpdate(&habit, update, today, m.logger); err != nil {
+		return data.Habit{}, err
+	}
+
+	if err := validation.ValidateHabit(habit, m.logger); err != nil {
+		return data.Habit{}, err
+	}
+
+	habit = withProgress(habit, today)
+
+	if err := m.db.UpdateHabitsHandler(ctx, currentUserData.UserID, habitId, habit); err != nil {
+		return data.Habit{}, err
+	}
+
+	habit.Revision++
+
+	return habit, nil
+}
+
+// checkRevision returns ErrRevisionMismatch when the client expects habit to be at a revision it has moved on from
+func checkRevision(habit data.Habit, revision *int64) error {
+	if revision == nil || *revision == habit.Revision {
+		return nil
+	}
+
+	return fmt.Errorf("%s - habitId=%s is at revision %d not %d: %w", helper.GetFunctionName(), habit.HabitID, habit.Revision, *revision, db.ErrRevisionMismatch)
+}
+
+/*
+applyHabitUpdate copies the fields set in update onto habit. Days is ignored as it is computed from CompletionDates, which are validated
+against today in the user's timezone and de-duplicated.
+*/
+func applyHabitUpdate(habit *data.Habit, update data.UpdateHabit, today time.Time, logger logger.ILogger) error {
+	if update.Name != nil {
+		habit.Name = *update.Name
 	}
 
-	currentUserData, ok := userDetails.(*data.UserData)
+	if update.DaysTarget != nil {
+		habit.DaysTarget = *update.DaysTarget
+	}
+
+	if update.CompletionDates != nil {
+		completionDates, err := validation.ValidateCompletionDates(*update.CompletionDates, today, logger)
+
+		if err != nil {
+			return err
+		}
+
+		habit.CompletionDates = completionDates
+	}
 
-	if !ok {
-		return nil, fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	if update.Schedule != nil {
+		habit.Schedule = *update.Schedule
 	}
 
-	result, err := m.db.RetrieveAllHabitsHandler(currentUserData.UserID)
+	if update.Unit != nil {
+		habit.Unit = *update.Unit
+	}
+
+	if update.DailyGoal != nil {
+		habit.DailyGoal = *update.DailyGoal
+	}
+
+	return nil
+}
+
+/*
+UpdateAllHabitsHandler applies each update to the user's habit with the same HabitID, ignoring updates for habits the user doesn't have,
+and returns all of the user's habits. Only the updated habits are written, and none are when any update.Revision doesn't match.
+*/
+func (m *HabitsModel) UpdateAllHabitsHandler(ctx context.Context, userID string, updates []data.UpdateHabit) ([]data.Habit, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userID))
+
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer m.locks.lock(currentUserData.UserID)()
+
+	habits, err := m.db.RetrieveAllHabitsHandler(ctx, currentUserData.UserID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	habits, ok := result.([]data.Habit)
+	today := userNow(currentUserData)
+	updated := []int{}
+
+	for i := range habits {
+		isUpdated := false
+
+		for _, update := range updates {
+			if update.HabitID != habits[i].HabitID {
+				continue
+			}
+
+			if err := checkRevision(habits[i], update.Revision); err != nil {
+				return nil, err
+			}
+
+			if err := applyHabitUpdate(&habits[i], update, today, m.logger); err != nil {
+				return nil, err
+			}
+
+			isUpdated = true
+		}
+
+		if isUpdated {
+			if err := validation.ValidateHabit(habits[i], m.logger); err != nil {
+				return nil, err
+			}
+
+			updated = append(updated, i)
+		}
+
+		habits[i] = withProgress(habits[i], today)
+	}
+
+	updatedHabits := make([]data.Habit, len(updated))
+
+	for i, index := range updated {
+		updatedHabits[i] = habits[index]
+	}
+
+	if err := m.db.UpdateAllHabitsHandler(ctx, currentUserData.UserID, updatedHabits); err != nil {
+		return nil, err
+	}
 
-	if !ok {
-		return nil, fmt.Errorf("%s - habits type is not data.Habit", helper.GetFunctionName())
+	for _, index := range updated {
+		habits[index].Revision++
 	}
 
 	return habits, nil
 }
 
-func (m *HabitsModel) UpdateHabitsHandler(userEmailAddress string, habit data.Habit, habitId string) error {
-	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userEmailAddress=%s, habitId=%s", userEmailAddress, habitId))
+// DeleteHabitsHandler deletes the habit. When revision is set the habit must still be at that revision, otherwise ErrRevisionMismatch is returned
+func (m *HabitsModel) DeleteHabitsHandler(ctx context.Context, userID, habitId string, revision *int64) error {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s", userID, habitId))
 
-	userDetails, err := m.db.RetrieveUserDetails(&data.UserAuth{EmailAddress: userEmailAddress})
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
 		return err
 	}
 
-	currentUserData, ok := userDetails.(*data.UserData)
+	defer m.locks.lock(currentUserData.UserID)()
+
+	habit, err := m.db.RetrieveHabitsHandler(ctx, currentUserData.UserID, habitId)
 
-	if !ok {
-		return fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	if err != nil {
+		return err
 	}
 
-	if err := validation.ValidateHabit(habit, m.logger); err != nil {
+	if err := checkRevision(habit, revision); err != nil {
 		return err
 	}
 
-	if err := m.db.UpdateHabitsHandler(currentUserData.UserID, habitId, habit); err != nil {
+	if err := m.db.DeleteHabitsHandler(ctx, currentUserData.UserID, habitId, habit.Revision); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (m *HabitsModel) UpdateAllHabitsHandler(userEmailAddress string, habits *[]data.Habit) error {
-	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userEmailAddress=%s", userEmailAddress))
+/*
+CreateCompletionHandler marks the habit as completed on completionDate, or today when it is empty, and returns the recomputed habit.
+A measurable habit instead records value against completionDate, and is only completed once that day's values reach its DailyGoal.
+*/
+func (m *HabitsModel) CreateCompletionHandler(ctx context.Context, userID, habitId, completionDate string, value *float64) (data.Habit, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userID, habitId, completionDate))
 
-	userDetails, err := m.db.RetrieveUserDetails(&data.UserAuth{EmailAddress: userEmailAddress})
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
-		return err
+		return data.Habit{}, err
 	}
 
-	currentUserData, ok := userDetails.(*data.UserData)
+	today := userNow(currentUserData)
 
-	if !ok {
-		return fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	if completionDate == "" {
+		completionDate = today.Format(data.CompletionDateLayout)
 	}
 
-	if !ok {
-		return fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	if err := validation.ValidateCompletionDate(completionDate, today, m.logger); err != nil {
+		return data.Habit{}, err
 	}
 
-	for _, habit := range *habits {
-		if err := validation.ValidateHabit(habit, m.logger); err != nil {
-			return err
+	// Held from reading whether the habit is measurable until the completion is written
+	defer m.locks.lock(currentUserData.UserID)()
+
+	habit, err := m.db.RetrieveHabitsHandler(ctx, currentUserData.UserID, habitId)
+
+	if err != nil {
+		return data.Habit{}, err
+	}
+
+	if habit.DailyGoal <= 0 {
+		if value != nil {
+			m.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("habitId=%s isn't measurable so can't take a value", habitId))
+			return data.Habit{}, fmt.Errorf("%s - habitId=%s %w", helper.GetFunctionName(), habitId, validation.ErrInvalidCompletionValue.WithFields(apperror.FieldError{Field: "value", Message: "The habit has no daily goal so can't take a value"}))
 		}
+
+		habit, err = m.db.CreateCompletionHandler(ctx, currentUserData.UserID, habitId, completionDate)
+	} else {
+		if value == nil {
+			m.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("habitId=%s is measurable so needs a value", habitId))
+			return data.Habit{}, fmt.Errorf("%s - habitId=%s %w", helper.GetFunctionName(), habitId, validation.ErrInvalidCompletionValue.WithFields(apperror.FieldError{Field: "value", Message: "The habit has a daily goal so needs a value"}))
+		}
+
+		if err := validation.ValidateCompletionValue(*value, m.logger); err != nil {
+			return data.Habit{}, err
+		}
+
+		habit, err = m.db.CreateMeasurementHandler(ctx, currentUserData.UserID, habitId, data.HabitMeasurement{Date: completionDate, Value: *value})
 	}
 
-	if err := m.db.UpdateAllHabitsHandler(currentUserData.UserID, *habits); err != nil {
-		return err
+	if err != nil {
+		return data.Habit{}, err
 	}
 
-	return nil
+	return withProgress(habit, today), nil
 }
 
-func (m *HabitsModel) DeleteHabitsHandler(userEmailAddress, habitId string) error {
-	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userEmailAddress=%s, habitId=%s", userEmailAddress, habitId))
+// DeleteCompletionHandler undoes a completion on completionDate and returns the recomputed habit
+func (m *HabitsModel) DeleteCompletionHandler(ctx context.Context, userID, habitId, completionDate string) (data.Habit, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s, habitId=%s, completionDate=%s", userID, habitId, completionDate))
 
-	userDetails, err := m.db.RetrieveUserDetails(&data.UserAuth{EmailAddress: userEmailAddress})
+	currentUserData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
 
 	if err != nil {
-		return err
+		return data.Habit{}, err
 	}
 
-	currentUserData, ok := userDetails.(*data.UserData)
+	today := userNow(currentUserData)
 
-	if !ok {
-		return fmt.Errorf("%s - data.UserData is invalid", helper.GetFunctionName())
+	if err := validation.ValidateCompletionDate(completionDate, today, m.logger); err != nil {
+		return data.Habit{}, err
 	}
 
-	if err := m.db.DeleteHabitsHandler(currentUserData.UserID, habitId); err != nil {
-		return err
+	defer m.locks.lock(currentUserData.UserID)()
+
+	habit, err := m.db.DeleteCompletionHandler(ctx, currentUserData.UserID, habitId, completionDate)
+
+	if err != nil {
+		return data.Habit{}, err
 	}
 
-	return nil
+	return withProgress(habit, today), nil
+}
+
+// userNow is the current time in the user's timezone, so "today" and completion dates follow the user's calendar rather than the server's
+func userNow(userData *data.UserData) time.Time {
+	location, err := time.LoadLocation(userData.Timezone)
+
+	if err != nil {
+		location = time.UTC
+	}
+
+	return time.Now().In(location)
 }
@@ -1,36 +1,42 @@
 package model
 
 import (
+	"context"
+	"dohabits/apperror"
 	"dohabits/data"
 	"dohabits/db"
 	"dohabits/helper"
 	"dohabits/logger"
+	"dohabits/validation"
+	"errors"
 	"reflect"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestCreateHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	model := NewHabitsModel(logger, db)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 
 	testCases := []struct {
-		name             string
-		userEmailAddress string
-		newHabit         data.NewHabit
-		want             *data.NewHabitResponse
+		name     string
+		userID   string
+		newHabit data.NewHabit
+		want     *data.NewHabitResponse
 	}{
 		{
-			name:             "Successfully Create Habit",
-			userEmailAddress: "johndoe1@example.com",
-			newHabit:         data.NewHabit{Name: "Create Habit Test", DaysTarget: 11},
-			want:             &data.NewHabitResponse{HabitID: "0", Name: "Create Habit Test", DaysTarget: 11},
+			name:     "Successfully Create Habit",
+			userID:   "1",
+			newHabit: data.NewHabit{Name: "Create Habit Test", DaysTarget: 11},
+			want:     &data.NewHabitResponse{HabitID: "0", Name: "Create Habit Test", DaysTarget: 11, Schedule: data.HabitSchedule{Type: data.ScheduleDaily}, Revision: 1},
 		},
 	}
 
 	for _, val := range testCases {
 		t.Run(val.name, func(t *testing.T) {
-			newHabitResponse, err := model.CreateHabitsHandler(val.userEmailAddress, val.newHabit)
+			newHabitResponse, err := model.CreateHabitsHandler(context.Background(), val.userID, val.newHabit)
 
 			if err != nil {
 				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
@@ -59,25 +65,25 @@ func TestCreateHabitsHandler(t *testing.T) {
 func TestRetrieveHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	model := NewHabitsModel(logger, db)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 
 	testCases := []struct {
-		name             string
-		userEmailAddress string
-		habitId          string
-		want             data.Habit
+		name    string
+		userID  string
+		habitId string
+		want    data.Habit
 	}{
 		{
-			name:             "Get Habit Successfully",
-			userEmailAddress: "johndoe1@example.com",
-			habitId:          data.MockHabit[0].HabitID,
-			want:             data.MockHabit[0],
+			name:    "Get Habit Successfully",
+			userID:  "1",
+			habitId: data.MockHabit[0].HabitID,
+			want:    withProgress(data.MockHabit[0], time.Now()),
 		},
 	}
 
 	for _, val := range testCases {
 		t.Run(val.name, func(t *testing.T) {
-			habit, err := model.RetrieveHabitsHandler(val.userEmailAddress, val.habitId)
+			habit, err := model.RetrieveHabitsHandler(context.Background(), val.userID, val.habitId)
 
 			if err != nil {
 				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
@@ -97,31 +103,31 @@ func TestRetrieveHabitsHandler(t *testing.T) {
 func TestRetrieveAllHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	model := NewHabitsModel(logger, db)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 
 	var mockHabitForUserID1 []data.Habit
 
 	for _, habit := range data.MockHabit {
 		if habit.UserID == "1" {
-			mockHabitForUserID1 = append(mockHabitForUserID1, habit)
+			mockHabitForUserID1 = append(mockHabitForUserID1, withProgress(habit, time.Now()))
 		}
 	}
 
 	testCases := []struct {
-		name             string
-		userEmailAddress string
-		want             []data.Habit
+		name   string
+		userID string
+		want   []data.Habit
 	}{
 		{
-			name:             "Get All Habits Successfully",
-			userEmailAddress: "johndoe1@example.com",
-			want:             mockHabitForUserID1,
+			name:   "Get All Habits Successfully",
+			userID: "1",
+			want:   mockHabitForUserID1,
 		},
 	}
 
 	for _, val := range testCases {
 		t.Run(val.name, func(t *testing.T) {
-			habits, err := model.RetrieveAllHabitsHandler(val.userEmailAddress)
+			habits, err := model.RetrieveAllHabitsHandler(context.Background(), val.userID)
 
 			if err != nil {
 				t.Errorf("TestRetrieve Failed - err=%s", err)
@@ -143,52 +149,131 @@ func TestRetrieveAllHabitsHandler(t *testing.T) {
 func TestUpdateHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	model := NewHabitsModel(logger, db)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+
+	name, days, daysTarget := "Pray everday", 12, 30
 
 	testCases := []struct {
-		name             string
-		userEmailAddress string
-		updateHabit      data.Habit
-		habitId          string
+		name        string
+		userID      string
+		updateHabit data.UpdateHabit
+		habitId     string
 	}{
 		{
-			name:             "Update Habit Successfully",
-			userEmailAddress: "johndoe1@example.com",
-			updateHabit:      data.Habit{Name: "Pray everday", Days: 12, DaysTarget: 30},
-			habitId:          "1",
+			name:        "Update Habit Successfully",
+			userID:      "1",
+			updateHabit: data.UpdateHabit{Name: &name, Days: &days, DaysTarget: &daysTarget},
+			habitId:     "1",
 		},
 	}
 
 	for _, val := range testCases {
 		t.Run(val.name, func(t *testing.T) {
-			if err := model.UpdateHabitsHandler(val.userEmailAddress, val.updateHabit, val.habitId); err != nil {
+			habit, err := model.UpdateHabitsHandler(context.Background(), val.userID, val.updateHabit, val.habitId)
+
+			if err != nil {
 				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
 				return
 			}
+
+			if habit.Name != name || habit.DaysTarget != daysTarget || habit.Days != habit.Progress.TotalCompletions {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), habit)
+			}
+		})
+	}
+}
+
+func TestUpdateHabitsHandlerCompletionDates(t *testing.T) {
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	defer func() {
+		data.MockHabit = originalMockHabitState
+	}()
+
+	logger := logger.NewLogger(0)
+	model := NewHabitsModel(logger, db.NewMockDB(logger), data.UnverifiedUserAccessAll)
+	twoDaysAgo := time.Now().UTC().AddDate(0, 0, -2).Format(data.CompletionDateLayout)
+	future := time.Now().UTC().AddDate(0, 0, 2).Format(data.CompletionDateLayout)
+
+	testCases := []struct {
+		name            string
+		completionDates []string
+		want            []string
+		wantFields      []string
+	}{
+		{
+			name:            "Duplicates are removed",
+			completionDates: []string{"2025-01-01", twoDaysAgo, "2025-01-01"},
+			want:            []string{"2025-01-01", twoDaysAgo},
+		},
+		{
+			name:            "Every invalid date is reported",
+			completionDates: []string{"2025-01-01", future, "0001-01-01", "01/02/2025"},
+			wantFields:      []string{"completionDates[1]", "completionDates[2]", "completionDates[3]"},
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			habit, err := model.UpdateHabitsHandler(context.Background(), "1", data.UpdateHabit{CompletionDates: &val.completionDates}, "1")
+
+			if val.wantFields == nil {
+				if err != nil || !reflect.DeepEqual(habit.CompletionDates, val.want) {
+					t.Errorf("%s - Failed - got=%v, want=%v, err=%v", helper.GetFunctionName(), habit.CompletionDates, val.want, err)
+				}
+
+				return
+			}
+
+			var appErr *apperror.Error
+
+			if !errors.Is(err, validation.ErrInvalidCompletionDate) || !errors.As(err, &appErr) {
+				t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, validation.ErrInvalidCompletionDate)
+			}
+
+			fields := []string{}
+
+			for _, field := range appErr.Fields {
+				fields = append(fields, field.Field)
+			}
+
+			if !reflect.DeepEqual(fields, val.wantFields) {
+				t.Errorf("%s - Failed - got fields=%v, want=%v", helper.GetFunctionName(), fields, val.wantFields)
+			}
 		})
 	}
+
+	// UpdateAllHabitsHandler validates them too, and writes none of the updates
+	completionDates := []string{future}
+
+	if _, err := model.UpdateAllHabitsHandler(context.Background(), "1", []data.UpdateHabit{{HabitID: "1", CompletionDates: &completionDates}}); !errors.Is(err, validation.ErrInvalidCompletionDate) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, validation.ErrInvalidCompletionDate)
+	}
 }
 
 func TestUpdateAllHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	model := NewHabitsModel(logger, db)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+
+	name, days, daysTarget := "Pray everday", 12, 30
 
 	testCases := []struct {
-		name             string
-		userEmailAddress string
-		updateHabit      []data.Habit
-		habitId          string
+		name        string
+		userID      string
+		updateHabit []data.UpdateHabit
+		habitId     string
 	}{
 		{
-			name:             "Update Habit Successfully",
-			userEmailAddress: "johndoe1@example.com",
-			updateHabit: []data.Habit{
+			name:   "Update Habit Successfully",
+			userID: "1",
+			updateHabit: []data.UpdateHabit{
 				{
-					HabitID: "1", Name: "Pray everday", Days: 12, DaysTarget: 30,
+					HabitID: "1", Name: &name, Days: &days, DaysTarget: &daysTarget,
 				},
 				{
-					HabitID: "2", Name: "Pray everday", Days: 12, DaysTarget: 30,
+					HabitID: "2", Name: &name, Days: &days, DaysTarget: &daysTarget,
 				},
 			},
 			habitId: "1",
@@ -197,10 +282,18 @@ func TestUpdateAllHabitsHandler(t *testing.T) {
 
 	for _, val := range testCases {
 		t.Run(val.name, func(t *testing.T) {
-			if err := model.UpdateAllHabitsHandler(val.userEmailAddress, &val.updateHabit); err != nil {
+			habits, err := model.UpdateAllHabitsHandler(context.Background(), val.userID, val.updateHabit)
+
+			if err != nil {
 				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
 				return
 			}
+
+			for _, habit := range habits {
+				if habit.HabitID == val.habitId && (habit.Name != name || habit.DaysTarget != daysTarget) {
+					t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), habit)
+				}
+			}
 		})
 	}
 }
@@ -208,32 +301,32 @@ func TestUpdateAllHabitsHandler(t *testing.T) {
 func TestDeleteHabitsHandler(t *testing.T) {
 	logger := logger.NewLogger(0)
 	db := db.NewMockDB(logger)
-	model := NewHabitsModel(logger, db)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
 
 	testCases := []struct {
-		name             string
-		userEmailAddress string
-		habitId          string
-		wantErr          bool
-		got              bool
+		name    string
+		userID  string
+		habitId string
+		wantErr bool
+		got     bool
 	}{
 		{
-			name:             "Delete Habit Successfully",
-			userEmailAddress: "johndoe1@example.com",
-			habitId:          "1",
-			wantErr:          false,
+			name:    "Delete Habit Successfully",
+			userID:  "1",
+			habitId: "1",
+			wantErr: false,
 		},
 		{
-			name:             "Delete Habit Failed",
-			userEmailAddress: "1",
-			habitId:          "9",
-			wantErr:          true,
+			name:    "Delete Habit Failed",
+			userID:  "1",
+			habitId: "9",
+			wantErr: true,
 		},
 	}
 
 	for _, val := range testCases {
 		t.Run(val.name, func(t *testing.T) {
-			if err := model.DeleteHabitsHandler(val.userEmailAddress, val.habitId); err != nil {
+			if err := model.DeleteHabitsHandler(context.Background(), val.userID, val.habitId, nil); err != nil {
 				val.got = true
 			}
 
@@ -244,3 +337,305 @@ func TestDeleteHabitsHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateCompletionHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	today := time.Now().Format(data.CompletionDateLayout)
+	value := 2.0
+
+	testCases := []struct {
+		name           string
+		userID         string
+		habitId        string
+		completionDate string
+		value          *float64
+		wantDate       string
+		wantErr        bool
+	}{
+		{
+			name:     "Check in today by default",
+			userID:   "1",
+			habitId:  "2",
+			wantDate: today,
+		},
+		{
+			name:           "Check in a past date",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: "2025-01-01",
+			wantDate:       "2025-01-01",
+		},
+		{
+			name:           "Duplicate date",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: "2025-01-01",
+			wantErr:        true,
+		},
+		{
+			name:           "Future date",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: time.Now().AddDate(0, 0, 2).Format(data.CompletionDateLayout),
+			wantErr:        true,
+		},
+		{
+			name:           "Invalid date",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: "2025-02-30",
+			wantErr:        true,
+		},
+		{
+			name:           "Habit belongs to another user",
+			userID:         "2",
+			habitId:        "2",
+			completionDate: "2025-01-02",
+			wantErr:        true,
+		},
+		{
+			name:           "Value on a habit without a daily goal",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: "2025-01-03",
+			value:          &value,
+			wantErr:        true,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			habit, err := model.CreateCompletionHandler(context.Background(), val.userID, val.habitId, val.completionDate, val.value)
+
+			if val.wantErr != (err != nil) {
+				t.Errorf("%s - Failed - want=%v, err=%v", helper.GetFunctionName(), val.wantErr, err)
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			if habit.CompletionDates[len(habit.CompletionDates)-1] != val.wantDate || habit.Days != habit.Progress.TotalCompletions {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), habit)
+			}
+		})
+	}
+
+	data.MockHabit = originalMockHabitState
+}
+
+func TestCreateCompletionHandlerUsesUserTimezone(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	// UTC+14, so it's always a day ahead of most servers for part of the day
+	const timezone = "Pacific/Kiritimati"
+
+	if err := db.UpdateUserTimezone(context.Background(), "1", timezone); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	location, err := time.LoadLocation(timezone)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	habit, err := model.CreateCompletionHandler(context.Background(), "1", "2", "", nil)
+	today := time.Now().In(location).Format(data.CompletionDateLayout)
+
+	if err != nil || !slices.Contains(habit.CompletionDates, today) {
+		t.Errorf("%s - Failed - want %s in the user's timezone - got=%+v, err=%v", helper.GetFunctionName(), today, habit.CompletionDates, err)
+	}
+
+	data.MockHabit = originalMockHabitState
+	data.MockUsers = originalMockUsersState
+}
+
+func TestCreateCompletionHandlerMeasurable(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	for i, habit := range data.MockHabit {
+		if habit.HabitID == "2" {
+			data.MockHabit[i].Unit = "km"
+			data.MockHabit[i].DailyGoal = 3
+		}
+	}
+
+	ctx := context.Background()
+	value := 1.5
+
+	if _, err := model.CreateCompletionHandler(ctx, "1", "2", "2025-01-01", nil); !errors.Is(err, validation.ErrInvalidCompletionValue) {
+		t.Errorf("%s - Failed - a measurable habit should need a value - err=%v", helper.GetFunctionName(), err)
+	}
+
+	habit, err := model.CreateCompletionHandler(ctx, "1", "2", "2025-01-01", &value)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if !reflect.DeepEqual(habit.Progress.PartialDays, []data.HabitDayTotal{{Date: "2025-01-01", Value: 1.5}}) || habit.Progress.TotalCompletions != 3 {
+		t.Errorf("%s - Failed - first measurement shouldn't meet the goal - got=%+v", helper.GetFunctionName(), habit.Progress)
+	}
+
+	habit, err = model.CreateCompletionHandler(ctx, "1", "2", "2025-01-01", &value)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if len(habit.Progress.PartialDays) != 0 || habit.Progress.TotalCompletions != 4 || habit.Days != 4 {
+		t.Errorf("%s - Failed - measurements adding up to the goal should complete the day - got=%+v", helper.GetFunctionName(), habit.Progress)
+	}
+
+	habit, err = model.DeleteCompletionHandler(ctx, "1", "2", "2025-01-01")
+
+	if err != nil || len(habit.Measurements) != 0 || habit.Progress.TotalCompletions != 3 {
+		t.Errorf("%s - Failed - undo should remove the day's measurements - got=%+v, err=%v", helper.GetFunctionName(), habit, err)
+	}
+
+	data.MockHabit = originalMockHabitState
+}
+
+func TestDeleteCompletionHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	model := NewHabitsModel(logger, db, data.UnverifiedUserAccessAll)
+
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	testCases := []struct {
+		name           string
+		userID         string
+		habitId        string
+		completionDate string
+		wantErr        bool
+	}{
+		{
+			name:           "Undo completion successfully",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: "2024-12-02",
+		},
+		{
+			name:           "Date isn't completed",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: "2024-12-02",
+			wantErr:        true,
+		},
+		{
+			name:           "Invalid date",
+			userID:         "1",
+			habitId:        "2",
+			completionDate: "02-12-2024",
+			wantErr:        true,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			habit, err := model.DeleteCompletionHandler(context.Background(), val.userID, val.habitId, val.completionDate)
+
+			if val.wantErr != (err != nil) {
+				t.Errorf("%s - Failed - want=%v, err=%v", helper.GetFunctionName(), val.wantErr, err)
+				return
+			}
+
+			if err == nil && slices.Contains(habit.CompletionDates, val.completionDate) {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), habit)
+			}
+		})
+	}
+
+	data.MockHabit = originalMockHabitState
+}
+
+func TestHabitRevisions(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	model := NewHabitsModel(logger, mockDB, data.UnverifiedUserAccessAll)
+	ctx := context.Background()
+
+	// Make a deep copy of the original state
+	originalMockHabitState := make([]data.Habit, len(data.MockHabit))
+	copy(originalMockHabitState, data.MockHabit)
+
+	defer func() { data.MockHabit = originalMockHabitState }()
+
+	const userID = "1"
+
+	habit, err := model.RetrieveHabitsHandler(ctx, userID, "2")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	retrieved := habit.Revision
+	name := "Code every weekday"
+
+	updated, err := model.UpdateHabitsHandler(ctx, userID, data.UpdateHabit{Name: &name, Revision: &retrieved}, "2")
+
+	if err != nil || updated.Revision != retrieved+1 {
+		t.Fatalf("%s - Failed - update at the current revision got revision=%d, err=%v", helper.GetFunctionName(), updated.Revision, err)
+	}
+
+	staleName := "Overwritten"
+
+	if _, err := model.UpdateHabitsHandler(ctx, userID, data.UpdateHabit{Name: &staleName, Revision: &retrieved}, "2"); !errors.Is(err, db.ErrRevisionMismatch) {
+		t.Errorf("%s - Failed - stale update err=%v, want=%v", helper.GetFunctionName(), err, db.ErrRevisionMismatch)
+	}
+
+	if _, err := model.UpdateAllHabitsHandler(ctx, userID, []data.UpdateHabit{
+		{HabitID: "1", Name: &staleName},
+		{HabitID: "2", Name: &staleName, Revision: &retrieved},
+	}); !errors.Is(err, db.ErrRevisionMismatch) {
+		t.Errorf("%s - Failed - stale bulk update err=%v, want=%v", helper.GetFunctionName(), err, db.ErrRevisionMismatch)
+	}
+
+	habits, err := model.RetrieveAllHabitsHandler(ctx, userID)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	for _, habit := range habits {
+		if habit.Name == staleName {
+			t.Errorf("%s - Failed - a stale update was written to habitId=%s", helper.GetFunctionName(), habit.HabitID)
+		}
+	}
+
+	checkedIn, err := model.CreateCompletionHandler(ctx, userID, "2", "", nil)
+
+	if err != nil || checkedIn.Revision != updated.Revision+1 {
+		t.Fatalf("%s - Failed - check-in got revision=%d, err=%v", helper.GetFunctionName(), checkedIn.Revision, err)
+	}
+
+	if err := model.DeleteHabitsHandler(ctx, userID, "2", &updated.Revision); !errors.Is(err, db.ErrRevisionMismatch) {
+		t.Errorf("%s - Failed - stale delete err=%v, want=%v", helper.GetFunctionName(), err, db.ErrRevisionMismatch)
+	}
+
+	if err := model.DeleteHabitsHandler(ctx, userID, "2", &checkedIn.Revision); err != nil {
+		t.Errorf("%s - Failed - delete at the current revision err=%v", helper.GetFunctionName(), err)
+	}
+}
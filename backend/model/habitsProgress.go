@@ -0,0 +1,279 @@
+package model
+
+import (
+	"dohabits/data"
+	"math"
+	"slices"
+	"sort"
+	"time"
+)
+
+/*
+CalculateHabitProgress works out the streaks, total completions, percent-to-target and completion rate from the habit's CompletionDates.
+Duplicate, unparsable and future dates are ignored.
+
+Streaks and the completion rate are counted in the schedule's periods: days for daily and weekday habits, Monday to Sunday weeks,
+calendar months, or Interval-day blocks starting on the day the habit began. A period is met once it has enough completions.
+The period containing today is still in progress, so until it is met it neither breaks the current streak nor lowers the completion rate.
+
+today should be in the user's timezone, as it decides which calendar day is today and the day the habit was created on.
+
+A measurable habit, one with a DailyGoal, is also completed on every day its Measurements add up to the goal.
+Days with some progress short of the goal are reported as PartialDays.
+*/
+func CalculateHabitProgress(habit data.Habit, today time.Time) data.HabitProgress {
+	progress := data.HabitProgress{StreakUnit: streakUnit(habit.Schedule)}
+	completionDates := habit.CompletionDates
+
+	if habit.DailyGoal > 0 {
+		var metDates []string
+		metDates, progress.PartialDays, progress.TodayTotal = measuredDays(habit, today)
+		completionDates = append(slices.Clone(completionDates), metDates...)
+	}
+
+	days := completionDays(completionDates, today)
+
+	progress.TotalCompletions = len(days)
+
+	if habit.DaysTarget > 0 {
+		percent := float64(progress.TotalCompletions) / float64(habit.DaysTarget) * 100
+		progress.PercentToTarget = math.Min(100, roundPercent(percent))
+	}
+
+	start, ok := habitStartDay(habit, days, today.Location())
+
+	if !ok {
+		return progress
+	}
+
+	periods := schedulePeriods(habit.Schedule, start, truncateToDay(today))
+
+	if len(periods) == 0 {
+		return progress
+	}
+
+	met := metPeriods(habit.Schedule, periods, days)
+
+	run, metCount := 0, 0
+	for _, isMet := range met {
+		if isMet {
+			run++
+			metCount++
+		} else {
+			run = 0
+		}
+
+		progress.LongestStreak = max(progress.LongestStreak, run)
+	}
+
+	elapsed := len(periods)
+	progress.CurrentStreak = run
+
+	if last := len(periods) - 1; !met[last] {
+		// Today's period is still in progress so the streak up to the previous period is kept
+		elapsed--
+
+		for i := last - 1; i >= 0 && met[i]; i-- {
+			progress.CurrentStreak++
+		}
+	}
+
+	if elapsed > 0 {
+		progress.CompletionRate = roundPercent(float64(metCount) / float64(elapsed) * 100)
+	}
+
+	return progress
+}
+
+// withProgress returns the habit with Progress populated and Days set to the total number of completed days
+func withProgress(habit data.Habit, today time.Time) data.Habit {
+	habit.Schedule = normaliseSchedule(habit.Schedule)
+	habit.Progress = CalculateHabitProgress(habit, today)
+	habit.Days = habit.Progress.TotalCompletions
+
+	return habit
+}
+
+// normaliseSchedule defaults habits stored before schedules existed, or created without one, to daily
+func normaliseSchedule(schedule data.HabitSchedule) data.HabitSchedule {
+	if schedule.Type == "" {
+		schedule.Type = data.ScheduleDaily
+	}
+
+	return schedule
+}
+
+/*
+maxProgressDays is how far back the periods go. Completions before validation.MinCompletionDate can't be recorded any more, but
+one stored before could otherwise make every read of the habit walk through thousands of years of periods.
+*/
+const maxProgressDays = 50 * 366
+
+type schedulePeriod struct {
+	start time.Time
+	end   time.Time // Exclusive
+}
+
+// schedulePeriods returns every period of the schedule from the one containing start, or maxProgressDays ago, up to the one containing today
+func schedulePeriods(schedule data.HabitSchedule, start, today time.Time) []schedulePeriod {
+	periods := []schedulePeriod{}
+
+	// Unix seconds rather than Sub, which saturates at about 292 years
+	if skip := (today.Unix()-start.Unix())/(24*60*60) - maxProgressDays; skip > 0 {
+		// Interval periods stay lined up with the day the habit started
+		if schedule.Type == data.ScheduleEveryNDays {
+			interval := int64(max(schedule.Interval, 1))
+			skip = (skip + interval - 1) / interval * interval
+		}
+
+		start = start.AddDate(0, 0, int(skip))
+	}
+
+	next := func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+
+	switch schedule.Type {
+	case data.ScheduleTimesPerWeek:
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7) // Back to Monday
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	case data.ScheduleTimesPerMonth:
+		start = time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }
+	case data.ScheduleEveryNDays:
+		interval := max(schedule.Interval, 1)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, interval) }
+	}
+
+	for periodStart := start; !periodStart.After(today); periodStart = next(periodStart) {
+		if schedule.Type == data.ScheduleWeekdays && !slices.Contains(schedule.Weekdays, periodStart.Weekday()) {
+			continue
+		}
+
+		periods = append(periods, schedulePeriod{start: periodStart, end: next(periodStart)})
+	}
+
+	return periods
+}
+
+// metPeriods reports for each period whether it has the number of completions the schedule requires
+func metPeriods(schedule data.HabitSchedule, periods []schedulePeriod, days []time.Time) []bool {
+	required := 1
+
+	if schedule.Type == data.ScheduleTimesPerWeek || schedule.Type == data.ScheduleTimesPerMonth {
+		required = max(schedule.Times, 1)
+	}
+
+	met := make([]bool, len(periods))
+	i := 0
+
+	for p, period := range periods {
+		// Skip completions that fall outside every period, e.g. on a day a weekday habit isn't scheduled
+		for i < len(days) && days[i].Before(period.start) {
+			i++
+		}
+
+		count := 0
+		for ; i < len(days) && days[i].Before(period.end); i++ {
+			count++
+		}
+
+		met[p] = count >= required
+	}
+
+	return met
+}
+
+// habitStartDay is the earlier of the day the habit was created, in location, and its first completion
+func habitStartDay(habit data.Habit, days []time.Time, location *time.Location) (time.Time, bool) {
+	if habit.CreatedAt.IsZero() {
+		if len(days) == 0 {
+			return time.Time{}, false
+		}
+
+		return days[0], true
+	}
+
+	start := truncateToDay(habit.CreatedAt.In(location))
+
+	if len(days) > 0 && days[0].Before(start) {
+		start = days[0]
+	}
+
+	return start, true
+}
+
+func streakUnit(schedule data.HabitSchedule) string {
+	switch schedule.Type {
+	case data.ScheduleTimesPerWeek:
+		return "week"
+	case data.ScheduleTimesPerMonth:
+		return "month"
+	case data.ScheduleEveryNDays:
+		return "interval"
+	default:
+		return "day"
+	}
+}
+
+func roundPercent(percent float64) float64 {
+	return math.Round(percent*100) / 100
+}
+
+/*
+measuredDays adds up a measurable habit's Measurements per day, up to and including today.
+It returns the days that met the DailyGoal, the days with some progress that didn't (leaving out days already in CompletionDates) and today's total.
+*/
+func measuredDays(habit data.Habit, today time.Time) (metDates []string, partialDays []data.HabitDayTotal, todayTotal float64) {
+	lastDate := today.Format(data.CompletionDateLayout)
+	totals := map[string]float64{}
+
+	for _, measurement := range habit.Measurements {
+		if _, err := time.Parse(data.CompletionDateLayout, measurement.Date); err != nil || measurement.Date > lastDate {
+			continue
+		}
+
+		totals[measurement.Date] += measurement.Value
+	}
+
+	for date, total := range totals {
+		// Rounded so that e.g. 0.1 + 0.2 meets a goal of 0.3
+		total = math.Round(total*1e6) / 1e6
+		totals[date] = total
+
+		if total >= habit.DailyGoal {
+			metDates = append(metDates, date)
+		} else if total > 0 && !slices.Contains(habit.CompletionDates, date) {
+			partialDays = append(partialDays, data.HabitDayTotal{Date: date, Value: total})
+		}
+	}
+
+	sort.Slice(partialDays, func(i, j int) bool { return partialDays[i].Date < partialDays[j].Date })
+
+	return metDates, partialDays, totals[lastDate]
+}
+
+// completionDays returns the distinct, valid completion dates up to and including today in ascending order
+func completionDays(completionDates []string, today time.Time) []time.Time {
+	lastDay := truncateToDay(today)
+	seen := map[time.Time]bool{}
+	days := []time.Time{}
+
+	for _, completionDate := range completionDates {
+		day, err := time.Parse(data.CompletionDateLayout, completionDate)
+
+		if err != nil || day.After(lastDay) || seen[day] {
+			continue
+		}
+
+		seen[day] = true
+		days = append(days, day)
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	return days
+}
+
+// truncateToDay returns midnight UTC of the calendar day t falls on in its own location
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"dohabits/data"
+	"dohabits/helper"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCalculateHabitProgress(t *testing.T) {
+	today := time.Date(2025, time.January, 10, 15, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name  string
+		habit data.Habit
+		want  data.HabitProgress
+	}{
+		{
+			name:  "No completions",
+			habit: data.Habit{DaysTarget: 30, CompletionDates: []string{}},
+			want:  data.HabitProgress{StreakUnit: "day"},
+		},
+		{
+			name:  "Current streak ending today",
+			habit: data.Habit{DaysTarget: 30, CompletionDates: []string{"2025-01-08", "2025-01-10", "2025-01-09"}},
+			want:  data.HabitProgress{CurrentStreak: 3, LongestStreak: 3, TotalCompletions: 3, PercentToTarget: 10, StreakUnit: "day", CompletionRate: 100},
+		},
+		{
+			name:  "Current streak ending yesterday is kept",
+			habit: data.Habit{DaysTarget: 30, CompletionDates: []string{"2025-01-08", "2025-01-09"}},
+			want:  data.HabitProgress{CurrentStreak: 2, LongestStreak: 2, TotalCompletions: 2, PercentToTarget: 6.67, StreakUnit: "day", CompletionRate: 100},
+		},
+		{
+			name:  "Streak broken before yesterday",
+			habit: data.Habit{DaysTarget: 30, CompletionDates: []string{"2025-01-01", "2025-01-02", "2025-01-03", "2025-01-07"}},
+			want:  data.HabitProgress{CurrentStreak: 0, LongestStreak: 3, TotalCompletions: 4, PercentToTarget: 13.33, StreakUnit: "day", CompletionRate: 44.44},
+		},
+		{
+			name:  "Duplicate, invalid and future dates are ignored",
+			habit: data.Habit{DaysTarget: 30, CompletionDates: []string{"2025-01-10", "2025-01-10", "not-a-date", "2025-01-11"}},
+			want:  data.HabitProgress{CurrentStreak: 1, LongestStreak: 1, TotalCompletions: 1, PercentToTarget: 3.33, StreakUnit: "day", CompletionRate: 100},
+		},
+		{
+			name:  "Percent to target is capped at 100",
+			habit: data.Habit{DaysTarget: 2, CompletionDates: []string{"2025-01-08", "2025-01-09", "2025-01-10"}},
+			want:  data.HabitProgress{CurrentStreak: 3, LongestStreak: 3, TotalCompletions: 3, PercentToTarget: 100, StreakUnit: "day", CompletionRate: 100},
+		},
+		{
+			name:  "Zero target",
+			habit: data.Habit{DaysTarget: 0, CompletionDates: []string{"2025-01-10"}},
+			want:  data.HabitProgress{CurrentStreak: 1, LongestStreak: 1, TotalCompletions: 1, PercentToTarget: 0, StreakUnit: "day", CompletionRate: 100},
+		},
+		{
+			name:  "Created without completions",
+			habit: data.Habit{DaysTarget: 30, CreatedAt: time.Date(2025, time.January, 8, 9, 0, 0, 0, time.UTC)},
+			want:  data.HabitProgress{StreakUnit: "day", CompletionRate: 0},
+		},
+		{
+			name: "Specific weekdays skip unscheduled days",
+			habit: data.Habit{
+				DaysTarget:      30,
+				Schedule:        data.HabitSchedule{Type: data.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}},
+				CompletionDates: []string{"2025-01-03", "2025-01-04", "2025-01-06", "2025-01-07", "2025-01-08", "2025-01-09"},
+			},
+			want: data.HabitProgress{CurrentStreak: 5, LongestStreak: 5, TotalCompletions: 6, PercentToTarget: 20, StreakUnit: "day", CompletionRate: 100},
+		},
+		{
+			name: "Times per week",
+			habit: data.Habit{
+				DaysTarget:      30,
+				Schedule:        data.HabitSchedule{Type: data.ScheduleTimesPerWeek, Times: 3},
+				CompletionDates: []string{"2024-12-30", "2024-12-31", "2025-01-02", "2025-01-06", "2025-01-07"},
+			},
+			want: data.HabitProgress{CurrentStreak: 1, LongestStreak: 1, TotalCompletions: 5, PercentToTarget: 16.67, StreakUnit: "week", CompletionRate: 100},
+		},
+		{
+			name: "Times per month",
+			habit: data.Habit{
+				DaysTarget:      30,
+				Schedule:        data.HabitSchedule{Type: data.ScheduleTimesPerMonth, Times: 2},
+				CompletionDates: []string{"2024-11-01", "2024-11-15", "2024-12-05", "2025-01-02", "2025-01-03"},
+			},
+			want: data.HabitProgress{CurrentStreak: 1, LongestStreak: 1, TotalCompletions: 5, PercentToTarget: 16.67, StreakUnit: "month", CompletionRate: 66.67},
+		},
+		{
+			name: "Every N days",
+			habit: data.Habit{
+				DaysTarget:      30,
+				CreatedAt:       time.Date(2025, time.January, 1, 9, 0, 0, 0, time.UTC),
+				Schedule:        data.HabitSchedule{Type: data.ScheduleEveryNDays, Interval: 3},
+				CompletionDates: []string{"2025-01-02", "2025-01-05", "2025-01-08"},
+			},
+			want: data.HabitProgress{CurrentStreak: 3, LongestStreak: 3, TotalCompletions: 3, PercentToTarget: 10, StreakUnit: "interval", CompletionRate: 100},
+		},
+		{
+			name: "Measurable days only count once the daily goal is met",
+			habit: data.Habit{
+				DaysTarget: 30,
+				DailyGoal:  2,
+				Measurements: []data.HabitMeasurement{
+					{Date: "2025-01-08", Value: 2}, {Date: "2025-01-09", Value: 1}, {Date: "2025-01-09", Value: 1.5}, {Date: "2025-01-10", Value: 0.5},
+				},
+			},
+			want: data.HabitProgress{
+				CurrentStreak: 2, LongestStreak: 2, TotalCompletions: 2, PercentToTarget: 6.67, StreakUnit: "day", CompletionRate: 100,
+				TodayTotal: 0.5, PartialDays: []data.HabitDayTotal{{Date: "2025-01-10", Value: 0.5}},
+			},
+		},
+		{
+			name: "Measurable habit keeps completion dates and ignores future measurements",
+			habit: data.Habit{
+				DaysTarget:      30,
+				DailyGoal:       0.3,
+				CompletionDates: []string{"2025-01-09"},
+				Measurements: []data.HabitMeasurement{
+					{Date: "2025-01-09", Value: 0.1}, {Date: "2025-01-10", Value: 0.1}, {Date: "2025-01-10", Value: 0.2}, {Date: "2025-01-11", Value: 5},
+				},
+			},
+			want: data.HabitProgress{CurrentStreak: 2, LongestStreak: 2, TotalCompletions: 2, PercentToTarget: 6.67, StreakUnit: "day", CompletionRate: 100, TodayTotal: 0.3},
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			got := CalculateHabitProgress(val.habit, today)
+
+			if !reflect.DeepEqual(got, val.want) {
+				t.Errorf("%s - Failed - got=%+v, want=%+v", helper.GetFunctionName(), got, val.want)
+			}
+		})
+	}
+}
+
+func TestCalculateHabitProgressUsesTodaysTimezone(t *testing.T) {
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// Created on 10 January in UTC, which is still 9 January in Los Angeles
+	habit := data.Habit{DaysTarget: 10, CreatedAt: time.Date(2025, time.January, 10, 3, 0, 0, 0, time.UTC), CompletionDates: []string{"2025-01-10"}}
+	today := time.Date(2025, time.January, 10, 12, 0, 0, 0, losAngeles)
+
+	got := CalculateHabitProgress(habit, today)
+	want := data.HabitProgress{CurrentStreak: 1, LongestStreak: 1, TotalCompletions: 1, PercentToTarget: 10, StreakUnit: "day", CompletionRate: 50}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s - Failed - got=%+v, want=%+v", helper.GetFunctionName(), got, want)
+	}
+}
+
+func TestWithProgressIgnoresClientDays(t *testing.T) {
+	today := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+	habit := data.Habit{Days: 99, DaysTarget: 10, CompletionDates: []string{"2025-01-09", "2025-01-10"}}
+
+	got := withProgress(habit, today)
+
+	if got.Days != 2 || got.Progress.TotalCompletions != 2 {
+		t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), got)
+	}
+}
+
+func TestSchedulePeriodsIsCapped(t *testing.T) {
+	today := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+	yearOne := time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		schedule data.HabitSchedule
+		start    time.Time
+	}{
+		{name: "Daily", schedule: data.HabitSchedule{Type: data.ScheduleDaily}, start: yearOne},
+		{name: "Interval", schedule: data.HabitSchedule{Type: data.ScheduleEveryNDays, Interval: 3}, start: yearOne},
+		{name: "Monthly", schedule: data.HabitSchedule{Type: data.ScheduleTimesPerMonth, Times: 2}, start: yearOne},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			periods := schedulePeriods(val.schedule, val.start, today)
+
+			if len(periods) == 0 || len(periods) > maxProgressDays+1 || periods[0].start.Before(today.AddDate(0, 0, -maxProgressDays-31)) {
+				t.Fatalf("%s - Failed - got %d periods from %s", helper.GetFunctionName(), len(periods), periods[0].start)
+			}
+
+			// Interval periods are still counted from the start day
+			if val.schedule.Type == data.ScheduleEveryNDays && (periods[0].start.Unix()-val.start.Unix())/(24*60*60)%3 != 0 {
+				t.Errorf("%s - Failed - the first period %s isn't a whole number of intervals after %s", helper.GetFunctionName(), periods[0].start, val.start)
+			}
+		})
+	}
+
+	// A completion stored before the earliest date that can be recorded now doesn't walk back to it
+	habit := data.Habit{DaysTarget: 10, CreatedAt: today, CompletionDates: []string{"0001-01-01", "2025-01-10"}}
+
+	if got := CalculateHabitProgress(habit, today); got.CurrentStreak != 1 || got.TotalCompletions != 2 {
+		t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), got)
+	}
+}
@@ -0,0 +1,284 @@
+package model
+
+import (
+	"context"
+	"crypto/rand"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/middleware/session"
+	"dohabits/totp"
+	"dohabits/validation"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// totpIssuer names the account in the user's authenticator app
+	totpIssuer = "DoHabits"
+	// recoveryCodeCount is how many recovery codes a user is given when they enable two-factor authentication
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrInvalidMFACode    = apperror.New(http.StatusUnauthorized, apperror.CodeInvalidMFACode, "The code is wrong or has expired")
+	ErrMFAAlreadyEnabled = apperror.New(http.StatusConflict, apperror.CodeMFAAlreadyEnabled, "Two-factor authentication is already enabled, disable it before enrolling again")
+	ErrMFANotEnabled     = apperror.New(http.StatusConflict, apperror.CodeMFANotEnabled, "Two-factor authentication isn't enabled")
+	ErrTOTPNotEnrolled   = apperror.New(http.StatusConflict, apperror.CodeMFANotEnabled, "Start enrolling an authenticator app before confirming it")
+
+	errMFACodeRequired = apperror.ErrInvalidRequest.WithFields(apperror.FieldError{Field: "Code", Message: "Code or RecoveryCode is required"})
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAModel struct {
+	logger        logger.ILogger
+	db            db.IDB
+	mfaTokens     session.IMFAToken
+	loginAttempts loginattempts.ILimiter
+}
+
+type IMFAModel interface {
+	EnrolTOTPHandler(ctx context.Context, userID string) (*data.TOTPEnrolment, error)
+	ConfirmTOTPHandler(ctx context.Context, userID string, confirmTOTPRequest *data.ConfirmTOTPRequest) ([]string, error)
+	DisableTOTPHandler(ctx context.Context, userID string, disableTOTPRequest *data.DisableTOTPRequest) error
+	StartMFALogin(userData *data.UserData) (string, time.Time, error)
+	CompleteMFALogin(ctx context.Context, mfaLoginRequest *data.MFALoginRequest, client data.ClientInfo) (*data.UserData, error)
+}
+
+func NewMFAModel(logger logger.ILogger, db db.IDB, mfaTokens session.IMFAToken, loginAttempts loginattempts.ILimiter) *MFAModel {
+	return &MFAModel{
+		logger:        logger,
+		db:            db,
+		mfaTokens:     mfaTokens,
+		loginAttempts: loginAttempts,
+	}
+}
+
+/*
+EnrolTOTPHandler starts enrolling an authenticator app by giving the user a new secret, which the app reads from the
+otpauth:// URI. Two-factor authentication isn't enabled until ConfirmTOTPHandler is given a code from the app, so a user who
+abandons enrolment part way isn't locked out. Enrolling again before confirming replaces the secret.
+*/
+func (m *MFAModel) EnrolTOTPHandler(ctx context.Context, userID string) (*data.TOTPEnrolment, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if userData.TOTP.Enabled {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrMFAAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.db.UpdateUserTOTP(ctx, userData.UserID, data.UserTOTP{Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	return &data.TOTPEnrolment{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, userData.EmailAddress, secret),
+	}, nil
+}
+
+// ConfirmTOTPHandler enables two-factor authentication once the user proves their app has the secret, and returns their recovery codes
+func (m *MFAModel) ConfirmTOTPHandler(ctx context.Context, userID string, confirmTOTPRequest *data.ConfirmTOTPRequest) ([]string, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if userData.TOTP.Enabled {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrMFAAlreadyEnabled)
+	}
+
+	if userData.TOTP.Secret == "" {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrTOTPNotEnrolled)
+	}
+
+	step, ok := totp.Validate(userData.TOTP.Secret, confirmTOTPRequest.Code, time.Now())
+
+	if !ok {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrInvalidMFACode)
+	}
+
+	recoveryCodes, recoveryCodeHashes, err := generateRecoveryCodes()
+
+	if err != nil {
+		return nil, err
+	}
+
+	userTOTP := data.UserTOTP{
+		Secret:        userData.TOTP.Secret,
+		Enabled:       true,
+		RecoveryCodes: recoveryCodeHashes,
+		LastUsedStep:  step, // The code just used can't log anyone in
+	}
+
+	if err := m.db.UpdateUserTOTP(ctx, userData.UserID, userTOTP); err != nil {
+		return nil, err
+	}
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Two-factor authentication enabled for userId=%s", userData.UserID))
+
+	return recoveryCodes, nil
+}
+
+// DisableTOTPHandler turns two-factor authentication off. It takes the password and a code, so a stolen session alone can't.
+func (m *MFAModel) DisableTOTPHandler(ctx context.Context, userID string, disableTOTPRequest *data.DisableTOTPRequest) error {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if !userData.TOTP.Enabled {
+		return fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrMFANotEnabled)
+	}
+
+	if !validation.VerifyUserPassword(disableTOTPRequest.Password, userData.Password) {
+		return fmt.Errorf("%s - Invalid Password: %w", helper.GetFunctionName(), validation.ErrInvalidCredentials)
+	}
+
+	if err := m.verifyCode(ctx, userData, disableTOTPRequest.Code, disableTOTPRequest.RecoveryCode); err != nil {
+		return err
+	}
+
+	if err := m.db.UpdateUserTOTP(ctx, userData.UserID, data.UserTOTP{}); err != nil {
+		return err
+	}
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Two-factor authentication disabled for userId=%s", userData.UserID))
+
+	return nil
+}
+
+// StartMFALogin returns the MFA pending token a user with two-factor authentication is given once their password is accepted
+func (m *MFAModel) StartMFALogin(userData *data.UserData) (string, time.Time, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("userId=%s", userData.UserID))
+
+	return m.mfaTokens.GenerateMFAToken(userData.UserID, userData.EmailAddress)
+}
+
+/*
+CompleteMFALogin checks the MFA pending token and the code or recovery code given with it, and returns the user to start a session
+for. Wrong codes count as failed logins, so the codes can't be guessed within the token's lifetime, or by logging in again for
+another token.
+*/
+func (m *MFAModel) CompleteMFALogin(ctx context.Context, mfaLoginRequest *data.MFALoginRequest, client data.ClientInfo) (*data.UserData, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	claims, err := m.mfaTokens.ParseMFAToken(mfaLoginRequest.MFAToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.loginAttempts.Check(ctx, claims.EmailAddress, client.IPAddress); err != nil {
+		return nil, err
+	}
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, claims.Subject)
+
+	if errors.Is(err, db.ErrUserNotFound) {
+		return nil, fmt.Errorf("%s - the user no longer exists: %w", helper.GetFunctionName(), session.ErrInvalidMFAToken)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Two-factor authentication has been disabled since the password was checked
+	if !userData.TOTP.Enabled {
+		return nil, fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), claims.Subject, session.ErrInvalidMFAToken)
+	}
+
+	if err := m.verifyCode(ctx, userData, mfaLoginRequest.Code, mfaLoginRequest.RecoveryCode); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) || errors.Is(err, db.ErrTOTPStepUsed) || errors.Is(err, db.ErrRecoveryCodeNotFound) {
+			if err := m.loginAttempts.RecordFailure(ctx, claims.EmailAddress, client.IPAddress); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, err
+	}
+
+	return userData, nil
+}
+
+// verifyCode accepts either a code from the authenticator app or a recovery code, each of which can only be used once
+func (m *MFAModel) verifyCode(ctx context.Context, userData *data.UserData, code, recoveryCode string) error {
+	switch {
+	case code != "":
+		step, ok := totp.Validate(userData.TOTP.Secret, code, time.Now())
+
+		if !ok {
+			m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Wrong two-factor code for userId=%s", userData.UserID))
+			return fmt.Errorf("%s - userId=%s: %w", helper.GetFunctionName(), userData.UserID, ErrInvalidMFACode)
+		}
+
+		return m.db.ConsumeTOTPStep(ctx, userData.UserID, step)
+	case recoveryCode != "":
+		err := m.db.ConsumeRecoveryCode(ctx, userData.UserID, helper.HashToken(normaliseRecoveryCode(recoveryCode)))
+
+		if err != nil {
+			m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Recovery code rejected for userId=%s", userData.UserID))
+			return err
+		}
+
+		m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Recovery code used by userId=%s", userData.UserID))
+
+		return nil
+	default:
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), errMFACodeRequired)
+	}
+}
+
+/*
+generateRecoveryCodes returns recoveryCodeCount random codes formatted as xxxx-xxxx-xxxx-xxxx for the user to write down, and
+the hashes that are stored. Each has 80 bits of entropy so, like the tokens helper.HashToken is for, a fast hash is enough.
+*/
+func generateRecoveryCodes() ([]string, []string, error) {
+	recoveryCodes := make([]string, 0, recoveryCodeCount)
+	recoveryCodeHashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 10)
+
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("%s - Failed to generate a recovery code: %w", helper.GetFunctionName(), err)
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+
+		recoveryCodes = append(recoveryCodes, fmt.Sprintf("%s-%s-%s-%s", code[0:4], code[4:8], code[8:12], code[12:16]))
+		recoveryCodeHashes = append(recoveryCodeHashes, helper.HashToken(code))
+	}
+
+	return recoveryCodes, recoveryCodeHashes, nil
+}
+
+// normaliseRecoveryCode lets a recovery code be typed without its dashes, with spaces or in capitals
+func normaliseRecoveryCode(recoveryCode string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(recoveryCode))
+}
@@ -0,0 +1,281 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/middleware/session"
+	"dohabits/totp"
+	"dohabits/validation"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPEnrolment(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := NewMFAModel(logger, mockDB, session.NewMFAToken(session.NewHMACKeySet("secretJwt"), logger), loginAttempts)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	defer func() { data.MockUsers = originalMockUsersState }()
+
+	// Confirming before enrolling has no secret to check the code against
+	if _, err := mfaModel.ConfirmTOTPHandler(ctx, "1", &data.ConfirmTOTPRequest{Code: "123456"}); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrTOTPNotEnrolled)
+	}
+
+	enrolment, err := mfaModel.EnrolTOTPHandler(ctx, "1")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if enrolment.Secret == "" || enrolment.URI == "" {
+		t.Fatalf("%s - Failed - got=%+v", helper.GetFunctionName(), enrolment)
+	}
+
+	// Two-factor authentication isn't enabled until the enrolment is confirmed
+	userData, err := mockDB.RetrieveUserDetails(ctx, "johndoe1@example.com")
+
+	if err != nil || userData.TOTP.Enabled {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), userData.TOTP, err)
+	}
+
+	wrongCode := totpCode(t, enrolment.Secret, totp.Step(time.Now())+10)
+
+	if _, err := mfaModel.ConfirmTOTPHandler(ctx, "1", &data.ConfirmTOTPRequest{Code: wrongCode}); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrInvalidMFACode)
+	}
+
+	recoveryCodes, err := mfaModel.ConfirmTOTPHandler(ctx, "1", &data.ConfirmTOTPRequest{Code: totpCode(t, enrolment.Secret, totp.Step(time.Now()))})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("%s - Failed - got %d recovery codes, want=%d", helper.GetFunctionName(), len(recoveryCodes), recoveryCodeCount)
+	}
+
+	userData, err = mockDB.RetrieveUserDetails(ctx, "johndoe1@example.com")
+
+	if err != nil || !userData.TOTP.Enabled || len(userData.TOTP.RecoveryCodes) != recoveryCodeCount {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), userData.TOTP, err)
+	}
+
+	// Only the hashes of the recovery codes are stored
+	for _, recoveryCodeHash := range userData.TOTP.RecoveryCodes {
+		for _, recoveryCode := range recoveryCodes {
+			if recoveryCodeHash == recoveryCode || recoveryCodeHash == normaliseRecoveryCode(recoveryCode) {
+				t.Fatalf("%s - Failed - recovery code %s is stored in plain text", helper.GetFunctionName(), recoveryCode)
+			}
+		}
+	}
+
+	if _, err := mfaModel.EnrolTOTPHandler(ctx, "1"); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrMFAAlreadyEnabled)
+	}
+}
+
+func TestMFALogin(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	keys := session.NewHMACKeySet("secretJwt")
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := NewMFAModel(logger, mockDB, session.NewMFAToken(keys, logger), loginAttempts)
+	authModel := NewAuthModel(logger, mockDB, nil, mfaModel, nil, loginAttempts, validation.DefaultPasswordPolicy(), data.UnverifiedUserAccessAll)
+	jwtTokens := session.NewJSONWebToken(keys, mockDB, logger)
+	csrfTokens := session.NewMockCSRFToken(logger)
+	client := data.ClientInfo{UserAgent: "test-agent", IPAddress: "192.0.2.1"}
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	enrolment, err := mfaModel.EnrolTOTPHandler(ctx, "1")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	step := totp.Step(time.Now())
+	recoveryCodes, err := mfaModel.ConfirmTOTPHandler(ctx, "1", &data.ConfirmTOTPRequest{Code: totpCode(t, enrolment.Secret, step)})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// The password alone only gets an MFA pending token, not a session
+	login := func() string {
+		t.Helper()
+
+		userLoggedIn, err := authModel.LoginHandler(ctx, httptest.NewRecorder(), &data.UserAuth{EmailAddress: "johndoe1@example.com", Password: "1secret?Password"}, client, jwtTokens, csrfTokens)
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		if userLoggedIn.MFAToken == "" || userLoggedIn.AccessToken != "" || userLoggedIn.RefreshToken != "" {
+			t.Fatalf("%s - Failed - got=%+v", helper.GetFunctionName(), userLoggedIn)
+		}
+
+		return userLoggedIn.MFAToken
+	}
+
+	mfaToken := login()
+
+	// The MFA pending token isn't an access token
+	if _, err := jwtTokens.ParseJSONWebToken(mfaToken); err == nil {
+		t.Fatalf("%s - Failed - the MFA token was accepted as an access token", helper.GetFunctionName())
+	}
+
+	testCases := []struct {
+		name            string
+		mfaLoginRequest *data.MFALoginRequest
+		wantErr         error
+	}{
+		{
+			name:            "Test no code",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: mfaToken},
+			wantErr:         errMFACodeRequired,
+		},
+		{
+			name:            "Test invalid MFA token",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: "not-a-jwt", Code: totpCode(t, enrolment.Secret, step+1)},
+			wantErr:         session.ErrInvalidMFAToken,
+		},
+		{
+			name:            "Test the code used to confirm the enrolment can't log in",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: mfaToken, Code: totpCode(t, enrolment.Secret, step)},
+			wantErr:         db.ErrTOTPStepUsed,
+		},
+		{
+			name:            "Test wrong code",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: mfaToken, Code: totpCode(t, enrolment.Secret, step+10)},
+			wantErr:         ErrInvalidMFACode,
+		},
+		{
+			name:            "Test code from the authenticator app",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: mfaToken, Code: totpCode(t, enrolment.Secret, step+1)},
+		},
+		{
+			name:            "Test the same code can't be used twice",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: mfaToken, Code: totpCode(t, enrolment.Secret, step+1)},
+			wantErr:         db.ErrTOTPStepUsed,
+		},
+		{
+			name:            "Test recovery code typed in capitals without dashes",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: mfaToken, RecoveryCode: typedRecoveryCode(recoveryCodes[0])},
+		},
+		{
+			name:            "Test the same recovery code can't be used twice",
+			mfaLoginRequest: &data.MFALoginRequest{MFAToken: mfaToken, RecoveryCode: recoveryCodes[0]},
+			wantErr:         db.ErrRecoveryCodeNotFound,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			userLoggedIn, err := authModel.LoginMFAHandler(ctx, httptest.NewRecorder(), val.mfaLoginRequest, client, jwtTokens, csrfTokens)
+
+			if val.wantErr != nil {
+				if !errors.Is(err, val.wantErr) {
+					t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+				return
+			}
+
+			if !userLoggedIn.Success || userLoggedIn.AccessToken == "" || userLoggedIn.RefreshToken == "" {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), userLoggedIn)
+			}
+		})
+	}
+
+	// Disabling two-factor authentication takes the password as well as a code
+	disableTestCases := []struct {
+		name               string
+		disableTOTPRequest *data.DisableTOTPRequest
+		wantErr            error
+	}{
+		{
+			name:               "Test wrong password",
+			disableTOTPRequest: &data.DisableTOTPRequest{Password: "wrong", RecoveryCode: recoveryCodes[1]},
+			wantErr:            validation.ErrInvalidCredentials,
+		},
+		{
+			name:               "Test no code",
+			disableTOTPRequest: &data.DisableTOTPRequest{Password: "1secret?Password"},
+			wantErr:            errMFACodeRequired,
+		},
+		{
+			name:               "Test password and recovery code",
+			disableTOTPRequest: &data.DisableTOTPRequest{Password: "1secret?Password", RecoveryCode: recoveryCodes[1]},
+		},
+		{
+			name:               "Test it's already disabled",
+			disableTOTPRequest: &data.DisableTOTPRequest{Password: "1secret?Password", RecoveryCode: recoveryCodes[2]},
+			wantErr:            ErrMFANotEnabled,
+		},
+	}
+
+	for _, val := range disableTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			err := mfaModel.DisableTOTPHandler(ctx, "1", val.disableTOTPRequest)
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+
+	// With two-factor authentication disabled the MFA pending token no longer completes a login, and the password is enough again
+	if _, err := authModel.LoginMFAHandler(ctx, httptest.NewRecorder(), &data.MFALoginRequest{MFAToken: mfaToken, RecoveryCode: recoveryCodes[3]}, client, jwtTokens, csrfTokens); !errors.Is(err, session.ErrInvalidMFAToken) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, session.ErrInvalidMFAToken)
+	}
+
+	userLoggedIn, err := authModel.LoginHandler(ctx, httptest.NewRecorder(), &data.UserAuth{EmailAddress: "johndoe1@example.com", Password: "1secret?Password"}, client, jwtTokens, csrfTokens)
+
+	if err != nil || userLoggedIn.MFAToken != "" || userLoggedIn.AccessToken == "" {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), userLoggedIn, err)
+	}
+}
+
+// totpCode returns the code the authenticator app shows for step
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, step)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	return code
+}
+
+// typedRecoveryCode returns recoveryCode the way a user might type it
+func typedRecoveryCode(recoveryCode string) string {
+	return strings.ToUpper(strings.ReplaceAll(recoveryCode, "-", ""))
+}
@@ -0,0 +1,263 @@
+package model
+
+import (
+	"context"
+	"crypto/subtle"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/oidc"
+	"dohabits/validation"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrOIDCProviderNotFound = apperror.New(http.StatusNotFound, apperror.CodeOIDCProviderNotFound, "Identity provider isn't configured")
+	ErrOIDCEmailNotVerified = apperror.New(http.StatusForbidden, apperror.CodeEmailNotVerified, "The identity provider hasn't verified your email address")
+)
+
+// The name a new user gets when the provider doesn't have one registration would accept. They can change it on their profile.
+const (
+	oidcFallbackFirstName = "DoHabits"
+	oidcFallbackLastName  = "User"
+)
+
+// OIDCModel calls the providers without holding a lock, and only serialises finding, creating or linking the account, per email address
+type OIDCModel struct {
+	logger      logger.ILogger
+	db          db.IDB
+	stateTokens session.IOIDCStateToken
+	providers   map[string]oidc.IProvider
+	locks       *userLocks
+}
+
+type IOIDCModel interface {
+	StartOIDCLogin(ctx context.Context, providerName string) (*data.OIDCLoginStart, error)
+	CompleteOIDCLogin(ctx context.Context, oidcLoginRequest *data.OIDCLoginRequest) (*data.UserData, error)
+}
+
+func NewOIDCModel(logger logger.ILogger, db db.IDB, stateTokens session.IOIDCStateToken, providers map[string]oidc.IProvider) *OIDCModel {
+	return &OIDCModel{
+		logger:      logger,
+		db:          db,
+		stateTokens: stateTokens,
+		providers:   providers,
+		locks:       newUserLocks(),
+	}
+}
+
+// StartOIDCLogin returns where to send the user to sign in with the provider, and the state token to keep until they come back
+func (om *OIDCModel) StartOIDCLogin(ctx context.Context, providerName string) (*data.OIDCLoginStart, error) {
+	om.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("provider=%s", providerName))
+
+	provider, ok := om.providers[providerName]
+
+	if !ok {
+		return nil, fmt.Errorf("%s - provider=%s: %w", helper.GetFunctionName(), providerName, ErrOIDCProviderNotFound)
+	}
+
+	state, err := helper.RandomID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := helper.RandomID()
+
+	if err != nil {
+		return nil, err
+	}
+
+	codeVerifier, err := oidc.NewCodeVerifier()
+
+	if err != nil {
+		return nil, err
+	}
+
+	authorizationURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(codeVerifier))
+
+	if err != nil {
+		return nil, err
+	}
+
+	stateToken, expiresAt, err := om.stateTokens.GenerateOIDCStateToken(providerName, state, nonce, codeVerifier)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &data.OIDCLoginStart{
+		AuthorizationURL: authorizationURL,
+		StateToken:       stateToken,
+		ExpiresAt:        expiresAt,
+	}, nil
+}
+
+/*
+CompleteOIDCLogin exchanges the code the user came back from the provider with for their ID token and returns the user with
+its verified email address. A user who doesn't have an account yet gets one without a password, and can set one with
+/password/forgot. An existing account is linked by its email address, which is lowercased first as providers don't agree on case. An account that was never verified may have been registered
+by someone else before the owner of the address, so linking it clears its password and MFA and revokes its sessions and personal
+access tokens before verifying it.
+*/
+func (om *OIDCModel) CompleteOIDCLogin(ctx context.Context, oidcLoginRequest *data.OIDCLoginRequest) (*data.UserData, error) {
+	om.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("provider=%s", oidcLoginRequest.Provider))
+
+	provider, ok := om.providers[oidcLoginRequest.Provider]
+
+	if !ok {
+		return nil, fmt.Errorf("%s - provider=%s: %w", helper.GetFunctionName(), oidcLoginRequest.Provider, ErrOIDCProviderNotFound)
+	}
+
+	stateClaims, err := om.stateTokens.ParseOIDCStateToken(oidcLoginRequest.StateToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The state proves the user came back from the sign in this browser started, rather than a link someone else sent them
+	if stateClaims.Provider != oidcLoginRequest.Provider || subtle.ConstantTimeCompare([]byte(stateClaims.State), []byte(oidcLoginRequest.State)) != 1 {
+		return nil, fmt.Errorf("%s - state doesn't match: %w", helper.GetFunctionName(), session.ErrInvalidOIDCState)
+	}
+
+	rawIDToken, err := provider.Exchange(ctx, oidcLoginRequest.Code, stateClaims.CodeVerifier)
+
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := provider.VerifyIDToken(ctx, rawIDToken, stateClaims.Nonce)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Only an address the provider has verified can be trusted to find the user's account, otherwise anyone could claim it
+	if idToken.Email == "" || !idToken.EmailVerified {
+		om.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Provider %s sign in for subject %s refused as the email address isn't verified", oidcLoginRequest.Provider, idToken.Subject))
+		return nil, fmt.Errorf("%s - subject=%s: %w", helper.GetFunctionName(), idToken.Subject, ErrOIDCEmailNotVerified)
+	}
+
+	emailAddress := strings.ToLower(strings.TrimSpace(idToken.Email))
+
+	// Two sign ins for the same address at once would otherwise both create the user, or both claim it
+	defer om.locks.lock(emailAddress)()
+
+	userData, err := om.db.RetrieveUserDetails(ctx, emailAddress)
+
+	if errors.Is(err, db.ErrUserNotFound) {
+		return om.createUser(ctx, oidcLoginRequest.Provider, emailAddress, idToken)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !userData.EmailVerified {
+		if err := om.claimUnverifiedUser(ctx, oidcLoginRequest.Provider, idToken, userData); err != nil {
+			return nil, err
+		}
+	}
+
+	om.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Provider %s subject %s signed in as userId=%s", oidcLoginRequest.Provider, idToken.Subject, userData.UserID))
+
+	return userData, nil
+}
+
+/*
+claimUnverifiedUser hands an account nobody has proved they own to the user the provider has verified the email address of. Whoever
+registered it is locked out, so an account registered in advance with someone else's address can't be used to reach theirs. It's
+verified last, so if it fails part way it's claimed again on the next sign in.
+*/
+func (om *OIDCModel) claimUnverifiedUser(ctx context.Context, providerName string, idToken *oidc.IDTokenClaims, userData *data.UserData) error {
+	if err := om.db.UpdateUserPassword(ctx, userData.UserID, ""); err != nil {
+		return err
+	}
+
+	if err := om.db.UpdateUserTOTP(ctx, userData.UserID, data.UserTOTP{}); err != nil {
+		return err
+	}
+
+	revoked, err := om.db.DeleteUserSessions(ctx, userData.UserID, "")
+
+	if err != nil {
+		return err
+	}
+
+	personalAccessTokens, err := om.db.RetrievePersonalAccessTokens(ctx, userData.UserID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, personalAccessToken := range personalAccessTokens {
+		if err := om.db.DeletePersonalAccessToken(ctx, userData.UserID, personalAccessToken.TokenID); err != nil && !errors.Is(err, db.ErrPersonalAccessTokenNotFound) {
+			return err
+		}
+	}
+
+	if err := om.db.UpdateUserEmailVerified(ctx, userData.UserID, true); err != nil {
+		return err
+	}
+
+	userData.Password = ""
+	userData.TOTP = data.UserTOTP{}
+	userData.EmailVerified = true
+
+	om.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Provider %s subject %s claimed unverified userId=%s - cleared its password and MFA, revoked %d sessions and %d personal access tokens", providerName, idToken.Subject, userData.UserID, revoked, len(personalAccessTokens)))
+
+	return nil
+}
+
+/*
+createUser registers the user the ID token is for at emailAddress, with the name and timezone the provider has for them. A name
+or timezone registration wouldn't accept is replaced with the fallback.
+*/
+func (om *OIDCModel) createUser(ctx context.Context, providerName, emailAddress string, idToken *oidc.IDTokenClaims) (*data.UserData, error) {
+	firstName, lastName := idToken.GivenName, idToken.FamilyName
+
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(idToken.Name, " ")
+	}
+
+	if !validation.IsValidName(firstName) {
+		firstName = oidcFallbackFirstName
+	}
+
+	if !validation.IsValidName(lastName) {
+		lastName = oidcFallbackLastName
+	}
+
+	timezone := idToken.ZoneInfo
+
+	if !validation.IsValidTimezone(timezone) {
+		timezone = data.DefaultTimezone
+	}
+
+	userData, err := om.db.RegisterUserHandler(ctx, &data.RegisterUserRequest{
+		EmailAddress: emailAddress,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Timezone:     timezone,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := om.db.UpdateUserEmailVerified(ctx, userData.UserID, true); err != nil {
+		return nil, err
+	}
+
+	userData.EmailVerified = true
+
+	om.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Provider %s subject %s signed up as userId=%s", providerName, idToken.Subject, userData.UserID))
+
+	return userData, nil
+}
@@ -0,0 +1,370 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/middleware/session"
+	"dohabits/oidc"
+	"dohabits/validation"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestOIDCProvider returns a provider called "company" that signs users in with a MockIdP served on localhost
+func newTestOIDCProvider(t *testing.T) (*oidc.MockIdP, map[string]oidc.IProvider) {
+	t.Helper()
+
+	idp, err := oidc.NewMockIdP("dohabits", "secret")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	server := httptest.NewServer(idp)
+	t.Cleanup(server.Close)
+	idp.Issuer = server.URL
+
+	provider, err := oidc.NewProvider(oidc.Config{
+		Name:         "company",
+		Issuer:       server.URL,
+		ClientID:     "dohabits",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/oidc/company/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	}, logger.NewLogger(0))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	return idp, map[string]oidc.IProvider{"company": provider}
+}
+
+// signInWithProvider follows the authorization URL like the user's browser and returns the request the frontend would post to the callback
+func signInWithProvider(t *testing.T, oidcLoginStart *data.OIDCLoginStart) *data.OIDCLoginRequest {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+
+	res, err := client.Get(oidcLoginStart.AuthorizationURL)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	res.Body.Close()
+
+	location, err := url.Parse(res.Header.Get("Location"))
+
+	if err != nil || res.StatusCode != http.StatusFound {
+		t.Fatalf("%s - Failed - status=%d, location=%s", helper.GetFunctionName(), res.StatusCode, res.Header.Get("Location"))
+	}
+
+	return &data.OIDCLoginRequest{
+		Provider:   "company",
+		Code:       location.Query().Get("code"),
+		State:      location.Query().Get("state"),
+		StateToken: oidcLoginStart.StateToken,
+	}
+}
+
+func TestCompleteOIDCLogin(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	idp, providers := newTestOIDCProvider(t)
+	oidcModel := NewOIDCModel(logger, mockDB, session.NewOIDCStateToken(session.NewHMACKeySet("secretJwt"), logger), providers)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	defer func() { data.MockUsers = originalMockUsersState }()
+
+	unverifiedUser, err := mockDB.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "unverified@example.com", FirstName: "Una", LastName: "Verified", Timezone: data.DefaultTimezone})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name       string
+		user       oidc.MockIdPUser
+		tamper     func(claims jwt.MapClaims)
+		change     func(request *data.OIDCLoginRequest)
+		wantErr    error
+		wantUserID string
+	}{
+		{
+			name:       "Test an existing user is linked by their email address",
+			user:       oidc.MockIdPUser{Subject: "sub-1", Email: "johndoe1@example.com", EmailVerified: true},
+			wantUserID: "1",
+		},
+		{
+			name:       "Test an unverified user is verified by the provider",
+			user:       oidc.MockIdPUser{Subject: "sub-2", Email: "unverified@example.com", EmailVerified: true},
+			wantUserID: unverifiedUser.UserID,
+		},
+		{
+			name:       "Test an existing user is linked ignoring the case of their email address",
+			user:       oidc.MockIdPUser{Subject: "sub-1", Email: " JohnDoe1@Example.com", EmailVerified: true},
+			wantUserID: "1",
+		},
+		{
+			name: "Test a new user is created",
+			user: oidc.MockIdPUser{Subject: "sub-3", Email: "new.starter@example.com", EmailVerified: true, GivenName: "New", FamilyName: "Starter", ZoneInfo: "Europe/London"},
+		},
+		{
+			name: "Test a new user without a name registration accepts is created",
+			user: oidc.MockIdPUser{Subject: "sub-5", Email: "No.Name@example.com", EmailVerified: true, FamilyName: strings.Repeat("x", 51)},
+		},
+		{
+			name:    "Test an email address the provider hasn't verified",
+			user:    oidc.MockIdPUser{Subject: "sub-4", Email: "janesmith@example.com", EmailVerified: false},
+			wantErr: ErrOIDCEmailNotVerified,
+		},
+		{
+			name:    "Test a state that doesn't match the state token",
+			user:    oidc.MockIdPUser{Subject: "sub-1", Email: "johndoe1@example.com", EmailVerified: true},
+			change:  func(request *data.OIDCLoginRequest) { request.State = "another-sign-in" },
+			wantErr: session.ErrInvalidOIDCState,
+		},
+		{
+			name:    "Test a forged state token",
+			user:    oidc.MockIdPUser{Subject: "sub-1", Email: "johndoe1@example.com", EmailVerified: true},
+			change:  func(request *data.OIDCLoginRequest) { request.StateToken = "forged" },
+			wantErr: session.ErrInvalidOIDCState,
+		},
+		{
+			name:    "Test a provider that isn't configured",
+			user:    oidc.MockIdPUser{Subject: "sub-1", Email: "johndoe1@example.com", EmailVerified: true},
+			change:  func(request *data.OIDCLoginRequest) { request.Provider = "other" },
+			wantErr: ErrOIDCProviderNotFound,
+		},
+		{
+			name:    "Test an ID token for another sign in",
+			user:    oidc.MockIdPUser{Subject: "sub-1", Email: "johndoe1@example.com", EmailVerified: true},
+			tamper:  func(claims jwt.MapClaims) { claims["nonce"] = "another-sign-in" },
+			wantErr: oidc.ErrLoginFailed,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			idp.User = val.user
+			idp.Tamper = val.tamper
+
+			oidcLoginStart, err := oidcModel.StartOIDCLogin(ctx, "company")
+
+			if err != nil {
+				t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			oidcLoginRequest := signInWithProvider(t, oidcLoginStart)
+
+			if val.change != nil {
+				val.change(oidcLoginRequest)
+			}
+
+			userData, err := oidcModel.CompleteOIDCLogin(ctx, oidcLoginRequest)
+
+			if val.wantErr != nil {
+				if !errors.Is(err, val.wantErr) {
+					t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			if val.wantUserID != "" && userData.UserID != val.wantUserID {
+				t.Errorf("%s - Failed - userId=%s, want=%s", helper.GetFunctionName(), userData.UserID, val.wantUserID)
+			}
+
+			stored, err := mockDB.RetrieveUserDetails(ctx, strings.ToLower(strings.TrimSpace(val.user.Email)))
+
+			if err != nil || stored.UserID != userData.UserID || !stored.EmailVerified {
+				t.Errorf("%s - Failed - stored=%+v, err=%v", helper.GetFunctionName(), stored, err)
+			}
+		})
+	}
+
+	newUser, err := mockDB.RetrieveUserDetails(ctx, "new.starter@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if newUser.FirstName != "New" || newUser.LastName != "Starter" || newUser.Timezone != "Europe/London" {
+		t.Errorf("%s - Failed - newUser=%+v", helper.GetFunctionName(), newUser)
+	}
+
+	noName, err := mockDB.RetrieveUserDetails(ctx, "no.name@example.com")
+
+	if err != nil || noName.FirstName != oidcFallbackFirstName || noName.LastName != oidcFallbackLastName {
+		t.Errorf("%s - Failed - noName=%+v, err=%v", helper.GetFunctionName(), noName, err)
+	}
+
+	// The new user has no password until they set one
+	if validation.VerifyUserPassword("", newUser.Password) {
+		t.Errorf("%s - Failed - the new user can log in without a password", helper.GetFunctionName())
+	}
+
+	if _, err := oidcModel.StartOIDCLogin(ctx, "other"); !errors.Is(err, ErrOIDCProviderNotFound) {
+		t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, ErrOIDCProviderNotFound)
+	}
+}
+
+func TestCompleteOIDCLoginClaimsUnverifiedUser(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	idp, providers := newTestOIDCProvider(t)
+	oidcModel := NewOIDCModel(logger, mockDB, session.NewOIDCStateToken(session.NewHMACKeySet("secretJwt"), logger), providers)
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+		data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState
+	}()
+
+	// Someone else registers the address before its owner, and sets up the account so they can keep using it
+	hashedPassword, err := validation.HashPassword("squatter-password")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	squatted, err := mockDB.RegisterUserHandler(ctx, &data.RegisterUserRequest{EmailAddress: "owner@example.com", Password: string(hashedPassword), FirstName: "Not", LastName: "Owner", Timezone: data.DefaultTimezone})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	setup := []error{
+		mockDB.UpdateUserTOTP(ctx, squatted.UserID, data.UserTOTP{Secret: "JBSWY3DPEHPK3PXP", Enabled: true}),
+		mockDB.LoginUser(ctx, &data.UserSession{SessionID: "squatter-session", UserID: squatted.UserID, RefreshToken: "refresh", CreatedAt: time.Now()}),
+		mockDB.CreatePersonalAccessToken(ctx, &data.PersonalAccessToken{TokenID: "squatter-token", UserID: squatted.UserID, Name: "Script", TokenHash: "squatter-hash"}),
+	}
+
+	for _, err := range setup {
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+	}
+
+	// The owner signs in with a provider that has verified the address
+	idp.User = oidc.MockIdPUser{Subject: "owner", Email: "owner@example.com", EmailVerified: true}
+
+	oidcLoginStart, err := oidcModel.StartOIDCLogin(ctx, "company")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	userData, err := oidcModel.CompleteOIDCLogin(ctx, signInWithProvider(t, oidcLoginStart))
+
+	if err != nil || userData.UserID != squatted.UserID || userData.TOTP.Enabled {
+		t.Fatalf("%s - Failed - userData=%+v, err=%v", helper.GetFunctionName(), userData, err)
+	}
+
+	stored, err := mockDB.RetrieveUserDetailsByID(ctx, squatted.UserID)
+
+	if err != nil || !stored.EmailVerified || stored.TOTP.Enabled || validation.VerifyUserPassword("squatter-password", stored.Password) {
+		t.Errorf("%s - Failed - the account can still be used by whoever registered it, stored=%+v, err=%v", helper.GetFunctionName(), stored, err)
+	}
+
+	if sessions, err := mockDB.RetrieveUserSessions(ctx, squatted.UserID); err != nil || len(sessions) != 0 {
+		t.Errorf("%s - Failed - sessions=%+v, err=%v", helper.GetFunctionName(), sessions, err)
+	}
+
+	if personalAccessTokens, err := mockDB.RetrievePersonalAccessTokens(ctx, squatted.UserID); err != nil || len(personalAccessTokens) != 0 {
+		t.Errorf("%s - Failed - personalAccessTokens=%+v, err=%v", helper.GetFunctionName(), personalAccessTokens, err)
+	}
+}
+
+func TestLoginOIDCHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	keys := session.NewHMACKeySet("secretJwt")
+	idp, providers := newTestOIDCProvider(t)
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := NewMFAModel(logger, mockDB, session.NewMFAToken(keys, logger), loginAttempts)
+	oidcModel := NewOIDCModel(logger, mockDB, session.NewOIDCStateToken(keys, logger), providers)
+	authModel := NewAuthModel(logger, mockDB, nil, mfaModel, oidcModel, loginAttempts, validation.DefaultPasswordPolicy(), data.UnverifiedUserAccessAll)
+	jwtTokens := session.NewJSONWebToken(keys, mockDB, logger)
+	csrfTokens := session.NewMockCSRFToken(logger)
+	client := data.ClientInfo{UserAgent: "test-agent", IPAddress: "192.0.2.1"}
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	if err := mockDB.UpdateUserTOTP(ctx, "2", data.UserTOTP{Secret: "JBSWY3DPEHPK3PXP", Enabled: true}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	login := func(emailAddress string) *data.UserLoggedInData {
+		t.Helper()
+
+		idp.User = oidc.MockIdPUser{Subject: emailAddress, Email: emailAddress, EmailVerified: true}
+
+		oidcLoginStart, err := authModel.StartOIDCLoginHandler(ctx, "company")
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		userLoggedIn, err := authModel.LoginOIDCHandler(ctx, httptest.NewRecorder(), signInWithProvider(t, oidcLoginStart), client, jwtTokens, csrfTokens)
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		return userLoggedIn
+	}
+
+	// The sign in gets the same session a password login does
+	userLoggedIn := login("johndoe1@example.com")
+
+	if !userLoggedIn.Success || userLoggedIn.AccessToken == "" || userLoggedIn.RefreshToken == "" {
+		t.Fatalf("%s - Failed - userLoggedIn=%+v", helper.GetFunctionName(), userLoggedIn)
+	}
+
+	if _, err := mockDB.RetrieveUserSession(ctx, "1", userLoggedIn.SessionID); err != nil {
+		t.Fatalf("%s - Failed - the session wasn't stored: err=%s", helper.GetFunctionName(), err)
+	}
+
+	// The provider's sign in doesn't stand in for the app's own second factor
+	userLoggedIn = login("janesmith@example.com")
+
+	if userLoggedIn.Success || userLoggedIn.MFAToken == "" || userLoggedIn.AccessToken != "" {
+		t.Fatalf("%s - Failed - userLoggedIn=%+v", helper.GetFunctionName(), userLoggedIn)
+	}
+}
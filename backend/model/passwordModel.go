@@ -0,0 +1,175 @@
+package model
+
+import (
+	"context"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/mail"
+	"dohabits/validation"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// resetPasswordPath is the frontend page the emailed link opens, which posts the token and new password to /password/reset
+const resetPasswordPath = "/resetpassword"
+
+type PasswordModel struct {
+	logger         logger.ILogger
+	db             db.IDB
+	mailer         mail.Mailer
+	passwordPolicy *validation.PasswordPolicy
+	siteURL        string
+}
+
+type IPasswordModel interface {
+	ForgotPasswordHandler(ctx context.Context, forgotPasswordRequest *data.ForgotPasswordRequest) error
+	ResetPasswordHandler(ctx context.Context, resetPasswordRequest *data.ResetPasswordRequest) error
+	ChangePasswordHandler(ctx context.Context, userID, sessionID string, changePasswordRequest *data.ChangePasswordRequest) (int64, error)
+}
+
+func NewPasswordModel(logger logger.ILogger, db db.IDB, mailer mail.Mailer, passwordPolicy *validation.PasswordPolicy, siteURL string) *PasswordModel {
+	return &PasswordModel{
+		logger:         logger,
+		db:             db,
+		mailer:         mailer,
+		passwordPolicy: passwordPolicy,
+		siteURL:        strings.TrimRight(siteURL, "/"),
+	}
+}
+
+/*
+ForgotPasswordHandler emails the user a link to reset their password. The link's token is only stored hashed, works once, and
+expires after db.PasswordResetTTL. Unknown email addresses and failed sends succeed all the same, so the response doesn't reveal
+who has an account.
+*/
+func (pm *PasswordModel) ForgotPasswordHandler(ctx context.Context, forgotPasswordRequest *data.ForgotPasswordRequest) error {
+	pm.logger.InfoLog(helper.GetFunctionName(), "")
+
+	if !validation.IsValidEmail(forgotPasswordRequest.EmailAddress) {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), validation.ErrInvalidUser.WithFields(apperror.FieldError{Field: "EmailAddress", Message: "Email address is invalid"}))
+	}
+
+	userData, err := pm.db.RetrieveUserDetails(ctx, forgotPasswordRequest.EmailAddress)
+
+	if errors.Is(err, db.ErrUserNotFound) {
+		pm.logger.InfoLog(helper.GetFunctionName(), "No user has the email address, no email sent")
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	token, err := helper.RandomID()
+
+	if err != nil {
+		return err
+	}
+
+	if err := pm.db.CreatePasswordReset(ctx, &data.PasswordReset{TokenHash: helper.HashToken(token), UserID: userData.UserID}); err != nil {
+		return err
+	}
+
+	resetLink := fmt.Sprintf("%s%s?token=%s", pm.siteURL, resetPasswordPath, url.QueryEscape(token))
+
+	message := mail.Message{
+		To:      userData.EmailAddress,
+		Subject: "Reset your DoHabits password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your DoHabits account. To choose a new password, open this link within %s:\n\n%s\n\nIf it wasn't you, you can ignore this email and your password won't change.\n",
+			userData.FirstName, db.PasswordResetTTL, resetLink),
+	}
+
+	if err := pm.mailer.Send(ctx, message); err != nil {
+		pm.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Failed to send the password reset email for userId=%s: err=%s", userData.UserID, err))
+	}
+
+	return nil
+}
+
+// ResetPasswordHandler sets the password of the user the reset token was sent to, and logs them out everywhere in case the old password was stolen
+func (pm *PasswordModel) ResetPasswordHandler(ctx context.Context, resetPasswordRequest *data.ResetPasswordRequest) error {
+	pm.logger.InfoLog(helper.GetFunctionName(), "")
+
+	// Checked before the token is consumed, so a rejected password doesn't use up the link
+	if err := pm.passwordPolicy.Validate("NewPassword", resetPasswordRequest.NewPassword, ""); err != nil {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), validation.ErrInvalidUser.WithFields(apperror.FieldErrors(err)...))
+	}
+
+	passwordReset, err := pm.db.ConsumePasswordReset(ctx, helper.HashToken(resetPasswordRequest.Token))
+
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := validation.HashPassword(resetPasswordRequest.NewPassword)
+
+	if err != nil {
+		return err
+	}
+
+	if err := pm.db.UpdateUserPassword(ctx, passwordReset.UserID, string(hashedPassword)); err != nil {
+		return err
+	}
+
+	revoked, err := pm.db.DeleteUserSessions(ctx, passwordReset.UserID, "")
+
+	if err != nil {
+		return err
+	}
+
+	pm.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("Password reset for userId=%s, revoked %d sessions", passwordReset.UserID, revoked))
+
+	return nil
+}
+
+/*
+ChangePasswordHandler sets a new password once the current one is confirmed, and logs the user out everywhere apart from sessionID,
+the session the change was made from, in case the old password was stolen. It returns how many sessions it logged out.
+*/
+func (pm *PasswordModel) ChangePasswordHandler(ctx context.Context, userID, sessionID string, changePasswordRequest *data.ChangePasswordRequest) (int64, error) {
+	pm.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := pm.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// A stolen access token on its own can't lock the user out of their account
+	if !validation.VerifyUserPassword(changePasswordRequest.CurrentPassword, userData.Password) {
+		return 0, fmt.Errorf("%s - Invalid Password: %w", helper.GetFunctionName(), validation.ErrInvalidCredentials)
+	}
+
+	if err := pm.passwordPolicy.Validate("NewPassword", changePasswordRequest.NewPassword, userData.EmailAddress); err != nil {
+		return 0, fmt.Errorf("%s - %w", helper.GetFunctionName(), validation.ErrInvalidUser.WithFields(apperror.FieldErrors(err)...))
+	}
+
+	if changePasswordRequest.NewPassword == changePasswordRequest.CurrentPassword {
+		return 0, fmt.Errorf("%s - %w", helper.GetFunctionName(), validation.ErrInvalidUser.WithFields(apperror.FieldError{Field: "NewPassword", Message: "New password must be different from the current password"}))
+	}
+
+	hashedPassword, err := validation.HashPassword(changePasswordRequest.NewPassword)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := pm.db.UpdateUserPassword(ctx, userData.UserID, string(hashedPassword)); err != nil {
+		return 0, err
+	}
+
+	revoked, err := pm.db.DeleteUserSessions(ctx, userData.UserID, sessionID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	pm.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("Password changed for userId=%s, revoked %d other sessions", userData.UserID, revoked))
+
+	return revoked, nil
+}
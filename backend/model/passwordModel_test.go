@@ -0,0 +1,256 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/mail"
+	"dohabits/validation"
+	"errors"
+	"regexp"
+	"testing"
+)
+
+var resetTokenPattern = regexp.MustCompile(`/resetpassword\?token=([A-Za-z0-9_-]+)`)
+
+// resetTokenFromEmail returns the token in the reset link of the latest email the mailer sent
+func resetTokenFromEmail(t *testing.T, mailer *mail.MockMailer) string {
+	t.Helper()
+
+	sent := mailer.Sent()
+
+	if len(sent) == 0 {
+		t.Fatalf("%s - Failed - no email was sent", helper.GetFunctionName())
+	}
+
+	match := resetTokenPattern.FindStringSubmatch(sent[len(sent)-1].Body)
+
+	if match == nil {
+		t.Fatalf("%s - Failed - no reset link in the email:\n%s", helper.GetFunctionName(), sent[len(sent)-1].Body)
+	}
+
+	return match[1]
+}
+
+func TestForgotPasswordHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mailer := mail.NewMockMailer(logger)
+	passwordModel := NewPasswordModel(logger, db.NewMockDB(logger), mailer, validation.DefaultPasswordPolicy(), "http://localhost/")
+	ctx := context.Background()
+
+	originalMockPasswordResetsState := make([]data.PasswordReset, len(data.MockPasswordResets))
+	copy(originalMockPasswordResetsState, data.MockPasswordResets)
+
+	defer func() { data.MockPasswordResets = originalMockPasswordResetsState }()
+
+	// An unknown email address succeeds without sending anything, so it can't be told apart from a known one
+	if err := passwordModel.ForgotPasswordHandler(ctx, &data.ForgotPasswordRequest{EmailAddress: "nobody@example.com"}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if sent := mailer.Sent(); len(sent) != 0 {
+		t.Fatalf("%s - Failed - sent=%+v", helper.GetFunctionName(), sent)
+	}
+
+	err := passwordModel.ForgotPasswordHandler(ctx, &data.ForgotPasswordRequest{EmailAddress: "not-an-email"})
+
+	if !errors.Is(err, validation.ErrInvalidUser) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, validation.ErrInvalidUser)
+	}
+
+	if err := passwordModel.ForgotPasswordHandler(ctx, &data.ForgotPasswordRequest{EmailAddress: "johndoe1@example.com"}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	sent := mailer.Sent()
+
+	if len(sent) != 1 || sent[0].To != "johndoe1@example.com" {
+		t.Fatalf("%s - Failed - sent=%+v", helper.GetFunctionName(), sent)
+	}
+
+	token := resetTokenFromEmail(t, mailer)
+
+	// Only the hash of the token is stored
+	for _, val := range data.MockPasswordResets {
+		if val.TokenHash == token {
+			t.Fatalf("%s - Failed - the reset token was stored in plain text", helper.GetFunctionName())
+		}
+	}
+
+	// A failed send isn't reported to the client either
+	mailer.Err = errors.New("mail server is down")
+
+	if err := passwordModel.ForgotPasswordHandler(ctx, &data.ForgotPasswordRequest{EmailAddress: "johndoe1@example.com"}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mailer := mail.NewMockMailer(logger)
+	mockDB := db.NewMockDB(logger)
+	passwordModel := NewPasswordModel(logger, mockDB, mailer, validation.DefaultPasswordPolicy(), "http://localhost")
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+	originalMockPasswordResetsState := make([]data.PasswordReset, len(data.MockPasswordResets))
+	copy(originalMockPasswordResetsState, data.MockPasswordResets)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+		data.MockPasswordResets = originalMockPasswordResetsState
+	}()
+
+	forgotPasswordRequest := &data.ForgotPasswordRequest{EmailAddress: "john.loggedin@example.com"}
+
+	if err := passwordModel.ForgotPasswordHandler(ctx, forgotPasswordRequest); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	replacedToken := resetTokenFromEmail(t, mailer)
+
+	if err := passwordModel.ForgotPasswordHandler(ctx, forgotPasswordRequest); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	token := resetTokenFromEmail(t, mailer)
+
+	testCases := []struct {
+		name                 string
+		resetPasswordRequest *data.ResetPasswordRequest
+		wantErr              error
+	}{
+		{
+			name:                 "Test an invalid password doesn't use up the token",
+			resetPasswordRequest: &data.ResetPasswordRequest{Token: token, NewPassword: "short"},
+			wantErr:              validation.ErrInvalidUser,
+		},
+		{
+			name:                 "Test a token that was never issued",
+			resetPasswordRequest: &data.ResetPasswordRequest{Token: "not-a-token", NewPassword: "2secret?Password"},
+			wantErr:              db.ErrPasswordResetNotFound,
+		},
+		{
+			name:                 "Test the token from an earlier email stops working",
+			resetPasswordRequest: &data.ResetPasswordRequest{Token: replacedToken, NewPassword: "2secret?Password"},
+			wantErr:              db.ErrPasswordResetNotFound,
+		},
+		{
+			name:                 "Test the password is reset",
+			resetPasswordRequest: &data.ResetPasswordRequest{Token: token, NewPassword: "2secret?Password"},
+		},
+		{
+			name:                 "Test the token only works once",
+			resetPasswordRequest: &data.ResetPasswordRequest{Token: token, NewPassword: "3secret?Password"},
+			wantErr:              db.ErrPasswordResetNotFound,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			err := passwordModel.ResetPasswordHandler(ctx, val.resetPasswordRequest)
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+		})
+	}
+
+	userData, err := mockDB.RetrieveUserDetails(ctx, "john.loggedin@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if !validation.VerifyUserPassword("2secret?Password", userData.Password) {
+		t.Errorf("%s - Failed - the new password doesn't work", helper.GetFunctionName())
+	}
+
+	// Both devices john.loggedin@example.com was logged in on are logged out
+	if userSessions, err := mockDB.RetrieveUserSessions(ctx, userData.UserID); err != nil || len(userSessions) != 0 {
+		t.Errorf("%s - Failed - sessions=%+v, err=%v", helper.GetFunctionName(), userSessions, err)
+	}
+}
+
+func TestChangePasswordHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	passwordModel := NewPasswordModel(logger, mockDB, mail.NewMockMailer(logger), validation.DefaultPasswordPolicy(), "http://localhost")
+	ctx := context.Background()
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	testCases := []struct {
+		name                  string
+		changePasswordRequest *data.ChangePasswordRequest
+		wantErr               error
+		wantRevoked           int64
+	}{
+		{
+			name:                  "Test the current password is wrong",
+			changePasswordRequest: &data.ChangePasswordRequest{CurrentPassword: "wrong?Password1", NewPassword: "2secret?Password"},
+			wantErr:               validation.ErrInvalidCredentials,
+		},
+		{
+			name:                  "Test the new password breaks the policy",
+			changePasswordRequest: &data.ChangePasswordRequest{CurrentPassword: "1secret?Password", NewPassword: "Password123"},
+			wantErr:               validation.ErrInvalidUser,
+		},
+		{
+			name:                  "Test the new password is the current password",
+			changePasswordRequest: &data.ChangePasswordRequest{CurrentPassword: "1secret?Password", NewPassword: "1secret?Password"},
+			wantErr:               validation.ErrInvalidUser,
+		},
+		{
+			name:                  "Test the password is changed and the other session is logged out",
+			changePasswordRequest: &data.ChangePasswordRequest{CurrentPassword: "1secret?Password", NewPassword: "2secret?Password"},
+			wantRevoked:           1,
+		},
+		{
+			name:                  "Test the old password no longer works",
+			changePasswordRequest: &data.ChangePasswordRequest{CurrentPassword: "1secret?Password", NewPassword: "3secret?Password"},
+			wantErr:               validation.ErrInvalidCredentials,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			revoked, err := passwordModel.ChangePasswordHandler(ctx, "4", "mock-session-4a", val.changePasswordRequest)
+
+			if !errors.Is(err, val.wantErr) || revoked != val.wantRevoked {
+				t.Errorf("%s - Failed - revoked=%d, want=%d, err=%v, wantErr=%v", helper.GetFunctionName(), revoked, val.wantRevoked, err, val.wantErr)
+			}
+		})
+	}
+
+	userData, err := mockDB.RetrieveUserDetails(ctx, "john.loggedin@example.com")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if !validation.VerifyUserPassword("2secret?Password", userData.Password) {
+		t.Errorf("%s - Failed - the new password doesn't work", helper.GetFunctionName())
+	}
+
+	// The session the password was changed from is kept
+	userSessions, err := mockDB.RetrieveUserSessions(ctx, userData.UserID)
+
+	if err != nil || len(userSessions) != 1 || userSessions[0].SessionID != "mock-session-4a" {
+		t.Errorf("%s - Failed - sessions=%+v, err=%v", helper.GetFunctionName(), userSessions, err)
+	}
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/validation"
+	"fmt"
+	"time"
+)
+
+type PersonalAccessTokenModel struct {
+	logger               logger.ILogger
+	db                   db.IDB
+	personalAccessTokens session.IPersonalAccessTokens
+}
+
+type IPersonalAccessTokenModel interface {
+	CreatePersonalAccessTokenHandler(ctx context.Context, userID string, request *data.CreatePersonalAccessTokenRequest) (string, *data.PersonalAccessToken, error)
+	RetrievePersonalAccessTokensHandler(ctx context.Context, userID string) ([]data.PersonalAccessToken, error)
+	DeletePersonalAccessTokenHandler(ctx context.Context, userID, tokenID string) error
+}
+
+func NewPersonalAccessTokenModel(logger logger.ILogger, db db.IDB, personalAccessTokens session.IPersonalAccessTokens) *PersonalAccessTokenModel {
+	return &PersonalAccessTokenModel{
+		logger:               logger,
+		db:                   db,
+		personalAccessTokens: personalAccessTokens,
+	}
+}
+
+/*
+CreatePersonalAccessTokenHandler creates a token with the requested name, scopes and expiry and returns it along with what's
+stored. The token is only ever returned here, the database only has its hash.
+*/
+func (m *PersonalAccessTokenModel) CreatePersonalAccessTokenHandler(ctx context.Context, userID string, request *data.CreatePersonalAccessTokenRequest) (string, *data.PersonalAccessToken, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	if err := validation.ValidatePersonalAccessToken(request); err != nil {
+		return "", nil, err
+	}
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	token, tokenHash, err := m.personalAccessTokens.GeneratePersonalAccessToken()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	tokenID, err := helper.RandomID()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+
+	personalAccessToken := data.PersonalAccessToken{
+		TokenID:   tokenID,
+		UserID:    userData.UserID,
+		Name:      request.Name,
+		TokenHash: tokenHash,
+		Scopes:    request.Scopes,
+		CreatedAt: now,
+	}
+
+	if request.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, request.ExpiresInDays)
+		personalAccessToken.ExpiresAt = &expiresAt
+	}
+
+	if err := m.db.CreatePersonalAccessToken(ctx, &personalAccessToken); err != nil {
+		return "", nil, err
+	}
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Personal access token tokenId=%s created for userId=%s with scopes %v", tokenID, userData.UserID, request.Scopes))
+
+	return token, &personalAccessToken, nil
+}
+
+// RetrievePersonalAccessTokensHandler returns the user's tokens that haven't been revoked or expired, oldest first
+func (m *PersonalAccessTokenModel) RetrievePersonalAccessTokensHandler(ctx context.Context, userID string) ([]data.PersonalAccessToken, error) {
+	m.logger.InfoLog(helper.GetFunctionName(), "")
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.db.RetrievePersonalAccessTokens(ctx, userData.UserID)
+}
+
+// DeletePersonalAccessTokenHandler revokes one of the user's tokens, which stops working straight away
+func (m *PersonalAccessTokenModel) DeletePersonalAccessTokenHandler(ctx context.Context, userID, tokenID string) error {
+	m.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("tokenId=%s", tokenID))
+
+	userData, err := m.db.RetrieveUserDetailsByID(ctx, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := m.db.DeletePersonalAccessToken(ctx, userData.UserID, tokenID); err != nil {
+		return err
+	}
+
+	m.logger.SecurityLog(helper.GetFunctionName(), fmt.Sprintf("Personal access token tokenId=%s revoked for userId=%s", tokenID, userData.UserID))
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"context"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/logger"
+	"dohabits/middleware/session"
+	"dohabits/validation"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCreatePersonalAccessTokenHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	personalAccessTokens := session.NewPersonalAccessTokens(mockDB, logger)
+	personalAccessTokenModel := NewPersonalAccessTokenModel(logger, mockDB, personalAccessTokens)
+	ctx := context.Background()
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	testCases := []struct {
+		name       string
+		request    data.CreatePersonalAccessTokenRequest
+		wantExpiry bool
+		wantErr    error
+	}{
+		{name: "Test token that never expires", request: data.CreatePersonalAccessTokenRequest{Name: "CI", Scopes: []string{data.ScopeHabitsRead}}},
+		{name: "Test token that expires", request: data.CreatePersonalAccessTokenRequest{Name: "Script", Scopes: []string{data.ScopeHabitsRead, data.ScopeHabitsWrite}, ExpiresInDays: 30}, wantExpiry: true},
+		{name: "Test token without a name", request: data.CreatePersonalAccessTokenRequest{Name: " ", Scopes: []string{data.ScopeHabitsRead}}, wantErr: validation.ErrInvalidPersonalAccessToken},
+		{name: "Test token without scopes", request: data.CreatePersonalAccessTokenRequest{Name: "CI"}, wantErr: validation.ErrInvalidPersonalAccessToken},
+		{name: "Test token with an unknown scope", request: data.CreatePersonalAccessTokenRequest{Name: "CI", Scopes: []string{"admin"}}, wantErr: validation.ErrInvalidPersonalAccessToken},
+		{name: "Test token with a scope listed twice", request: data.CreatePersonalAccessTokenRequest{Name: "CI", Scopes: []string{data.ScopeHabitsRead, data.ScopeHabitsRead}}, wantErr: validation.ErrInvalidPersonalAccessToken},
+		{name: "Test token that expires too late", request: data.CreatePersonalAccessTokenRequest{Name: "CI", Scopes: []string{data.ScopeHabitsRead}, ExpiresInDays: 366}, wantErr: validation.ErrInvalidPersonalAccessToken},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			token, personalAccessToken, err := personalAccessTokenModel.CreatePersonalAccessTokenHandler(ctx, "1", &val.request)
+
+			if !errors.Is(err, val.wantErr) {
+				t.Errorf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, val.wantErr)
+				return
+			}
+
+			if val.wantErr != nil {
+				return
+			}
+
+			if personalAccessToken.UserID != "1" || personalAccessToken.Name != val.request.Name || !reflect.DeepEqual(personalAccessToken.Scopes, val.request.Scopes) ||
+				(personalAccessToken.ExpiresAt != nil) != val.wantExpiry {
+				t.Errorf("%s - Failed - got=%+v", helper.GetFunctionName(), personalAccessToken)
+				return
+			}
+
+			if val.wantExpiry && !personalAccessToken.ExpiresAt.Equal(personalAccessToken.CreatedAt.AddDate(0, 0, 30)) {
+				t.Errorf("%s - Failed - got ExpiresAt=%s, CreatedAt=%s", helper.GetFunctionName(), personalAccessToken.ExpiresAt, personalAccessToken.CreatedAt)
+				return
+			}
+
+			// Only the hash is stored, and the token authenticates as its user
+			if personalAccessToken.TokenHash == token || personalAccessToken.TokenHash != helper.HashToken(token) {
+				t.Errorf("%s - Failed - TokenHash=%s", helper.GetFunctionName(), personalAccessToken.TokenHash)
+				return
+			}
+
+			claims, err := personalAccessTokens.ParsePersonalAccessToken(ctx, token)
+
+			if err != nil || claims.Username != "johndoe1@example.com" || claims.PersonalAccessTokenID != personalAccessToken.TokenID {
+				t.Errorf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), claims, err)
+			}
+		})
+	}
+}
+
+func TestRetrieveAndDeletePersonalAccessTokens(t *testing.T) {
+	logger := logger.NewLogger(0)
+	mockDB := db.NewMockDB(logger)
+	personalAccessTokens := session.NewPersonalAccessTokens(mockDB, logger)
+	personalAccessTokenModel := NewPersonalAccessTokenModel(logger, mockDB, personalAccessTokens)
+	ctx := context.Background()
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	token, personalAccessToken, err := personalAccessTokenModel.CreatePersonalAccessTokenHandler(ctx, "1", &data.CreatePersonalAccessTokenRequest{Name: "CI", Scopes: []string{data.ScopeHabitsRead}})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, _, err := personalAccessTokenModel.CreatePersonalAccessTokenHandler(ctx, "4", &data.CreatePersonalAccessTokenRequest{Name: "Other user", Scopes: []string{data.ScopeHabitsRead}}); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	got, err := personalAccessTokenModel.RetrievePersonalAccessTokensHandler(ctx, "1")
+
+	if err != nil || len(got) != 1 || got[0].TokenID != personalAccessToken.TokenID {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), got, err)
+	}
+
+	// A user can't revoke someone else's token
+	if err := personalAccessTokenModel.DeletePersonalAccessTokenHandler(ctx, "4", personalAccessToken.TokenID); !errors.Is(err, db.ErrPersonalAccessTokenNotFound) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, db.ErrPersonalAccessTokenNotFound)
+	}
+
+	if err := personalAccessTokenModel.DeletePersonalAccessTokenHandler(ctx, "1", personalAccessToken.TokenID); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// A revoked token stops working straight away
+	if _, err := personalAccessTokens.ParsePersonalAccessToken(ctx, token); err == nil {
+		t.Fatalf("%s - Failed - the revoked token still works", helper.GetFunctionName())
+	}
+
+	if got, err := personalAccessTokenModel.RetrievePersonalAccessTokensHandler(ctx, "1"); err != nil || len(got) != 0 {
+		t.Fatalf("%s - Failed - got=%+v, err=%v", helper.GetFunctionName(), got, err)
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import "sync"
+
+/*
+userLocks hands out a mutex per user, so each user's writes are serialised while different users never wait on each other.
+Read-modify-write operations, such as merging a partial update into the stored habit, hold the user's lock for the whole operation.
+A user's entry is removed once nobody holds or is waiting for it, so the map only grows with the number of users writing at once.
+*/
+type userLocks struct {
+	mx    sync.Mutex
+	locks map[string]*userLock
+}
+
+type userLock struct {
+	mx      sync.Mutex
+	waiting int
+}
+
+func newUserLocks() *userLocks {
+	return &userLocks{locks: map[string]*userLock{}}
+}
+
+// lock blocks until userID's lock is held and returns the function that releases it
+func (l *userLocks) lock(userID string) (unlock func()) {
+	l.mx.Lock()
+	lock, ok := l.locks[userID]
+
+	if !ok {
+		lock = &userLock{}
+		l.locks[userID] = lock
+	}
+
+	lock.waiting++
+	l.mx.Unlock()
+
+	lock.mx.Lock()
+
+	return func() {
+		lock.mx.Unlock()
+
+		l.mx.Lock()
+		lock.waiting--
+
+		if lock.waiting == 0 {
+			delete(l.locks, userID)
+		}
+
+		l.mx.Unlock()
+	}
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"dohabits/helper"
+	"testing"
+	"time"
+)
+
+func TestUserLocks(t *testing.T) {
+	locks := newUserLocks()
+
+	unlockFirst := locks.lock("1")
+
+	// Another user isn't blocked by user 1's lock
+	otherUser := make(chan struct{})
+
+	go func() {
+		locks.lock("2")()
+		close(otherUser)
+	}()
+
+	select {
+	case <-otherUser:
+	case <-time.After(time.Second):
+		t.Fatalf("%s - Failed - user 2 was blocked by user 1", helper.GetFunctionName())
+	}
+
+	// The same user waits until the lock is released
+	sameUser := make(chan struct{})
+
+	go func() {
+		locks.lock("1")()
+		close(sameUser)
+	}()
+
+	select {
+	case <-sameUser:
+		t.Fatalf("%s - Failed - user 1 took the lock twice", helper.GetFunctionName())
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlockFirst()
+
+	select {
+	case <-sameUser:
+	case <-time.After(time.Second):
+		t.Fatalf("%s - Failed - user 1 wasn't given the lock after it was released", helper.GetFunctionName())
+	}
+
+	locks.mx.Lock()
+	defer locks.mx.Unlock()
+
+	if len(locks.locks) != 0 {
+		t.Errorf("%s - Failed - got %d locks left, want=0", helper.GetFunctionName(), len(locks.locks))
+	}
+}
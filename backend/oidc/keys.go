@@ -0,0 +1,123 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"dohabits/data"
+	"encoding/base64"
+	"math/big"
+	"net"
+)
+
+// minRSAKeyBits is the smallest RSA modulus an ID token is accepted from
+const minRSAKeyBits = 2048
+
+// publicKey is one of the provider's signing keys. algorithm is empty when the provider doesn't say which algorithm the key is for.
+type publicKey struct {
+	algorithm string
+	key       interface{}
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+/*
+parseKeySet returns the provider's signing keys by their key ID. Keys that aren't for signatures, or that the app can't verify
+ID tokens with, are left out rather than failing the whole set, as providers publish keys for other uses alongside them.
+*/
+func parseKeySet(keySet data.JSONWebKeySet) map[string]publicKey {
+	keys := map[string]publicKey{}
+
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		var key interface{}
+
+		switch jwk.KeyType {
+		case "RSA":
+			key = parseRSAKey(jwk)
+		case "EC":
+			key = parseECKey(jwk)
+		}
+
+		if key != nil {
+			keys[jwk.KeyID] = publicKey{algorithm: jwk.Algorithm, key: key}
+		}
+	}
+
+	return keys
+}
+
+func parseRSAKey(jwk data.JSONWebKey) *rsa.PublicKey {
+	modulus, err := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+
+	if err != nil {
+		return nil
+	}
+
+	exponent, err := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+
+	if err != nil || len(exponent) == 0 || len(exponent) > 4 {
+		return nil
+	}
+
+	key := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(modulus),
+		E: int(new(big.Int).SetBytes(exponent).Int64()),
+	}
+
+	if key.N.BitLen() < minRSAKeyBits {
+		return nil
+	}
+
+	return key
+}
+
+func parseECKey(jwk data.JSONWebKey) *ecdsa.PublicKey {
+	curve, ok := curves[jwk.Curve]
+
+	if !ok {
+		return nil
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+
+	if err != nil {
+		return nil
+	}
+
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+
+	if err != nil {
+		return nil
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil
+	}
+
+	return key
+}
+
+// isLoopback reports whether host is this machine, which is the only place an issuer is allowed to use plain http
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
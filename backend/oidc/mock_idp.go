@@ -0,0 +1,240 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"dohabits/data"
+	"dohabits/helper"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MockIdPUser is who signs in at the MockIdP's authorization endpoint
+type MockIdPUser struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	ZoneInfo      string
+}
+
+type mockAuthorization struct {
+	redirectURI   string
+	codeChallenge string
+	nonce         string
+	user          MockIdPUser
+}
+
+/*
+MockIdP is a stand in identity provider for tests to sign in against. Serve it with httptest.NewServer and set Issuer to the
+server's URL. Its authorization endpoint signs User in straight away and redirects back with a code, and its token endpoint
+checks the client's secret, redirect URI and PKCE code verifier the way a real provider does before issuing an ID token.
+*/
+type MockIdP struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	User         MockIdPUser
+	Tamper       func(claims jwt.MapClaims) // Changes the ID token's claims before it's signed, to test tokens that should be refused
+
+	mx     sync.Mutex
+	key    *rsa.PrivateKey
+	keyID  string
+	codes  map[string]mockAuthorization
+	nextID int
+}
+
+func NewMockIdP(clientID, clientSecret string) (*MockIdP, error) {
+	idp := &MockIdP{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        map[string]mockAuthorization{},
+	}
+
+	if err := idp.RotateKey(); err != nil {
+		return nil, err
+	}
+
+	return idp, nil
+}
+
+// RotateKey replaces the key ID tokens are signed with, dropping the old one from the key set like a provider rotating its keys
+func (idp *MockIdP) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+
+	if err != nil {
+		return err
+	}
+
+	idp.mx.Lock()
+	defer idp.mx.Unlock()
+
+	idp.nextID++
+	idp.key = key
+	idp.keyID = "mock-key-" + strconv.Itoa(idp.nextID)
+
+	return nil
+}
+
+// SignIDToken signs claims as an ID token from the MockIdP, for tests that need a token the token endpoint wouldn't issue
+func (idp *MockIdP) SignIDToken(claims jwt.MapClaims) (string, error) {
+	idp.mx.Lock()
+	defer idp.mx.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = idp.keyID
+
+	return token.SignedString(idp.key)
+}
+
+func (idp *MockIdP) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case discoveryPath:
+		idp.writeJSON(w, http.StatusOK, map[string]interface{}{
+			"issuer":                           idp.Issuer,
+			"authorization_endpoint":           idp.Issuer + "/authorize",
+			"token_endpoint":                   idp.Issuer + "/token",
+			"jwks_uri":                         idp.Issuer + "/jwks",
+			"response_types_supported":         []string{"code"},
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	case "/jwks":
+		idp.mx.Lock()
+		keySet := data.JSONWebKeySet{Keys: []data.JSONWebKey{{
+			KeyType:   "RSA",
+			Use:       "sig",
+			Algorithm: "RS256",
+			KeyID:     idp.keyID,
+			Modulus:   base64.RawURLEncoding.EncodeToString(idp.key.N.Bytes()),
+			Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(idp.key.E)).Bytes()),
+		}}}
+		idp.mx.Unlock()
+
+		idp.writeJSON(w, http.StatusOK, keySet)
+	case "/authorize":
+		idp.authorize(w, r)
+	case "/token":
+		idp.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (idp *MockIdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("response_type") != "code" || query.Get("client_id") != idp.ClientID || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := helper.RandomID()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	idp.mx.Lock()
+	idp.codes[code] = mockAuthorization{
+		redirectURI:   query.Get("redirect_uri"),
+		codeChallenge: query.Get("code_challenge"),
+		nonce:         query.Get("nonce"),
+		user:          idp.User,
+	}
+	idp.mx.Unlock()
+
+	redirectURL, err := url.Parse(query.Get("redirect_uri"))
+
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	values := redirectURL.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirectURL.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
+
+func (idp *MockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Method != http.MethodPost || r.PostForm.Get("grant_type") != "authorization_code" {
+		idp.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+
+	if clientID != idp.ClientID || clientSecret != idp.ClientSecret {
+		idp.writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	// A code can only be exchanged once, whether or not the exchange succeeds
+	idp.mx.Lock()
+	authorization, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mx.Unlock()
+
+	if !ok || authorization.redirectURI != r.PostForm.Get("redirect_uri") || CodeChallenge(r.PostForm.Get("code_verifier")) != authorization.codeChallenge {
+		idp.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"iss":            idp.Issuer,
+		"sub":            authorization.user.Subject,
+		"aud":            idp.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          authorization.nonce,
+		"email":          authorization.user.Email,
+		"email_verified": authorization.user.EmailVerified,
+		"given_name":     authorization.user.GivenName,
+		"family_name":    authorization.user.FamilyName,
+		"zoneinfo":       authorization.user.ZoneInfo,
+	}
+
+	if idp.Tamper != nil {
+		idp.Tamper(claims)
+	}
+
+	idToken, err := idp.SignIDToken(claims)
+
+	if err != nil {
+		idp.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	idp.writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "mock-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (idp *MockIdP) writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
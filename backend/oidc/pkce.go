@@ -0,0 +1,22 @@
+package oidc
+
+import (
+	"crypto/sha256"
+	"dohabits/helper"
+	"encoding/base64"
+)
+
+/*
+NewCodeVerifier returns a PKCE code verifier, the secret that has to be sent with the code to exchange it, RFC 7636 section 4.1.
+Only its challenge goes to the provider when the sign in starts, so a code intercepted on its way back is no use without it.
+*/
+func NewCodeVerifier() (string, error) {
+	return helper.RandomID()
+}
+
+// CodeChallenge returns the S256 challenge for verifier, RFC 7636 section 4.2
+func CodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,393 @@
+/*
+Package oidc signs users in with an OpenID Connect identity provider, using the authorization code flow with PKCE. A Provider
+finds the provider's endpoints with discovery, builds the URL the user is sent to to sign in, exchanges the code they come back
+with for an ID token, and checks the ID token was signed by the provider for this app and this sign in.
+*/
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// httpTimeout caps each request to the provider, so a provider that hangs doesn't hold up the login
+	httpTimeout = 10 * time.Second
+	// maxResponseSize caps how much of a response from the provider is read
+	maxResponseSize = 1 << 20
+	// clockSkew is how far the provider's clock can be from ours when the ID token's times are checked
+	clockSkew = time.Minute
+	// keysRefreshInterval is the soonest the provider's keys are fetched again for a token signed with a key we don't have, so
+	// tokens with made up key IDs can't make the app flood the provider with requests
+	keysRefreshInterval = time.Minute
+)
+
+var (
+	ErrLoginFailed         = apperror.New(http.StatusUnauthorized, apperror.CodeOIDCLoginFailed, "The sign in with the identity provider couldn't be verified, please try again")
+	ErrProviderUnavailable = apperror.New(http.StatusBadGateway, apperror.CodeOIDCProviderUnavailable, "The identity provider can't be reached, please try again later")
+)
+
+// signingMethods are the algorithms ID tokens are accepted with. The provider's key decides which of them a token can use.
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Config is how the app is registered with an identity provider
+type Config struct {
+	Name         string // Identifies the provider in the API's paths, e.g. /oidc/{name}/login
+	Issuer       string
+	ClientID     string
+	ClientSecret string // Empty for a public client, which only has PKCE to protect the code
+	RedirectURL  string // The frontend page the provider sends the user back to, which posts the code to /oidc/{name}/callback
+	Scopes       []string
+}
+
+// IDTokenClaims are the claims of a verified ID token that the app uses
+type IDTokenClaims struct {
+	Nonce           string       `json:"nonce"`
+	Email           string       `json:"email"`
+	EmailVerified   flexibleBool `json:"email_verified"`
+	Name            string       `json:"name"`
+	GivenName       string       `json:"given_name"`
+	FamilyName      string       `json:"family_name"`
+	ZoneInfo        string       `json:"zoneinfo"` // The user's IANA timezone
+	AuthorizedParty string       `json:"azp"`
+	jwt.RegisteredClaims
+}
+
+// flexibleBool is a boolean claim that some providers send as the string "true" or "false"
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(value []byte) error {
+	var s string
+
+	if err := json.Unmarshal(value, &s); err == nil {
+		*b = flexibleBool(s == "true")
+		return nil
+	}
+
+	var v bool
+
+	if err := json.Unmarshal(value, &v); err != nil {
+		return err
+	}
+
+	*b = flexibleBool(v)
+
+	return nil
+}
+
+// metadata is the part of the provider's discovery document the app uses
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+/*
+Provider is an identity provider users can sign in with. Its discovery document is fetched the first time it's used rather
+than when the app starts, so the app still starts while the provider is down, and its signing keys are fetched again whenever
+an ID token is signed with one it doesn't have, so the provider can rotate them.
+*/
+type Provider struct {
+	config Config
+	client *http.Client
+	logger logger.ILogger
+
+	metadataMx sync.Mutex
+	metadata   *metadata
+
+	keysMx        sync.Mutex
+	keys          map[string]publicKey
+	keysFetchedAt time.Time
+}
+
+type IProvider interface {
+	// AuthCodeURL returns the URL the user is sent to to sign in, which sends them back to the RedirectURL with a code and state
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange swaps the code the user came back with for their ID token, returning ErrLoginFailed if the provider refuses it
+	Exchange(ctx context.Context, code, codeVerifier string) (string, error)
+	// VerifyIDToken returns the claims of an ID token issued by the provider for this app with nonce, or ErrLoginFailed for any other token
+	VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error)
+}
+
+func NewProvider(config Config, logger logger.ILogger) (*Provider, error) {
+	issuer, err := url.Parse(config.Issuer)
+
+	if err != nil || issuer.Host == "" {
+		return nil, fmt.Errorf("%s - provider %s: issuer %q isn't a URL", helper.GetFunctionName(), config.Name, config.Issuer)
+	}
+
+	// Everything the app trusts about the provider comes from the issuer, so it has to be fetched over TLS outside of local testing
+	if issuer.Scheme != "https" && !(issuer.Scheme == "http" && isLoopback(issuer.Hostname())) {
+		return nil, fmt.Errorf("%s - provider %s: issuer %q must use https", helper.GetFunctionName(), config.Name, config.Issuer)
+	}
+
+	if config.ClientID == "" {
+		return nil, fmt.Errorf("%s - provider %s: client ID is required", helper.GetFunctionName(), config.Name)
+	}
+
+	return &Provider{
+		config: config,
+		client: &http.Client{Timeout: httpTimeout},
+		logger: logger,
+	}, nil
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	metadata, err := p.discover(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	authorizationURL, err := url.Parse(metadata.AuthorizationEndpoint)
+
+	if err != nil {
+		return "", fmt.Errorf("%s - authorization endpoint %q: %v: %w", helper.GetFunctionName(), metadata.AuthorizationEndpoint, err, ErrProviderUnavailable)
+	}
+
+	query := authorizationURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authorizationURL.RawQuery = query.Encode()
+
+	return authorizationURL.String(), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", p.config.ClientID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return "", fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrProviderUnavailable)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	// client_secret_basic, the method every provider has to support. The ID and secret are form encoded first, RFC 6749 section 2.3.1.
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	res, err := p.client.Do(req)
+
+	if err != nil {
+		return "", fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrProviderUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	tokens := tokenResponse{}
+
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("%s - token response with status %d: %v: %w", helper.GetFunctionName(), res.StatusCode, err, ErrProviderUnavailable)
+	}
+
+	// The provider refused the code, e.g. it has expired, been used already or the code verifier is wrong
+	if res.StatusCode == http.StatusBadRequest || res.StatusCode == http.StatusUnauthorized {
+		return "", fmt.Errorf("%s - %s %s: %w", helper.GetFunctionName(), tokens.Error, tokens.ErrorDescription, ErrLoginFailed)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s - token response with status %d: %w", helper.GetFunctionName(), res.StatusCode, ErrProviderUnavailable)
+	}
+
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("%s - token response has no ID token: %w", helper.GetFunctionName(), ErrLoginFailed)
+	}
+
+	return tokens.IDToken, nil
+}
+
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+
+	if _, err := parser.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return p.verificationKey(ctx, token)
+	}); err != nil {
+		// The token may be fine if the provider's keys couldn't be fetched
+		if errors.Is(err, ErrProviderUnavailable) {
+			return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), err)
+		}
+
+		return nil, fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrLoginFailed)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%s - ID token has no subject: %w", helper.GetFunctionName(), ErrLoginFailed)
+	}
+
+	// A token for several clients has to name this app as the one it was issued to, OpenID Connect Core section 3.1.3.7
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return nil, fmt.Errorf("%s - ID token was issued to %q: %w", helper.GetFunctionName(), claims.AuthorizedParty, ErrLoginFailed)
+	}
+
+	// The nonce ties the token to the sign in this browser started, so a token from another sign in can't be replayed
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%s - ID token nonce doesn't match: %w", helper.GetFunctionName(), ErrLoginFailed)
+	}
+
+	return claims, nil
+}
+
+// discover returns the provider's metadata, fetching its discovery document the first time it's needed
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.metadataMx.Lock()
+	defer p.metadataMx.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	discovered := &metadata{}
+
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+discoveryPath, discovered); err != nil {
+		return nil, err
+	}
+
+	// A document for another issuer could point the app at someone else's keys, OpenID Connect Discovery section 4.3
+	if discovered.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("%s - discovery document is for issuer %q, not %q: %w", helper.GetFunctionName(), discovered.Issuer, p.config.Issuer, ErrProviderUnavailable)
+	}
+
+	if discovered.AuthorizationEndpoint == "" || discovered.TokenEndpoint == "" || discovered.JWKSURI == "" {
+		return nil, fmt.Errorf("%s - discovery document for %s is missing an endpoint: %w", helper.GetFunctionName(), p.config.Issuer, ErrProviderUnavailable)
+	}
+
+	p.logger.InfoLog(helper.GetFunctionName(), fmt.Sprintf("Discovered OIDC provider %s at %s", p.config.Name, p.config.Issuer))
+	p.metadata = discovered
+
+	return p.metadata, nil
+}
+
+/*
+verificationKey returns the provider's key that token was signed with. The keys are fetched again when the token's key ID isn't
+one of them, at most once every keysRefreshInterval. A token without a key ID can only be verified while the provider has one key.
+*/
+func (p *Provider) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	keyID, _ := token.Header["kid"].(string)
+
+	metadata, err := p.discover(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.keysMx.Lock()
+	defer p.keysMx.Unlock()
+
+	key, ok := p.findKey(keyID)
+
+	if !ok && time.Since(p.keysFetchedAt) >= keysRefreshInterval {
+		keySet := data.JSONWebKeySet{}
+
+		if err := p.getJSON(ctx, metadata.JWKSURI, &keySet); err != nil {
+			return nil, err
+		}
+
+		p.keys = parseKeySet(keySet)
+		p.keysFetchedAt = time.Now()
+
+		key, ok = p.findKey(keyID)
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("provider has no key %q", keyID)
+	}
+
+	if key.algorithm != "" && key.algorithm != token.Method.Alg() {
+		return nil, fmt.Errorf("key %q is for %s, not %s", keyID, key.algorithm, token.Method.Alg())
+	}
+
+	return key.key, nil
+}
+
+func (p *Provider) findKey(keyID string) (publicKey, bool) {
+	if keyID == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[keyID]
+
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if err != nil {
+		return fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrProviderUnavailable)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("%s - %v: %w", helper.GetFunctionName(), err, ErrProviderUnavailable)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s - %s returned status %d: %w", helper.GetFunctionName(), url, res.StatusCode, ErrProviderUnavailable)
+	}
+
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("%s - %s: %v: %w", helper.GetFunctionName(), url, err, ErrProviderUnavailable)
+	}
+
+	return nil
+}
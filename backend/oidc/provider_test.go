@@ -0,0 +1,363 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"dohabits/helper"
+	"dohabits/logger"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "dohabits"
+	testClientSecret = "client&secret"
+	testRedirectURL  = "https://dohabits.example.com/oidc/company/callback"
+)
+
+func newTestProvider(t *testing.T) (*MockIdP, *Provider) {
+	t.Helper()
+
+	idp, err := NewMockIdP(testClientID, testClientSecret)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	idp.User = MockIdPUser{Subject: "idp-user-1", Email: "johndoe1@example.com", EmailVerified: true, GivenName: "John", FamilyName: "Doe"}
+
+	server := httptest.NewServer(idp)
+	t.Cleanup(server.Close)
+	idp.Issuer = server.URL
+
+	provider, err := NewProvider(Config{
+		Name:         "company",
+		Issuer:       server.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       defaultScopes,
+	}, logger.NewLogger(0))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	return idp, provider
+}
+
+// signIn follows the authorization URL like the user's browser and returns the code and state the provider redirects back with
+func signIn(t *testing.T, provider *Provider, state, nonce, codeVerifier string) (string, string) {
+	t.Helper()
+
+	authCodeURL, err := provider.AuthCodeURL(context.Background(), state, nonce, CodeChallenge(codeVerifier))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }}
+
+	res, err := client.Get(authCodeURL)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	res.Body.Close()
+
+	location, err := url.Parse(res.Header.Get("Location"))
+
+	if err != nil || res.StatusCode != http.StatusFound {
+		t.Fatalf("%s - Failed - status=%d, location=%s", helper.GetFunctionName(), res.StatusCode, res.Header.Get("Location"))
+	}
+
+	if location.Scheme+"://"+location.Host+location.Path != testRedirectURL {
+		t.Fatalf("%s - Failed - redirected to %s", helper.GetFunctionName(), location)
+	}
+
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestProviderSignIn(t *testing.T) {
+	idp, provider := newTestProvider(t)
+	ctx := context.Background()
+
+	codeVerifier, err := NewCodeVerifier()
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	code, state := signIn(t, provider, "state-1", "nonce-1", codeVerifier)
+
+	if state != "state-1" {
+		t.Fatalf("%s - Failed - state=%s", helper.GetFunctionName(), state)
+	}
+
+	idToken, err := provider.Exchange(ctx, code, codeVerifier)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, idToken, "nonce-1")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if claims.Subject != idp.User.Subject || claims.Email != idp.User.Email || !bool(claims.EmailVerified) || claims.GivenName != "John" || claims.FamilyName != "Doe" {
+		t.Errorf("%s - Failed - claims=%+v", helper.GetFunctionName(), claims)
+	}
+
+	// The provider only lets a code be exchanged once
+	if _, err := provider.Exchange(ctx, code, codeVerifier); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("%s - Failed - reused code err=%v, want %v", helper.GetFunctionName(), err, ErrLoginFailed)
+	}
+
+	// An intercepted code is no use without the code verifier
+	code, _ = signIn(t, provider, "state-2", "nonce-2", codeVerifier)
+
+	if _, err := provider.Exchange(ctx, code, "not-the-code-verifier"); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("%s - Failed - wrong code verifier err=%v, want %v", helper.GetFunctionName(), err, ErrLoginFailed)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp, provider := newTestProvider(t)
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, minRSAKeyBits)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	claims := func(change func(jwt.MapClaims)) jwt.MapClaims {
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iss":            idp.Issuer,
+			"sub":            "idp-user-1",
+			"aud":            testClientID,
+			"iat":            now.Unix(),
+			"exp":            now.Add(5 * time.Minute).Unix(),
+			"nonce":          "nonce-1",
+			"email":          "johndoe1@example.com",
+			"email_verified": "true",
+		}
+
+		if change != nil {
+			change(claims)
+		}
+
+		return claims
+	}
+
+	signed := func(change func(jwt.MapClaims)) string {
+		token, err := idp.SignIDToken(claims(change))
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		return token
+	}
+
+	wrongKey := jwt.NewWithClaims(jwt.SigningMethodRS256, claims(nil))
+	wrongKey.Header["kid"] = "mock-key-1"
+	wrongKeyToken, _ := wrongKey.SignedString(otherKey)
+
+	unsignedToken, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims(nil)).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil)).SignedString([]byte(testClientSecret))
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "Test a token from the provider", token: signed(nil)},
+		{name: "Test a token for this app and others", token: signed(func(c jwt.MapClaims) { c["aud"] = []string{"other-app", testClientID}; c["azp"] = testClientID })},
+		{name: "Test a token for another app", token: signed(func(c jwt.MapClaims) { c["aud"] = "other-app" }), wantErr: true},
+		{name: "Test a token issued to another app", token: signed(func(c jwt.MapClaims) { c["aud"] = []string{"other-app", testClientID}; c["azp"] = "other-app" }), wantErr: true},
+		{name: "Test a token from another issuer", token: signed(func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }), wantErr: true},
+		{name: "Test a token for another sign in", token: signed(func(c jwt.MapClaims) { c["nonce"] = "nonce-2" }), wantErr: true},
+		{name: "Test an expired token", token: signed(func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }), wantErr: true},
+		{name: "Test a token without an expiry", token: signed(func(c jwt.MapClaims) { delete(c, "exp") }), wantErr: true},
+		{name: "Test a token without a subject", token: signed(func(c jwt.MapClaims) { delete(c, "sub") }), wantErr: true},
+		{name: "Test a token signed with another key", token: wrongKeyToken, wantErr: true},
+		{name: "Test an unsigned token", token: unsignedToken, wantErr: true},
+		{name: "Test a token signed with the client secret", token: hmacToken, wantErr: true},
+		{name: "Test a token that isn't a JWT", token: "not-a-jwt", wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(ctx, val.token, "nonce-1")
+
+			if (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+				return
+			}
+
+			if err != nil && !errors.Is(err, ErrLoginFailed) {
+				t.Errorf("%s - Failed - err=%v, want %v", helper.GetFunctionName(), err, ErrLoginFailed)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	idp, provider := newTestProvider(t)
+	ctx := context.Background()
+
+	claims := jwt.MapClaims{
+		"iss":   idp.Issuer,
+		"sub":   "idp-user-1",
+		"aud":   testClientID,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(5 * time.Minute).Unix(),
+		"nonce": "nonce-1",
+	}
+
+	oldToken, _ := idp.SignIDToken(claims)
+
+	if _, err := provider.VerifyIDToken(ctx, oldToken, "nonce-1"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if err := idp.RotateKey(); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	newToken, _ := idp.SignIDToken(claims)
+
+	// The keys were fetched moments ago, so the new key isn't fetched yet
+	if _, err := provider.VerifyIDToken(ctx, newToken, "nonce-1"); !errors.Is(err, ErrLoginFailed) {
+		t.Fatalf("%s - Failed - err=%v, want %v", helper.GetFunctionName(), err, ErrLoginFailed)
+	}
+
+	provider.keysFetchedAt = time.Now().Add(-keysRefreshInterval)
+
+	if _, err := provider.VerifyIDToken(ctx, newToken, "nonce-1"); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// The old key has gone from the provider's key set
+	if _, err := provider.VerifyIDToken(ctx, oldToken, "nonce-1"); !errors.Is(err, ErrLoginFailed) {
+		t.Fatalf("%s - Failed - err=%v, want %v", helper.GetFunctionName(), err, ErrLoginFailed)
+	}
+}
+
+func TestProviderDiscovery(t *testing.T) {
+	idp, provider := newTestProvider(t)
+
+	// A discovery document claiming to be another issuer is refused
+	issuer := idp.Issuer
+	idp.Issuer = "https://evil.example.com"
+
+	if _, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("%s - Failed - err=%v, want %v", helper.GetFunctionName(), err, ErrProviderUnavailable)
+	}
+
+	idp.Issuer = issuer
+
+	authCodeURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	parsedURL, err := url.Parse(authCodeURL)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	query := parsedURL.Query()
+
+	for key, want := range map[string]string{"client_id": testClientID, "redirect_uri": testRedirectURL, "scope": "openid email profile", "state": "state", "nonce": "nonce", "code_challenge": "challenge", "code_challenge_method": "S256"} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s - Failed - %s=%q, want %q", helper.GetFunctionName(), key, got, want)
+		}
+	}
+
+	// A provider that can't be reached doesn't stop the app starting, it fails the sign in
+	unreachable, err := NewProvider(Config{Name: "down", Issuer: "http://127.0.0.1:1", ClientID: testClientID}, logger.NewLogger(0))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	if _, err := unreachable.AuthCodeURL(context.Background(), "state", "nonce", "challenge"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("%s - Failed - err=%v, want %v", helper.GetFunctionName(), err, ErrProviderUnavailable)
+	}
+}
+
+func TestLoadProviders(t *testing.T) {
+	t.Setenv("OIDC_COMPANY_ISSUER", "https://login.example.com")
+	t.Setenv("OIDC_COMPANY_CLIENT_ID", "dohabits")
+	t.Setenv("OIDC_MY_IDP_ISSUER", "https://idp.example.com/realms/staff")
+	t.Setenv("OIDC_MY_IDP_CLIENT_ID", "dohabits")
+	t.Setenv("OIDC_MY_IDP_SCOPES", "email")
+	t.Setenv("OIDC_MY_IDP_REDIRECT_URL", "https://app.example.com/signin")
+	t.Setenv("OIDC_INSECURE_ISSUER", "http://login.example.com")
+	t.Setenv("OIDC_INSECURE_CLIENT_ID", "dohabits")
+
+	testCases := []struct {
+		name      string
+		providers string
+		wantErr   bool
+	}{
+		{name: "Test no providers", providers: ""},
+		{name: "Test two providers", providers: "company, my-idp"},
+		{name: "Test a provider without settings", providers: "company,missing", wantErr: true},
+		{name: "Test a provider listed twice", providers: "company,company", wantErr: true},
+		{name: "Test a provider name with upper case letters", providers: "Company", wantErr: true},
+		{name: "Test an issuer without TLS", providers: "insecure", wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			providers, err := LoadProviders(val.providers, "https://dohabits.example.com/", logger.NewLogger(0))
+
+			if (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+			}
+
+			if val.wantErr {
+				return
+			}
+
+			names, _ := ProviderNames(val.providers)
+
+			if len(providers) != len(names) {
+				t.Errorf("%s - Failed - got %d providers, want %d", helper.GetFunctionName(), len(providers), len(names))
+			}
+		})
+	}
+
+	providers, err := LoadProviders("company,my-idp", "https://dohabits.example.com/", logger.NewLogger(0))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	company := providers["company"].(*Provider).config
+	myIdP := providers["my-idp"].(*Provider).config
+
+	if company.RedirectURL != "https://dohabits.example.com/oidc/company/callback" || len(company.Scopes) != 3 {
+		t.Errorf("%s - Failed - company=%+v", helper.GetFunctionName(), company)
+	}
+
+	if myIdP.RedirectURL != "https://app.example.com/signin" || len(myIdP.Scopes) != 2 || myIdP.Scopes[0] != "openid" {
+		t.Errorf("%s - Failed - my-idp=%+v", helper.GetFunctionName(), myIdP)
+	}
+}
@@ -0,0 +1,96 @@
+package oidc
+
+import (
+	"dohabits/helper"
+	"dohabits/logger"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// defaultScopes are asked for when OIDC_<NAME>_SCOPES isn't set, enough to find the user's account or create one
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// validProviderName keeps provider names usable in both paths and environment variable names
+var validProviderName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ProviderNames splits OIDC_PROVIDERS, a comma-separated list of lower case names such as "company,google"
+func ProviderNames(providers string) ([]string, error) {
+	names := []string{}
+
+	for _, name := range strings.Split(providers, ",") {
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			continue
+		}
+
+		if !validProviderName.MatchString(name) {
+			return nil, fmt.Errorf("%s - OIDC provider name %q must be lower case letters and digits, optionally separated by hyphens", helper.GetFunctionName(), name)
+		}
+
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("%s - OIDC provider %q is listed more than once", helper.GetFunctionName(), name)
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// ProviderEnvVar returns the name of the environment variable with a provider's setting, e.g. OIDC_COMPANY_ISSUER for company's ISSUER
+func ProviderEnvVar(name, setting string) string {
+	return fmt.Sprintf("OIDC_%s_%s", strings.ToUpper(strings.ReplaceAll(name, "-", "_")), setting)
+}
+
+/*
+LoadProviders returns the identity providers named in OIDC_PROVIDERS by their name, reading each one's OIDC_<NAME>_ISSUER,
+OIDC_<NAME>_CLIENT_ID and optional OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_SCOPES and OIDC_<NAME>_REDIRECT_URL. The redirect URL
+defaults to the frontend's /oidc/<name>/callback page under siteURL. With no providers named, users can only log in with a password.
+*/
+func LoadProviders(providers, siteURL string, logger logger.ILogger) (map[string]IProvider, error) {
+	names, err := ProviderNames(providers)
+
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := map[string]IProvider{}
+
+	for _, name := range names {
+		config := Config{
+			Name:         name,
+			Issuer:       os.Getenv(ProviderEnvVar(name, "ISSUER")),
+			ClientID:     os.Getenv(ProviderEnvVar(name, "CLIENT_ID")),
+			ClientSecret: os.Getenv(ProviderEnvVar(name, "CLIENT_SECRET")),
+			RedirectURL:  os.Getenv(ProviderEnvVar(name, "REDIRECT_URL")),
+			Scopes:       strings.Fields(os.Getenv(ProviderEnvVar(name, "SCOPES"))),
+		}
+
+		if config.RedirectURL == "" {
+			config.RedirectURL = fmt.Sprintf("%s/oidc/%s/callback", strings.TrimRight(siteURL, "/"), name)
+		}
+
+		if len(config.Scopes) == 0 {
+			config.Scopes = defaultScopes
+		}
+
+		// Without openid the provider doesn't return an ID token
+		if !slices.Contains(config.Scopes, "openid") {
+			config.Scopes = append([]string{"openid"}, config.Scopes...)
+		}
+
+		provider, err := NewProvider(config, logger)
+
+		if err != nil {
+			return nil, err
+		}
+
+		loaded[name] = provider
+	}
+
+	return loaded, nil
+}
@@ -15,13 +15,181 @@ func SetUpRoutes(app internal.IApp) {
 
 	http.HandleFunc(fmt.Sprintf("/%s/register", endpoint), app.GetMiddleware().MiddlewareList(app.GetAuthController().RegisterUserHandler, data.Middleware{HTTPMethod: http.MethodPost}))
 	http.HandleFunc(fmt.Sprintf("/%s/login", endpoint), app.GetMiddleware().MiddlewareList(app.GetAuthController().LoginHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/login/mfa", endpoint), app.GetMiddleware().MiddlewareList(app.GetAuthController().LoginMFAHandler, data.Middleware{HTTPMethod: http.MethodPost}))
 	http.HandleFunc(fmt.Sprintf("/%s/logout", endpoint), app.GetMiddleware().MiddlewareList(app.GetAuthController().LogoutHandler, data.Middleware{IsProtected: true, HTTPMethod: http.MethodPost}))
 	http.HandleFunc(fmt.Sprintf("/%s/refresh", endpoint), app.GetMiddleware().MiddlewareList(app.GetAuthController().RefreshHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/updateprofile", endpoint), app.GetMiddleware().MiddlewareList(app.GetAuthController().UpdateProfileHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPut}))
+	http.HandleFunc(fmt.Sprintf("/%s/password/forgot", endpoint), app.GetMiddleware().MiddlewareList(app.GetPasswordController().ForgotPasswordHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/password/reset", endpoint), app.GetMiddleware().MiddlewareList(app.GetPasswordController().ResetPasswordHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/password/change", endpoint), app.GetMiddleware().MiddlewareList(app.GetPasswordController().ChangePasswordHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/verify-email", endpoint), app.GetMiddleware().MiddlewareList(app.GetEmailVerificationController().VerifyEmailHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/verify-email/change", endpoint), app.GetMiddleware().MiddlewareList(app.GetEmailVerificationController().ConfirmEmailChangeHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/verify-email/resend", endpoint), app.GetMiddleware().MiddlewareList(app.GetEmailVerificationController().ResendVerificationEmailHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/mfa/totp/enrol", endpoint), app.GetMiddleware().MiddlewareList(app.GetMFAController().EnrolTOTPHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/mfa/totp/confirm", endpoint), app.GetMiddleware().MiddlewareList(app.GetMFAController().ConfirmTOTPHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/mfa/totp/disable", endpoint), app.GetMiddleware().MiddlewareList(app.GetMFAController().DisableTOTPHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPost}))
 
-	http.HandleFunc(fmt.Sprintf("/%s/createhabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().CreateHabitsHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPost}))
-	http.HandleFunc(fmt.Sprintf("/%s/retrievehabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().RetrieveHabitsHandler, data.Middleware{IsProtected: true, HTTPMethod: http.MethodGet}))
-	http.HandleFunc(fmt.Sprintf("/%s/retrievehabits", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().RetrieveAllHabitsHandler, data.Middleware{IsProtected: true, HTTPMethod: http.MethodGet}))
-	http.HandleFunc(fmt.Sprintf("/%s/updatehabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().UpdateHabitsHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPut}))
-	http.HandleFunc(fmt.Sprintf("/%s/updatehabits", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().UpdateAllHabitsHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPut}))
-	http.HandleFunc(fmt.Sprintf("/%s/deletehabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().DeleteHabitsHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+	http.HandleFunc(fmt.Sprintf("/%s/createhabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().CreateHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	http.HandleFunc(fmt.Sprintf("/%s/retrievehabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().RetrieveHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsRead, HTTPMethod: http.MethodGet}))
+	http.HandleFunc(fmt.Sprintf("/%s/retrievehabits", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().RetrieveAllHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsRead, HTTPMethod: http.MethodGet}))
+	http.HandleFunc(fmt.Sprintf("/%s/updatehabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().UpdateHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodPut}))
+	http.HandleFunc(fmt.Sprintf("/%s/updatehabits", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().UpdateAllHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodPut}))
+	http.HandleFunc(fmt.Sprintf("/%s/deletehabit", endpoint), app.GetMiddleware().MiddlewareList(app.GetHabitsController().DeleteHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+
+	// The RESTful resource API runs alongside the verb-style routes above so existing clients keep working
+	habitsRouter := NewHabitsRouter(app)
+	http.Handle(fmt.Sprintf("/%s/habits", endpoint), habitsRouter)
+	http.Handle(fmt.Sprintf("/%s/habits/", endpoint), habitsRouter)
+
+	sessionsRouter := NewSessionsRouter(app)
+	http.Handle(fmt.Sprintf("/%s/sessions", endpoint), sessionsRouter)
+	http.Handle(fmt.Sprintf("/%s/sessions/", endpoint), sessionsRouter)
+
+	personalAccessTokensRouter := NewPersonalAccessTokensRouter(app)
+	http.Handle(fmt.Sprintf("/%s/tokens", endpoint), personalAccessTokensRouter)
+	http.Handle(fmt.Sprintf("/%s/tokens/", endpoint), personalAccessTokensRouter)
+
+	meRouter := NewMeRouter(app)
+	http.Handle(fmt.Sprintf("/%s/me", endpoint), meRouter)
+	http.Handle(fmt.Sprintf("/%s/me/", endpoint), meRouter)
+
+	http.Handle(fmt.Sprintf("/%s/oidc/", endpoint), NewOIDCRouter(app))
+
+	http.Handle(fmt.Sprintf("/%s/admin/", endpoint), NewAdminRouter(app))
+
+	// Published at the well-known path rather than under the API so other services can find it
+	http.HandleFunc("/.well-known/jwks.json", app.GetMiddleware().MiddlewareList(app.GetKeysController().JSONWebKeySetHandler, data.Middleware{HTTPMethod: http.MethodGet}))
+}
+
+/*
+NewHabitsRouter returns the /habits resource API on its own mux using Go 1.22 method and path patterns.
+The controllers read {habitId} and {completionDate} with r.PathValue. When a path matches but its method doesn't,
+the mux itself replies 405 Method Not Allowed with an Allow header listing the registered methods.
+*/
+func NewHabitsRouter(app internal.IApp) *http.ServeMux {
+	endpoint := fmt.Sprintf("%s/%s", app.GetAPIName(), app.GetAPIVersion())
+	habitsController := app.GetHabitsController()
+	mw := app.GetMiddleware()
+
+	habits := fmt.Sprintf("/%s/habits", endpoint)
+	habit := habits + "/{habitId}"
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+habits, mw.MiddlewareList(habitsController.RetrieveAllHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsRead, HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("POST "+habits, mw.MiddlewareList(habitsController.CreateHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	mux.HandleFunc("PATCH "+habits, mw.MiddlewareList(habitsController.UpdateAllHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodPatch}))
+
+	mux.HandleFunc("GET "+habit, mw.MiddlewareList(habitsController.RetrieveHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsRead, HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("PATCH "+habit, mw.MiddlewareList(habitsController.UpdateHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodPatch}))
+	mux.HandleFunc("DELETE "+habit, mw.MiddlewareList(habitsController.DeleteHabitsHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+
+	mux.HandleFunc("POST "+habit+"/completions", mw.MiddlewareList(habitsController.CreateCompletionHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	mux.HandleFunc("DELETE "+habit+"/completions/{completionDate}", mw.MiddlewareList(habitsController.DeleteCompletionHandler, data.Middleware{IsProtected: true, Scope: data.ScopeHabitsWrite, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+
+	return mux
+}
+
+// NewSessionsRouter returns the /sessions API, which lets a user see where they're logged in and log out of any of them
+func NewSessionsRouter(app internal.IApp) *http.ServeMux {
+	endpoint := fmt.Sprintf("%s/%s", app.GetAPIName(), app.GetAPIVersion())
+	authController := app.GetAuthController()
+	mw := app.GetMiddleware()
+
+	sessions := fmt.Sprintf("/%s/sessions", endpoint)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+sessions, mw.MiddlewareList(authController.RetrieveSessionsHandler, data.Middleware{IsProtected: true, HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("DELETE "+sessions, mw.MiddlewareList(authController.DeleteSessionsHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+	mux.HandleFunc("DELETE "+sessions+"/{sessionId}", mw.MiddlewareList(authController.DeleteSessionHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+
+	return mux
+}
+
+/*
+NewPersonalAccessTokensRouter returns the /tokens API, which lets a user create, list and revoke personal access tokens. None of
+its routes have a Scope, so they can't be used with a personal access token, only after logging in.
+*/
+func NewPersonalAccessTokensRouter(app internal.IApp) *http.ServeMux {
+	endpoint := fmt.Sprintf("%s/%s", app.GetAPIName(), app.GetAPIVersion())
+	personalAccessTokenController := app.GetPersonalAccessTokenController()
+	mw := app.GetMiddleware()
+
+	tokens := fmt.Sprintf("/%s/tokens", endpoint)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+tokens, mw.MiddlewareList(personalAccessTokenController.RetrievePersonalAccessTokensHandler, data.Middleware{IsProtected: true, HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("POST "+tokens, mw.MiddlewareList(personalAccessTokenController.CreatePersonalAccessTokenHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	mux.HandleFunc("DELETE "+tokens+"/{tokenId}", mw.MiddlewareList(personalAccessTokenController.DeletePersonalAccessTokenHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+
+	return mux
+}
+
+/*
+NewMeRouter returns the /me API, which lets a user update their profile, change their email address, download their personal data
+and delete their account.
+None of its routes have a Scope, so they can't be used with a personal access token, only after logging in.
+*/
+func NewMeRouter(app internal.IApp) *http.ServeMux {
+	endpoint := fmt.Sprintf("%s/%s", app.GetAPIName(), app.GetAPIVersion())
+	authController := app.GetAuthController()
+	emailVerificationController := app.GetEmailVerificationController()
+	exportController := app.GetExportController()
+	mw := app.GetMiddleware()
+
+	me := fmt.Sprintf("/%s/me", endpoint)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("PATCH "+me, mw.MiddlewareList(authController.UpdateProfileHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPatch}))
+	mux.HandleFunc("DELETE "+me, mw.MiddlewareList(authController.DeleteAccountHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodDelete}))
+	mux.HandleFunc("POST "+me+"/email", mw.MiddlewareList(emailVerificationController.ChangeEmailHandler, data.Middleware{IsProtected: true, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	mux.HandleFunc("GET "+me+"/export", mw.MiddlewareList(exportController.ExportHandler, data.Middleware{IsProtected: true, HTTPMethod: http.MethodGet}))
+
+	return mux
+}
+
+/*
+NewOIDCRouter returns the /oidc API for signing in with the identity providers in OIDC_PROVIDERS. The login route sends the browser
+to the provider, which sends it back to the frontend, and the frontend posts the code and state it came back with to the callback.
+*/
+func NewOIDCRouter(app internal.IApp) *http.ServeMux {
+	endpoint := fmt.Sprintf("%s/%s", app.GetAPIName(), app.GetAPIVersion())
+	authController := app.GetAuthController()
+	mw := app.GetMiddleware()
+
+	provider := fmt.Sprintf("/%s/oidc/{provider}", endpoint)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+provider+"/login", mw.MiddlewareList(authController.StartOIDCLoginHandler, data.Middleware{HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("POST "+provider+"/callback", mw.MiddlewareList(authController.LoginOIDCHandler, data.Middleware{HTTPMethod: http.MethodPost}))
+
+	return mux
+}
+
+/*
+NewAdminRouter returns the /admin API for support staff. Looking users up and logging them out needs data.RoleSupport, and
+disabling or enabling an account needs data.RoleAdmin. None of its routes have a Scope, so personal access tokens can't use them.
+*/
+func NewAdminRouter(app internal.IApp) *http.ServeMux {
+	endpoint := fmt.Sprintf("%s/%s", app.GetAPIName(), app.GetAPIVersion())
+	adminController := app.GetAdminController()
+	mw := app.GetMiddleware()
+
+	users := fmt.Sprintf("/%s/admin/users", endpoint)
+	user := users + "/{userId}"
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+users, mw.MiddlewareList(adminController.SearchUsersHandler, data.Middleware{IsProtected: true, Role: data.RoleSupport, HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("GET "+user, mw.MiddlewareList(adminController.RetrieveUserHandler, data.Middleware{IsProtected: true, Role: data.RoleSupport, HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("GET "+user+"/habits", mw.MiddlewareList(adminController.RetrieveUserHabitsHandler, data.Middleware{IsProtected: true, Role: data.RoleSupport, HTTPMethod: http.MethodGet}))
+	mux.HandleFunc("POST "+user+"/logout", mw.MiddlewareList(adminController.LogoutUserHandler, data.Middleware{IsProtected: true, Role: data.RoleSupport, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	mux.HandleFunc("POST "+user+"/disable", mw.MiddlewareList(adminController.DisableUserHandler, data.Middleware{IsProtected: true, Role: data.RoleAdmin, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+	mux.HandleFunc("POST "+user+"/enable", mw.MiddlewareList(adminController.EnableUserHandler, data.Middleware{IsProtected: true, Role: data.RoleAdmin, CSRFRequired: true, HTTPMethod: http.MethodPost}))
+
+	return mux
 }
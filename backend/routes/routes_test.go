@@ -0,0 +1,572 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"dohabits/controller"
+	"dohabits/data"
+	"dohabits/db"
+	"dohabits/helper"
+	"dohabits/internal"
+	"dohabits/logger"
+	"dohabits/loginattempts"
+	"dohabits/mail"
+	"dohabits/middleware"
+	"dohabits/middleware/session"
+	"dohabits/model"
+	"dohabits/oidc"
+	"dohabits/validation"
+	"dohabits/view"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestApp() *internal.App {
+	logger := logger.NewLogger(0)
+	db := db.NewMockDB(logger)
+	keys := session.NewHMACKeySet("secret")
+	jwtTokens := session.NewJSONWebToken(keys, db, logger)
+	csrfTokens := session.NewMockCSRFToken(logger)
+	personalAccessTokens := session.NewPersonalAccessTokens(db, logger)
+
+	emailVerificationModel := model.NewEmailVerificationModel(logger, db, mail.NewMockMailer(logger), session.NewEmailVerificationToken(keys, logger), "http://localhost")
+	loginAttempts := loginattempts.NewLimiter(loginattempts.NewMemoryStore(), loginattempts.DefaultPolicy(), logger)
+	mfaModel := model.NewMFAModel(logger, db, session.NewMFAToken(keys, logger), loginAttempts)
+	oidcModel := model.NewOIDCModel(logger, db, session.NewOIDCStateToken(keys, logger), map[string]oidc.IProvider{})
+	authController := controller.NewAuthController(model.NewAuthModel(logger, db, emailVerificationModel, mfaModel, oidcModel, loginAttempts, validation.DefaultPasswordPolicy(), data.UnverifiedUserAccessAll), view.NewAuthView(logger), jwtTokens, csrfTokens, logger, nil)
+	habitsController := controller.NewHabitsController(model.NewHabitsModel(logger, db, data.UnverifiedUserAccessAll), view.NewHabitsView(logger), logger)
+	keysController := controller.NewKeysController(keys, view.NewKeysView(logger), logger)
+	passwordController := controller.NewPasswordController(model.NewPasswordModel(logger, db, mail.NewMockMailer(logger), validation.DefaultPasswordPolicy(), "http://localhost"), view.NewPasswordView(logger), logger)
+	emailVerificationController := controller.NewEmailVerificationController(emailVerificationModel, view.NewEmailVerificationView(logger), logger)
+	mfaController := controller.NewMFAController(mfaModel, view.NewMFAView(logger), logger)
+	personalAccessTokenController := controller.NewPersonalAccessTokenController(model.NewPersonalAccessTokenModel(logger, db, personalAccessTokens), view.NewPersonalAccessTokenView(logger), logger)
+	adminController := controller.NewAdminController(model.NewAdminModel(logger, db), view.NewAdminView(logger), logger)
+	exportController := controller.NewExportController(model.NewExportModel(logger, db), view.NewExportView(logger), logger)
+	mw := middleware.NewMiddleware(jwtTokens, csrfTokens, personalAccessTokens, logger)
+
+	return internal.NewApp(authController, habitsController, keysController, passwordController, emailVerificationController, mfaController, personalAccessTokenController, adminController, exportController, db, mw, logger, "dohabitsapp", "v1", "1.0.0", "8080", jwtTokens)
+}
+
+func TestNewHabitsRouter(t *testing.T) {
+	router := NewHabitsRouter(newTestApp())
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Test collection route is protected",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/habits",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test habit route is protected",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/habits/1",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test completion route is protected",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/habits/1/completions/2025-01-01",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test unknown method on the collection",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/habits",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "GET, HEAD, PATCH, POST",
+		},
+		{
+			name:       "Test unknown method on a habit",
+			method:     http.MethodPut,
+			path:       "/dohabitsapp/v1/habits/1",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "DELETE, GET, HEAD, PATCH",
+		},
+		{
+			name:       "Test unknown method on completions",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/habits/1/completions",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "POST",
+		},
+		{
+			name:       "Test unknown path",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/habits/1/other",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+
+			if allow := w.Header().Get("Allow"); allow != val.wantAllow {
+				t.Errorf("%s - Failed - Allow = %q, want=%q", helper.GetFunctionName(), allow, val.wantAllow)
+			}
+		})
+	}
+}
+
+func TestNewSessionsRouter(t *testing.T) {
+	router := NewSessionsRouter(newTestApp())
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Test sessions route is protected",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/sessions",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test session route is protected",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/sessions/abc",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test unknown method on a session",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/sessions/abc",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "DELETE",
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+
+			if allow := w.Header().Get("Allow"); allow != val.wantAllow {
+				t.Errorf("%s - Failed - Allow = %q, want=%q", helper.GetFunctionName(), allow, val.wantAllow)
+			}
+		})
+	}
+}
+
+func TestNewPersonalAccessTokensRouter(t *testing.T) {
+	app := newTestApp()
+	router := NewPersonalAccessTokensRouter(app)
+	personalAccessTokens := session.NewPersonalAccessTokens(app.GetDB(), app.GetLogger())
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	token, tokenHash, err := personalAccessTokens.GeneratePersonalAccessToken()
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	personalAccessToken := &data.PersonalAccessToken{TokenID: "script", UserID: "1", Name: "Script", TokenHash: tokenHash, Scopes: data.PersonalAccessTokenScopes}
+
+	if err := app.GetDB().CreatePersonalAccessToken(context.Background(), personalAccessToken); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Test tokens route is protected",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/tokens",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test a personal access token can't list tokens",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/tokens",
+			token:      token,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test a personal access token can't revoke tokens",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/tokens/script",
+			token:      token,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test unknown method on a token",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/tokens/script",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "DELETE",
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, nil)
+
+			if val.token != "" {
+				req.Header.Set("Authorization", "Bearer "+val.token)
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+
+			if allow := w.Header().Get("Allow"); allow != val.wantAllow {
+				t.Errorf("%s - Failed - Allow = %q, want=%q", helper.GetFunctionName(), allow, val.wantAllow)
+			}
+		})
+	}
+}
+
+func TestNewMeRouter(t *testing.T) {
+	app := newTestApp()
+	router := NewMeRouter(app)
+	personalAccessTokens := session.NewPersonalAccessTokens(app.GetDB(), app.GetLogger())
+
+	originalMockPersonalAccessTokensState := make([]data.PersonalAccessToken, len(data.MockPersonalAccessTokens))
+	copy(originalMockPersonalAccessTokensState, data.MockPersonalAccessTokens)
+
+	defer func() { data.MockPersonalAccessTokens = originalMockPersonalAccessTokensState }()
+
+	token, tokenHash, err := personalAccessTokens.GeneratePersonalAccessToken()
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	personalAccessToken := &data.PersonalAccessToken{TokenID: "script", UserID: "1", Name: "Script", TokenHash: tokenHash, Scopes: data.PersonalAccessTokenScopes}
+
+	if err := app.GetDB().CreatePersonalAccessToken(context.Background(), personalAccessToken); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Test delete account route is protected",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/me",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test change email route is protected",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/me/email",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test a personal access token can't delete the account",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/me",
+			token:      token,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test export route is protected",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/me/export",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test a personal access token can't export the user's data",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/me/export",
+			token:      token,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test unknown method on me",
+			method:     http.MethodPut,
+			path:       "/dohabitsapp/v1/me",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "DELETE, PATCH",
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, nil)
+
+			if val.token != "" {
+				req.Header.Set("Authorization", "Bearer "+val.token)
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+
+			if allow := w.Header().Get("Allow"); allow != val.wantAllow {
+				t.Errorf("%s - Failed - Allow = %q, want=%q", helper.GetFunctionName(), allow, val.wantAllow)
+			}
+		})
+	}
+
+	accessToken, _, err := session.NewJSONWebToken(session.NewHMACKeySet("secret"), app.GetDB(), app.GetLogger()).GenerateJSONWebTokens("1", "johndoe1@example.com", "mock-session-1", nil)
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/dohabitsapp/v1/me/export", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("%s - Failed - HTTP Status Code = %d, Content-Type = %q, body=%s", helper.GetFunctionName(), w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	if _, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len())); err != nil {
+		t.Errorf("%s - Failed - the export can't be opened, err=%s", helper.GetFunctionName(), err)
+	}
+}
+
+func TestNewOIDCRouter(t *testing.T) {
+	router := NewOIDCRouter(newTestApp())
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Test login with a provider that isn't configured",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/oidc/company/login",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Test callback without the state cookie",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/oidc/company/callback",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Test unknown method on the callback",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/oidc/company/callback",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "POST",
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d", helper.GetFunctionName(), status, val.wantStatus)
+			}
+
+			if allow := w.Header().Get("Allow"); allow != val.wantAllow {
+				t.Errorf("%s - Failed - Allow = %q, want=%q", helper.GetFunctionName(), allow, val.wantAllow)
+			}
+		})
+	}
+}
+
+func TestNewAdminRouter(t *testing.T) {
+	app := newTestApp()
+	router := NewAdminRouter(app)
+	jwtTokens := session.NewJSONWebToken(session.NewHMACKeySet("secret"), app.GetDB(), app.GetLogger())
+
+	originalMockUsersState := make([]data.UserData, len(data.MockUsers))
+	copy(originalMockUsersState, data.MockUsers)
+
+	originalMockUserSessionState := make([]data.UserSession, len(data.MockUserSession))
+	copy(originalMockUserSessionState, data.MockUserSession)
+
+	defer func() {
+		data.MockUsers = originalMockUsersState
+		data.MockUserSession = originalMockUserSessionState
+	}()
+
+	accessToken := func(roles []string) string {
+		token, _, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "mock-session-1", roles)
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		return token
+	}
+
+	userToken := accessToken(nil)
+	supportToken := accessToken([]string{data.RoleSupport})
+	adminToken := accessToken([]string{data.RoleAdmin})
+
+	// A refresh token issued while the user was an admin still carries the role after it's taken away, until it's exchanged
+	_, demotedAdminRefreshToken, err := jwtTokens.GenerateJSONWebTokens("1", "johndoe1@example.com", "mock-session-1", []string{data.RoleAdmin})
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Test users route is protected",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/admin/users",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test a demoted admin's refresh token isn't an access token",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/admin/users",
+			token:      demotedAdminRefreshToken,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Test a user without a role can't search users",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/admin/users",
+			token:      userToken,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test support can search users",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/admin/users?q=jane",
+			token:      supportToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test support can't search with a limit that isn't a number",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/admin/users?limit=ten",
+			token:      supportToken,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Test support can see a user's habits",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/admin/users/2/habits",
+			token:      supportToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test support can't see a user that doesn't exist",
+			method:     http.MethodGet,
+			path:       "/dohabitsapp/v1/admin/users/999",
+			token:      supportToken,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Test support can log a user out",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/admin/users/4/logout",
+			token:      supportToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test support can't disable a user",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/admin/users/2/disable",
+			token:      supportToken,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "Test an admin can disable a user",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/admin/users/2/disable",
+			token:      adminToken,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Test an admin can't disable themselves",
+			method:     http.MethodPost,
+			path:       "/dohabitsapp/v1/admin/users/1/disable",
+			token:      adminToken,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "Test unknown method on a user",
+			method:     http.MethodDelete,
+			path:       "/dohabitsapp/v1/admin/users/2",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "GET, HEAD",
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			req := httptest.NewRequest(val.method, val.path, nil)
+
+			if val.token != "" {
+				req.Header.Set("Authorization", "Bearer "+val.token)
+			}
+
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if status := w.Code; status != val.wantStatus {
+				t.Errorf("%s - Failed - HTTP Status Code = %d, want=%d, body=%s", helper.GetFunctionName(), status, val.wantStatus, w.Body)
+			}
+
+			if allow := w.Header().Get("Allow"); allow != val.wantAllow {
+				t.Errorf("%s - Failed - Allow = %q, want=%q", helper.GetFunctionName(), allow, val.wantAllow)
+			}
+		})
+	}
+}
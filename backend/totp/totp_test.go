@@ -0,0 +1,102 @@
+package totp
+
+import (
+	"dohabits/helper"
+	"encoding/base32"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 secret of the RFC 6238 test vectors, "12345678901234567890"
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// The last 6 digits of the 8 digit codes in RFC 6238 appendix B
+	testCases := []struct {
+		name string
+		time int64
+		want string
+	}{
+		{name: "Test T=59", time: 59, want: "287082"},
+		{name: "Test T=1111111109", time: 1111111109, want: "081804"},
+		{name: "Test T=1111111111", time: 1111111111, want: "050471"},
+		{name: "Test T=1234567890", time: 1234567890, want: "005924"},
+		{name: "Test T=2000000000", time: 2000000000, want: "279037"},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			got, err := Code(rfcSecret, Step(time.Unix(val.time, 0)))
+
+			if err != nil || got != val.want {
+				t.Errorf("%s - Failed - got=%s, want=%s, err=%v", helper.GetFunctionName(), got, val.want, err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	generated, err := GenerateSecret()
+
+	if err != nil || len(generated) != 32 {
+		t.Fatalf("%s - Failed - secret=%s, err=%v", helper.GetFunctionName(), generated, err)
+	}
+
+	// A fixed secret and time, as with random ones a code from too long ago could match the current window by chance
+	secret := rfcSecret
+	now := time.Unix(1234567890, 0)
+	step := Step(now)
+
+	codeAt := func(step int64) string {
+		code, err := Code(secret, step)
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		return code
+	}
+
+	testCases := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOk   bool
+	}{
+		{name: "Test current code", code: codeAt(step), wantStep: step, wantOk: true},
+		{name: "Test previous code allows for clock drift", code: codeAt(step - 1), wantStep: step - 1, wantOk: true},
+		{name: "Test next code allows for clock drift", code: codeAt(step + 1), wantStep: step + 1, wantOk: true},
+		{name: "Test code from too long ago", code: codeAt(step - 2)},
+		{name: "Test code that's too short", code: "12345"},
+		{name: "Test code that isn't a number", code: "abcdef"},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			gotStep, ok := Validate(secret, val.code, now)
+
+			if ok != val.wantOk || (ok && gotStep != val.wantStep) {
+				t.Errorf("%s - Failed - got step=%d ok=%t, want step=%d ok=%t", helper.GetFunctionName(), gotStep, ok, val.wantStep, val.wantOk)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("DoHabits", "john doe@example.com", "JBSWY3DPEHPK3PXP"))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	query := uri.Query()
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/DoHabits:john doe@example.com" {
+		t.Errorf("%s - Failed - got=%s", helper.GetFunctionName(), uri)
+	}
+
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "DoHabits" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("%s - Failed - got=%s", helper.GetFunctionName(), uri)
+	}
+}
@@ -1,18 +1,39 @@
 package validation
 
 import (
+	"dohabits/apperror"
 	"dohabits/data"
 	"dohabits/helper"
 	"dohabits/logger"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
 	"regexp"
+	"time"
 )
 
+var (
+	ErrInvalidHabit           = apperror.New(http.StatusUnprocessableEntity, apperror.CodeValidationFailed, "Habit is invalid")
+	ErrInvalidCompletionDate  = apperror.New(http.StatusUnprocessableEntity, apperror.CodeValidationFailed, "Completion date is invalid")
+	ErrInvalidCompletionValue = apperror.New(http.StatusUnprocessableEntity, apperror.CodeValidationFailed, "Completion value is invalid")
+)
+
+// maxMeasurement caps both a habit's daily goal and a single measurement
+const maxMeasurement = 1000000
+
+// MinCompletionDate is the earliest date a habit can be completed on, so a date such as 0001-01-01 can't make every progress calculation walk through two thousand years
+const MinCompletionDate = "2000-01-01"
+
 type habitForValidation struct {
 	name       string
 	daysTarget int
+	schedule   data.HabitSchedule
+	unit       string
+	dailyGoal  float64
 }
 
+// ValidateHabit checks every field of a data.NewHabit or data.Habit and returns ErrInvalidHabit listing all the invalid ones
 func ValidateHabit(value interface{}, logger logger.ILogger) error {
 	habitForValidation := habitForValidation{}
 
@@ -21,26 +42,40 @@ func ValidateHabit(value interface{}, logger logger.ILogger) error {
 		return err
 	}
 
-	if err := validateHabitName(habitForValidation.name); err != nil {
-		logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("%s", err))
-		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
-	}
+	fields := apperror.FieldErrors(
+		validateHabitName(habitForValidation.name),
+		validateHabitDaysTarget(habitForValidation.daysTarget),
+		validateHabitSchedule(habitForValidation.schedule),
+		validateHabitGoal(habitForValidation.unit, habitForValidation.dailyGoal),
+	)
 
-	if err := validateHabitDaysTarget(habitForValidation.daysTarget); err != nil {
+	if len(fields) > 0 {
+		err := ErrInvalidHabit.WithFields(fields...)
 		logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("%s", err))
-		return fmt.Errorf("%s - %s", helper.GetFunctionName(), err)
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), err)
 	}
 
 	return nil
 }
 
+// invalidField is the error the habit validators return. The message is shown to the client so mustn't contain internal details
+func invalidField(field, format string, args ...any) error {
+	return apperror.FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
 func processHabit(value interface{}, habitForValidation *habitForValidation) error {
 	if newHabit, ok := value.(data.NewHabit); ok {
 		habitForValidation.name = newHabit.Name
 		habitForValidation.daysTarget = newHabit.DaysTarget
+		habitForValidation.schedule = newHabit.Schedule
+		habitForValidation.unit = newHabit.Unit
+		habitForValidation.dailyGoal = newHabit.DailyGoal
 	} else if habit, ok := value.(data.Habit); ok {
 		habitForValidation.name = habit.Name
 		habitForValidation.daysTarget = habit.DaysTarget
+		habitForValidation.schedule = habit.Schedule
+		habitForValidation.unit = habit.Unit
+		habitForValidation.dailyGoal = habit.DailyGoal
 	} else {
 		return fmt.Errorf("%s - value type is not a habit", helper.GetFunctionName())
 	}
@@ -52,13 +87,13 @@ func validateHabitName(name string) error {
 
 	lengthCheck := func(name string) error {
 		if len(name) <= 0 {
-			return fmt.Errorf("%s -  No Habit Name Supplied", helper.GetFunctionName())
+			return invalidField("name", "No Habit Name Supplied")
 		}
 
 		maxCharacterLength := 255
 
 		if len(name) >= maxCharacterLength {
-			return fmt.Errorf("%s -  Habit Name exceeds max character length of %d", helper.GetFunctionName(), maxCharacterLength)
+			return invalidField("name", "Habit Name exceeds max character length of %d", maxCharacterLength)
 		}
 
 		return nil
@@ -68,7 +103,7 @@ func validateHabitName(name string) error {
 		matchLettersNumbersAndColon := regexp.MustCompile(`^[a-zA-Z0-9: ]+$`)
 
 		if !matchLettersNumbersAndColon.MatchString(name) {
-			return fmt.Errorf("%s - Habit Name is invalid", helper.GetFunctionName())
+			return invalidField("name", "Habit Name can only contain letters, numbers, spaces and colons")
 		}
 
 		return nil
@@ -93,13 +128,157 @@ func validateHabitDaysTarget(days int) error {
 	return nil
 }
 
+// validateHabitSchedule checks the schedule type is known and only the fields that type uses are set
+func validateHabitSchedule(schedule data.HabitSchedule) error {
+	const maxInterval = 365
+
+	usesWeekdays, usesTimes, usesInterval := false, false, false
+
+	switch schedule.Type {
+	case "", data.ScheduleDaily:
+	case data.ScheduleWeekdays:
+		usesWeekdays = true
+
+		if len(schedule.Weekdays) == 0 {
+			return invalidField("schedule.weekdays", "Habit Schedule weekdays must list at least one day")
+		}
+
+		seen := map[time.Weekday]bool{}
+
+		for _, weekday := range schedule.Weekdays {
+			if weekday < time.Sunday || weekday > time.Saturday {
+				return invalidField("schedule.weekdays", "Habit Schedule weekday %d must be between 0 (Sunday) and 6 (Saturday)", weekday)
+			}
+
+			if seen[weekday] {
+				return invalidField("schedule.weekdays", "Habit Schedule weekday %d is listed more than once", weekday)
+			}
+
+			seen[weekday] = true
+		}
+	case data.ScheduleTimesPerWeek:
+		usesTimes = true
+
+		if schedule.Times < 1 || schedule.Times > 7 {
+			return invalidField("schedule.times", "Habit Schedule times per week must be between 1 and 7")
+		}
+	case data.ScheduleTimesPerMonth:
+		usesTimes = true
+
+		if schedule.Times < 1 || schedule.Times > 31 {
+			return invalidField("schedule.times", "Habit Schedule times per month must be between 1 and 31")
+		}
+	case data.ScheduleEveryNDays:
+		usesInterval = true
+
+		if schedule.Interval < 1 || schedule.Interval > maxInterval {
+			return invalidField("schedule.interval", "Habit Schedule interval must be between 1 and %d days", maxInterval)
+		}
+	default:
+		return invalidField("schedule.type", "Habit Schedule type %q is invalid", schedule.Type)
+	}
+
+	if (!usesWeekdays && len(schedule.Weekdays) > 0) || (!usesTimes && schedule.Times != 0) || (!usesInterval && schedule.Interval != 0) {
+		return invalidField("schedule", "Habit Schedule has fields that don't apply to type %q", schedule.Type)
+	}
+
+	return nil
+}
+
+// validateHabitGoal checks the daily goal of a measurable habit, and that only measurable habits have a unit
+func validateHabitGoal(unit string, dailyGoal float64) error {
+	const maxUnitLength = 32
+
+	if math.IsNaN(dailyGoal) || dailyGoal < 0 || dailyGoal > maxMeasurement {
+		return invalidField("dailyGoal", "Habit Daily Goal must be between 0 and %d", maxMeasurement)
+	}
+
+	if len(unit) > maxUnitLength {
+		return invalidField("unit", "Habit Unit exceeds max character length of %d", maxUnitLength)
+	}
+
+	if unit != "" && dailyGoal == 0 {
+		return invalidField("unit", "Habit Unit requires a Daily Goal")
+	}
+
+	return nil
+}
+
 func validateDay(day int) error {
 	if day < 0 {
-		return fmt.Errorf("%s - Habit Days cannot be less than 0", helper.GetFunctionName())
+		return invalidField("daysTarget", "Habit Days cannot be less than 0")
 	}
 
 	if day >= 9999 {
-		return fmt.Errorf("%s - Habit Days cannot be more than 9999 days", helper.GetFunctionName())
+		return invalidField("daysTarget", "Habit Days cannot be more than 9999 days")
+	}
+
+	return nil
+}
+
+// ValidateCompletionDate checks the date is a real YYYY-MM-DD calendar date from MinCompletionDate up to today
+func ValidateCompletionDate(completionDate string, today time.Time, logger logger.ILogger) error {
+	day, err := time.Parse(data.CompletionDateLayout, completionDate)
+
+	if err != nil {
+		logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("completionDate=%s is not in the format %s", completionDate, data.CompletionDateLayout))
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidCompletionDate.WithFields(apperror.FieldError{Field: "completionDate", Message: fmt.Sprintf("%s is not in the format %s", completionDate, data.CompletionDateLayout)}))
+	}
+
+	if day.Format(data.CompletionDateLayout) > today.Format(data.CompletionDateLayout) {
+		logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("completionDate=%s is in the future", completionDate))
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidCompletionDate.WithFields(apperror.FieldError{Field: "completionDate", Message: fmt.Sprintf("%s is in the future", completionDate)}))
+	}
+
+	if completionDate < MinCompletionDate {
+		logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("completionDate=%s is before %s", completionDate, MinCompletionDate))
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidCompletionDate.WithFields(apperror.FieldError{Field: "completionDate", Message: fmt.Sprintf("%s is before %s", completionDate, MinCompletionDate)}))
+	}
+
+	return nil
+}
+
+/*
+ValidateCompletionDates checks each date as ValidateCompletionDate does and returns them without duplicates, in the order given. Every
+invalid date is reported, against its index in completionDates.
+*/
+func ValidateCompletionDates(completionDates []string, today time.Time, logger logger.ILogger) ([]string, error) {
+	unique := make([]string, 0, len(completionDates))
+	seen := make(map[string]bool, len(completionDates))
+	fields := []apperror.FieldError{}
+
+	for i, completionDate := range completionDates {
+		if err := ValidateCompletionDate(completionDate, today, logger); err != nil {
+			var appErr *apperror.Error
+
+			message := "Completion date is invalid"
+
+			if errors.As(err, &appErr) && len(appErr.Fields) > 0 {
+				message = appErr.Fields[0].Message
+			}
+
+			fields = append(fields, apperror.FieldError{Field: fmt.Sprintf("completionDates[%d]", i), Message: message})
+			continue
+		}
+
+		if !seen[completionDate] {
+			seen[completionDate] = true
+			unique = append(unique, completionDate)
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidCompletionDate.WithFields(fields...))
+	}
+
+	return unique, nil
+}
+
+// ValidateCompletionValue checks a measurement is a positive amount no larger than the biggest allowed daily goal
+func ValidateCompletionValue(value float64, logger logger.ILogger) error {
+	if math.IsNaN(value) || value <= 0 || value > maxMeasurement {
+		logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("value=%v is out of range", value))
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidCompletionValue.WithFields(apperror.FieldError{Field: "value", Message: fmt.Sprintf("%v must be greater than 0 and at most %d", value, maxMeasurement)}))
 	}
 
 	return nil
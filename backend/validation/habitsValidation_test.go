@@ -4,7 +4,9 @@ import (
 	"dohabits/data"
 	"dohabits/helper"
 	"dohabits/logger"
+	"errors"
 	"testing"
+	"time"
 )
 
 func Test_ValidateHabit(t *testing.T) {
@@ -129,3 +131,266 @@ func Test_ValidateHabitDaysTarget(t *testing.T) {
 		})
 	}
 }
+
+func Test_ValidateCompletionDate(t *testing.T) {
+	logger := logger.NewLogger(0)
+	today := time.Date(2025, time.January, 10, 23, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		completionDate string
+		wantErr        bool
+	}{
+		{
+			name:           "Today",
+			completionDate: "2025-01-10",
+			wantErr:        false,
+		},
+		{
+			name:           "Past date",
+			completionDate: "2024-12-31",
+			wantErr:        false,
+		},
+		{
+			name:           "Future date",
+			completionDate: "2025-01-11",
+			wantErr:        true,
+		},
+		{
+			name:           "Earliest date",
+			completionDate: MinCompletionDate,
+			wantErr:        false,
+		},
+		{
+			name:           "Before the earliest date",
+			completionDate: "1999-12-31",
+			wantErr:        true,
+		},
+		{
+			name:           "Year one",
+			completionDate: "0001-01-01",
+			wantErr:        true,
+		},
+		{
+			name:           "Not a calendar date",
+			completionDate: "2025-02-30",
+			wantErr:        true,
+		},
+		{
+			name:           "Wrong format",
+			completionDate: "10/01/2025",
+			wantErr:        true,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			got := ValidateCompletionDate(val.completionDate, today, logger)
+
+			if val.wantErr != errors.Is(got, ErrInvalidCompletionDate) {
+				t.Errorf("%s - Failed - got=%v, want=%v", helper.GetFunctionName(), got, val.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func Test_ValidateHabitSchedule(t *testing.T) {
+	testCases := []struct {
+		name     string
+		schedule data.HabitSchedule
+		wantErr  bool
+	}{
+		{
+			name:     "Empty schedule defaults to daily",
+			schedule: data.HabitSchedule{},
+			wantErr:  false,
+		},
+		{
+			name:     "Daily",
+			schedule: data.HabitSchedule{Type: data.ScheduleDaily},
+			wantErr:  false,
+		},
+		{
+			name:     "Specific weekdays",
+			schedule: data.HabitSchedule{Type: data.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Friday}},
+			wantErr:  false,
+		},
+		{
+			name:     "Weekdays without any days",
+			schedule: data.HabitSchedule{Type: data.ScheduleWeekdays},
+			wantErr:  true,
+		},
+		{
+			name:     "Weekday out of range",
+			schedule: data.HabitSchedule{Type: data.ScheduleWeekdays, Weekdays: []time.Weekday{7}},
+			wantErr:  true,
+		},
+		{
+			name:     "Duplicate weekday",
+			schedule: data.HabitSchedule{Type: data.ScheduleWeekdays, Weekdays: []time.Weekday{time.Monday, time.Monday}},
+			wantErr:  true,
+		},
+		{
+			name:     "Three times per week",
+			schedule: data.HabitSchedule{Type: data.ScheduleTimesPerWeek, Times: 3},
+			wantErr:  false,
+		},
+		{
+			name:     "Eight times per week",
+			schedule: data.HabitSchedule{Type: data.ScheduleTimesPerWeek, Times: 8},
+			wantErr:  true,
+		},
+		{
+			name:     "Ten times per month",
+			schedule: data.HabitSchedule{Type: data.ScheduleTimesPerMonth, Times: 10},
+			wantErr:  false,
+		},
+		{
+			name:     "Zero times per month",
+			schedule: data.HabitSchedule{Type: data.ScheduleTimesPerMonth},
+			wantErr:  true,
+		},
+		{
+			name:     "Every 3 days",
+			schedule: data.HabitSchedule{Type: data.ScheduleEveryNDays, Interval: 3},
+			wantErr:  false,
+		},
+		{
+			name:     "Interval too long",
+			schedule: data.HabitSchedule{Type: data.ScheduleEveryNDays, Interval: 366},
+			wantErr:  true,
+		},
+		{
+			name:     "Field that doesn't apply to the type",
+			schedule: data.HabitSchedule{Type: data.ScheduleDaily, Times: 2},
+			wantErr:  true,
+		},
+		{
+			name:     "Unknown type",
+			schedule: data.HabitSchedule{Type: "yearly"},
+			wantErr:  true,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			got := validateHabitSchedule(val.schedule)
+
+			if val.wantErr != (got != nil) {
+				t.Errorf("%s - Failed - got=%v, want=%v", helper.GetFunctionName(), got, val.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func Test_ValidateHabitGoal(t *testing.T) {
+	testCases := []struct {
+		name      string
+		unit      string
+		dailyGoal float64
+		wantErr   bool
+	}{
+		{
+			name:      "Binary habit",
+			unit:      "",
+			dailyGoal: 0,
+			wantErr:   false,
+		},
+		{
+			name:      "Measurable habit with a unit",
+			unit:      "glasses",
+			dailyGoal: 8,
+			wantErr:   false,
+		},
+		{
+			name:      "Measurable habit without a unit",
+			unit:      "",
+			dailyGoal: 2.5,
+			wantErr:   false,
+		},
+		{
+			name:      "Unit without a daily goal",
+			unit:      "pages",
+			dailyGoal: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "Negative daily goal",
+			unit:      "km",
+			dailyGoal: -1,
+			wantErr:   true,
+		},
+		{
+			name:      "Daily goal too large",
+			unit:      "steps",
+			dailyGoal: 1000001,
+			wantErr:   true,
+		},
+		{
+			name:      "Unit too long",
+			unit:      "a unit name that is far too long to show",
+			dailyGoal: 1,
+			wantErr:   true,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			got := validateHabitGoal(val.unit, val.dailyGoal)
+
+			if val.wantErr != (got != nil) {
+				t.Errorf("%s - Failed - got=%v, want=%v", helper.GetFunctionName(), got, val.wantErr)
+				return
+			}
+		})
+	}
+}
+
+func Test_ValidateCompletionValue(t *testing.T) {
+	logger := logger.NewLogger(0)
+
+	testCases := []struct {
+		name    string
+		value   float64
+		wantErr bool
+	}{
+		{
+			name:    "Whole number",
+			value:   3,
+			wantErr: false,
+		},
+		{
+			name:    "Fraction",
+			value:   0.5,
+			wantErr: false,
+		},
+		{
+			name:    "Zero",
+			value:   0,
+			wantErr: true,
+		},
+		{
+			name:    "Negative",
+			value:   -2,
+			wantErr: true,
+		},
+		{
+			name:    "Too large",
+			value:   1000001,
+			wantErr: true,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			got := ValidateCompletionValue(val.value, logger)
+
+			if val.wantErr != errors.Is(got, ErrInvalidCompletionValue) {
+				t.Errorf("%s - Failed - got=%v, want=%v", helper.GetFunctionName(), got, val.wantErr)
+				return
+			}
+		})
+	}
+}
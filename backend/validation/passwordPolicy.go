@@ -0,0 +1,160 @@
+package validation
+
+import (
+	"bufio"
+	"dohabits/helper"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed commonPasswords.txt
+var commonPasswords string
+
+/*
+PasswordPolicy decides which passwords users can choose. A password must be at least MinLength characters and no more than
+maxPassowrd bytes, mix at least MinCharacterClasses of lower case letters, upper case letters, digits and symbols, and not be on
+the list of common and breached passwords. The list is checked without case, so capitalising a listed password doesn't get it through.
+*/
+type PasswordPolicy struct {
+	MinLength           int
+	MinCharacterClasses int
+	blocked             map[string]struct{}
+}
+
+func DefaultPasswordPolicy() *PasswordPolicy {
+	policy := &PasswordPolicy{
+		MinLength:           minPassword,
+		MinCharacterClasses: 3,
+		blocked:             map[string]struct{}{},
+	}
+
+	// The embedded list is part of the build, so it can't fail to read
+	_ = policy.addBlocked(strings.NewReader(commonPasswords))
+
+	return policy
+}
+
+/*
+ParsePasswordPolicy reads PASSWORD_MIN_LENGTH, PASSWORD_MIN_CHARACTER_CLASSES and PASSWORD_BLOCKLIST_FILE. Any that are empty
+keep their DefaultPasswordPolicy value. The blocklist file has a password on each line and adds to the built in list rather
+than replacing it.
+*/
+func ParsePasswordPolicy(minLength, minCharacterClasses, blocklistFile string) (*PasswordPolicy, error) {
+	policy := DefaultPasswordPolicy()
+
+	if minLength != "" {
+		n, err := strconv.Atoi(minLength)
+
+		if err != nil || n < minPassword || n > maxPassowrd {
+			return nil, fmt.Errorf("%s - PASSWORD_MIN_LENGTH must be a whole number from %d to %d, not %q", helper.GetFunctionName(), minPassword, maxPassowrd, minLength)
+		}
+
+		policy.MinLength = n
+	}
+
+	if minCharacterClasses != "" {
+		n, err := strconv.Atoi(minCharacterClasses)
+
+		if err != nil || n < 0 || n > 4 {
+			return nil, fmt.Errorf("%s - PASSWORD_MIN_CHARACTER_CLASSES must be a whole number from 0 to 4, not %q", helper.GetFunctionName(), minCharacterClasses)
+		}
+
+		policy.MinCharacterClasses = n
+	}
+
+	if blocklistFile != "" {
+		file, err := os.Open(blocklistFile)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s - PASSWORD_BLOCKLIST_FILE: %w", helper.GetFunctionName(), err)
+		}
+
+		defer file.Close()
+
+		if err := policy.addBlocked(file); err != nil {
+			return nil, fmt.Errorf("%s - PASSWORD_BLOCKLIST_FILE %s: %w", helper.GetFunctionName(), blocklistFile, err)
+		}
+	}
+
+	return policy, nil
+}
+
+// addBlocked adds each line of list to the blocked passwords, skipping blank lines and # comments
+func (p *PasswordPolicy) addBlocked(list io.Reader) error {
+	scanner := bufio.NewScanner(list)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p.blocked[strings.ToLower(line)] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+/*
+Validate checks password against the policy and returns an apperror.FieldError for field describing the first rule it breaks,
+or nil if it's allowed. emailAddress is the user's, which can't be used as their password, and is skipped when empty.
+*/
+func (p *PasswordPolicy) Validate(field, password, emailAddress string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return invalidField(field, "Password must be at least %d characters", p.MinLength)
+	}
+
+	// Bcrypt only hashes the first 72 bytes, so anything after them wouldn't be checked at login
+	if len(password) > maxPassowrd {
+		return invalidField(field, "Password can't be longer than %d bytes", maxPassowrd)
+	}
+
+	if classes := characterClasses(password); classes < p.MinCharacterClasses {
+		return invalidField(field, "Password must mix at least %d of lower case letters, upper case letters, digits and symbols", p.MinCharacterClasses)
+	}
+
+	if _, ok := p.blocked[strings.ToLower(password)]; ok {
+		return invalidField(field, "Password is too common, please choose another")
+	}
+
+	if emailAddress != "" && strings.EqualFold(password, emailAddress) {
+		return invalidField(field, "Password can't be your email address")
+	}
+
+	return nil
+}
+
+// characterClasses counts which of lower case letters, upper case letters, digits and symbols password uses. Anything that isn't a letter or digit, spaces included, is a symbol.
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+
+	classes := 0
+
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			classes++
+		}
+	}
+
+	return classes
+}
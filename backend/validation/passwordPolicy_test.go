@@ -0,0 +1,96 @@
+package validation
+
+import (
+	"dohabits/apperror"
+	"dohabits/helper"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+
+	testCases := []struct {
+		name         string
+		password     string
+		emailAddress string
+		wantErr      bool
+	}{
+		{name: "Test a password that mixes every character class", password: "1secret?Password"},
+		{name: "Test a password longer than the old 20 character cap", password: "correct Horse battery staple 42"},
+		{name: "Test a password of 72 bytes", password: "Aa1?" + strings.Repeat("x", 68)},
+		{name: "Test a password that's too short", password: "1Sec?", wantErr: true},
+		{name: "Test a password longer than bcrypt hashes", password: "Aa1?" + strings.Repeat("x", 69), wantErr: true},
+		{name: "Test a password that only uses lower case letters", password: "onlylowercaseletters", wantErr: true},
+		{name: "Test a common password", password: "Password123", wantErr: true},
+		{name: "Test the email address as the password", password: "John.Doe1@example.com", emailAddress: "john.doe1@example.com", wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			err := policy.Validate("Password", val.password, val.emailAddress)
+
+			if (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+				return
+			}
+
+			var field apperror.FieldError
+
+			if err != nil && (!errors.As(err, &field) || field.Field != "Password") {
+				t.Errorf("%s - Failed - err=%v isn't a FieldError for Password", helper.GetFunctionName(), err)
+			}
+		})
+	}
+}
+
+func TestParsePasswordPolicy(t *testing.T) {
+	blocklistFile := filepath.Join(t.TempDir(), "blocklist.txt")
+
+	if err := os.WriteFile(blocklistFile, []byte("# Breached passwords\n\nCorrect?Horse1\n"), 0o600); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	testCases := []struct {
+		name                string
+		minLength           string
+		minCharacterClasses string
+		blocklistFile       string
+		password            string
+		wantErr             bool
+		wantInvalid         bool
+	}{
+		{name: "Test defaults", password: "1secret?Password"},
+		{name: "Test a longer minimum length", minLength: "20", password: "1secret?Password", wantInvalid: true},
+		{name: "Test character classes turned off", minCharacterClasses: "0", password: "onlylowercaseletters"},
+		{name: "Test the blocklist file adds to the built in list", blocklistFile: blocklistFile, password: "correct?horse1", wantInvalid: true},
+		{name: "Test the built in list is kept with a blocklist file", blocklistFile: blocklistFile, password: "Password123", wantInvalid: true},
+		{name: "Test minimum length below 8", minLength: "6", wantErr: true},
+		{name: "Test minimum length longer than bcrypt hashes", minLength: "73", wantErr: true},
+		{name: "Test character classes isn't a number", minCharacterClasses: "all", wantErr: true},
+		{name: "Test character classes above 4", minCharacterClasses: "5", wantErr: true},
+		{name: "Test missing blocklist file", blocklistFile: filepath.Join(t.TempDir(), "missing.txt"), wantErr: true},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			policy, err := ParsePasswordPolicy(val.minLength, val.minCharacterClasses, val.blocklistFile)
+
+			if (err != nil) != val.wantErr {
+				t.Errorf("%s - Failed - err=%v, wantErr=%v", helper.GetFunctionName(), err, val.wantErr)
+				return
+			}
+
+			if val.wantErr {
+				return
+			}
+
+			if err := policy.Validate("Password", val.password, ""); (err != nil) != val.wantInvalid {
+				t.Errorf("%s - Failed - err=%v, wantInvalid=%v", helper.GetFunctionName(), err, val.wantInvalid)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package validation
+
+import (
+	"dohabits/apperror"
+	"dohabits/data"
+	"dohabits/helper"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidPersonalAccessToken = apperror.New(http.StatusUnprocessableEntity, apperror.CodeValidationFailed, "Personal access token is invalid")
+
+const (
+	maxTokenNameLength = 100
+	maxTokenExpiryDays = 365
+)
+
+// ValidatePersonalAccessToken checks a request to create a personal access token and returns ErrInvalidPersonalAccessToken listing all the invalid fields
+func ValidatePersonalAccessToken(request *data.CreatePersonalAccessTokenRequest) error {
+	fields := apperror.FieldErrors(
+		validateTokenName(request.Name),
+		validateTokenScopes(request.Scopes),
+		validateTokenExpiry(request.ExpiresInDays),
+	)
+
+	if len(fields) > 0 {
+		return fmt.Errorf("%s - %w", helper.GetFunctionName(), ErrInvalidPersonalAccessToken.WithFields(fields...))
+	}
+
+	return nil
+}
+
+func validateTokenName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return invalidField("Name", "Name is required")
+	}
+
+	if utf8.RuneCountInString(name) > maxTokenNameLength {
+		return invalidField("Name", "Name exceeds max character length of %d", maxTokenNameLength)
+	}
+
+	return nil
+}
+
+func validateTokenScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return invalidField("Scopes", "At least one of %s is required", strings.Join(data.PersonalAccessTokenScopes, ", "))
+	}
+
+	for i, scope := range scopes {
+		if !slices.Contains(data.PersonalAccessTokenScopes, scope) {
+			return invalidField("Scopes", "Scope %q is invalid, it must be one of %s", scope, strings.Join(data.PersonalAccessTokenScopes, ", "))
+		}
+
+		if slices.Contains(scopes[:i], scope) {
+			return invalidField("Scopes", "Scope %q is listed more than once", scope)
+		}
+	}
+
+	return nil
+}
+
+func validateTokenExpiry(expiresInDays int) error {
+	if expiresInDays < 0 || expiresInDays > maxTokenExpiryDays {
+		return invalidField("ExpiresInDays", "ExpiresInDays must be between 0, for a token that never expires, and %d", maxTokenExpiryDays)
+	}
+
+	return nil
+}
@@ -1,16 +1,25 @@
 package validation
 
 import (
+	"dohabits/apperror"
 	"fmt"
+	"net/http"
 	"regexp"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrInvalidUser        = apperror.New(http.StatusUnprocessableEntity, apperror.CodeValidationFailed, "User details are invalid")
+	ErrInvalidTimezone    = apperror.New(http.StatusUnprocessableEntity, apperror.CodeValidationFailed, "Timezone is invalid")
+	ErrInvalidCredentials = apperror.New(http.StatusUnauthorized, apperror.CodeInvalidCredentials, "Email address or password is incorrect")
+)
+
 const (
 	maxNameLength  = 50
 	maxEmailLength = 320
-	minPassword    = 8
+	minPassword    = 8  // The shortest PASSWORD_MIN_LENGTH allowed
 	maxPassowrd    = 72 // Bcrypt has a limit of 72 bytes
 )
 
@@ -44,21 +53,17 @@ func IsValidEmail(email string) bool {
 	return success
 }
 
-func IsValidatePassword(plainTextPassword string) bool {
-	success := true
-
-	if len(plainTextPassword) == 0 || len(plainTextPassword) < minPassword || len(plainTextPassword) > maxPassowrd {
-		return !success
+// IsValidTimezone reports whether timezone is an IANA name such as Europe/London. "Local" is rejected as it depends on the server
+func IsValidTimezone(timezone string) bool {
+	if timezone == "" || timezone == "Local" {
+		return false
 	}
 
-	validPassword := regexp.MustCompile(`^[a-zA-Z0-9!@#$%^&*()_+\-=\[\]{};':"\\|,.<>\/?]{8,20}$`)
-
-	if !validPassword.MatchString(plainTextPassword) {
-		return !success
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return false
 	}
 
-	return success
-
+	return true
 }
 
 func HashPassword(plainTextPassword string) ([]byte, error) {
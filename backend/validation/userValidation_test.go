@@ -0,0 +1,48 @@
+package validation
+
+import (
+	"dohabits/helper"
+	"testing"
+)
+
+func Test_IsValidTimezone(t *testing.T) {
+	testCases := []struct {
+		name     string
+		timezone string
+		want     bool
+	}{
+		{
+			name:     "UTC",
+			timezone: "UTC",
+			want:     true,
+		},
+		{
+			name:     "IANA name",
+			timezone: "Australia/Sydney",
+			want:     true,
+		},
+		{
+			name:     "Empty",
+			timezone: "",
+			want:     false,
+		},
+		{
+			name:     "Server local time",
+			timezone: "Local",
+			want:     false,
+		},
+		{
+			name:     "Unknown",
+			timezone: "Europe/Atlantis",
+			want:     false,
+		},
+	}
+
+	for _, val := range testCases {
+		t.Run(val.name, func(t *testing.T) {
+			if got := IsValidTimezone(val.timezone); got != val.want {
+				t.Errorf("%s - Failed - got=%v, want=%v", helper.GetFunctionName(), got, val.want)
+			}
+		})
+	}
+}
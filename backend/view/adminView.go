@@ -0,0 +1,106 @@
+package view
+
+import (
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"fmt"
+)
+
+type AdminView struct {
+	logger logger.ILogger
+}
+
+type IAdminView interface {
+	UsersHandler(users []data.UserData, limit, offset int) ([]byte, error)
+	UserHandler(user *data.UserData) ([]byte, error)
+	UserHabitsHandler(user *data.UserData, habits []data.Habit) ([]byte, error)
+	LogoutUserHandler(revoked, revokedTokens int64) ([]byte, error)
+}
+
+func NewAdminView(logger logger.ILogger) *AdminView {
+	return &AdminView{
+		logger: logger,
+	}
+}
+
+func (v *AdminView) UsersHandler(users []data.UserData, limit, offset int) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	response := data.AdminUsersResponse{
+		Success: true,
+		Users:   make([]data.AdminUserResponse, 0, len(users)),
+		Limit:   limit,
+		Offset:  offset,
+	}
+
+	for _, user := range users {
+		response.Users = append(response.Users, adminUserResponse(user))
+	}
+
+	return v.marshal(response)
+}
+
+func (v *AdminView) UserHandler(user *data.UserData) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.marshal(data.AdminUserDetailResponse{
+		Success: true,
+		User:    adminUserResponse(*user),
+	})
+}
+
+func (v *AdminView) UserHabitsHandler(user *data.UserData, habits []data.Habit) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	if habits == nil {
+		habits = []data.Habit{}
+	}
+
+	return v.marshal(data.AdminUserHabitsResponse{
+		Success: true,
+		User:    adminUserResponse(*user),
+		Habits:  habits,
+	})
+}
+
+func (v *AdminView) LogoutUserHandler(revoked, revokedTokens int64) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.marshal(data.AdminLogoutUserResponse{Success: true, Revoked: revoked, RevokedTokens: revokedTokens})
+}
+
+func (v *AdminView) marshal(response any) ([]byte, error) {
+	result, err := json.Marshal(response)
+
+	if err != nil {
+		v.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error encoding to JSON - err=%s", err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// adminUserResponse leaves out the user's password and two-factor secrets
+func adminUserResponse(user data.UserData) data.AdminUserResponse {
+	roles := user.Roles
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return data.AdminUserResponse{
+		UserID:        user.UserID,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		EmailAddress:  user.EmailAddress,
+		Timezone:      user.Timezone,
+		EmailVerified: user.EmailVerified,
+		MFAEnabled:    user.TOTP.Enabled,
+		Roles:         roles,
+		Disabled:      user.Disabled,
+		CreatedAt:     user.CreatedAt,
+		LastLogin:     user.LastLogin,
+	}
+}
@@ -14,7 +14,12 @@ type AuthView struct {
 type IAuthView interface {
 	RegisterUserHandler(registeredUserData *data.RegisterUserData) ([]byte, error)
 	LoginHandler(loginData *data.UserLoggedInData) ([]byte, error)
-	RefreshHandler(userRefreshRequest *data.UserRefreshRequest) ([]byte, error)
+	MFARequiredHandler(loginData *data.UserLoggedInData) ([]byte, error)
+	RefreshHandler(userRefreshData *data.UserRefreshData) ([]byte, error)
+	UpdateProfileHandler(userData *data.UserData) ([]byte, error)
+	SessionsHandler(userSessions []data.UserSession, currentSessionID string) ([]byte, error)
+	DeleteSessionsHandler(revoked int64) ([]byte, error)
+	DeleteAccountHandler() ([]byte, error)
 }
 
 func NewAuthView(logger logger.ILogger) *AuthView {
@@ -28,12 +33,7 @@ func (ac *AuthView) RegisterUserHandler(registeredUserData *data.RegisterUserDat
 
 	jsonRes, err := json.Marshal(data.RegisterUserResponse{
 		Success: registeredUserData.Success,
-		User: data.UserDataResponse{
-			FirstName:    registeredUserData.User.FirstName,
-			LastName:     registeredUserData.User.LastName,
-			EmailAddress: registeredUserData.User.EmailAddress,
-			CreatedAt:    registeredUserData.User.CreatedAt,
-		},
+		User:    userDataResponse(registeredUserData.User),
 	})
 
 	if err != nil {
@@ -47,13 +47,9 @@ func (ac *AuthView) LoginHandler(loginData *data.UserLoggedInData) ([]byte, erro
 	ac.logger.InfoLog(helper.GetFunctionName(), "")
 
 	jsonRes, err := json.Marshal(data.UserLoggedInResponse{
-		Success: loginData.Success,
-		User: data.UserDataResponse{
-			FirstName:    loginData.User.FirstName,
-			LastName:     loginData.User.LastName,
-			EmailAddress: loginData.User.EmailAddress,
-			CreatedAt:    loginData.User.CreatedAt,
-		},
+		Success:    loginData.Success,
+		User:       userDataResponse(loginData.User),
+		SessionID:  loginData.SessionID,
 		LoggedInAt: loginData.LoggedInAt,
 	})
 
@@ -64,12 +60,30 @@ func (ac *AuthView) LoginHandler(loginData *data.UserLoggedInData) ([]byte, erro
 	return jsonRes, nil
 }
 
-func (ac *AuthView) RefreshHandler(userRefreshRequest *data.UserRefreshRequest) ([]byte, error) {
+// MFARequiredHandler is the login response when the user still has to enter a code. It doesn't describe the user, as they haven't finished logging in.
+func (ac *AuthView) MFARequiredHandler(loginData *data.UserLoggedInData) ([]byte, error) {
+	ac.logger.InfoLog(helper.GetFunctionName(), "")
+
+	jsonRes, err := json.Marshal(data.MFARequiredResponse{
+		Success:     true,
+		MFARequired: true,
+		MFAToken:    loginData.MFAToken,
+		ExpiresAt:   loginData.MFAExpiresAt,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonRes, nil
+}
+
+func (ac *AuthView) RefreshHandler(userRefreshData *data.UserRefreshData) ([]byte, error) {
 	ac.logger.InfoLog(helper.GetFunctionName(), "")
 
 	jsonRes, err := json.Marshal(data.UserRefreshResponse{
-		Success:      true,
-		EmailAddress: userRefreshRequest.EmailAddress,
+		Success:      userRefreshData.Success,
+		EmailAddress: userRefreshData.EmailAddress,
 	})
 
 	if err != nil {
@@ -78,3 +92,93 @@ func (ac *AuthView) RefreshHandler(userRefreshRequest *data.UserRefreshRequest)
 
 	return jsonRes, err
 }
+
+func (ac *AuthView) UpdateProfileHandler(userData *data.UserData) ([]byte, error) {
+	ac.logger.InfoLog(helper.GetFunctionName(), "")
+
+	jsonRes, err := json.Marshal(data.UpdateProfileResponse{
+		Success: true,
+		User:    userDataResponse(*userData),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonRes, nil
+}
+
+// SessionsHandler lists the user's sessions without their refresh tokens, marking the one the request was made with
+func (ac *AuthView) SessionsHandler(userSessions []data.UserSession, currentSessionID string) ([]byte, error) {
+	ac.logger.InfoLog(helper.GetFunctionName(), "")
+
+	sessions := make([]data.UserSessionResponse, 0, len(userSessions))
+
+	for _, val := range userSessions {
+		sessions = append(sessions, data.UserSessionResponse{
+			SessionID: val.SessionID,
+			UserAgent: val.UserAgent,
+			IPAddress: val.IPAddress,
+			CreatedAt: val.CreatedAt,
+			Current:   val.SessionID == currentSessionID,
+		})
+	}
+
+	jsonRes, err := json.Marshal(data.UserSessionsResponse{
+		Success:  true,
+		Sessions: sessions,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonRes, nil
+}
+
+func (ac *AuthView) DeleteSessionsHandler(revoked int64) ([]byte, error) {
+	ac.logger.InfoLog(helper.GetFunctionName(), "")
+
+	jsonRes, err := json.Marshal(data.DeleteSessionsResponse{
+		Success: true,
+		Revoked: revoked,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonRes, nil
+}
+
+func (ac *AuthView) DeleteAccountHandler() ([]byte, error) {
+	ac.logger.InfoLog(helper.GetFunctionName(), "")
+
+	jsonRes, err := json.Marshal(data.DeleteAccountResponse{Success: true})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonRes, nil
+}
+
+// userDataResponse is the user as they see themselves, without their password or two-factor secrets
+func userDataResponse(userData data.UserData) data.UserDataResponse {
+	roles := userData.Roles
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return data.UserDataResponse{
+		FirstName:     userData.FirstName,
+		LastName:      userData.LastName,
+		EmailAddress:  userData.EmailAddress,
+		Timezone:      userData.Timezone,
+		EmailVerified: userData.EmailVerified,
+		MFAEnabled:    userData.TOTP.Enabled,
+		Roles:         roles,
+		CreatedAt:     userData.CreatedAt,
+	}
+}
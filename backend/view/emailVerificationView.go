@@ -0,0 +1,61 @@
+package view
+
+import (
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"fmt"
+)
+
+type EmailVerificationView struct {
+	logger logger.ILogger
+}
+
+type IEmailVerificationView interface {
+	VerifyEmailHandler() ([]byte, error)
+	ResendVerificationEmailHandler() ([]byte, error)
+	ChangeEmailHandler() ([]byte, error)
+	ConfirmEmailChangeHandler() ([]byte, error)
+}
+
+func NewEmailVerificationView(logger logger.ILogger) *EmailVerificationView {
+	return &EmailVerificationView{
+		logger: logger,
+	}
+}
+
+func (v *EmailVerificationView) VerifyEmailHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.success()
+}
+
+func (v *EmailVerificationView) ResendVerificationEmailHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.success()
+}
+
+func (v *EmailVerificationView) ChangeEmailHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.success()
+}
+
+func (v *EmailVerificationView) ConfirmEmailChangeHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.success()
+}
+
+func (v *EmailVerificationView) success() ([]byte, error) {
+	result, err := json.Marshal(data.EmailVerificationResponse{Success: true})
+
+	if err != nil {
+		v.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error encoding to JSON - err=%s", err))
+		return nil, err
+	}
+
+	return result, nil
+}
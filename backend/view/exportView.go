@@ -0,0 +1,292 @@
+package view
+
+import (
+	"archive/zip"
+	"bytes"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ExportView struct {
+	logger logger.ILogger
+}
+
+type IExportView interface {
+	ExportHandler(w io.Writer, export *data.PersonalDataExport) error
+}
+
+func NewExportView(logger logger.ILogger) *ExportView {
+	return &ExportView{
+		logger: logger,
+	}
+}
+
+/*
+ExportHandler writes the export to w as a ZIP archive, one file after another. The habits are read once, so every file describes
+the same habits: habits.json is written as they're read, and the CSV rows are collected in memory and added after it, so none of
+the user's data is left on the server's disk. The JSON files are for importing into another service and the CSV files are for
+spreadsheets. The archive's index is written last, so an export that fails part way can't be opened.
+*/
+func (v *ExportView) ExportHandler(w io.Writer, export *data.PersonalDataExport) error {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		write func(io.Writer, *data.PersonalDataExport) error
+	}{
+		{name: "profile.json", write: writeExportProfile},
+		{name: "sessions.json", write: writeExportSessions},
+		{name: "personal_access_tokens.json", write: writeExportPersonalAccessTokens},
+	}
+
+	for _, file := range files {
+		if err := addExportFile(archive, file.name, export.ExportedAt, func(fileWriter io.Writer) error { return file.write(fileWriter, export) }); err != nil {
+			return err
+		}
+	}
+
+	csvFiles := []*exportCSVFile{
+		{name: "habits.csv", header: []string{"HabitID", "Name", "CreatedAt", "Schedule", "DaysTarget", "Unit", "DailyGoal", "TotalCompletions", "CurrentStreak", "LongestStreak", "StreakUnit", "CompletionRate", "PercentToTarget"}, rows: habitCSVRows},
+		{name: "completions.csv", header: []string{"HabitID", "HabitName", "Date"}, rows: completionCSVRows},
+		{name: "measurements.csv", header: []string{"HabitID", "HabitName", "Date", "Value", "Unit"}, rows: measurementCSVRows},
+	}
+
+	for _, csvFile := range csvFiles {
+		if err := csvFile.open(); err != nil {
+			return fmt.Errorf("%s - Failed to start %s: %w", helper.GetFunctionName(), csvFile.name, err)
+		}
+	}
+
+	err := addExportFile(archive, "habits.json", export.ExportedAt, func(fileWriter io.Writer) error {
+		return writeExportHabits(fileWriter, export, func(habit data.Habit) error {
+			for _, csvFile := range csvFiles {
+				if err := csvFile.write(habit); err != nil {
+					return fmt.Errorf("failed to write %s: %w", csvFile.name, err)
+				}
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for _, csvFile := range csvFiles {
+		if err := addExportFile(archive, csvFile.name, export.ExportedAt, csvFile.copyTo); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// addExportFile adds a file called name to the archive, with the contents write writes to it
+func addExportFile(archive *zip.Writer, name string, modified time.Time, write func(io.Writer) error) error {
+	fileWriter, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+
+	if err != nil {
+		return fmt.Errorf("%s - Failed to add %s: %w", helper.GetFunctionName(), name, err)
+	}
+
+	if err := write(fileWriter); err != nil {
+		return fmt.Errorf("%s - Failed to write %s: %w", helper.GetFunctionName(), name, err)
+	}
+
+	return nil
+}
+
+func writeExportProfile(w io.Writer, export *data.PersonalDataExport) error {
+	user := export.User
+	roles := user.Roles
+
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return writeExportJSON(w, data.ExportProfile{
+		UserID:        user.UserID,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		EmailAddress:  user.EmailAddress,
+		Timezone:      user.Timezone,
+		EmailVerified: user.EmailVerified,
+		MFAEnabled:    user.TOTP.Enabled,
+		Roles:         roles,
+		CreatedAt:     user.CreatedAt,
+		LastLogin:     user.LastLogin,
+	})
+}
+
+func writeExportSessions(w io.Writer, export *data.PersonalDataExport) error {
+	sessions := make([]data.ExportSession, 0, len(export.Sessions))
+
+	for _, userSession := range export.Sessions {
+		sessions = append(sessions, data.ExportSession{
+			SessionID: userSession.SessionID,
+			UserAgent: userSession.UserAgent,
+			IPAddress: userSession.IPAddress,
+			CreatedAt: userSession.CreatedAt,
+		})
+	}
+
+	return writeExportJSON(w, sessions)
+}
+
+func writeExportPersonalAccessTokens(w io.Writer, export *data.PersonalDataExport) error {
+	personalAccessTokens := make([]data.ExportPersonalAccessToken, 0, len(export.PersonalAccessTokens))
+
+	for _, personalAccessToken := range export.PersonalAccessTokens {
+		scopes := personalAccessToken.Scopes
+
+		if scopes == nil {
+			scopes = []string{}
+		}
+
+		personalAccessTokens = append(personalAccessTokens, data.ExportPersonalAccessToken{
+			TokenID:    personalAccessToken.TokenID,
+			Name:       personalAccessToken.Name,
+			Scopes:     scopes,
+			CreatedAt:  personalAccessToken.CreatedAt,
+			ExpiresAt:  personalAccessToken.ExpiresAt,
+			LastUsedAt: personalAccessToken.LastUsedAt,
+		})
+	}
+
+	return writeExportJSON(w, personalAccessTokens)
+}
+
+// writeExportHabits writes the habits as a JSON array, encoding each one as it's read and then passing it on to fn
+func writeExportHabits(w io.Writer, export *data.PersonalDataExport, fn func(data.Habit) error) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	separator := "\n  "
+
+	err := export.EachHabit(func(habit data.Habit) error {
+		result, err := json.MarshalIndent(habit, "  ", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+
+		separator = ",\n  "
+
+		if _, err := w.Write(result); err != nil {
+			return err
+		}
+
+		return fn(habit)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return err
+}
+
+// exportCSVFile collects a CSV file's rows in memory while the habits are read, until it can be added to the archive
+type exportCSVFile struct {
+	name      string
+	header    []string
+	rows      func(data.Habit) [][]string
+	buffer    bytes.Buffer
+	csvWriter *csv.Writer
+}
+
+func (f *exportCSVFile) open() error {
+	f.csvWriter = csv.NewWriter(&f.buffer)
+
+	return f.csvWriter.Write(f.header)
+}
+
+func (f *exportCSVFile) write(habit data.Habit) error {
+	return f.csvWriter.WriteAll(f.rows(habit))
+}
+
+func (f *exportCSVFile) copyTo(w io.Writer) error {
+	f.csvWriter.Flush()
+
+	if err := f.csvWriter.Error(); err != nil {
+		return err
+	}
+
+	_, err := f.buffer.WriteTo(w)
+	return err
+}
+
+func habitCSVRows(habit data.Habit) [][]string {
+	return [][]string{{
+		habit.HabitID,
+		csvText(habit.Name),
+		habit.CreatedAt.Format(time.RFC3339),
+		habit.Schedule.Type,
+		strconv.Itoa(habit.DaysTarget),
+		csvText(habit.Unit),
+		csvNumber(habit.DailyGoal),
+		strconv.Itoa(habit.Progress.TotalCompletions),
+		strconv.Itoa(habit.Progress.CurrentStreak),
+		strconv.Itoa(habit.Progress.LongestStreak),
+		habit.Progress.StreakUnit,
+		csvNumber(habit.Progress.CompletionRate),
+		csvNumber(habit.Progress.PercentToTarget),
+	}}
+}
+
+func completionCSVRows(habit data.Habit) [][]string {
+	rows := make([][]string, 0, len(habit.CompletionDates))
+
+	for _, completionDate := range habit.CompletionDates {
+		rows = append(rows, []string{habit.HabitID, csvText(habit.Name), completionDate})
+	}
+
+	return rows
+}
+
+func measurementCSVRows(habit data.Habit) [][]string {
+	rows := make([][]string, 0, len(habit.Measurements))
+
+	for _, measurement := range habit.Measurements {
+		rows = append(rows, []string{habit.HabitID, csvText(habit.Name), measurement.Date, csvNumber(measurement.Value), csvText(habit.Unit)})
+	}
+
+	return rows
+}
+
+func writeExportJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
+
+// csvText stops a spreadsheet treating text the user typed, such as a habit named "=1+1", as a formula
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+
+	return text
+}
+
+func csvNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
@@ -0,0 +1,194 @@
+package view
+
+import (
+	"archive/zip"
+	"bytes"
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	exportView := NewExportView(logger)
+	reads := 0
+	lastUsedAt := time.Date(2025, time.January, 2, 9, 0, 0, 0, time.UTC)
+
+	habits := []data.Habit{
+		{HabitID: "1", UserID: "1", Name: "Read", DaysTarget: 30, CompletionDates: []string{"2025-01-01", "2025-01-02"}, Schedule: data.HabitSchedule{Type: data.ScheduleDaily}},
+		{HabitID: "2", UserID: "1", Name: "=HYPERLINK(\"http://example.com\")", DaysTarget: 10, CompletionDates: []string{}, Unit: "km", DailyGoal: 5,
+			Measurements: []data.HabitMeasurement{{Date: "2025-01-01", Value: 2.5}, {Date: "2025-01-01", Value: 3}}},
+	}
+
+	export := &data.PersonalDataExport{
+		User:     &data.UserData{UserID: "1", FirstName: "John", LastName: "Doe", EmailAddress: "johndoe1@example.com", Password: "hashedPassword", TOTP: data.UserTOTP{Secret: "totpSecret", Enabled: true}},
+		Sessions: []data.UserSession{{SessionID: "session-1", UserID: "1", RefreshToken: "refreshTokenSecret", UserAgent: "Firefox", IPAddress: "203.0.113.1"}},
+		PersonalAccessTokens: []data.PersonalAccessToken{
+			{TokenID: "token-1", UserID: "1", Name: "Home Assistant", TokenHash: "tokenHashSecret", Scopes: []string{data.ScopeHabitsRead}, LastUsedAt: &lastUsedAt},
+		},
+		EachHabit: func(fn func(data.Habit) error) error {
+			reads++
+
+			for _, habit := range habits {
+				if err := fn(habit); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		ExportedAt: time.Date(2025, time.January, 3, 12, 0, 0, 0, time.UTC),
+	}
+
+	var archive bytes.Buffer
+
+	if err := exportView.ExportHandler(&archive, export); err != nil {
+		t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+	}
+
+	// Every file is written from the same read of the habits
+	if reads != 1 {
+		t.Errorf("%s - Failed - the habits were read %d times", helper.GetFunctionName(), reads)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+
+	if err != nil {
+		t.Fatalf("%s - Failed - the archive can't be opened, err=%s", helper.GetFunctionName(), err)
+	}
+
+	files := map[string]string{}
+
+	for _, file := range reader.File {
+		contents, err := file.Open()
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		result, err := io.ReadAll(contents)
+		contents.Close()
+
+		if err != nil {
+			t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+		}
+
+		files[file.Name] = string(result)
+	}
+
+	for _, name := range []string{"profile.json", "sessions.json", "personal_access_tokens.json", "habits.json", "habits.csv", "completions.csv", "measurements.csv"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s - Failed - %s is missing from the archive", helper.GetFunctionName(), name)
+		}
+	}
+
+	// Secrets aren't exported
+	for _, secret := range []string{"hashedPassword", "totpSecret", "refreshTokenSecret", "tokenHashSecret"} {
+		for name, contents := range files {
+			if strings.Contains(contents, secret) {
+				t.Errorf("%s - Failed - %s contains %s", helper.GetFunctionName(), name, secret)
+			}
+		}
+	}
+
+	profile := data.ExportProfile{}
+
+	if err := json.Unmarshal([]byte(files["profile.json"]), &profile); err != nil || profile.EmailAddress != "johndoe1@example.com" || !profile.MFAEnabled {
+		t.Errorf("%s - Failed - profile=%+v, err=%v", helper.GetFunctionName(), profile, err)
+	}
+
+	sessions := []data.ExportSession{}
+
+	if err := json.Unmarshal([]byte(files["sessions.json"]), &sessions); err != nil || len(sessions) != 1 || sessions[0].UserAgent != "Firefox" {
+		t.Errorf("%s - Failed - sessions=%+v, err=%v", helper.GetFunctionName(), sessions, err)
+	}
+
+	personalAccessTokens := []data.ExportPersonalAccessToken{}
+
+	if err := json.Unmarshal([]byte(files["personal_access_tokens.json"]), &personalAccessTokens); err != nil || len(personalAccessTokens) != 1 ||
+		personalAccessTokens[0].Name != "Home Assistant" || personalAccessTokens[0].LastUsedAt == nil || !personalAccessTokens[0].LastUsedAt.Equal(lastUsedAt) {
+		t.Errorf("%s - Failed - personalAccessTokens=%+v, err=%v", helper.GetFunctionName(), personalAccessTokens, err)
+	}
+
+	exportedHabits := []data.Habit{}
+
+	if err := json.Unmarshal([]byte(files["habits.json"]), &exportedHabits); err != nil || len(exportedHabits) != 2 || len(exportedHabits[1].Measurements) != 2 {
+		t.Errorf("%s - Failed - habits=%+v, err=%v", helper.GetFunctionName(), exportedHabits, err)
+	}
+
+	csvTestCases := []struct {
+		name     string
+		wantRows [][]string
+	}{
+		{
+			name: "completions.csv",
+			wantRows: [][]string{
+				{"HabitID", "HabitName", "Date"},
+				{"1", "Read", "2025-01-01"},
+				{"1", "Read", "2025-01-02"},
+			},
+		},
+		{
+			name: "measurements.csv",
+			wantRows: [][]string{
+				{"HabitID", "HabitName", "Date", "Value", "Unit"},
+				{"2", "'=HYPERLINK(\"http://example.com\")", "2025-01-01", "2.5", "km"},
+				{"2", "'=HYPERLINK(\"http://example.com\")", "2025-01-01", "3", "km"},
+			},
+		},
+	}
+
+	for _, val := range csvTestCases {
+		t.Run(val.name, func(t *testing.T) {
+			rows, err := csv.NewReader(strings.NewReader(files[val.name])).ReadAll()
+
+			if err != nil {
+				t.Fatalf("%s - Failed - err=%s", helper.GetFunctionName(), err)
+			}
+
+			if len(rows) != len(val.wantRows) {
+				t.Fatalf("%s - Failed - got rows=%v, want=%v", helper.GetFunctionName(), rows, val.wantRows)
+			}
+
+			for i := range rows {
+				if strings.Join(rows[i], ",") != strings.Join(val.wantRows[i], ",") {
+					t.Errorf("%s - Failed - got row=%v, want=%v", helper.GetFunctionName(), rows[i], val.wantRows[i])
+				}
+			}
+		})
+	}
+
+	if rows, err := csv.NewReader(strings.NewReader(files["habits.csv"])).ReadAll(); err != nil || len(rows) != 3 {
+		t.Errorf("%s - Failed - habits.csv rows=%v, err=%v", helper.GetFunctionName(), rows, err)
+	}
+}
+
+func TestExportHandlerFailure(t *testing.T) {
+	logger := logger.NewLogger(0)
+	exportView := NewExportView(logger)
+	errDatabase := errors.New("database went away")
+
+	export := &data.PersonalDataExport{
+		User:       &data.UserData{UserID: "1"},
+		EachHabit:  func(fn func(data.Habit) error) error { return errDatabase },
+		ExportedAt: time.Now(),
+	}
+
+	var archive bytes.Buffer
+
+	if err := exportView.ExportHandler(&archive, export); !errors.Is(err, errDatabase) {
+		t.Fatalf("%s - Failed - got err=%v, want=%v", helper.GetFunctionName(), err, errDatabase)
+	}
+
+	// The archive is cut short, so it can't be mistaken for a complete export
+	if _, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len())); err == nil {
+		t.Errorf("%s - Failed - an export that failed part way can be opened", helper.GetFunctionName())
+	}
+}
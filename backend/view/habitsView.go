@@ -19,6 +19,8 @@ type IHabitsView interface {
 	UpdateHabitsHandler(habit data.Habit) ([]byte, error)
 	UpdateAllHabitsHandler(habit *[]data.Habit) ([]byte, error)
 	DeleteHabitsHandler() ([]byte, error)
+	CreateCompletionHandler(habit data.Habit) ([]byte, error)
+	DeleteCompletionHandler(habit data.Habit) ([]byte, error)
 }
 
 func NewHabitsView(logger logger.ILogger) *HabitsView {
@@ -35,6 +37,10 @@ func (v *HabitsView) CreateHabitsHandler(newHabit *data.NewHabitResponse) ([]byt
 		Name:            newHabit.Name,
 		DaysTarget:      newHabit.DaysTarget,
 		CompletionDates: []string{},
+		Schedule:        newHabit.Schedule,
+		Unit:            newHabit.Unit,
+		DailyGoal:       newHabit.DailyGoal,
+		Revision:        newHabit.Revision,
 	}
 
 	result, err := json.Marshal(newHabitData)
@@ -132,3 +138,25 @@ func (v *HabitsView) DeleteHabitsHandler() ([]byte, error) {
 
 	return jsonRes, nil
 }
+
+func (v *HabitsView) CreateCompletionHandler(habit data.Habit) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+	result, err := json.Marshal(habit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (v *HabitsView) DeleteCompletionHandler(habit data.Habit) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+	result, err := json.Marshal(habit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -268,3 +268,49 @@ func Test_DeleteHabitsHandler(t *testing.T) {
 		})
 	}
 }
+
+func Test_CreateCompletionHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	v := NewHabitsView(logger)
+
+	habit := data.MockHabit[0]
+
+	marshalledHabit, err := json.Marshal(habit)
+
+	if err != nil {
+		t.Errorf("Fail err: %s", err)
+	}
+
+	got, err := v.CreateCompletionHandler(habit)
+
+	if err != nil {
+		t.Errorf("Fail err: %s", err)
+	}
+
+	if !bytes.Equal(marshalledHabit, got) {
+		t.Errorf("Fail want doesn't match got")
+	}
+}
+
+func Test_DeleteCompletionHandler(t *testing.T) {
+	logger := logger.NewLogger(0)
+	v := NewHabitsView(logger)
+
+	habit := data.MockHabit[0]
+
+	marshalledHabit, err := json.Marshal(habit)
+
+	if err != nil {
+		t.Errorf("Fail err: %s", err)
+	}
+
+	got, err := v.DeleteCompletionHandler(habit)
+
+	if err != nil {
+		t.Errorf("Fail err: %s", err)
+	}
+
+	if !bytes.Equal(marshalledHabit, got) {
+		t.Errorf("Fail want doesn't match got")
+	}
+}
@@ -0,0 +1,36 @@
+package view
+
+import (
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"fmt"
+)
+
+type KeysView struct {
+	logger logger.ILogger
+}
+
+type IKeysView interface {
+	JSONWebKeySetHandler(jwks data.JSONWebKeySet) ([]byte, error)
+}
+
+func NewKeysView(logger logger.ILogger) *KeysView {
+	return &KeysView{
+		logger: logger,
+	}
+}
+
+func (v *KeysView) JSONWebKeySetHandler(jwks data.JSONWebKeySet) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	result, err := json.Marshal(jwks)
+
+	if err != nil {
+		v.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error encoding to JSON - err=%s", err))
+		return nil, err
+	}
+
+	return result, nil
+}
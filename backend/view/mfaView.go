@@ -0,0 +1,61 @@
+package view
+
+import (
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"fmt"
+)
+
+type MFAView struct {
+	logger logger.ILogger
+}
+
+type IMFAView interface {
+	EnrolTOTPHandler(enrolment *data.TOTPEnrolment) ([]byte, error)
+	ConfirmTOTPHandler(recoveryCodes []string) ([]byte, error)
+	DisableTOTPHandler() ([]byte, error)
+}
+
+func NewMFAView(logger logger.ILogger) *MFAView {
+	return &MFAView{
+		logger: logger,
+	}
+}
+
+func (v *MFAView) EnrolTOTPHandler(enrolment *data.TOTPEnrolment) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.marshal(data.EnrolTOTPResponse{
+		Success: true,
+		Secret:  enrolment.Secret,
+		URI:     enrolment.URI,
+	})
+}
+
+func (v *MFAView) ConfirmTOTPHandler(recoveryCodes []string) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.marshal(data.ConfirmTOTPResponse{
+		Success:       true,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (v *MFAView) DisableTOTPHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.marshal(data.MFAResponse{Success: true})
+}
+
+func (v *MFAView) marshal(response any) ([]byte, error) {
+	result, err := json.Marshal(response)
+
+	if err != nil {
+		v.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error encoding to JSON - err=%s", err))
+		return nil, err
+	}
+
+	return result, nil
+}
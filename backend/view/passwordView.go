@@ -0,0 +1,61 @@
+package view
+
+import (
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"fmt"
+)
+
+type PasswordView struct {
+	logger logger.ILogger
+}
+
+type IPasswordView interface {
+	ForgotPasswordHandler() ([]byte, error)
+	ResetPasswordHandler() ([]byte, error)
+	ChangePasswordHandler(revoked int64) ([]byte, error)
+}
+
+func NewPasswordView(logger logger.ILogger) *PasswordView {
+	return &PasswordView{
+		logger: logger,
+	}
+}
+
+func (v *PasswordView) ForgotPasswordHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.success()
+}
+
+func (v *PasswordView) ResetPasswordHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.success()
+}
+
+func (v *PasswordView) ChangePasswordHandler(revoked int64) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	result, err := json.Marshal(data.ChangePasswordResponse{Success: true, Revoked: revoked})
+
+	if err != nil {
+		v.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error encoding to JSON - err=%s", err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (v *PasswordView) success() ([]byte, error) {
+	result, err := json.Marshal(data.PasswordResponse{Success: true})
+
+	if err != nil {
+		v.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error encoding to JSON - err=%s", err))
+		return nil, err
+	}
+
+	return result, nil
+}
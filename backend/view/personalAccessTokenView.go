@@ -0,0 +1,79 @@
+package view
+
+import (
+	"dohabits/data"
+	"dohabits/helper"
+	"dohabits/logger"
+	"encoding/json"
+	"fmt"
+)
+
+type PersonalAccessTokenView struct {
+	logger logger.ILogger
+}
+
+type IPersonalAccessTokenView interface {
+	CreatePersonalAccessTokenHandler(token string, personalAccessToken *data.PersonalAccessToken) ([]byte, error)
+	PersonalAccessTokensHandler(personalAccessTokens []data.PersonalAccessToken) ([]byte, error)
+	DeletePersonalAccessTokenHandler() ([]byte, error)
+}
+
+func NewPersonalAccessTokenView(logger logger.ILogger) *PersonalAccessTokenView {
+	return &PersonalAccessTokenView{
+		logger: logger,
+	}
+}
+
+func (v *PersonalAccessTokenView) CreatePersonalAccessTokenHandler(token string, personalAccessToken *data.PersonalAccessToken) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.marshal(data.CreatePersonalAccessTokenResponse{
+		Success:             true,
+		Token:               token,
+		PersonalAccessToken: personalAccessTokenResponse(*personalAccessToken),
+	})
+}
+
+func (v *PersonalAccessTokenView) PersonalAccessTokensHandler(personalAccessTokens []data.PersonalAccessToken) ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	response := data.PersonalAccessTokensResponse{
+		Success:              true,
+		PersonalAccessTokens: make([]data.PersonalAccessTokenResponse, 0, len(personalAccessTokens)),
+	}
+
+	for _, personalAccessToken := range personalAccessTokens {
+		response.PersonalAccessTokens = append(response.PersonalAccessTokens, personalAccessTokenResponse(personalAccessToken))
+	}
+
+	return v.marshal(response)
+}
+
+func (v *PersonalAccessTokenView) DeletePersonalAccessTokenHandler() ([]byte, error) {
+	v.logger.InfoLog(helper.GetFunctionName(), "")
+
+	return v.marshal(data.DeletePersonalAccessTokenResponse{Success: true})
+}
+
+func (v *PersonalAccessTokenView) marshal(response any) ([]byte, error) {
+	result, err := json.Marshal(response)
+
+	if err != nil {
+		v.logger.ErrorLog(helper.GetFunctionName(), fmt.Sprintf("Error encoding to JSON - err=%s", err))
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// personalAccessTokenResponse leaves out the user and the token's hash
+func personalAccessTokenResponse(personalAccessToken data.PersonalAccessToken) data.PersonalAccessTokenResponse {
+	return data.PersonalAccessTokenResponse{
+		TokenID:    personalAccessToken.TokenID,
+		Name:       personalAccessToken.Name,
+		Scopes:     personalAccessToken.Scopes,
+		CreatedAt:  personalAccessToken.CreatedAt,
+		ExpiresAt:  personalAccessToken.ExpiresAt,
+		LastUsedAt: personalAccessToken.LastUsedAt,
+	}
+}
@@ -1,6 +1,5 @@
 import { NextRouter } from "next/router";
 import { mockLoggedInUser, mockRegisteredUser } from "../data/mock_users";
-import { AuthModel } from "../model/authModel";
 import IHabit from "../shared/interfaces/IHabit";
 import ILoggedInUser from "../shared/interfaces/ILoggedInUser";
 import ILoginUser from "../shared/interfaces/ILoginUser";
@@ -76,6 +75,7 @@ export class AuthService {
 
 			const response = await fetch(`/api/${process.env.API_URL}/logout`, {
 				method: "POST",
+				credentials: "same-origin",
 				headers: {
 					"Content-Type": "application/json",
 					Authorization: shortlivedJWTAccessToken || "",
@@ -96,23 +96,10 @@ export class AuthService {
 
 	public static async refresh(callback: Function, router: NextRouter, callbackBody?: string | IHabit | IHabit[]) {
 		try {
-			const shortlivedJWTAccessToken = sessionStorage.getItem("access-token");
-			const userAccessToken = shortlivedJWTAccessToken ? AuthModel.parseJWT(shortlivedJWTAccessToken) : null;
-			const userData = !userAccessToken ? sessionStorage.getItem("user-data") : null;
-
-			if (!userAccessToken) {
-				console.log("User Doesn't Have An Access Token. Attempt to get Email Address from 'user-data' Session Storage");
-				if (!userData) {
-					router.push("/login");
-					console.log("User Doesn't Have An Email Address. Redirecting to Login Page");
-					return;
-				}
-				return;
-			}
-
+			// The refresh token is sent in its HttpOnly cookie, which scripts can't read
 			const response = await fetch(`/api/${process.env.API_URL}/refresh`, {
 				method: "POST",
-				body: JSON.stringify({ EmailAddress: userAccessToken.username }),
+				credentials: "same-origin",
 			});
 
 			if (!response.ok) {
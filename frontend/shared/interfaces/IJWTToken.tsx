@@ -1,4 +1,5 @@
 export default interface IJWTToken {
     exp: number;
     username: string;
+    sid: string;
 }
\ No newline at end of file
@@ -1,3 +0,0 @@
-export default interface UserRefreshRequest {
-	EmailAddress: string; /*`json:"EmailAddress"`*/
-};
\ No newline at end of file